@@ -0,0 +1,244 @@
+// Package api embute a especificação OpenAPI da API pública (openapi.yaml) e a
+// página do Swagger UI, e valida requisições contra os schemas da especificação.
+// O mesmo arquivo é servido pelo gateway e comparado com as rotas registradas
+// nos testes do User Service.
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+//go:embed swagger.html
+var swaggerHTML []byte
+
+// swaggerUI guarda os arquivos do swagger-ui-dist copiados por
+// scripts/vendor-swagger-ui.sh, na versão de swagger-ui/VERSION
+//
+//go:embed swagger-ui
+var swaggerUI embed.FS
+
+// swaggerAssets são os arquivos do Swagger UI servidos pelo gateway, com o
+// Content-Type de cada um
+var swaggerAssets = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+}
+
+// methods são as chaves de um Path Item que descrevem operações
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// Spec é a especificação carregada, com as operações indexadas por rota do gin
+type Spec struct {
+	json       []byte
+	doc        document
+	operations map[string]*Operation
+}
+
+// Route identifica uma operação pelo método e pelo caminho no formato do gin
+// ("/api/v1/contacts/:id")
+type Route struct {
+	Method string
+	Path   string
+}
+
+func (r Route) String() string {
+	return r.Method + " " + r.Path
+}
+
+// Operation é uma operação da especificação com parâmetros e corpo já resolvidos
+type Operation struct {
+	ID         string
+	Method     string
+	Path       string
+	Parameters []*Parameter
+	// Body é nil quando a operação não recebe corpo ou quando ele não é JSON
+	// (arquivos como text/csv), caso em que a validação fica com o serviço
+	Body         *Schema
+	BodyRequired bool
+
+	spec *Spec
+}
+
+// Parameter é um parâmetro de path, query ou header
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type document struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas    map[string]*Schema    `json:"schemas"`
+		Parameters map[string]*Parameter `json:"parameters"`
+	} `json:"components"`
+}
+
+type operationDoc struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []*Parameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+// Load interpreta a especificação embutida
+func Load() (*Spec, error) {
+	return Parse(specYAML)
+}
+
+// Parse interpreta uma especificação OpenAPI em YAML ou JSON
+func Parse(content []byte) (*Spec, error) {
+	var raw any
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("erro ao interpretar especificação OpenAPI: %w", err)
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao converter especificação OpenAPI: %w", err)
+	}
+
+	s := &Spec{json: data, operations: map[string]*Operation{}}
+	if err := json.Unmarshal(data, &s.doc); err != nil {
+		return nil, fmt.Errorf("especificação OpenAPI inválida: %w", err)
+	}
+
+	for path, item := range s.doc.Paths {
+		for _, method := range methods {
+			rawOp, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op operationDoc
+			if err := json.Unmarshal(rawOp, &op); err != nil {
+				return nil, fmt.Errorf("operação %s %s inválida: %w", strings.ToUpper(method), path, err)
+			}
+			operation, err := s.resolve(strings.ToUpper(method), path, op)
+			if err != nil {
+				return nil, err
+			}
+			s.operations[Route{operation.Method, operation.Path}.String()] = operation
+		}
+	}
+	return s, nil
+}
+
+func (s *Spec) resolve(method, path string, op operationDoc) (*Operation, error) {
+	operation := &Operation{
+		ID:     op.OperationID,
+		Method: method,
+		Path:   ginPath(path),
+		spec:   s,
+	}
+
+	for _, param := range op.Parameters {
+		if param.Ref != "" {
+			name := strings.TrimPrefix(param.Ref, "#/components/parameters/")
+			resolved, ok := s.doc.Components.Parameters[name]
+			if !ok {
+				return nil, fmt.Errorf("%s %s: parâmetro %q não encontrado", method, path, param.Ref)
+			}
+			param = resolved
+		}
+		operation.Parameters = append(operation.Parameters, param)
+	}
+
+	if op.RequestBody != nil {
+		if len(op.RequestBody.Content) == 0 {
+			return nil, fmt.Errorf("%s %s: corpo sem content", method, path)
+		}
+		if media, ok := op.RequestBody.Content["application/json"]; ok {
+			operation.Body = media.Schema
+			operation.BodyRequired = op.RequestBody.Required
+		}
+	}
+
+	// Referências quebradas são erro de carga, não de validação
+	for _, schema := range operation.schemas() {
+		if err := s.checkRefs(schema, map[*Schema]bool{}); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", method, path, err)
+		}
+	}
+	return operation, nil
+}
+
+func (o *Operation) schemas() []*Schema {
+	var schemas []*Schema
+	if o.Body != nil {
+		schemas = append(schemas, o.Body)
+	}
+	for _, param := range o.Parameters {
+		if param.Schema != nil {
+			schemas = append(schemas, param.Schema)
+		}
+	}
+	return schemas
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// ginPath converte "/contacts/{id}" em "/contacts/:id"
+func ginPath(path string) string {
+	return pathParam.ReplaceAllString(path, ":$1")
+}
+
+// JSON retorna a especificação serializada em JSON, como servida em /openapi.json
+func (s *Spec) JSON() []byte {
+	return s.json
+}
+
+// Routes lista as operações da especificação, ordenadas por caminho e método
+func (s *Spec) Routes() []Route {
+	routes := make([]Route, 0, len(s.operations))
+	for _, op := range s.operations {
+		routes = append(routes, Route{op.Method, op.Path})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Operation busca a operação pelo método e pelo caminho no formato do gin
+// (c.FullPath()); retorna nil se a rota não estiver na especificação
+func (s *Spec) Operation(method, path string) *Operation {
+	return s.operations[Route{method, path}.String()]
+}
+
+// SwaggerUI retorna a página HTML do Swagger UI, que carrega /openapi.json
+func SwaggerUI() []byte {
+	return swaggerHTML
+}
+
+// SwaggerAsset retorna um dos arquivos embutidos usados pela página do Swagger
+// UI (swagger-ui.css ou swagger-ui-bundle.js) e o Content-Type. ok é false para
+// qualquer outro nome.
+func SwaggerAsset(name string) (data []byte, contentType string, ok bool) {
+	contentType, ok = swaggerAssets[name]
+	if !ok {
+		return nil, "", false
+	}
+	data, err := swaggerUI.ReadFile("swagger-ui/" + name)
+	if err != nil {
+		return nil, "", false
+	}
+	return data, contentType, true
+}
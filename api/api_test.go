@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadSpec(t *testing.T) *Spec {
+	t.Helper()
+	spec, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestLoad(t *testing.T) {
+	spec := loadSpec(t)
+
+	var doc map[string]any
+	if err := json.Unmarshal(spec.JSON(), &doc); err != nil {
+		t.Fatalf("JSON inválido: %v", err)
+	}
+	if doc["openapi"] != "3.1.0" {
+		t.Errorf("openapi = %v", doc["openapi"])
+	}
+
+	op := spec.Operation("PUT", "/api/v1/contacts/:id")
+	if op == nil || op.ID != "updateContact" {
+		t.Fatalf("operação não encontrada pelo caminho do gin: %+v", op)
+	}
+	if op.Body == nil {
+		t.Error("corpo de updateContact não resolvido")
+	}
+}
+
+func TestLoadNonJSONBody(t *testing.T) {
+	op := loadSpec(t).Operation("POST", "/api/v1/contacts/import")
+	if op == nil {
+		t.Fatal("importContacts não encontrada")
+	}
+	if op.Body != nil {
+		t.Error("corpo text/vcard ou text/csv não deveria ter schema para validação")
+	}
+	if violations, err := op.ValidateBody([]byte("BEGIN:VCARD")); err != nil || len(violations) > 0 {
+		t.Errorf("ValidateBody = %v, %v", violations, err)
+	}
+}
+
+func TestParseRejectsBrokenRefs(t *testing.T) {
+	_, err := Parse([]byte(`
+openapi: 3.1.0
+paths:
+  /x:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/Inexistente"}
+`))
+	if err == nil {
+		t.Fatal("esperado erro para referência quebrada")
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	register := loadSpec(t).Operation("POST", "/api/v1/auth/register")
+
+	tests := []struct {
+		name string
+		body string
+		want []Violation
+	}{
+		{
+			name: "válido",
+			body: `{"username":"maria","email":"maria@meuapoio.com","password":"senha123","consents":[{"type":"terms_of_use","version":"1.0"}]}`,
+		},
+		{
+			name: "campos obrigatórios",
+			body: `{"username":"maria"}`,
+			want: []Violation{
+				{Field: "email", Rule: "required"},
+				{Field: "password", Rule: "required"},
+				{Field: "consents", Rule: "required"},
+			},
+		},
+		{
+			name: "regras dos campos",
+			body: `{"username":"ma","email":"nao-e-email","password":123,"consents":[{"type":"outro","version":"1.0"}]}`,
+			want: []Violation{
+				{Field: "consents[0].type", Rule: "oneof", Param: "terms_of_use privacy_policy sensitive_health_data contact"},
+				{Field: "email", Rule: "email"},
+				{Field: "password", Rule: "type", Param: "string"},
+				{Field: "username", Rule: "min", Param: "3"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := register.ValidateBody([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violações = %+v, esperado %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := register.ValidateBody(nil); err != ErrEmptyBody {
+		t.Errorf("corpo vazio: erro = %v", err)
+	}
+	if _, err := register.ValidateBody([]byte(`{"username":`)); err == nil {
+		t.Error("esperado erro para JSON inválido")
+	}
+}
+
+func TestValidateNullableFields(t *testing.T) {
+	update := loadSpec(t).Operation("PUT", "/api/v1/users/profile")
+
+	got, err := update.ValidateBody([]byte(`{"full_name":null,"birth_date":"1990-05-01T00:00:00Z","preferred_language":null}`))
+	if err != nil || len(got) != 0 {
+		t.Fatalf("violações = %+v, erro = %v", got, err)
+	}
+
+	got, _ = update.ValidateBody([]byte(`{"birth_date":"01/05/1990","preferred_language":"fr"}`))
+	want := []Violation{
+		{Field: "birth_date", Rule: "datetime", Param: "2006-01-02T15:04:05Z07:00"},
+		{Field: "preferred_language", Rule: "oneof", Param: "pt-BR en es"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("violações = %+v, esperado %+v", got, want)
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	activity := loadSpec(t).Operation("GET", "/api/v1/users/security-activity")
+
+	if got := activity.ValidateQuery(url.Values{"limit": {"50"}}); len(got) != 0 {
+		t.Errorf("violações = %+v", got)
+	}
+
+	want := []Violation{{Field: "limit", Rule: "lte", Param: "200"}}
+	if got := activity.ValidateQuery(url.Values{"limit": {"500"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("violações = %+v, esperado %+v", got, want)
+	}
+
+	want = []Violation{{Field: "limit", Rule: "type", Param: "integer"}}
+	if got := activity.ValidateQuery(url.Values{"limit": {"muitos"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("violações = %+v, esperado %+v", got, want)
+	}
+}
+
+func TestValidateContactRelationship(t *testing.T) {
+	create := loadSpec(t).Operation("POST", "/api/v1/contacts")
+
+	for _, body := range []string{
+		`{"name":"Ana","phone":"11988887777","relationship":"sibling"}`,
+		`{"name":"Ana","phone":"11988887777","relationship":null}`,
+	} {
+		if got, err := create.ValidateBody([]byte(body)); err != nil || len(got) != 0 {
+			t.Errorf("%s: violações = %+v, erro = %v", body, got, err)
+		}
+	}
+
+	got, _ := create.ValidateBody([]byte(`{"name":"Ana","phone":"11988887777","relationship":"irmã"}`))
+	if len(got) != 1 || got[0].Field != "relationship" {
+		t.Errorf("parentesco fora da lista deveria ser recusado: %+v", got)
+	}
+}
+
+// A página do Swagger UI só carrega arquivos servidos pelo próprio gateway
+func TestSwaggerUIIsSelfContained(t *testing.T) {
+	page := string(SwaggerUI())
+	if strings.Contains(page, "://") {
+		t.Error("swagger.html referencia outra origem")
+	}
+
+	for name := range swaggerAssets {
+		if !strings.Contains(page, `"/docs/`+name+`"`) {
+			t.Errorf("swagger.html não carrega /docs/%s", name)
+		}
+	}
+	if _, _, ok := SwaggerAsset("VERSION"); ok {
+		t.Error("só os arquivos da página devem ser servidos")
+	}
+
+	for name := range swaggerAssets {
+		if _, err := swaggerUI.ReadFile("swagger-ui/" + name); err != nil {
+			t.Skipf("swagger-ui-dist não copiado para api/swagger-ui; rode scripts/vendor-swagger-ui.sh: %v", err)
+		}
+	}
+	for name := range swaggerAssets {
+		if data, contentType, ok := SwaggerAsset(name); !ok || len(data) == 0 || contentType == "" {
+			t.Errorf("SwaggerAsset(%q) = %d bytes, %q, %v", name, len(data), contentType, ok)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema é o subconjunto de JSON Schema usado na especificação: referências a
+// components/schemas, tipos (inclusive "null" em lista), objetos, arrays, enum,
+// limites de tamanho e valor, oneOf e os formatos email e date-time
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 schemaType         `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	OneOf                []*Schema          `json:"oneOf"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+}
+
+// schemaType aceita "type" como string ou lista de strings
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaType{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// Violation é uma regra da especificação que a requisição não cumpre. Rule
+// segue os nomes das tags do validator usado pelos serviços (required, min e
+// max para tamanho, gte e lte para valor, oneof, email) mais "type", "datetime"
+// e "unknown".
+type Violation struct {
+	Field string
+	Rule  string
+	Param string
+}
+
+// ErrEmptyBody indica uma operação com corpo obrigatório chamada sem corpo
+var ErrEmptyBody = errors.New("corpo da requisição vazio")
+
+const refPrefix = "#/components/schemas/"
+
+func (s *Spec) lookup(ref string) (*Schema, bool) {
+	schema, ok := s.doc.Components.Schemas[strings.TrimPrefix(ref, refPrefix)]
+	return schema, ok && strings.HasPrefix(ref, refPrefix)
+}
+
+// checkRefs garante que todas as referências alcançáveis a partir de schema existem
+func (s *Spec) checkRefs(schema *Schema, seen map[*Schema]bool) error {
+	if schema == nil || seen[schema] {
+		return nil
+	}
+	seen[schema] = true
+
+	if schema.Ref != "" {
+		target, ok := s.lookup(schema.Ref)
+		if !ok {
+			return fmt.Errorf("schema %q não encontrado", schema.Ref)
+		}
+		return s.checkRefs(target, seen)
+	}
+
+	children := append([]*Schema{schema.Items}, schema.OneOf...)
+	for _, property := range schema.Properties {
+		children = append(children, property)
+	}
+	if additional := schema.additional(); additional != nil {
+		children = append(children, additional)
+	}
+	for _, child := range children {
+		if err := s.checkRefs(child, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// additional retorna o schema de additionalProperties quando for um objeto
+func (schema *Schema) additional() *Schema {
+	if len(schema.AdditionalProperties) == 0 || schema.AdditionalProperties[0] != '{' {
+		return nil
+	}
+	var additional Schema
+	if json.Unmarshal(schema.AdditionalProperties, &additional) != nil {
+		return nil
+	}
+	return &additional
+}
+
+// ValidateBody confere o corpo JSON da requisição. O erro indica corpo ausente
+// ou que não é JSON; as violações dos schemas vêm na lista.
+func (o *Operation) ValidateBody(body []byte) ([]Violation, error) {
+	if o.Body == nil {
+		return nil, nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if o.BodyRequired {
+			return nil, ErrEmptyBody
+		}
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("corpo não é um JSON válido: %w", err)
+	}
+
+	var violations []Violation
+	o.spec.validate(o.Body, "", value, &violations)
+	return violations, nil
+}
+
+// ValidateQuery confere os parâmetros de query declarados na operação. Valores
+// de query chegam como texto e são convertidos conforme o tipo do schema.
+func (o *Operation) ValidateQuery(query url.Values) []Violation {
+	var violations []Violation
+	for _, param := range o.Parameters {
+		if param.In != "query" {
+			continue
+		}
+		raw, present := query[param.Name]
+		if !present || len(raw) == 0 {
+			if param.Required {
+				violations = append(violations, Violation{Field: param.Name, Rule: "required"})
+			}
+			continue
+		}
+		if param.Schema == nil {
+			continue
+		}
+
+		var value any = raw[0]
+		switch {
+		case param.Schema.allows("integer"), param.Schema.allows("number"):
+			if _, err := strconv.ParseFloat(raw[0], 64); err == nil {
+				value = json.Number(raw[0])
+			}
+		case param.Schema.allows("boolean"):
+			if b, err := strconv.ParseBool(raw[0]); err == nil {
+				value = b
+			}
+		}
+		o.spec.validate(param.Schema, param.Name, value, &violations)
+	}
+	return violations
+}
+
+func (schema *Schema) allows(typ string) bool {
+	for _, t := range schema.Type {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Spec) validate(schema *Schema, field string, value any, violations *[]Violation) {
+	if schema.Ref != "" {
+		target, _ := s.lookup(schema.Ref)
+		s.validate(target, field, value, violations)
+		return
+	}
+
+	if len(schema.OneOf) > 0 {
+		matches := 0
+		for _, option := range schema.OneOf {
+			var optionViolations []Violation
+			s.validate(option, field, value, &optionViolations)
+			if len(optionViolations) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			*violations = append(*violations, s.oneOfViolation(schema, field))
+		}
+		return
+	}
+
+	if len(schema.Type) > 0 && !schema.matchesType(value) {
+		*violations = append(*violations, Violation{Field: field, Rule: "type", Param: strings.Join(schema.Type, "|")})
+		return
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		*violations = append(*violations, Violation{Field: field, Rule: "oneof", Param: enumParam(schema.Enum)})
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		schema.validateString(field, v, violations)
+	case json.Number:
+		schema.validateNumber(field, v, violations)
+	case []any:
+		if schema.Items != nil {
+			for i, item := range v {
+				s.validate(schema.Items, fmt.Sprintf("%s[%d]", field, i), item, violations)
+			}
+		}
+	case map[string]any:
+		s.validateObject(schema, field, v, violations)
+	}
+}
+
+// oneOfViolation reporta o oneOf como um todo; o caso comum é "enum ou null",
+// em que a lista de valores aceitos é a mensagem mais útil
+func (s *Spec) oneOfViolation(schema *Schema, field string) Violation {
+	for _, option := range schema.OneOf {
+		if option.Ref != "" {
+			option, _ = s.lookup(option.Ref)
+		}
+		if len(option.Enum) > 0 {
+			return Violation{Field: field, Rule: "oneof", Param: enumParam(option.Enum)}
+		}
+	}
+	return Violation{Field: field, Rule: "type"}
+}
+
+func (schema *Schema) matchesType(value any) bool {
+	for _, typ := range schema.Type {
+		switch v := value.(type) {
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		case bool:
+			if typ == "boolean" {
+				return true
+			}
+		case string:
+			if typ == "string" {
+				return true
+			}
+		case json.Number:
+			if typ == "number" {
+				return true
+			}
+			if _, err := v.Int64(); err == nil && typ == "integer" {
+				return true
+			}
+		case []any:
+			if typ == "array" {
+				return true
+			}
+		case map[string]any:
+			if typ == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (schema *Schema) validateString(field, value string, violations *[]Violation) {
+	length := utf8.RuneCountInString(value)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		*violations = append(*violations, Violation{Field: field, Rule: "min", Param: strconv.Itoa(*schema.MinLength)})
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		*violations = append(*violations, Violation{Field: field, Rule: "max", Param: strconv.Itoa(*schema.MaxLength)})
+	}
+
+	switch schema.Format {
+	case "email":
+		if _, err := mail.ParseAddress(value); err != nil || strings.ContainsAny(value, "<> ") {
+			*violations = append(*violations, Violation{Field: field, Rule: "email"})
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			*violations = append(*violations, Violation{Field: field, Rule: "datetime", Param: time.RFC3339})
+		}
+	}
+}
+
+func (schema *Schema) validateNumber(field string, value json.Number, violations *[]Violation) {
+	n, err := value.Float64()
+	if err != nil {
+		return
+	}
+	if schema.Minimum != nil && n < *schema.Minimum {
+		*violations = append(*violations, Violation{Field: field, Rule: "gte", Param: formatNumber(*schema.Minimum)})
+	}
+	if schema.Maximum != nil && n > *schema.Maximum {
+		*violations = append(*violations, Violation{Field: field, Rule: "lte", Param: formatNumber(*schema.Maximum)})
+	}
+}
+
+func (s *Spec) validateObject(schema *Schema, field string, value map[string]any, violations *[]Violation) {
+	for _, name := range schema.Required {
+		if _, ok := value[name]; !ok {
+			*violations = append(*violations, Violation{Field: join(field, name), Rule: "required"})
+		}
+	}
+
+	// Ordem estável para que a resposta não varie entre requisições iguais
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	forbidden := string(schema.AdditionalProperties) == "false"
+	additional := schema.additional()
+	for _, name := range names {
+		property, ok := schema.Properties[name]
+		switch {
+		case ok:
+			s.validate(property, join(field, name), value[name], violations)
+		case forbidden:
+			*violations = append(*violations, Violation{Field: join(field, name), Rule: "unknown"})
+		case additional != nil:
+			s.validate(additional, join(field, name), value[name], violations)
+		}
+	}
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumParam(enum []any) string {
+	values := make([]string, len(enum))
+	for i, v := range enum {
+		values[i] = fmt.Sprint(v)
+	}
+	return strings.Join(values, " ")
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
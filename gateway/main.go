@@ -8,9 +8,9 @@ import (
 	"net/url"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/meuapoio/gateway/middleware"
+	"github.com/meuapoio/api"
+	"github.com/meuapoio/shared/apierror"
 	"github.com/meuapoio/shared/config"
 )
 
@@ -20,7 +20,18 @@ type ServiceRegistry struct {
 }
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Falha ao carregar configuração: ", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Configuração inválida: ", err)
+	}
+
+	spec, err := api.Load()
+	if err != nil {
+		log.Fatal("Falha ao carregar especificação OpenAPI: ", err)
+	}
 
 	// Registry de serviços
 	services := &ServiceRegistry{
@@ -28,63 +39,10 @@ func main() {
 	}
 
 	// Configurar Gin
-	if cfg.Environment == "production" {
+	if cfg.IsProduction() {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	r := gin.Default()
-
-	// Middleware global
-	r.Use(gin.Logger())
-	r.Use(gin.Recovery())
-	// Não confiar em proxies intermediários — evita spoofing de IP via X-Forwarded-For
-	r.SetTrustedProxies(nil)
-
-	// CORS
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	// Rate limiting
-	rateLimiter := middleware.NewRateLimiter(100, time.Minute) // 100 requests por minuto
-	r.Use(rateLimiter.Limit())
-
-	// Health check do Gateway
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":    "ok",
-			"service":   "api-gateway",
-			"timestamp": time.Now().Unix(),
-		})
-	})
-
-	// Rotas do User Service
-	userGroup := r.Group("/api/v1")
-	{
-		// Rotas públicas (sem autenticação)
-		userGroup.POST("/auth/register", proxyToService(services.UserService))
-		userGroup.POST("/auth/login", proxyToService(services.UserService))
-
-		// Rotas protegidas (com autenticação)
-		protected := userGroup.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
-		{
-			// Usuários
-			protected.GET("/users/profile", proxyToService(services.UserService))
-			protected.PUT("/users/profile", proxyToService(services.UserService))
-			protected.DELETE("/users/profile", proxyToService(services.UserService))
-
-			// Contatos
-			protected.GET("/contacts", proxyToService(services.UserService))
-			protected.POST("/contacts", proxyToService(services.UserService))
-			protected.PUT("/contacts/:id", proxyToService(services.UserService))
-			protected.DELETE("/contacts/:id", proxyToService(services.UserService))
-		}
-	}
+	r := newRouter(cfg, spec, services)
 
 	// Iniciar servidor
 	port := cfg.Port
@@ -119,12 +77,9 @@ func createReverseProxy(target string) http.Handler {
 	// Customizar o director para preservar headers
 	originalDirector := proxy.Director
 	proxy.Director = func(req *http.Request) {
+		// O director padrão já preserva o path e a query string originais
 		originalDirector(req)
 
-		// Preservar o path original
-		req.URL.Path = req.URL.Path
-		req.URL.RawQuery = req.URL.RawQuery
-
 		// Headers importantes
 		req.Header.Set("X-Forwarded-Host", req.Header.Get("Host"))
 		req.Header.Set("X-Origin-Service", "api-gateway")
@@ -133,9 +88,7 @@ func createReverseProxy(target string) http.Handler {
 	// Error handling
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 		log.Printf("Erro no proxy para %s: %v", target, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadGateway)
-		w.Write([]byte(`{"error": "Serviço temporariamente indisponível"}`))
+		apierror.New(apierror.CodeServiceUnavailable).Write(w, r)
 	}
 
 	return proxy
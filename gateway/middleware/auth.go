@@ -1,10 +1,11 @@
 package middleware
 
 import (
-	"net/http"
+	"errors"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/shared/apierror"
 	"github.com/meuapoio/shared/utils"
 )
 
@@ -12,24 +13,25 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token de autorização necessário"})
-			c.Abort()
+			apierror.Abort(c, apierror.CodeAuthTokenMissing)
 			return
 		}
 
 		// Verificar formato: "Bearer <token>"
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Formato de token inválido"})
-			c.Abort()
+			apierror.Abort(c, apierror.CodeAuthTokenMalformed)
 			return
 		}
 
 		// Validar token
 		claims, err := utils.ValidateJWT(parts[1], jwtSecret)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
-			c.Abort()
+			if errors.Is(err, utils.ErrTokenExpired) {
+				apierror.Abort(c, apierror.CodeAuthTokenExpired)
+				return
+			}
+			apierror.Abort(c, apierror.CodeAuthTokenInvalid)
 			return
 		}
 
@@ -1,11 +1,11 @@
 package middleware
 
 import (
-	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/shared/apierror"
 )
 
 type RateLimiter struct {
@@ -130,11 +130,8 @@ func (rl *RateLimiter) Limit() gin.HandlerFunc {
 		visitor := rl.getVisitor(ip)
 
 		if !visitor.limiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":       "Rate limit exceeded",
-				"retry_after": "60s",
-			})
-			c.Abort()
+			c.Header("Retry-After", "60")
+			apierror.AbortWith(c, apierror.New(apierror.CodeRateLimited).With("retry_after", "60s"))
 			return
 		}
 
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/api"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/i18n"
+)
+
+// maxValidatedBody limita o corpo lido para validação; corpos maiores seguem
+// sem validação para o serviço, que aplica os próprios limites
+const maxValidatedBody = 1 << 20
+
+// ValidateRequests rejeita, antes do proxy, requisições cujo corpo ou query não
+// seguem a especificação OpenAPI. Rotas fora da especificação passam direto.
+func ValidateRequests(spec *api.Spec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op := spec.Operation(c.Request.Method, c.FullPath())
+		if op == nil {
+			c.Next()
+			return
+		}
+
+		p := apierror.New(apierror.CodeValidationFailed)
+		for _, v := range op.ValidateQuery(c.Request.URL.Query()) {
+			p.WithField(v.Field, v.Rule, violationMessage(v))
+		}
+
+		if op.Body != nil && c.Request.Body != nil {
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxValidatedBody+1))
+			if err != nil {
+				apierror.Abort(c, apierror.CodeMalformedRequest)
+				return
+			}
+			// O corpo volta a ser lido pelo proxy reverso
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+
+			if len(body) <= maxValidatedBody {
+				violations, err := op.ValidateBody(body)
+				switch {
+				case errors.Is(err, api.ErrEmptyBody):
+					apierror.AbortWith(c, apierror.New(apierror.CodeMalformedRequest).WithDetail(emptyBody))
+					return
+				case err != nil:
+					apierror.Abort(c, apierror.CodeMalformedRequest)
+					return
+				}
+				for _, v := range violations {
+					// Corpo que não é um objeto, como o serviço responderia
+					if v.Field == "" {
+						apierror.Abort(c, apierror.CodeMalformedRequest)
+						return
+					}
+					p.WithField(v.Field, v.Rule, violationMessage(v))
+				}
+			}
+		}
+
+		if len(p.Errors) > 0 {
+			apierror.AbortWith(c, p)
+			return
+		}
+		c.Next()
+	}
+}
+
+var emptyBody = i18n.Text{
+	i18n.PortugueseBR: "Corpo da requisição vazio",
+	i18n.English:      "Empty request body",
+	i18n.Spanish:      "Cuerpo de la solicitud vacío",
+}
+
+// violationMessages usa {field} e {param} como marcadores
+var violationMessages = map[string]i18n.Text{
+	"required": {
+		i18n.PortugueseBR: "{field} é um campo obrigatório",
+		i18n.English:      "{field} is a required field",
+		i18n.Spanish:      "{field} es un campo requerido",
+	},
+	"type": {
+		i18n.PortugueseBR: "{field} deve ser do tipo {param}",
+		i18n.English:      "{field} must be of type {param}",
+		i18n.Spanish:      "{field} debe ser del tipo {param}",
+	},
+	"min": {
+		i18n.PortugueseBR: "{field} deve ter pelo menos {param} caracteres",
+		i18n.English:      "{field} must be at least {param} characters in length",
+		i18n.Spanish:      "{field} debe tener al menos {param} caracteres",
+	},
+	"max": {
+		i18n.PortugueseBR: "{field} deve ter no máximo {param} caracteres",
+		i18n.English:      "{field} must be a maximum of {param} characters in length",
+		i18n.Spanish:      "{field} debe tener como máximo {param} caracteres",
+	},
+	"gte": {
+		i18n.PortugueseBR: "{field} deve ser maior ou igual a {param}",
+		i18n.English:      "{field} must be {param} or greater",
+		i18n.Spanish:      "{field} debe ser {param} o mayor",
+	},
+	"lte": {
+		i18n.PortugueseBR: "{field} deve ser menor ou igual a {param}",
+		i18n.English:      "{field} must be {param} or less",
+		i18n.Spanish:      "{field} debe ser {param} o menor",
+	},
+	"oneof": {
+		i18n.PortugueseBR: "{field} deve ser um de [{param}]",
+		i18n.English:      "{field} must be one of [{param}]",
+		i18n.Spanish:      "{field} debe ser uno de [{param}]",
+	},
+	"email": {
+		i18n.PortugueseBR: "{field} deve ser um endereço de e-mail válido",
+		i18n.English:      "{field} must be a valid email address",
+		i18n.Spanish:      "{field} debe ser una dirección de correo electrónico válida",
+	},
+	"datetime": {
+		i18n.PortugueseBR: "{field} deve ser uma data e hora no formato RFC 3339",
+		i18n.English:      "{field} must be an RFC 3339 date-time",
+		i18n.Spanish:      "{field} debe ser una fecha y hora en formato RFC 3339",
+	},
+	"unknown": {
+		i18n.PortugueseBR: "{field} não é um campo aceito",
+		i18n.English:      "{field} is not an accepted field",
+		i18n.Spanish:      "{field} no es un campo aceptado",
+	},
+}
+
+func violationMessage(v api.Violation) i18n.Text {
+	template, ok := violationMessages[v.Rule]
+	if !ok {
+		template = violationMessages["type"]
+	}
+	message := i18n.Text{}
+	for lang, text := range template {
+		// Tipos alternativos ("string|null") viram "string ou null"
+		param := strings.ReplaceAll(v.Param, "|", typeSeparator[lang])
+		message[lang] = strings.NewReplacer("{field}", v.Field, "{param}", param).Replace(text)
+	}
+	return message
+}
+
+var typeSeparator = map[string]string{
+	i18n.PortugueseBR: " ou ",
+	i18n.English:      " or ",
+	i18n.Spanish:      " o ",
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/api"
+	"github.com/meuapoio/gateway/middleware"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/config"
+	"github.com/meuapoio/shared/i18n"
+	sharedmw "github.com/meuapoio/shared/middleware"
+)
+
+// newRouter monta o engine do gateway com as rotas repassadas aos serviços.
+// Separado do main para que os testes confiram a tabela de rotas com a
+// especificação OpenAPI.
+func newRouter(cfg *config.Config, spec *api.Spec, services *ServiceRegistry) *gin.Engine {
+	r := gin.Default()
+	r.NoRoute(apierror.NoRoute)
+
+	// Middleware global
+	r.Use(gin.Logger())
+	r.Use(gin.Recovery())
+	r.Use(sharedmw.RequestID())
+	r.Use(i18n.Middleware())
+	// Não confiar em proxies intermediários — evita spoofing de IP via X-Forwarded-For
+	r.SetTrustedProxies(nil)
+
+	// CORS
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"http://localhost:3000"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Request-ID", "Idempotency-Key", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID", "Idempotent-Replayed", "Location", "ETag", "Last-Modified"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	// Rate limiting
+	rateLimiter := middleware.NewRateLimiter(100, time.Minute) // 100 requests por minuto
+	r.Use(rateLimiter.Limit())
+
+	// Health check do Gateway
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":    "ok",
+			"service":   "api-gateway",
+			"timestamp": time.Now().Unix(),
+		})
+	})
+
+	// Documentação da API
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", spec.JSON())
+	})
+	r.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", api.SwaggerUI())
+	})
+	// CSS e JS do Swagger UI, embutidos no binário como a página
+	r.GET("/docs/:file", func(c *gin.Context) {
+		data, contentType, ok := api.SwaggerAsset(c.Param("file"))
+		if !ok {
+			apierror.NoRoute(c)
+			return
+		}
+		c.Data(http.StatusOK, contentType, data)
+	})
+
+	// Validação contra a especificação: nas rotas protegidas, roda depois da
+	// autenticação para não responder detalhes do contrato sem token válido
+	validate := func(c *gin.Context) { c.Next() }
+	if cfg.GatewayValidateRequests {
+		validate = middleware.ValidateRequests(spec)
+	}
+
+	// Rotas do User Service
+	userGroup := r.Group("/api/v1")
+	{
+		// Rotas públicas (sem autenticação)
+		public := userGroup.Group("")
+		public.Use(validate)
+		{
+			public.POST("/auth/register", proxyToService(services.UserService))
+			public.POST("/auth/login", proxyToService(services.UserService))
+			public.GET("/users/export/:id/download", proxyToService(services.UserService))
+			public.GET("/images/:key", proxyToService(services.UserService))
+			public.GET("/consents/documents", proxyToService(services.UserService))
+			public.GET("/invitations/:token", proxyToService(services.UserService))
+			public.POST("/invitations/:token/accept", proxyToService(services.UserService))
+			public.POST("/invitations/:token/decline", proxyToService(services.UserService))
+			public.GET("/alerts/:token", proxyToService(services.UserService))
+			public.POST("/alerts/:token/acknowledge", proxyToService(services.UserService))
+			public.GET("/resources", proxyToService(services.UserService))
+			public.GET("/resources/:id", proxyToService(services.UserService))
+			public.GET("/shared-plans/:token", proxyToService(services.UserService))
+			public.GET("/shared-plans/:token/pdf", proxyToService(services.UserService))
+			public.GET("/health", proxyToService(services.UserService))
+		}
+
+		// Rotas protegidas (com autenticação)
+		protected := userGroup.Group("")
+		protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+		protected.Use(validate)
+		{
+			// Usuários
+			protected.GET("/users/profile", proxyToService(services.UserService))
+			protected.PUT("/users/profile", proxyToService(services.UserService))
+			protected.POST("/users/profile/image", proxyToService(services.UserService))
+			protected.DELETE("/users/profile/image", proxyToService(services.UserService))
+			protected.DELETE("/users/profile", proxyToService(services.UserService))
+			protected.GET("/users/security-activity", proxyToService(services.UserService))
+			protected.POST("/users/export", proxyToService(services.UserService))
+			protected.GET("/users/export/:id", proxyToService(services.UserService))
+
+			// Consentimentos
+			protected.GET("/consents", proxyToService(services.UserService))
+			protected.POST("/consents", proxyToService(services.UserService))
+			protected.DELETE("/consents/:type", proxyToService(services.UserService))
+
+			// Contatos
+			protected.GET("/contacts", proxyToService(services.UserService))
+			protected.POST("/contacts", proxyToService(services.UserService))
+			protected.POST("/contacts/import", proxyToService(services.UserService))
+			protected.GET("/contacts/export", proxyToService(services.UserService))
+			protected.GET("/contacts/relationships", proxyToService(services.UserService))
+			protected.GET("/contacts/:id", proxyToService(services.UserService))
+			protected.PUT("/contacts/:id", proxyToService(services.UserService))
+			protected.DELETE("/contacts/:id", proxyToService(services.UserService))
+			protected.POST("/contacts/:id/primary", proxyToService(services.UserService))
+			protected.POST("/contacts/:id/invitation", proxyToService(services.UserService))
+
+			// Alertas de SOS
+			protected.POST("/sos", proxyToService(services.UserService))
+			protected.GET("/sos", proxyToService(services.UserService))
+			protected.GET("/sos/:id", proxyToService(services.UserService))
+			protected.POST("/sos/:id/cancel", proxyToService(services.UserService))
+
+			// Recursos de crise (o User Service restringe a administradores)
+			protected.POST("/resources", proxyToService(services.UserService))
+			protected.PUT("/resources/:id", proxyToService(services.UserService))
+			protected.DELETE("/resources/:id", proxyToService(services.UserService))
+
+			// Plano de segurança
+			protected.GET("/safety-plan", proxyToService(services.UserService))
+			protected.PUT("/safety-plan", proxyToService(services.UserService))
+			protected.DELETE("/safety-plan", proxyToService(services.UserService))
+			protected.GET("/safety-plan/pdf", proxyToService(services.UserService))
+			protected.GET("/safety-plan/revisions", proxyToService(services.UserService))
+			protected.GET("/safety-plan/revisions/:version", proxyToService(services.UserService))
+			protected.GET("/safety-plan/shares", proxyToService(services.UserService))
+			protected.POST("/safety-plan/shares", proxyToService(services.UserService))
+			protected.DELETE("/safety-plan/shares/:id", proxyToService(services.UserService))
+		}
+	}
+
+	return r
+}
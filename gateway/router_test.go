@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/api"
+	"github.com/meuapoio/shared/config"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, *api.Spec) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	spec, err := api.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{JWTSecret: "segredo-de-teste"}
+	return newRouter(cfg, spec, &ServiceRegistry{UserService: "http://127.0.0.1:0"}), spec
+}
+
+// O gateway só repassa o que a especificação descreve, e repassa tudo o que
+// ela descreve
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+	r, spec := newTestRouter(t)
+
+	documented := map[api.Route]bool{}
+	for _, route := range spec.Routes() {
+		documented[route] = true
+	}
+
+	proxied := map[api.Route]bool{}
+	for _, info := range r.Routes() {
+		// /health, /openapi.json e /docs são respondidas pelo próprio gateway
+		if !strings.HasPrefix(info.Path, "/api/") {
+			continue
+		}
+		route := api.Route{Method: info.Method, Path: info.Path}
+		proxied[route] = true
+		if !documented[route] {
+			t.Errorf("rota %s do gateway não está em api/openapi.yaml", route)
+		}
+	}
+	for route := range documented {
+		if !proxied[route] {
+			t.Errorf("rota %s documentada mas não repassada pelo gateway", route)
+		}
+	}
+}
+
+func TestDocsAreServedByTheGateway(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/docs"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `src="/docs/swagger-ui-bundle.js"`) {
+		t.Errorf("/docs = %d", w.Code)
+	}
+	if w := get("/docs/VERSION"); w.Code != http.StatusNotFound {
+		t.Errorf("/docs/VERSION = %d, esperado 404", w.Code)
+	}
+	if w := get("/openapi.json"); w.Code != http.StatusOK {
+		t.Errorf("/openapi.json = %d", w.Code)
+	}
+}
@@ -0,0 +1,14 @@
+-- Dados de exemplo para desenvolvimento
+-- Executar depois das migrações:
+--   docker exec -i meuapoio-postgres psql -U postgres -d meuapoio < scripts/seed.sql
+
+INSERT INTO users (username, email, password_hash, full_name) VALUES 
+('admin', 'admin@meuapoio.com', '$2a$10$example.hash.here', 'Administrador'),
+('usuario_teste', 'teste@meuapoio.com', '$2a$10$example.hash.here', 'Usuário de Teste')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO audios (title, description, category, file_url, duration_seconds) VALUES 
+('Meditação para Ansiedade', 'Uma meditação guiada de 10 minutos para reduzir a ansiedade', 'meditation', '/audio/meditacao_ansiedade.mp3', 600),
+('Sons da Natureza - Chuva', 'Sons relaxantes de chuva para dormir', 'relaxing_music', '/audio/chuva_relaxante.mp3', 1800),
+('Respiração Consciente', 'Exercício de respiração para momentos de stress', 'meditation', '/audio/respiracao_consciente.mp3', 300)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,20 @@
+#!/bin/sh
+# Copia para api/swagger-ui os arquivos do swagger-ui-dist servidos pelo
+# gateway em /docs, na versão de api/swagger-ui/VERSION. O npm confere o hash
+# de integridade publicado no registro antes de extrair o pacote.
+#
+# Para atualizar: altere VERSION, rode este script e faça commit dos arquivos.
+set -eu
+
+dir=$(cd "$(dirname "$0")/../api/swagger-ui" && pwd)
+version=$(cat "$dir/VERSION")
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+(cd "$tmp" && npm pack --silent "swagger-ui-dist@$version" >/dev/null)
+tar -xzf "$tmp/swagger-ui-dist-$version.tgz" -C "$tmp"
+
+for file in swagger-ui.css swagger-ui-bundle.js LICENSE; do
+	cp "$tmp/package/$file" "$dir/$file"
+done
+echo "swagger-ui-dist $version copiado para $dir"
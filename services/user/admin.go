@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/crypto"
+	"github.com/meuapoio/shared/database"
+)
+
+// runAdmin concede (grant) ou revoga (revoke) o papel de administrador, que
+// permite editar o diretório de recursos de crise. Não há rota para isso: o
+// papel só muda por quem tem acesso ao banco.
+func runAdmin(db *database.DB, keyring *crypto.Keyring, args []string) {
+	if len(args) != 2 || (args[0] != "grant" && args[0] != "revoke") {
+		log.Fatal("Uso: admin grant|revoke <email>")
+	}
+	email, grant := args[1], args[0] == "grant"
+
+	err := repository.NewUserRepository(db, keyring).SetAdmin(context.Background(), email, grant)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Fatalf("Nenhuma conta com o email %s", email)
+	}
+	if err != nil {
+		log.Fatalf("Erro ao alterar o papel de administrador: %v", err)
+	}
+	if grant {
+		log.Printf("Papel de administrador concedido a %s", email)
+	} else {
+		log.Printf("Papel de administrador revogado de %s", email)
+	}
+}
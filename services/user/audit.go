@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/meuapoio/shared/audit"
+)
+
+// runAudit confere a cadeia de hashes do log de auditoria (AUDIT_HASH_CHAIN).
+// Termina com erro ao encontrar um evento alterado ou fora da sequência, para
+// que possa ser agendado e alertar quando falhar.
+func runAudit(db *sql.DB, args []string) {
+	if len(args) != 1 || args[0] != "verify" {
+		log.Fatal("Uso: audit verify")
+	}
+
+	id, err := audit.NewLogger(db, true).Verify(context.Background())
+	if err != nil {
+		log.Fatalf("Erro ao verificar o log de auditoria: %v", err)
+	}
+	if id != 0 {
+		log.Fatalf("Cadeia de auditoria inválida a partir do evento %d", id)
+	}
+	log.Print("Cadeia de auditoria íntegra")
+}
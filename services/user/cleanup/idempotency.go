@@ -0,0 +1,68 @@
+package cleanup
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/meuapoio/services/user/repository"
+)
+
+// IdempotencyPurger remove periodicamente as respostas de Idempotency-Key cujo
+// prazo de repetição (IDEMPOTENCY_TTL) terminou. As chaves de contas excluídas
+// já somem junto com a conta.
+type IdempotencyPurger struct {
+	repo     *repository.IdempotencyRepository
+	interval time.Duration
+	done     chan struct{}
+	// ctx é cancelado no Stop, interrompendo a remoção em andamento
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewIdempotencyPurger(repo *repository.IdempotencyRepository, interval time.Duration) *IdempotencyPurger {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &IdempotencyPurger{
+		repo:     repo,
+		interval: interval,
+		done:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start inicia a rotina em background
+func (p *IdempotencyPurger) Start() {
+	go p.run()
+}
+
+// Stop encerra a rotina em background
+func (p *IdempotencyPurger) Stop() {
+	p.cancel()
+	close(p.done)
+}
+
+func (p *IdempotencyPurger) run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.RunOnce(p.ctx)
+	for {
+		select {
+		case <-ticker.C:
+			p.RunOnce(p.ctx)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// RunOnce apaga as chaves vencidas e retorna quantas foram removidas
+func (p *IdempotencyPurger) RunOnce(ctx context.Context) int64 {
+	purged, err := p.repo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		log.Printf("Erro ao remover chaves de idempotência vencidas: %v", err)
+		return 0
+	}
+	return purged
+}
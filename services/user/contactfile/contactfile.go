@@ -0,0 +1,300 @@
+// Package contactfile converte contatos de emergência de e para os formatos de
+// agenda: vCard (3.0 e 4.0) e CSV. A validação dos contatos lidos fica com quem
+// chama, como nas requisições em JSON.
+package contactfile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/vcard"
+)
+
+// relationshipProperty guarda o parentesco no vCard; não há propriedade padrão
+// para o papel do contato
+const relationshipProperty = "X-MEUAPOIO-RELATIONSHIP"
+
+var (
+	// ErrMissingColumns indica um CSV sem as colunas de nome e telefone no
+	// cabeçalho
+	ErrMissingColumns = errors.New("contactfile: CSV sem as colunas name e phone")
+	// ErrEmpty indica um arquivo sem nenhum contato
+	ErrEmpty = errors.New("contactfile: nenhum contato no arquivo")
+)
+
+// Row é um contato lido do arquivo, ainda sem validação nem normalização do
+// telefone
+type Row struct {
+	// Line é a linha do arquivo onde o contato começa
+	Line    int
+	Contact models.CreateContactRequest
+}
+
+// ParseVCard lê os cartões do arquivo. O nome vem de FN ou, na falta dele, de
+// N; o telefone, do primeiro TEL marcado como celular ou preferido; o email, do
+// primeiro EMAIL.
+func ParseVCard(r io.Reader) ([]Row, error) {
+	cards, err := vcard.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) == 0 {
+		return nil, ErrEmpty
+	}
+
+	rows := make([]Row, len(cards))
+	for i, card := range cards {
+		rows[i] = Row{Line: card.Line, Contact: models.CreateContactRequest{
+			Name:         cardName(card),
+			Phone:        cardPhone(card),
+			Email:        optional(preferred(card.All("EMAIL"), "pref")),
+			Relationship: relationship(preferred(card.All(relationshipProperty))),
+		}}
+	}
+	return rows, nil
+}
+
+func cardName(card *vcard.Card) string {
+	if fn := card.Get("FN"); fn != nil && strings.TrimSpace(fn.Text()) != "" {
+		return strings.TrimSpace(fn.Text())
+	}
+	n := card.Get("N")
+	if n == nil {
+		return ""
+	}
+	// N é família;nome;nomes adicionais;prefixo;sufixo
+	components := append(n.Components(), "", "", "")
+	var parts []string
+	for _, part := range []string{components[1], components[2], components[0]} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func cardPhone(card *vcard.Card) string {
+	phone := preferred(card.All("TEL"), "cell", "pref")
+	// No vCard 4.0 o telefone costuma vir como URI: tel:+55-11-98888-7777;ext=2
+	phone = strings.TrimPrefix(phone, "tel:")
+	if i := strings.IndexByte(phone, ';'); i >= 0 {
+		phone = phone[:i]
+	}
+	return strings.TrimSpace(phone)
+}
+
+// preferred retorna o texto da primeira propriedade com um dos tipos, na ordem
+// informada, ou, se nenhuma tiver, da primeira da lista. O parâmetro PREF do
+// vCard 4.0 conta como o tipo "pref" do 3.0.
+func preferred(properties []vcard.Property, types ...string) string {
+	for _, t := range types {
+		for _, p := range properties {
+			if p.HasType(t) || (t == "pref" && len(p.Params["PREF"]) > 0) {
+				return p.Text()
+			}
+		}
+	}
+	if len(properties) == 0 {
+		return ""
+	}
+	return properties[0].Text()
+}
+
+func optional(value string) *string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// relationship converte o parentesco escrito na agenda para um dos valores de
+// models.Relationships; textos que não correspondem a nenhum viram "other"
+func relationship(value string) *string {
+	if optional(value) == nil {
+		return nil
+	}
+	parsed, ok := models.ParseRelationship(value)
+	if !ok {
+		parsed = models.RelationshipOther
+	}
+	return &parsed
+}
+
+// csvColumns associa os nomes de coluna aceitos, em minúsculas, aos campos. Os
+// nomes em inglês são os da exportação.
+var csvColumns = map[string]string{
+	"name":         "name",
+	"nome":         "name",
+	"phone":        "phone",
+	"telefone":     "phone",
+	"celular":      "phone",
+	"email":        "email",
+	"e-mail":       "email",
+	"relationship": "relationship",
+	"parentesco":   "relationship",
+	"relação":      "relationship",
+}
+
+// ParseCSV lê um CSV com cabeçalho. As colunas podem vir em qualquer ordem;
+// name e phone (ou nome e telefone) são obrigatórias e as desconhecidas são
+// ignoradas. O separador é vírgula, ou ponto e vírgula quando o cabeçalho só
+// tiver este, como nas planilhas exportadas em português.
+func ParseCSV(r io.Reader) ([]Row, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	header := data
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if bytes.IndexByte(header, ';') >= 0 && bytes.IndexByte(header, ',') < 0 {
+		reader.Comma = ';'
+	}
+
+	names, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range names {
+		if field, ok := csvColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, ErrMissingColumns
+	}
+	if _, ok := columns["phone"]; !ok {
+		return nil, ErrMissingColumns
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if blank(record) {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return uncell(strings.TrimSpace(record[i]))
+		}
+		rows = append(rows, Row{Line: line, Contact: models.CreateContactRequest{
+			Name:         value("name"),
+			Phone:        value("phone"),
+			Email:        optional(value("email")),
+			Relationship: relationship(value("relationship")),
+		}})
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmpty
+	}
+	return rows, nil
+}
+
+func blank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteVCard grava os contatos em vCard 4.0. O UID é o ID do contato, para que
+// a agenda reconheça o mesmo contato em exportações seguidas.
+func WriteVCard(w io.Writer, contacts []*models.EmergencyContact) error {
+	cards := make([]*vcard.Card, len(contacts))
+	for i, contact := range contacts {
+		card := &vcard.Card{Version: "4.0"}
+		card.Properties = append(card.Properties,
+			vcard.Property{Name: "UID", Value: "urn:uuid:" + contact.ID},
+			vcard.Text("FN", contact.Name),
+			vcard.Property{
+				Name:   "TEL",
+				Params: map[string][]string{"TYPE": {"cell"}, "VALUE": {"uri"}},
+				Value:  "tel:" + contact.Phone,
+			},
+		)
+		if contact.Email != nil {
+			card.Properties = append(card.Properties, vcard.Text("EMAIL", *contact.Email))
+		}
+		if contact.Relationship != nil {
+			card.Properties = append(card.Properties, vcard.Text(relationshipProperty, *contact.Relationship))
+		}
+		cards[i] = card
+	}
+	return vcard.Encode(w, cards)
+}
+
+// WriteCSV grava os contatos com cabeçalho, nas colunas que ParseCSV lê de
+// volta mais o contato principal e o status do convite
+func WriteCSV(w io.Writer, contacts []*models.EmergencyContact) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "phone", "email", "relationship", "is_primary", "status"})
+	for _, contact := range contacts {
+		cw.Write([]string{
+			cell(contact.Name),
+			cell(contact.Phone),
+			cell(deref(contact.Email)),
+			cell(deref(contact.Relationship)),
+			strconv.FormatBool(contact.IsPrimary),
+			contact.Status,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// formulaPrefixes são os caracteres iniciais que fazem planilhas tratarem a
+// célula como fórmula
+const formulaPrefixes = "=+-@\t\r"
+
+// cell neutraliza textos que planilhas interpretariam como fórmula
+func cell(value string) string {
+	if value != "" && strings.IndexByte(formulaPrefixes, value[0]) >= 0 {
+		return "'" + value
+	}
+	return value
+}
+
+// uncell desfaz cell, para que um CSV exportado possa ser importado de volta
+func uncell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.IndexByte(formulaPrefixes, value[1]) >= 0 {
+		return value[1:]
+	}
+	return value
+}
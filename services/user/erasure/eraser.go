@@ -0,0 +1,124 @@
+package erasure
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/blob"
+)
+
+// Eraser executa periodicamente a exclusão definitiva das contas cujo período de
+// carência terminou, com os arquivos de exportação e as fotos de perfil.
+type Eraser struct {
+	userRepo   repository.UserStore
+	exportRepo *repository.ExportRepository
+	images     blob.Store
+	auditLog   *audit.Logger
+	interval   time.Duration
+	done       chan struct{}
+	// ctx é cancelado no Stop, interrompendo as consultas em andamento
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewEraser(userRepo repository.UserStore, exportRepo *repository.ExportRepository, images blob.Store, auditLog *audit.Logger, interval time.Duration) *Eraser {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Eraser{
+		userRepo:   userRepo,
+		exportRepo: exportRepo,
+		images:     images,
+		auditLog:   auditLog,
+		interval:   interval,
+		done:       make(chan struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start inicia a rotina em background
+func (e *Eraser) Start() {
+	go e.run()
+}
+
+// Stop encerra a rotina em background
+func (e *Eraser) Stop() {
+	e.cancel()
+	close(e.done)
+}
+
+func (e *Eraser) run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	e.RunOnce(e.ctx)
+	for {
+		select {
+		case <-ticker.C:
+			e.RunOnce(e.ctx)
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// RunOnce apaga todas as contas vencidas e retorna quantas foram removidas
+func (e *Eraser) RunOnce(ctx context.Context) int {
+	now := time.Now()
+	ids, err := e.userRepo.ListDueForErasure(ctx, now)
+	if err != nil {
+		log.Printf("Erro ao buscar contas para exclusão: %v", err)
+		return 0
+	}
+
+	erased := 0
+	for _, id := range ids {
+		if e.erase(ctx, id, now) {
+			erased++
+		}
+	}
+
+	if erased > 0 {
+		log.Printf("%d conta(s) excluída(s) definitivamente", erased)
+	}
+	return erased
+}
+
+func (e *Eraser) erase(ctx context.Context, userID string, now time.Time) bool {
+	// Os caminhos precisam ser lidos antes, pois as linhas somem junto com a conta
+	files, err := e.exportRepo.ListFilePaths(ctx, userID)
+	if err != nil {
+		log.Printf("Erro ao buscar exportações da conta %s: %v", userID, err)
+		return false
+	}
+
+	ok, err := e.userRepo.Erase(ctx, userID, now)
+	if err != nil {
+		log.Printf("Erro ao excluir conta %s: %v", userID, err)
+		return false
+	}
+	if !ok {
+		// Exclusão cancelada por login entre a listagem e a remoção
+		return false
+	}
+
+	for _, path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Erro ao remover arquivo de exportação %s: %v", path, err)
+		}
+	}
+	if err := e.images.DeletePrefix(ctx, models.ProfileImagePrefix(userID)); err != nil {
+		log.Printf("Erro ao remover fotos de perfil da conta %s: %v", userID, err)
+	}
+
+	// O evento não guarda IP nem dados da requisição: é executado pelo sistema
+	e.auditLog.Log((&audit.Event{Action: audit.ActionAccountErased}).
+		SetActor(userID).
+		SetTarget(audit.TargetUser, userID))
+
+	return true
+}
@@ -0,0 +1,498 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/profileimage"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/blob"
+)
+
+// profileImageFile é o nome da foto de perfil dentro do arquivo exportado
+const profileImageFile = "foto-perfil.jpg"
+
+// UserData reúne todos os dados pessoais vinculados a um usuário (LGPD, art. 18)
+type UserData struct {
+	GeneratedAt time.Time    `json:"generated_at"`
+	Profile     *models.User `json:"profile"`
+	// Nome da foto de perfil no arquivo exportado (vazio se não houver foto)
+	ProfileImageFile  string `json:"profile_image_file,omitempty"`
+	profileImage      []byte
+	EmergencyContacts []*models.EmergencyContact `json:"emergency_contacts"`
+	SOSAlerts         []*models.Alert            `json:"sos_alerts"`
+	// Plano vigente (nulo se não houver), todas as versões e os links
+	SafetyPlan          *models.SafetyPlan           `json:"safety_plan"`
+	SafetyPlanRevisions []*models.SafetyPlan         `json:"safety_plan_revisions"`
+	SafetyPlanShares    []*models.SafetyPlanShare    `json:"safety_plan_shares"`
+	Favorites           []*models.FavoriteExport     `json:"favorites"`
+	PlayHistory         []*models.PlayHistoryExport  `json:"play_history"`
+	Notifications       []*models.NotificationExport `json:"notifications"`
+	Consents            []*models.Consent            `json:"consents"`
+	AuditEvents         []*audit.Event               `json:"audit_events"`
+}
+
+// Exporter processa os pedidos de exportação em background e gera um arquivo zip
+// com os dados em JSON e CSV.
+type Exporter struct {
+	exportRepo  *repository.ExportRepository
+	userRepo    repository.UserStore
+	contactRepo repository.ContactStore
+	consentRepo repository.ConsentStore
+	alertRepo   repository.AlertStore
+	planRepo    repository.SafetyPlanStore
+	images      blob.Store
+	auditLog    *audit.Logger
+	dir         string
+	linkTTL     time.Duration
+	jobs        chan string
+	done        chan struct{}
+	// ctx é cancelado no Stop, interrompendo as consultas em andamento
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewExporter(
+	exportRepo *repository.ExportRepository,
+	userRepo repository.UserStore,
+	contactRepo repository.ContactStore,
+	consentRepo repository.ConsentStore,
+	alertRepo repository.AlertStore,
+	planRepo repository.SafetyPlanStore,
+	images blob.Store,
+	auditLog *audit.Logger,
+	dir string,
+	linkTTL time.Duration,
+) *Exporter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Exporter{
+		exportRepo:  exportRepo,
+		userRepo:    userRepo,
+		contactRepo: contactRepo,
+		consentRepo: consentRepo,
+		alertRepo:   alertRepo,
+		planRepo:    planRepo,
+		images:      images,
+		auditLog:    auditLog,
+		dir:         dir,
+		linkTTL:     linkTTL,
+		jobs:        make(chan string, 100),
+		done:        make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// Start inicia os workers, reenfileira pedidos interrompidos e agenda a limpeza
+// dos arquivos expirados.
+func (e *Exporter) Start(workers int) error {
+	if err := os.MkdirAll(e.dir, 0o700); err != nil {
+		return fmt.Errorf("erro ao criar diretório de exportações: %w", err)
+	}
+
+	for i := 0; i < workers; i++ {
+		go e.worker()
+	}
+	go e.cleanupRoutine()
+
+	pending, err := e.exportRepo.ListPendingIDs(e.ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar exportações pendentes: %w", err)
+	}
+	for _, id := range pending {
+		e.Enqueue(id)
+	}
+
+	return nil
+}
+
+// Stop encerra os workers e a rotina de limpeza
+func (e *Exporter) Stop() {
+	e.cancel()
+	close(e.done)
+}
+
+// Enqueue agenda o processamento de um pedido. Se a fila estiver cheia o pedido
+// permanece pendente e é retomado no próximo Start.
+func (e *Exporter) Enqueue(id string) {
+	select {
+	case e.jobs <- id:
+	default:
+		log.Printf("Fila de exportação cheia, pedido %s ficará pendente", id)
+	}
+}
+
+func (e *Exporter) worker() {
+	for {
+		select {
+		case id := <-e.jobs:
+			e.process(id)
+		case <-e.done:
+			return
+		}
+	}
+}
+
+func (e *Exporter) process(id string) {
+	job, err := e.exportRepo.GetForProcessing(e.ctx, id)
+	if err != nil {
+		log.Printf("Erro ao iniciar exportação %s: %v", id, err)
+		return
+	}
+
+	path, err := e.build(job)
+	if err != nil {
+		log.Printf("Erro ao gerar exportação %s: %v", id, err)
+		if err := e.exportRepo.MarkFailed(e.ctx, id, "Erro ao gerar arquivo de exportação"); err != nil {
+			log.Printf("Erro ao marcar exportação %s como falha: %v", id, err)
+		}
+		return
+	}
+
+	if err := e.exportRepo.MarkCompleted(e.ctx, id, path, time.Now().Add(e.linkTTL)); err != nil {
+		log.Printf("Erro ao concluir exportação %s: %v", id, err)
+		os.Remove(path)
+	}
+}
+
+// Collect reúne os dados do usuário em todas as tabelas
+func (e *Exporter) Collect(ctx context.Context, userID string) (*UserData, error) {
+	profile, err := e.userRepo.GetByIDIncludingDeactivated(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("perfil: %w", err)
+	}
+
+	var image []byte
+	if profile.ProfileImageID != nil {
+		image, err = e.images.Get(ctx, models.ProfileImageKey(userID, *profile.ProfileImageID, profileimage.SizeLarge))
+		if err != nil && err != blob.ErrNotFound {
+			return nil, fmt.Errorf("foto de perfil: %w", err)
+		}
+	}
+
+	contacts, err := e.contactRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("contatos: %w", err)
+	}
+	if contacts == nil {
+		contacts = []*models.EmergencyContact{}
+	}
+
+	alerts, err := e.alertRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("alertas de SOS: %w", err)
+	}
+
+	plan, revisions, shares, err := e.collectSafetyPlan(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("plano de segurança: %w", err)
+	}
+
+	favorites, err := e.exportRepo.GetFavorites(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("favoritos: %w", err)
+	}
+
+	history, err := e.exportRepo.GetPlayHistory(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("histórico: %w", err)
+	}
+
+	notifications, err := e.exportRepo.GetNotifications(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("notificações: %w", err)
+	}
+
+	consents, err := e.consentRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("consentimentos: %w", err)
+	}
+
+	events, err := e.auditLog.ListByActor(ctx, userID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("auditoria: %w", err)
+	}
+	if events == nil {
+		events = []*audit.Event{}
+	}
+
+	data := &UserData{
+		GeneratedAt:         time.Now().UTC(),
+		Profile:             profile,
+		EmergencyContacts:   contacts,
+		SOSAlerts:           alerts,
+		SafetyPlan:          plan,
+		SafetyPlanRevisions: revisions,
+		SafetyPlanShares:    shares,
+		Favorites:           favorites,
+		PlayHistory:         history,
+		Notifications:       notifications,
+		Consents:            consents,
+		AuditEvents:         events,
+	}
+	if image != nil {
+		data.ProfileImageFile = profileImageFile
+		data.profileImage = image
+	}
+	return data, nil
+}
+
+// collectSafetyPlan lê o plano vigente, cada versão do histórico e os links de
+// compartilhamento. Sem plano, retorna o plano nulo e listas vazias.
+func (e *Exporter) collectSafetyPlan(ctx context.Context, userID string) (*models.SafetyPlan, []*models.SafetyPlan, []*models.SafetyPlanShare, error) {
+	plan, err := e.planRepo.Get(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, []*models.SafetyPlan{}, []*models.SafetyPlanShare{}, nil
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	listed, err := e.planRepo.ListRevisions(ctx, userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	revisions := make([]*models.SafetyPlan, 0, len(listed))
+	for _, revision := range listed {
+		version, err := e.planRepo.GetRevision(ctx, userID, revision.Version)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		revisions = append(revisions, version)
+	}
+
+	shares, err := e.planRepo.ListShares(ctx, userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return plan, revisions, shares, nil
+}
+
+func (e *Exporter) build(job *models.DataExport) (string, error) {
+	data, err := e.Collect(e.ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(e.dir, job.ID+".zip")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeArchive(file, data); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+func (e *Exporter) cleanupRoutine() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.cleanup()
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// cleanup remove os arquivos cujo link expirou
+func (e *Exporter) cleanup() {
+	expired, err := e.exportRepo.ListExpired(e.ctx, time.Now())
+	if err != nil {
+		log.Printf("Erro ao buscar exportações expiradas: %v", err)
+		return
+	}
+
+	for _, job := range expired {
+		if job.FilePath != nil {
+			if err := os.Remove(*job.FilePath); err != nil && !os.IsNotExist(err) {
+				log.Printf("Erro ao remover exportação %s: %v", job.ID, err)
+				continue
+			}
+		}
+		if err := e.exportRepo.MarkExpired(e.ctx, job.ID); err != nil {
+			log.Printf("Erro ao marcar exportação %s como expirada: %v", job.ID, err)
+		}
+	}
+}
+
+func writeArchive(file *os.File, data *UserData) error {
+	zw := zip.NewWriter(file)
+
+	jsonFile, err := zw.Create("dados.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(jsonFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return err
+	}
+
+	if data.profileImage != nil {
+		w, err := zw.Create(data.ProfileImageFile)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data.profileImage); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range csvTables(data) {
+		w, err := zw.Create(table.name)
+		if err != nil {
+			return err
+		}
+		if err := csv.NewWriter(w).WriteAll(table.rows); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+type csvTable struct {
+	name string
+	rows [][]string
+}
+
+func csvTables(data *UserData) []csvTable {
+	p := data.Profile
+	profile := [][]string{
+		{"id", "username", "email", "full_name", "birth_date", "phone", "profile_image_file", "preferred_language", "created_at", "updated_at"},
+		{p.ID, p.Username, p.Email, str(p.FullName), date(p.BirthDate), str(p.Phone), data.ProfileImageFile, str(p.PreferredLanguage), ts(&p.CreatedAt), ts(&p.UpdatedAt)},
+	}
+
+	contacts := [][]string{{"id", "name", "phone", "email", "relationship", "is_primary", "status", "created_at"}}
+	for _, c := range data.EmergencyContacts {
+		contacts = append(contacts, []string{
+			c.ID, c.Name, c.Phone, str(c.Email), str(c.Relationship), strconv.FormatBool(c.IsPrimary), c.Status, ts(&c.CreatedAt),
+		})
+	}
+
+	alerts := [][]string{{"id", "status", "level", "latitude", "longitude", "created_at", "acknowledged_at", "cancelled_at"}}
+	recipients := [][]string{{"alert_id", "contact_name", "level", "status", "channels", "notified_at", "acknowledged_at"}}
+	for _, a := range data.SOSAlerts {
+		latitude, longitude := "", ""
+		if a.Location != nil {
+			latitude = strconv.FormatFloat(a.Location.Latitude, 'f', -1, 64)
+			longitude = strconv.FormatFloat(a.Location.Longitude, 'f', -1, 64)
+		}
+		alerts = append(alerts, []string{
+			a.ID, a.Status, strconv.Itoa(a.Level), latitude, longitude, ts(&a.CreatedAt), ts(a.AcknowledgedAt), ts(a.CancelledAt),
+		})
+		for _, r := range a.Recipients {
+			recipients = append(recipients, []string{
+				a.ID, r.ContactName, strconv.Itoa(r.Level), r.Status, strings.Join(r.Channels, ","), ts(r.NotifiedAt), ts(r.AcknowledgedAt),
+			})
+		}
+	}
+
+	planItems := [][]string{{"version", "section", "position", "text", "contact_id", "phone"}}
+	if plan := data.SafetyPlan; plan != nil {
+		for _, section := range plan.Sections {
+			for i, item := range section.Items {
+				planItems = append(planItems, []string{
+					strconv.Itoa(plan.Version), section.Type, strconv.Itoa(i + 1), item.Text, str(item.ContactID), str(item.Phone),
+				})
+			}
+		}
+	}
+
+	shares := [][]string{{"id", "contact_id", "contact_name", "expires_at", "revoked_at", "last_viewed_at", "created_at"}}
+	for _, s := range data.SafetyPlanShares {
+		shares = append(shares, []string{
+			s.ID, s.ContactID, s.ContactName, ts(&s.ExpiresAt), ts(s.RevokedAt), ts(s.LastViewedAt), ts(&s.CreatedAt),
+		})
+	}
+
+	favorites := [][]string{{"audio_id", "title", "created_at"}}
+	for _, f := range data.Favorites {
+		favorites = append(favorites, []string{f.AudioID, f.Title, ts(&f.CreatedAt)})
+	}
+
+	history := [][]string{{"audio_id", "title", "played_at", "completion_percentage"}}
+	for _, h := range data.PlayHistory {
+		history = append(history, []string{h.AudioID, h.Title, ts(&h.PlayedAt), strconv.Itoa(h.CompletionPercentage)})
+	}
+
+	notifications := [][]string{{"id", "title", "message", "type", "is_read", "scheduled_for", "sent_at", "created_at"}}
+	for _, n := range data.Notifications {
+		notifications = append(notifications, []string{
+			n.ID, n.Title, n.Message, n.Type, strconv.FormatBool(n.IsRead), ts(n.ScheduledFor), ts(n.SentAt), ts(&n.CreatedAt),
+		})
+	}
+
+	consents := [][]string{{"type", "version", "ip_address", "granted_at", "withdrawn_at"}}
+	for _, c := range data.Consents {
+		consents = append(consents, []string{c.Type, c.Version, str(c.IPAddress), ts(&c.GrantedAt), ts(c.WithdrawnAt)})
+	}
+
+	events := [][]string{{"id", "action", "target_type", "target_id", "ip_address", "user_agent", "request_id", "created_at"}}
+	for _, ev := range data.AuditEvents {
+		events = append(events, []string{
+			strconv.FormatInt(ev.ID, 10), ev.Action, str(ev.TargetType), str(ev.TargetID), ev.IPAddress, ev.UserAgent, ev.RequestID, ts(&ev.CreatedAt),
+		})
+	}
+
+	return []csvTable{
+		{"perfil.csv", profile},
+		{"contatos_emergencia.csv", contacts},
+		{"alertas_sos.csv", alerts},
+		{"avisos_sos.csv", recipients},
+		{"plano_seguranca.csv", planItems},
+		{"plano_seguranca_links.csv", shares},
+		{"favoritos.csv", favorites},
+		{"historico_reproducao.csv", history},
+		{"notificacoes.csv", notifications},
+		{"consentimentos.csv", consents},
+		{"eventos_seguranca.csv", events},
+	}
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func ts(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func date(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
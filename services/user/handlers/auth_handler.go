@@ -1,57 +1,72 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/meuapoio/services/user/models"
 	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/database"
 	"github.com/meuapoio/shared/utils"
 )
 
+var (
+	errEmailInUse    = errors.New("email já está em uso")
+	errUsernameInUse = errors.New("username já está em uso")
+)
+
 type AuthHandler struct {
-	userRepo  *repository.UserRepository
-	jwtSecret string
+	userRepo    repository.UserStore
+	consentRepo repository.ConsentStore
+	tx          database.Transactor
+	auditLog    *audit.Logger
+	images      *ProfileImages
+	jwtSecret   string
 }
 
-func NewAuthHandler(userRepo *repository.UserRepository, jwtSecret string) *AuthHandler {
+func NewAuthHandler(userRepo repository.UserStore, consentRepo repository.ConsentStore, tx database.Transactor, auditLog *audit.Logger, images *ProfileImages, jwtSecret string) *AuthHandler {
 	return &AuthHandler{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo:    userRepo,
+		consentRepo: consentRepo,
+		tx:          tx,
+		auditLog:    auditLog,
+		images:      images,
+		jwtSecret:   jwtSecret,
 	}
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.AbortBinding(c, err)
 		return
 	}
 
-	exists, err := h.userRepo.EmailExists(req.Email)
+	// Termos de uso e política de privacidade vigentes são obrigatórios no cadastro
+	docs, err := h.consentRepo.GetCurrentDocuments(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro interno do servidor"})
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
-	if exists {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email já está em uso"})
+	if missing := missingMandatory(docs, req.Consents); len(missing) > 0 {
+		apierror.AbortWith(c, consentRequiredProblem(missing))
 		return
 	}
-
-	exists, err = h.userRepo.UsernameExists(req.Username)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro interno do servidor"})
-		return
-	}
-	if exists {
-		c.JSON(http.StatusConflict, gin.H{"error": "Username já está em uso"})
-		return
+	for _, consent := range req.Consents {
+		if !isCurrentVersion(docs, consent.Type, consent.Version) {
+			apierror.Abort(c, apierror.CodeConsentVersionOutdated)
+			return
+		}
 	}
 
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar senha"})
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
@@ -64,14 +79,62 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		user.FullName = &req.FullName
 	}
 
-	if err := h.userRepo.Create(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar usuário"})
+	// Usuário e consentimentos são gravados juntos: não existe conta sem aceite registrado
+	err = h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		exists, err := h.userRepo.EmailExists(ctx, req.Email)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errEmailInUse
+		}
+
+		exists, err = h.userRepo.UsernameExists(ctx, req.Username)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errUsernameInUse
+		}
+
+		if err := h.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+
+		for i := range req.Consents {
+			if _, err := h.consentRepo.Grant(ctx, user.ID, &req.Consents[i], c.ClientIP()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errEmailInUse):
+		apierror.Abort(c, apierror.CodeEmailInUse)
+		return
+	case errors.Is(err, errUsernameInUse):
+		apierror.Abort(c, apierror.CodeUsernameInUse)
 		return
+	case err != nil:
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	h.auditLog.Log(audit.FromContext(c, audit.ActionRegister).
+		SetActor(user.ID).
+		SetTarget(audit.TargetUser, user.ID))
+
+	for _, consent := range req.Consents {
+		e := audit.FromContext(c, audit.ActionConsentGranted).
+			SetActor(user.ID).
+			SetTarget(audit.TargetConsent, consent.Type)
+		e.Changes = map[string]audit.Change{"version": {After: consent.Version}}
+		h.auditLog.Log(e)
 	}
 
 	token, err := utils.GenerateJWT(user.ID, user.Email, h.jwtSecret)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar token"})
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
@@ -86,28 +149,42 @@ func (h *AuthHandler) Register(c *gin.Context) {
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.AbortBinding(c, err)
 		return
 	}
 
-	user, err := h.userRepo.GetByEmail(req.Email)
+	user, err := h.userRepo.GetByEmail(c.Request.Context(), req.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Credenciais inválidas"})
+			h.auditLog.Log(audit.FromContext(c, audit.ActionLoginFailed).SetTarget(audit.TargetUser, ""))
+			apierror.Abort(c, apierror.CodeAuthInvalidCredentials)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro interno do servidor"})
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
 	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Credenciais inválidas"})
+		// Registrado em nome do titular da conta para que ele veja tentativas suspeitas
+		h.auditLog.Log(audit.FromContext(c, audit.ActionLoginFailed).
+			SetActor(user.ID).
+			SetTarget(audit.TargetUser, user.ID))
+		apierror.Abort(c, apierror.CodeAuthInvalidCredentials)
 		return
 	}
 
 	token, err := utils.GenerateJWT(user.ID, user.Email, h.jwtSecret)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar token"})
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	h.auditLog.Log(audit.FromContext(c, audit.ActionLogin).
+		SetActor(user.ID).
+		SetTarget(audit.TargetUser, user.ID))
+
+	if _, err := h.images.present(user); err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
@@ -116,5 +193,21 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		User:  *user,
 	}
 
+	// Login durante o período de carência cancela a exclusão agendada
+	if user.DeletionScheduledFor != nil {
+		if err := h.userRepo.CancelDeletion(c.Request.Context(), user.ID); err != nil {
+			apierror.Abort(c, apierror.CodeInternal)
+			return
+		}
+
+		h.auditLog.Log(audit.FromContext(c, audit.ActionAccountDeletionCancelled).
+			SetActor(user.ID).
+			SetTarget(audit.TargetUser, user.ID))
+
+		response.User.IsActive = true
+		response.User.DeletionScheduledFor = nil
+		response.DeletionCancelled = true
+	}
+
 	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/database"
+)
+
+var errOutdatedVersion = errors.New("versão do documento não é a vigente")
+
+type ConsentHandler struct {
+	consentRepo repository.ConsentStore
+	tx          database.Transactor
+	auditLog    *audit.Logger
+}
+
+func NewConsentHandler(consentRepo repository.ConsentStore, tx database.Transactor, auditLog *audit.Logger) *ConsentHandler {
+	return &ConsentHandler{consentRepo: consentRepo, tx: tx, auditLog: auditLog}
+}
+
+// GetDocuments lista a versão vigente de cada documento (rota pública, usada no cadastro)
+func (h *ConsentHandler) GetDocuments(c *gin.Context) {
+	docs, err := h.consentRepo.GetCurrentDocuments(c.Request.Context())
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"documents": docs})
+}
+
+// GetConsents lista o histórico de consentimentos e os documentos obrigatórios pendentes
+func (h *ConsentHandler) GetConsents(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	consents, err := h.consentRepo.GetByUserID(c.Request.Context(), userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	pending, err := h.consentRepo.GetPendingMandatory(c.Request.Context(), userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ConsentsResponse{Consents: consents, Pending: pending})
+}
+
+// GrantConsent registra o aceite da versão vigente de um documento
+func (h *ConsentHandler) GrantConsent(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	var req models.ConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.AbortBinding(c, err)
+		return
+	}
+
+	// A versão vigente é conferida na mesma transação do aceite, evitando registrar
+	// uma versão que deixou de ser vigente no meio da requisição
+	var consent *models.Consent
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		docs, err := h.consentRepo.GetCurrentDocuments(ctx)
+		if err != nil {
+			return err
+		}
+		if !isCurrentVersion(docs, req.Type, req.Version) {
+			return errOutdatedVersion
+		}
+
+		consent, err = h.consentRepo.Grant(ctx, userID.(string), &req, c.ClientIP())
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errOutdatedVersion) {
+			apierror.Abort(c, apierror.CodeConsentVersionOutdated)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	h.logConsent(c, audit.ActionConsentGranted, userID.(string), &req)
+
+	c.JSON(http.StatusCreated, consent)
+}
+
+// WithdrawConsent retira um consentimento. Retirar um documento obrigatório
+// bloqueia as rotas protegidas até um novo aceite.
+func (h *ConsentHandler) WithdrawConsent(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	consentType := c.Param("type")
+	if err := h.consentRepo.Withdraw(c.Request.Context(), userID.(string), consentType); err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeConsentNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	h.logConsent(c, audit.ActionConsentWithdrawn, userID.(string), &models.ConsentRequest{Type: consentType})
+
+	c.JSON(http.StatusOK, gin.H{"message": message(c, "consent.withdrawn")})
+}
+
+func (h *ConsentHandler) logConsent(c *gin.Context, action, userID string, req *models.ConsentRequest) {
+	e := audit.FromContext(c, action).SetActor(userID).SetTarget(audit.TargetConsent, req.Type)
+	if req.Version != "" {
+		e.Changes = map[string]audit.Change{"version": {After: req.Version}}
+	}
+	h.auditLog.Log(e)
+}
+
+// missingMandatory retorna os documentos obrigatórios vigentes que não constam na lista de aceites
+func missingMandatory(docs []*models.ConsentDocument, accepted []models.ConsentRequest) []*models.ConsentDocument {
+	missing := []*models.ConsentDocument{}
+	for _, doc := range docs {
+		if !doc.Mandatory {
+			continue
+		}
+
+		found := false
+		for _, a := range accepted {
+			if a.Type == doc.Type && a.Version == doc.Version {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, doc)
+		}
+	}
+	return missing
+}
+
+func isCurrentVersion(docs []*models.ConsentDocument, consentType, version string) bool {
+	for _, doc := range docs {
+		if doc.Type == consentType {
+			return doc.Version == version
+		}
+	}
+	return false
+}
+
+// consentRequiredProblem é a resposta do cadastro sem os aceites obrigatórios. Usa
+// o mesmo código do ConsentMiddleware, mas com 400, pois o erro está no corpo enviado.
+func consentRequiredProblem(pending []*models.ConsentDocument) *apierror.Problem {
+	return apierror.New(apierror.CodeConsentRequired).
+		WithStatus(http.StatusBadRequest).
+		WithDetail(mandatoryConsentsMissing).
+		With("pending", pending)
+}
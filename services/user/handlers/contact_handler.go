@@ -1,135 +1,376 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/meuapoio/services/user/models"
 	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/database"
+	"github.com/meuapoio/shared/i18n"
+	"github.com/meuapoio/shared/pagination"
+	"github.com/meuapoio/shared/sms"
 )
 
 type ContactHandler struct {
-	contactRepo *repository.ContactRepository
+	contactRepo   repository.ContactStore
+	tx            database.Transactor
+	auditLog      *audit.Logger
+	smsSender     sms.Sender
+	phoneRegion   string
+	invitationURL string
+	invitationTTL time.Duration
 }
 
-func NewContactHandler(contactRepo *repository.ContactRepository) *ContactHandler {
-	return &ContactHandler{contactRepo: contactRepo}
+// NewContactHandler cria o handler. phoneRegion é a região dos telefones
+// informados sem código do país. Cada contato novo recebe por smsSender um
+// convite com o link invitationURL/<token>, válido por invitationTTL.
+func NewContactHandler(contactRepo repository.ContactStore, tx database.Transactor, auditLog *audit.Logger, smsSender sms.Sender, phoneRegion, invitationURL string, invitationTTL time.Duration) *ContactHandler {
+	return &ContactHandler{
+		contactRepo:   contactRepo,
+		tx:            tx,
+		auditLog:      auditLog,
+		smsSender:     smsSender,
+		phoneRegion:   phoneRegion,
+		invitationURL: invitationURL,
+		invitationTTL: invitationTTL,
+	}
+}
+
+// contactListOptions são a paginação, as ordenações e os filtros aceitos em
+// GET /contacts
+var contactListOptions = pagination.Options{
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts: []pagination.Sort{
+		{Name: models.ContactSortPrimary, Desc: true, Key: []pagination.KeyType{pagination.KeyBool, pagination.KeyTime, pagination.KeyUUID}},
+		{Name: models.ContactSortCreatedAt, Desc: true, Key: []pagination.KeyType{pagination.KeyTime, pagination.KeyUUID}},
+		{Name: models.ContactSortUpdatedAt, Desc: true, Key: []pagination.KeyType{pagination.KeyTime, pagination.KeyUUID}},
+	},
+	Filters: []pagination.Filter{
+		{Name: models.ContactFilterStatus, Values: []string{models.ContactStatusPending, models.ContactStatusAccepted, models.ContactStatusDeclined}},
+		{Name: models.ContactFilterPrimary, Values: []string{"true", "false"}},
+	},
 }
 
+// GetContacts lista uma página dos contatos. O ETag e o Last-Modified são os da
+// página entregue.
 func (h *ContactHandler) GetContacts(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	req, problem := pagination.Parse(c.Request.URL.Query(), contactListOptions)
+	if problem != nil {
+		apierror.AbortWith(c, problem)
 		return
 	}
 
-	contacts, err := h.contactRepo.GetByUserID(userID.(string))
+	contacts, err := h.contactRepo.List(c.Request.Context(), userID.(string), req)
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		apierror.AbortWith(c, pagination.InvalidCursor())
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar contatos"})
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
+	page := pagination.NewPage(req, contacts, func(contact *models.EmergencyContact) []string {
+		return contact.SortKey(req.Sort)
+	})
 
-	if contacts == nil {
-		contacts = []*models.EmergencyContact{}
+	var lastModified time.Time
+	for _, contact := range page.Items {
+		if contact.UpdatedAt.After(lastModified) {
+			lastModified = contact.UpdatedAt
+		}
+	}
+	etag := contactsETag(page)
+	setValidators(c, etag, lastModified)
+	if notModified(c, etag) {
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"contacts": contacts})
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *ContactHandler) GetContact(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	contact, err := h.contactRepo.GetByID(c.Request.Context(), c.Param("id"), userID.(string))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeContactNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	etag := versionETag(contact.Version)
+	setValidators(c, etag, contact.UpdatedAt)
+	if notModified(c, etag) {
+		return
+	}
+
+	c.JSON(http.StatusOK, contact)
+}
+
+// GetRelationships lista os parentescos aceitos em relationship, com os nomes
+// no idioma da requisição
+func (h *ContactHandler) GetRelationships(c *gin.Context) {
+	lang := i18n.Language(c)
+	options := make([]models.RelationshipOption, len(models.Relationships))
+	for i, relationship := range models.Relationships {
+		options[i] = models.RelationshipOption{Value: relationship, Label: models.RelationshipLabels[relationship].In(lang)}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": options})
 }
 
 func (h *ContactHandler) CreateContact(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
 		return
 	}
 
 	var req models.CreateContactRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.AbortBinding(c, err)
+		return
+	}
+	if !normalizePhone(c, &req.Phone, h.phoneRegion) {
 		return
 	}
 
-	contact, err := h.contactRepo.Create(userID.(string), &req)
+	// O contato começa pendente e recebe o convite por SMS depois do commit
+	var contact *models.EmergencyContact
+	var pending *pendingInvitation
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		created, err := h.contactRepo.Create(ctx, userID.(string), &req)
+		if err != nil {
+			return err
+		}
+		if pending, err = h.invite(ctx, created); err != nil {
+			return err
+		}
+		contact, err = h.contactRepo.GetByID(ctx, created.ID, userID.(string))
+		return err
+	})
+	var limitErr *repository.ContactLimitError
+	if errors.As(err, &limitErr) {
+		apierror.AbortWith(c, apierror.New(apierror.CodeContactLimit).With("max_contacts", limitErr.Max))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar contato"})
+		if abortInactive(c, err) {
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
+	e := audit.FromContext(c, audit.ActionContactCreated).SetTarget(audit.TargetContact, contact.ID)
+	e.Changes = audit.Diff(nil, contact)
+	h.auditLog.Log(e)
+	h.sendInvitation(c, pending)
+
+	setValidators(c, versionETag(contact.Version), contact.UpdatedAt)
 	c.JSON(http.StatusCreated, contact)
 }
 
 func (h *ContactHandler) UpdateContact(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
 		return
 	}
 
 	contactID := c.Param("id")
 	if contactID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID do contato é obrigatório"})
+		apierror.AbortWith(c, apierror.Field("id", "required", contactIDRequired))
 		return
 	}
 
 	var req models.UpdateContactRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.AbortBinding(c, err)
+		return
+	}
+	if !normalizePhone(c, req.Phone, h.phoneRegion) {
 		return
 	}
 
-	_, err := h.contactRepo.GetByID(contactID, userID.(string))
+	// Um telefone novo é outra pessoa: o contato volta a pendente e recebe convite
+	var contact, updatedContact *models.EmergencyContact
+	var pending *pendingInvitation
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if contact, err = h.contactRepo.GetByID(ctx, contactID, userID.(string)); err != nil {
+			return err
+		}
+		version, err := checkIfMatch(c, contact.Version)
+		if err != nil {
+			return err
+		}
+		if err = h.contactRepo.Update(ctx, contactID, userID.(string), &req, version); err != nil {
+			return err
+		}
+		if updatedContact, err = h.contactRepo.GetByID(ctx, contactID, userID.(string)); err != nil {
+			return err
+		}
+		if updatedContact.Phone == contact.Phone {
+			return nil
+		}
+		if pending, err = h.invite(ctx, updatedContact); err != nil {
+			return err
+		}
+		updatedContact, err = h.contactRepo.GetByID(ctx, contactID, userID.(string))
+		return err
+	})
 	if err != nil {
+		if abortPrecondition(c, err) {
+			return
+		}
+		if abortInactive(c, err) {
+			return
+		}
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Contato não encontrado"})
+			apierror.Abort(c, apierror.CodeContactNotFound)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro interno do servidor"})
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	e := audit.FromContext(c, audit.ActionContactUpdated).SetTarget(audit.TargetContact, contactID)
+	e.Changes = audit.Diff(contact, updatedContact)
+	h.auditLog.Log(e)
+	if pending != nil {
+		h.sendInvitation(c, pending)
+	}
+
+	setValidators(c, versionETag(updatedContact.Version), updatedContact.UpdatedAt)
+	c.JSON(http.StatusOK, updatedContact)
+}
+
+// SetPrimaryContact torna o contato o principal do usuário; o anterior deixa de
+// ser principal na mesma transação
+func (h *ContactHandler) SetPrimaryContact(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
 		return
 	}
 
-	if err := h.contactRepo.Update(contactID, userID.(string), &req); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar contato"})
+	contactID := c.Param("id")
+	if contactID == "" {
+		apierror.AbortWith(c, apierror.Field("id", "required", contactIDRequired))
 		return
 	}
 
-	updatedContact, err := h.contactRepo.GetByID(contactID, userID.(string))
+	var contact, updatedContact *models.EmergencyContact
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if contact, err = h.contactRepo.GetByID(ctx, contactID, userID.(string)); err != nil {
+			return err
+		}
+		version, err := checkIfMatch(c, contact.Version)
+		if err != nil {
+			return err
+		}
+		if contact.IsPrimary {
+			updatedContact = contact
+			return nil
+		}
+		if err = h.contactRepo.SetPrimary(ctx, contactID, userID.(string), version); err != nil {
+			return err
+		}
+		updatedContact, err = h.contactRepo.GetByID(ctx, contactID, userID.(string))
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar contato atualizado"})
+		if abortPrecondition(c, err) {
+			return
+		}
+		if abortInactive(c, err) {
+			return
+		}
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeContactNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
+	if updatedContact != contact {
+		e := audit.FromContext(c, audit.ActionContactUpdated).SetTarget(audit.TargetContact, contactID)
+		e.Changes = audit.Diff(contact, updatedContact)
+		h.auditLog.Log(e)
+	}
+
+	setValidators(c, versionETag(updatedContact.Version), updatedContact.UpdatedAt)
 	c.JSON(http.StatusOK, updatedContact)
 }
 
 func (h *ContactHandler) DeleteContact(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
 		return
 	}
 
 	contactID := c.Param("id")
 	if contactID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID do contato é obrigatório"})
+		apierror.AbortWith(c, apierror.Field("id", "required", contactIDRequired))
 		return
 	}
 
 	// Verificar se contato existe e pertence ao usuário
-	_, err := h.contactRepo.GetByID(contactID, userID.(string))
+	var contact *models.EmergencyContact
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if contact, err = h.contactRepo.GetByID(ctx, contactID, userID.(string)); err != nil {
+			return err
+		}
+		version, err := checkIfMatch(c, contact.Version)
+		if err != nil {
+			return err
+		}
+		return h.contactRepo.Delete(ctx, contactID, userID.(string), version)
+	})
 	if err != nil {
+		if abortPrecondition(c, err) {
+			return
+		}
+		if abortInactive(c, err) {
+			return
+		}
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Contato não encontrado"})
+			apierror.Abort(c, apierror.CodeContactNotFound)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro interno do servidor"})
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
-	if err := h.contactRepo.Delete(contactID, userID.(string)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar contato"})
-		return
-	}
+	e := audit.FromContext(c, audit.ActionContactDeleted).SetTarget(audit.TargetContact, contactID)
+	e.Changes = audit.Diff(contact, nil)
+	h.auditLog.Log(e)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Contato deletado com sucesso"})
+	c.JSON(http.StatusOK, gin.H{"message": message(c, "contact.deleted")})
 }
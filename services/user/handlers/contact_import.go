@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/meuapoio/services/user/contactfile"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/i18n"
+	"github.com/meuapoio/shared/phone"
+	"github.com/meuapoio/shared/vcard"
+)
+
+const (
+	// maxImportSize limita o corpo da importação; agendas com fotos embutidas
+	// passam disso e devem ser exportadas sem elas
+	maxImportSize = 1 << 20
+	// maxImportContacts limita os contatos por arquivo, e com isso os SMS de
+	// convite disparados por uma única requisição
+	maxImportContacts = 100
+)
+
+// contactFileParsers associa os Content-Type aceitos na importação ao leitor
+// do formato
+var contactFileParsers = map[string]func(io.Reader) ([]contactfile.Row, error){
+	"text/vcard":   contactfile.ParseVCard,
+	"text/x-vcard": contactfile.ParseVCard,
+	"text/csv":     contactfile.ParseCSV,
+}
+
+// importMediaTypes é a lista devolvida em UNSUPPORTED_MEDIA_TYPE
+var importMediaTypes = []string{"text/vcard", "text/csv"}
+
+// ImportContacts cria contatos de emergência a partir de um arquivo vCard ou
+// CSV enviado no corpo. Contatos inválidos ou com telefone já cadastrado (ou
+// repetido no arquivo) são pulados e aparecem no relatório; os demais são
+// criados numa única transação e recebem o convite por SMS, como no cadastro
+// individual. Com dry_run=true nada é gravado.
+func (h *ContactHandler) ImportContacts(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	var query models.ContactImportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apierror.AbortBinding(c, err)
+		return
+	}
+
+	parse, ok := contactFileParsers[strings.ToLower(c.ContentType())]
+	if !ok {
+		apierror.AbortWith(c, apierror.New(apierror.CodeUnsupportedMedia).With("accepted", importMediaTypes))
+		return
+	}
+	rows, err := parse(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	if err != nil {
+		apierror.AbortWith(c, contactFileProblem(err))
+		return
+	}
+	if len(rows) > maxImportContacts {
+		apierror.AbortWith(c, apierror.New(apierror.CodeContactFileInvalid).WithDetail(contactFileTooManyContacts).With("max_contacts", maxImportContacts))
+		return
+	}
+
+	existing, err := h.contactRepo.GetByUserID(c.Request.Context(), userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+	result, requests := h.checkImport(i18n.Language(c), rows, existing)
+	result.DryRun = query.DryRun
+	if max := h.contactRepo.MaxContacts(); max > 0 {
+		result.MaxContacts = &max
+		if over := min(len(existing)+result.Valid-max, result.Valid); over > 0 {
+			result.OverLimit = over
+		}
+	}
+	if query.DryRun || result.Valid == 0 {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	if result.OverLimit > 0 {
+		// A simulação mostra o excedente; a importação é tudo ou nada
+		apierror.AbortWith(c, apierror.New(apierror.CodeContactLimit).With("max_contacts", *result.MaxContacts))
+		return
+	}
+
+	// Todos os contatos válidos entram juntos: uma falha não deixa o arquivo
+	// importado pela metade
+	created := make([]*models.EmergencyContact, len(rows))
+	var pending []*pendingInvitation
+	err = h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		for i, row := range result.Rows {
+			if row.Status != models.ImportRowValid {
+				continue
+			}
+			contact, err := h.contactRepo.Create(ctx, userID.(string), &requests[i])
+			if err != nil {
+				return err
+			}
+			invitation, err := h.invite(ctx, contact)
+			if err != nil {
+				return err
+			}
+			if created[i], err = h.contactRepo.GetByID(ctx, contact.ID, userID.(string)); err != nil {
+				return err
+			}
+			pending = append(pending, invitation)
+		}
+		return nil
+	})
+	var limitErr *repository.ContactLimitError
+	if errors.As(err, &limitErr) {
+		// Nenhum contato do arquivo é criado: a importação é tudo ou nada
+		apierror.AbortWith(c, apierror.New(apierror.CodeContactLimit).With("max_contacts", limitErr.Max))
+		return
+	}
+	if err != nil {
+		if abortInactive(c, err) {
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	for i, contact := range created {
+		if contact == nil {
+			continue
+		}
+		result.Rows[i].Status = models.ImportRowCreated
+		result.Rows[i].ContactID = &contact.ID
+
+		e := audit.FromContext(c, audit.ActionContactCreated).SetTarget(audit.TargetContact, contact.ID)
+		e.Changes = audit.Diff(nil, contact)
+		h.auditLog.Log(e)
+	}
+	for _, invitation := range pending {
+		h.sendInvitation(c, invitation)
+	}
+	result.Created, result.Valid = result.Valid, 0
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// checkImport valida cada contato do arquivo como no cadastro individual e
+// marca como duplicados os telefones já cadastrados ou repetidos no arquivo.
+// Retorna também as requisições, com o telefone em E.164, na ordem das linhas.
+func (h *ContactHandler) checkImport(lang string, rows []contactfile.Row, existing []*models.EmergencyContact) (*models.ContactImportResult, []models.CreateContactRequest) {
+	known := make(map[string]string, len(existing))
+	for _, contact := range existing {
+		known[contact.Phone] = contact.ID
+	}
+	seen := map[string]int{}
+
+	result := &models.ContactImportResult{Total: len(rows), Rows: make([]models.ContactImportRow, len(rows))}
+	requests := make([]models.CreateContactRequest, len(rows))
+	for i, row := range rows {
+		req := row.Contact
+		report := models.ContactImportRow{Row: i + 1, Line: row.Line, Name: req.Name, Phone: req.Phone}
+		report.Errors = validateImportRow(lang, &req, h.phoneRegion)
+		report.Phone = req.Phone
+
+		switch {
+		case len(report.Errors) > 0:
+			report.Status = models.ImportRowInvalid
+			result.Invalid++
+		case known[req.Phone] != "":
+			id := known[req.Phone]
+			report.Status, report.DuplicateOf = models.ImportRowDuplicate, &id
+			result.Duplicates++
+		case seen[req.Phone] != 0:
+			first := seen[req.Phone]
+			report.Status, report.DuplicateOfRow = models.ImportRowDuplicate, &first
+			result.Duplicates++
+		default:
+			report.Status = models.ImportRowValid
+			seen[req.Phone] = report.Row
+			result.Valid++
+		}
+		result.Rows[i], requests[i] = report, req
+	}
+	return result, requests
+}
+
+// validateImportRow aplica as regras de CreateContactRequest e normaliza o
+// telefone para E.164
+func validateImportRow(lang string, req *models.CreateContactRequest, region string) []models.ImportFieldError {
+	var fieldErrors []models.ImportFieldError
+	var validationErrs validator.ValidationErrors
+	if err := binding.Validator.ValidateStruct(req); errors.As(err, &validationErrs) {
+		for _, fe := range validationErrs {
+			fieldErrors = append(fieldErrors, models.ImportFieldError{
+				Field:   fe.Field(),
+				Rule:    fe.Tag(),
+				Message: i18n.ValidationMessage(lang, fe),
+			})
+		}
+	}
+	for _, fe := range fieldErrors {
+		if fe.Field == "phone" {
+			return fieldErrors
+		}
+	}
+
+	number, err := phone.Parse(req.Phone, region)
+	if err != nil {
+		return append(fieldErrors, models.ImportFieldError{Field: "phone", Rule: "phone", Message: invalidPhone.In(lang)})
+	}
+	req.Phone = number.E164()
+	return fieldErrors
+}
+
+// contactFileProblem traduz o erro de leitura do arquivo em CONTACT_FILE_INVALID,
+// com a linha do problema quando o formato a informa
+func contactFileProblem(err error) *apierror.Problem {
+	p := apierror.New(apierror.CodeContactFileInvalid)
+
+	var maxBytesErr *http.MaxBytesError
+	var vcardErr *vcard.SyntaxError
+	var csvErr *csv.ParseError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return p.WithDetail(contactFileTooLarge)
+	case errors.Is(err, contactfile.ErrMissingColumns):
+		return p.WithDetail(contactFileMissingColumns)
+	case errors.Is(err, contactfile.ErrEmpty):
+		return p.WithDetail(contactFileEmpty)
+	case errors.As(err, &vcardErr):
+		return p.WithDetail(contactFileMalformed).With("line", vcardErr.Line)
+	case errors.As(err, &csvErr):
+		return p.WithDetail(contactFileMalformed).With("line", csvErr.Line)
+	}
+	return p.WithDetail(contactFileMalformed)
+}
+
+// ExportContacts devolve os contatos de emergência do usuário como anexo vCard
+// 4.0 (padrão) ou CSV, para backup ou para levar a rede de apoio a outra agenda
+func (h *ContactHandler) ExportContacts(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	var query models.ContactExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apierror.AbortBinding(c, err)
+		return
+	}
+
+	contacts, err := h.contactRepo.GetByUserID(c.Request.Context(), userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType, extension := "text/vcard; charset=utf-8", "vcf"
+	if query.Format == "csv" {
+		contentType, extension = "text/csv; charset=utf-8", "csv"
+		err = contactfile.WriteCSV(&buf, contacts)
+	} else {
+		err = contactfile.WriteVCard(&buf, contacts)
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	h.auditLog.Log(audit.FromContext(c, audit.ActionContactsExported).SetTarget(audit.TargetUser, userID.(string)))
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "meuapoio-contatos."+extension))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/pagination"
+)
+
+// versionETag é o ETag forte de um registro, derivado da coluna version
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// contactsETag é o ETag fraco de uma página da lista: muda quando um contato da
+// página é criado, alterado ou removido, ou quando surge ou some a próxima página
+func contactsETag(page pagination.Page[*models.EmergencyContact]) string {
+	h := sha256.New()
+	for _, contact := range page.Items {
+		fmt.Fprintf(h, "%s:%d\n", contact.ID, contact.Version)
+	}
+	if page.NextCursor != nil {
+		fmt.Fprintf(h, "next:%s\n", *page.NextCursor)
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// setValidators adiciona ETag e Last-Modified à resposta. no-cache obriga o
+// cliente a revalidar com If-None-Match antes de reaproveitar a cópia.
+func setValidators(c *gin.Context, etag string, modified time.Time) {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified responde 304 quando o If-None-Match corresponde ao ETag atual
+func notModified(c *gin.Context, etag string) bool {
+	if !etagMatches(c.GetHeader("If-None-Match"), etag, false) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// preconditionError interrompe a transação quando o If-Match não corresponde
+// à versão lida
+type preconditionError struct {
+	etag string
+}
+
+func (e *preconditionError) Error() string {
+	return "If-Match não corresponde à versão atual " + e.etag
+}
+
+// linkWindow casa os ETags de profileETag, que acrescentam à versão o
+// vencimento dos links assinados
+var linkWindow = regexp.MustCompile(`"(\d+)-\d+"`)
+
+// checkIfMatch compara o If-Match com a versão lida e retorna a versão que a
+// escrita deve exigir. Sem o header retorna 0 e a última escrita prevalece.
+func checkIfMatch(c *gin.Context, version int) (int, error) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+	etag := versionETag(version)
+	if !etagMatches(linkWindow.ReplaceAllString(header, `"$1"`), etag, true) {
+		return 0, &preconditionError{etag: etag}
+	}
+	return version, nil
+}
+
+// abortPrecondition responde 412 se err vier de um If-Match que não corresponde
+// ou de uma escrita concorrente entre a leitura e a alteração
+func abortPrecondition(c *gin.Context, err error) bool {
+	var precondition *preconditionError
+	switch {
+	case errors.As(err, &precondition):
+		c.Header("ETag", precondition.etag)
+	case errors.Is(err, repository.ErrVersionConflict):
+	default:
+		return false
+	}
+	apierror.Abort(c, apierror.CodePreconditionFailed)
+	return true
+}
+
+// etagMatches verifica se a lista de ETags do header (RFC 9110) contém etag. A
+// comparação forte, exigida pelo If-Match, não aceita ETags fracos.
+func etagMatches(header, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strong {
+			if candidate == etag && !strings.HasPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/services/user/export"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/database"
+	"github.com/meuapoio/shared/utils"
+)
+
+type ExportHandler struct {
+	exportRepo *repository.ExportRepository
+	exporter   *export.Exporter
+	tx         database.Transactor
+	auditLog   *audit.Logger
+	linkSecret string
+}
+
+func NewExportHandler(exportRepo *repository.ExportRepository, exporter *export.Exporter, tx database.Transactor, auditLog *audit.Logger, linkSecret string) *ExportHandler {
+	return &ExportHandler{
+		exportRepo: exportRepo,
+		exporter:   exporter,
+		tx:         tx,
+		auditLog:   auditLog,
+		linkSecret: linkSecret,
+	}
+}
+
+// RequestExport agenda a geração do arquivo com todos os dados do usuário
+func (h *ExportHandler) RequestExport(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	// Evitar pedidos duplicados enquanto um ainda está em andamento
+	var job *models.DataExport
+	created := false
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		active, err := h.exportRepo.GetActiveByUserID(ctx, userID.(string))
+		if err == nil {
+			job = active
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		job, err = h.exportRepo.Create(ctx, userID.(string))
+		created = err == nil
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	h.exporter.Enqueue(job.ID)
+	h.auditLog.Log(audit.FromContext(c, audit.ActionDataExportRequested).SetTarget(audit.TargetDataExport, job.ID))
+
+	c.Header("Location", "/api/v1/users/export/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetExport retorna o status do pedido e, quando concluído, o link temporário de download
+func (h *ExportHandler) GetExport(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	job, err := h.exportRepo.GetByID(c.Request.Context(), c.Param("id"), userID.(string))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeExportNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	if job.Status == models.ExportStatusCompleted && job.ExpiresAt != nil {
+		job.DownloadURL = h.downloadURL(job.ID, *job.ExpiresAt)
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadExport entrega o arquivo zip. A rota é pública e protegida pela assinatura do link.
+func (h *ExportHandler) DownloadExport(c *gin.Context) {
+	exportID := c.Param("id")
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !utils.VerifyExpiring(h.linkSecret, "export:"+exportID, expires, c.Query("signature")) {
+		apierror.Abort(c, apierror.CodeExportLinkInvalid)
+		return
+	}
+
+	job, err := h.exportRepo.GetByIDForDownload(c.Request.Context(), exportID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeExportNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	if job.Status != models.ExportStatusCompleted || job.FilePath == nil {
+		apierror.Abort(c, apierror.CodeExportExpired)
+		return
+	}
+
+	h.auditLog.Log(audit.FromContext(c, audit.ActionDataExportDownloaded).
+		SetActor(job.UserID).
+		SetTarget(audit.TargetDataExport, job.ID))
+
+	c.Header("Cache-Control", "no-store")
+	c.FileAttachment(*job.FilePath, fmt.Sprintf("meuapoio-dados-%s.zip", job.CreatedAt.Format("2006-01-02")))
+}
+
+func (h *ExportHandler) downloadURL(exportID string, expiresAt time.Time) string {
+	signature := utils.SignExpiring(h.linkSecret, "export:"+exportID, expiresAt)
+	return fmt.Sprintf("/api/v1/users/export/%s/download?expires=%d&signature=%s", exportID, expiresAt.Unix(), signature)
+}
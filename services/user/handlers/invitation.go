@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+)
+
+// invitationResendInterval é o intervalo mínimo entre dois SMS de convite para o
+// mesmo contato, para que o reenvio não sirva para importunar um número
+const invitationResendInterval = 10 * time.Minute
+
+// errContactNotPending interrompe o reenvio para quem já respondeu ao convite
+var errContactNotPending = errors.New("contato já respondeu ao convite")
+
+// newInvitationToken gera o token do link e o hash gravado no banco; o token em
+// si só existe no SMS
+func newInvitationToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashInvitationToken(token), nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// pendingInvitation é um convite gravado na transação, enviado depois do commit
+type pendingInvitation struct {
+	token      string
+	phone      string
+	invitation *models.ContactInvitation
+}
+
+// invite grava um novo convite para o contato, invalidando o link anterior
+func (h *ContactHandler) invite(ctx context.Context, contact *models.EmergencyContact) (*pendingInvitation, error) {
+	token, hash, err := newInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	invitation, err := h.contactRepo.SetInvitation(ctx, contact.ID, contact.UserID, hash, now, now.Add(h.invitationTTL))
+	if err != nil {
+		return nil, err
+	}
+	return &pendingInvitation{token: token, phone: contact.Phone, invitation: invitation}, nil
+}
+
+// sendInvitation envia o SMS no idioma da requisição. Uma falha no envio não
+// desfaz a alteração do contato: o usuário pode reenviar o convite.
+func (h *ContactHandler) sendInvitation(c *gin.Context, pending *pendingInvitation) {
+	link := strings.TrimSuffix(h.invitationURL, "/") + "/" + pending.token
+	body := fmt.Sprintf(message(c, "contact.invitation_sms"), pending.invitation.InviterName, link)
+	if err := h.smsSender.Send(c.Request.Context(), pending.phone, body); err != nil {
+		log.Printf("Erro ao enviar convite do contato %s: %v", pending.invitation.ContactID, err)
+	}
+
+	h.auditLog.Log(audit.FromContext(c, audit.ActionContactInvited).SetTarget(audit.TargetContact, pending.invitation.ContactID))
+}
+
+// ResendInvitation envia um novo link para um contato que ainda não respondeu
+func (h *ContactHandler) ResendInvitation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	contactID := c.Param("id")
+	if contactID == "" {
+		apierror.AbortWith(c, apierror.Field("id", "required", contactIDRequired))
+		return
+	}
+
+	var contact *models.EmergencyContact
+	var pending *pendingInvitation
+	var retryAfter time.Duration
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if contact, err = h.contactRepo.GetByID(ctx, contactID, userID.(string)); err != nil {
+			return err
+		}
+		if contact.Status != models.ContactStatusPending {
+			return errContactNotPending
+		}
+		if contact.InvitationSentAt != nil {
+			if retryAfter = invitationResendInterval - time.Since(*contact.InvitationSentAt); retryAfter > 0 {
+				return nil
+			}
+		}
+		if pending, err = h.invite(ctx, contact); err != nil {
+			return err
+		}
+		contact, err = h.contactRepo.GetByID(ctx, contactID, userID.(string))
+		return err
+	})
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			apierror.Abort(c, apierror.CodeContactNotFound)
+		case errors.Is(err, errContactNotPending):
+			apierror.Abort(c, apierror.CodeContactNotPending)
+		default:
+			apierror.Abort(c, apierror.CodeInternal)
+		}
+		return
+	}
+	if pending == nil {
+		seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+		c.Header("Retry-After", seconds)
+		apierror.AbortWith(c, apierror.New(apierror.CodeRateLimited).With("retry_after", seconds+"s"))
+		return
+	}
+
+	h.sendInvitation(c, pending)
+
+	setValidators(c, versionETag(contact.Version), contact.UpdatedAt)
+	c.JSON(http.StatusOK, contact)
+}
+
+// GetInvitation mostra o convite à pessoa convidada. As rotas de convite são
+// públicas: o token do link é a credencial.
+func (h *ContactHandler) GetInvitation(c *gin.Context) {
+	invitation, err := h.contactRepo.GetInvitation(c.Request.Context(), hashInvitationToken(c.Param("token")), time.Now())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeInvitationNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, invitation)
+}
+
+// AcceptInvitation registra que a pessoa aceitou ser contato de apoio e passa a
+// receber os alertas do usuário
+func (h *ContactHandler) AcceptInvitation(c *gin.Context) {
+	h.respondInvitation(c, models.ContactStatusAccepted, audit.ActionContactInvitationAccepted)
+}
+
+// DeclineInvitation registra a recusa; o contato continua na lista do usuário,
+// mas não recebe alertas
+func (h *ContactHandler) DeclineInvitation(c *gin.Context) {
+	h.respondInvitation(c, models.ContactStatusDeclined, audit.ActionContactInvitationDeclined)
+}
+
+func (h *ContactHandler) respondInvitation(c *gin.Context, status, action string) {
+	invitation, err := h.contactRepo.RespondInvitation(c.Request.Context(), hashInvitationToken(c.Param("token")), status, time.Now())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeInvitationNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	h.auditLog.Log(audit.FromContext(c, action).SetTarget(audit.TargetContact, invitation.ContactID))
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, invitation)
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/i18n"
+	"github.com/meuapoio/shared/phone"
+)
+
+// messages são os textos das respostas de sucesso; os de erro ficam no catálogo
+// de códigos do shared/apierror
+var messages = i18n.Catalog{
+	"account.deletion_scheduled": {
+		i18n.PortugueseBR: "Exclusão da conta agendada. Faça login antes do prazo para cancelar.",
+		i18n.English:      "Account deletion scheduled. Log in before the deadline to cancel.",
+		i18n.Spanish:      "Eliminación de la cuenta programada. Inicia sesión antes del plazo para cancelar.",
+	},
+	"consent.withdrawn": {
+		i18n.PortugueseBR: "Consentimento retirado com sucesso",
+		i18n.English:      "Consent withdrawn successfully",
+		i18n.Spanish:      "Consentimiento retirado con éxito",
+	},
+	"contact.deleted": {
+		i18n.PortugueseBR: "Contato deletado com sucesso",
+		i18n.English:      "Contact deleted successfully",
+		i18n.Spanish:      "Contacto eliminado con éxito",
+	},
+	// SMS do convite: nome de quem convidou e link
+	"contact.invitation_sms": {
+		i18n.PortugueseBR: "%s adicionou você como contato de apoio no MeuApoio. Para aceitar ou recusar, acesse %s",
+		i18n.English:      "%s added you as a support contact on MeuApoio. To accept or decline, visit %s",
+		i18n.Spanish:      "%s te agregó como contacto de apoyo en MeuApoio. Para aceptar o rechazar, visita %s",
+	},
+	"resource.deleted": {
+		i18n.PortugueseBR: "Recurso removido do diretório",
+		i18n.English:      "Resource removed from the directory",
+		i18n.Spanish:      "Recurso eliminado del directorio",
+	},
+	"safety_plan.deleted": {
+		i18n.PortugueseBR: "Plano de segurança apagado",
+		i18n.English:      "Safety plan deleted",
+		i18n.Spanish:      "Plan de seguridad eliminado",
+	},
+	"safety_plan.share_revoked": {
+		i18n.PortugueseBR: "Link de compartilhamento revogado",
+		i18n.English:      "Sharing link revoked",
+		i18n.Spanish:      "Enlace para compartir revocado",
+	},
+	// SMS do compartilhamento: nome do usuário e link
+	"safety_plan.share_sms": {
+		i18n.PortugueseBR: "%s compartilhou com você o plano de segurança no MeuApoio, para você saber como ajudar numa crise. Acesse %s",
+		i18n.English:      "%s shared their safety plan with you on MeuApoio, so you know how to help in a crisis. Visit %s",
+		i18n.Spanish:      "%s compartió contigo su plan de seguridad en MeuApoio, para que sepas cómo ayudar en una crisis. Visita %s",
+	},
+}
+
+// detalhes usados em respostas de erro
+var (
+	contactIDRequired = i18n.Text{
+		i18n.PortugueseBR: "ID do contato é obrigatório",
+		i18n.English:      "Contact ID is required",
+		i18n.Spanish:      "El ID del contacto es obligatorio",
+	}
+	invalidPhone = i18n.Text{
+		i18n.PortugueseBR: "número de telefone inválido; informe DDD e número ou o código do país com +",
+		i18n.English:      "invalid phone number; include the area code, or the country code with +",
+		i18n.Spanish:      "número de teléfono inválido; incluya el código de área o el código de país con +",
+	}
+	contactFileMalformed = i18n.Text{
+		i18n.PortugueseBR: "não foi possível ler o arquivo; confira se é um vCard 3.0 ou 4.0 ou um CSV válido",
+		i18n.English:      "the file could not be read; check that it is a valid vCard 3.0 or 4.0 or CSV file",
+		i18n.Spanish:      "no se pudo leer el archivo; verifique que sea un vCard 3.0 o 4.0 o un CSV válido",
+	}
+	contactFileMissingColumns = i18n.Text{
+		i18n.PortugueseBR: "a primeira linha do CSV deve ter as colunas name e phone (ou nome e telefone)",
+		i18n.English:      "the first line of the CSV must have the columns name and phone",
+		i18n.Spanish:      "la primera línea del CSV debe tener las columnas name y phone",
+	}
+	contactFileEmpty = i18n.Text{
+		i18n.PortugueseBR: "nenhum contato encontrado no arquivo",
+		i18n.English:      "no contacts found in the file",
+		i18n.Spanish:      "no se encontraron contactos en el archivo",
+	}
+	contactFileTooManyContacts = i18n.Text{
+		i18n.PortugueseBR: "o arquivo tem mais de 100 contatos; divida-o em partes menores",
+		i18n.English:      "the file has more than 100 contacts; split it into smaller parts",
+		i18n.Spanish:      "el archivo tiene más de 100 contactos; divídalo en partes más pequeñas",
+	}
+	contactFileTooLarge = i18n.Text{
+		i18n.PortugueseBR: "o arquivo passa de 1 MiB; remova fotos ou divida-o em partes menores",
+		i18n.English:      "the file exceeds 1 MiB; remove photos or split it into smaller parts",
+		i18n.Spanish:      "el archivo supera 1 MiB; elimine las fotos o divídalo en partes más pequeñas",
+	}
+	imageMissing = i18n.Text{
+		i18n.PortugueseBR: "envie a foto no campo image de um formulário multipart",
+		i18n.English:      "send the photo in the image field of a multipart form",
+		i18n.Spanish:      "envíe la foto en el campo image de un formulario multipart",
+	}
+	imageTooLarge = i18n.Text{
+		i18n.PortugueseBR: "a foto passa do tamanho máximo de envio",
+		i18n.English:      "the photo exceeds the maximum upload size",
+		i18n.Spanish:      "la foto supera el tamaño máximo de envío",
+	}
+	imageCorrupt = i18n.Text{
+		i18n.PortugueseBR: "não foi possível ler a foto; o arquivo pode estar corrompido ou incompleto",
+		i18n.English:      "the photo could not be read; the file may be corrupt or incomplete",
+		i18n.Spanish:      "no se pudo leer la foto; el archivo puede estar dañado o incompleto",
+	}
+	imageTooSmall = i18n.Text{
+		i18n.PortugueseBR: "a foto deve ter pelo menos 64×64 pixels",
+		i18n.English:      "the photo must be at least 64×64 pixels",
+		i18n.Spanish:      "la foto debe tener al menos 64×64 píxeles",
+	}
+	imageTooManyPixels = i18n.Text{
+		i18n.PortugueseBR: "a foto tem resolução alta demais; reduza-a para até 40 megapixels",
+		i18n.English:      "the photo resolution is too high; reduce it to at most 40 megapixels",
+		i18n.Spanish:      "la resolución de la foto es demasiado alta; redúzcala a 40 megapíxeles como máximo",
+	}
+	limitOutOfRange = i18n.Text{
+		i18n.PortugueseBR: "deve estar entre 1 e 200",
+		i18n.English:      "must be between 1 and 200",
+		i18n.Spanish:      "debe estar entre 1 y 200",
+	}
+	contactNotOwned = i18n.Text{
+		i18n.PortugueseBR: "não é um dos seus contatos de emergência",
+		i18n.English:      "is not one of your emergency contacts",
+		i18n.Spanish:      "no es uno de tus contactos de emergencia",
+	}
+	revisionVersionInvalid = i18n.Text{
+		i18n.PortugueseBR: "deve ser um número de versão a partir de 1",
+		i18n.English:      "must be a version number of 1 or more",
+		i18n.Spanish:      "debe ser un número de versión a partir de 1",
+	}
+	mandatoryConsentsMissing = i18n.Text{
+		i18n.PortugueseBR: "É necessário aceitar os termos de uso e a política de privacidade vigentes",
+		i18n.English:      "You must accept the current terms of use and privacy policy",
+		i18n.Spanish:      "Es necesario aceptar los términos de uso y la política de privacidad vigentes",
+	}
+)
+
+// normalizePhone converte o telefone informado para E.164, interpretando números
+// sem código do país na região padrão. Responde VALIDATION_FAILED no campo phone
+// e retorna false quando o número é inválido.
+func normalizePhone(c *gin.Context, value *string, region string) bool {
+	if value == nil {
+		return true
+	}
+	number, err := phone.Parse(*value, region)
+	if err != nil {
+		apierror.AbortWith(c, apierror.Field("phone", "phone", invalidPhone))
+		return false
+	}
+	*value = number.E164()
+	return true
+}
+
+// message retorna o texto da chave no idioma da requisição
+func message(c *gin.Context, key string) string {
+	return messages.Get(i18n.Language(c), key)
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/profileimage"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/blob"
+)
+
+// multipartOverhead é a folga do limite do corpo para os cabeçalhos e
+// delimitadores do formulário multipart, além da própria foto
+const multipartOverhead = 64 << 10
+
+// ProfileImages guarda as fotos de perfil no blob.Store e gera os links
+// assinados devolvidos nos perfis
+type ProfileImages struct {
+	store   blob.Store
+	urlTTL  time.Duration
+	maxSize int
+	now     func() time.Time
+}
+
+// NewProfileImages cria o ProfileImages. Envios acima de maxSize bytes são
+// recusados; os links valem de urlTTL a duas vezes urlTTL (ver linkExpiry).
+func NewProfileImages(store blob.Store, urlTTL time.Duration, maxSize int) *ProfileImages {
+	return &ProfileImages{store: store, urlTTL: urlTTL, maxSize: maxSize, now: time.Now}
+}
+
+// linkExpiry é o vencimento dos links gerados agora. Os links são os mesmos
+// dentro de cada janela de urlTTL e valem até o fim da janela seguinte, para que
+// o ETag do perfil só mude uma vez por janela.
+func (p *ProfileImages) linkExpiry() time.Time {
+	return p.now().Truncate(p.urlTTL).Add(2 * p.urlTTL)
+}
+
+// present preenche os links da foto de perfil do usuário e retorna o
+// vencimento deles (zero se não houver foto)
+func (p *ProfileImages) present(user *models.User) (time.Time, error) {
+	user.ProfileImageURL, user.ProfileImageThumbnailURL = nil, nil
+	if user.ProfileImageID == nil {
+		return time.Time{}, nil
+	}
+
+	expiresAt := p.linkExpiry()
+	large, err := p.store.URL(models.ProfileImageKey(user.ID, *user.ProfileImageID, profileimage.SizeLarge), expiresAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	thumbnail, err := p.store.URL(models.ProfileImageKey(user.ID, *user.ProfileImageID, profileimage.SizeThumbnail), expiresAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	user.ProfileImageURL, user.ProfileImageThumbnailURL = &large, &thumbnail
+	return expiresAt, nil
+}
+
+// put grava as versões de uma foto nova e retorna o identificador dela
+func (p *ProfileImages) put(ctx context.Context, userID string, versions map[int][]byte) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	imageID := hex.EncodeToString(b)
+
+	for _, size := range profileimage.Sizes {
+		if err := p.store.Put(ctx, models.ProfileImageKey(userID, imageID, size), profileimage.ContentType, versions[size]); err != nil {
+			p.remove(ctx, userID, &imageID)
+			return "", err
+		}
+	}
+	return imageID, nil
+}
+
+// remove apaga as versões da foto. Falhas vão para o log: a foto já não é
+// referenciada e sai de vez na exclusão da conta, pelo prefixo do usuário.
+func (p *ProfileImages) remove(ctx context.Context, userID string, imageID *string) {
+	if imageID == nil {
+		return
+	}
+	for _, size := range profileimage.Sizes {
+		if err := p.store.Delete(ctx, models.ProfileImageKey(userID, *imageID, size)); err != nil {
+			log.Printf("Erro ao remover foto de perfil %s do usuário %s: %v", *imageID, userID, err)
+		}
+	}
+}
+
+// profileETag é o ETag do perfil: a versão e, com foto, o vencimento dos links,
+// para que a revalidação traga links novos. O If-Match compara só a versão.
+func profileETag(user *models.User, expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return versionETag(user.Version)
+	}
+	return `"` + strconv.Itoa(user.Version) + "-" + strconv.FormatInt(expiresAt.Unix(), 10) + `"`
+}
+
+// respondProfile preenche os links da foto e responde o perfil com os
+// validadores; no GET, responde 304 se o If-None-Match corresponder
+func (h *UserHandler) respondProfile(c *gin.Context, status int, user *models.User) {
+	expiresAt, err := h.images.present(user)
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+	etag := profileETag(user, expiresAt)
+	setValidators(c, etag, user.UpdatedAt)
+	if c.Request.Method == http.MethodGet && notModified(c, etag) {
+		return
+	}
+	c.JSON(status, user)
+}
+
+// UploadProfileImage recebe a foto no campo image de um formulário multipart,
+// gera as versões padrão e troca a foto atual. O If-Match segue a regra do
+// PUT /users/profile.
+func (h *UserHandler) UploadProfileImage(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	data, problem := h.readProfileImage(c)
+	if problem != nil {
+		apierror.AbortWith(c, problem)
+		return
+	}
+	versions, err := profileimage.Process(data)
+	if err != nil {
+		apierror.AbortWith(c, profileImageProblem(err))
+		return
+	}
+
+	// As versões são gravadas antes da transação: o perfil só passa a apontar
+	// para a foto nova quando ela já existe no armazenamento
+	imageID, err := h.images.put(c.Request.Context(), userID.(string), versions)
+	if err != nil {
+		log.Printf("Erro ao gravar foto de perfil do usuário %s: %v", userID, err)
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	user, updatedUser, err := h.setProfileImage(c, userID.(string), &imageID)
+	if err != nil {
+		h.images.remove(c.Request.Context(), userID.(string), &imageID)
+		h.abortProfileImage(c, err)
+		return
+	}
+	h.images.remove(c.Request.Context(), userID.(string), user.ProfileImageID)
+
+	h.auditProfileImage(c, user, updatedUser)
+	h.respondProfile(c, http.StatusOK, updatedUser)
+}
+
+// DeleteProfileImage remove a foto de perfil. Sem foto, responde o perfil sem
+// alterá-lo.
+func (h *UserHandler) DeleteProfileImage(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	user, updatedUser, err := h.setProfileImage(c, userID.(string), nil)
+	if err != nil {
+		h.abortProfileImage(c, err)
+		return
+	}
+	if user != updatedUser {
+		h.images.remove(c.Request.Context(), userID.(string), user.ProfileImageID)
+		h.auditProfileImage(c, user, updatedUser)
+	}
+
+	h.respondProfile(c, http.StatusOK, updatedUser)
+}
+
+// GetImage entrega um objeto do armazenamento local pelo link assinado gerado
+// em present. Com o MinIO os links vão direto ao bucket e a rota responde 404.
+func (h *UserHandler) GetImage(c *gin.Context) {
+	store, ok := h.images.store.(*blob.FileStore)
+	if !ok {
+		apierror.Abort(c, apierror.CodeImageNotFound)
+		return
+	}
+
+	key := c.Param("key")
+	if !store.Verify(key, c.Query("expires"), c.Query("signature")) {
+		apierror.Abort(c, apierror.CodeImageLinkInvalid)
+		return
+	}
+	data, err := store.Get(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			apierror.Abort(c, apierror.CodeImageNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	// O conteúdo de uma chave nunca muda: pode ficar em cache até o link vencer
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if maxAge := expires - h.images.now().Unix(); maxAge > 0 {
+		c.Header("Cache-Control", "private, max-age="+strconv.FormatInt(maxAge, 10)+", immutable")
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, mime.TypeByExtension(path.Ext(key)), data)
+}
+
+// readProfileImage lê a foto do campo image, sem aceitar mais que o limite
+// configurado
+func (h *UserHandler) readProfileImage(c *gin.Context) ([]byte, *apierror.Problem) {
+	if !strings.EqualFold(c.ContentType(), "multipart/form-data") {
+		return nil, apierror.New(apierror.CodeUnsupportedMedia).With("accepted", []string{"multipart/form-data"})
+	}
+	tooLarge := apierror.New(apierror.CodeImageInvalid).WithDetail(imageTooLarge).With("max_bytes", h.images.maxSize)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(h.images.maxSize)+multipartOverhead)
+	file, _, err := c.Request.FormFile("image")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, tooLarge
+		}
+		return nil, apierror.New(apierror.CodeImageInvalid).WithDetail(imageMissing)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, int64(h.images.maxSize)+1))
+	if err != nil {
+		return nil, apierror.New(apierror.CodeImageInvalid).WithDetail(imageCorrupt)
+	}
+	if len(data) > h.images.maxSize {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
+// profileImageProblem converte os erros de profileimage.Process na resposta
+func profileImageProblem(err error) *apierror.Problem {
+	p := apierror.New(apierror.CodeImageInvalid)
+	switch {
+	case errors.Is(err, profileimage.ErrUnsupportedType):
+		return apierror.New(apierror.CodeUnsupportedMedia).With("accepted", profileimage.AcceptedTypes)
+	case errors.Is(err, profileimage.ErrTooSmall):
+		return p.WithDetail(imageTooSmall)
+	case errors.Is(err, profileimage.ErrTooLarge):
+		return p.WithDetail(imageTooManyPixels)
+	}
+	return p.WithDetail(imageCorrupt)
+}
+
+// setProfileImage troca a foto na mesma transação da leitura, conferindo o
+// If-Match. Retorna o mesmo ponteiro em user e updatedUser quando nada mudou.
+func (h *UserHandler) setProfileImage(c *gin.Context, userID string, imageID *string) (*models.User, *models.User, error) {
+	var user, updatedUser *models.User
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if user, err = h.userRepo.GetByID(ctx, userID); err != nil {
+			return err
+		}
+		version, err := checkIfMatch(c, user.Version)
+		if err != nil {
+			return err
+		}
+		if imageID == nil && user.ProfileImageID == nil {
+			updatedUser = user
+			return nil
+		}
+		if err = h.userRepo.SetProfileImage(ctx, userID, imageID, version); err != nil {
+			return err
+		}
+		updatedUser, err = h.userRepo.GetByID(ctx, userID)
+		return err
+	})
+	return user, updatedUser, err
+}
+
+func (h *UserHandler) abortProfileImage(c *gin.Context, err error) {
+	if abortPrecondition(c, err) {
+		return
+	}
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.CodeUserNotFound)
+		return
+	}
+	apierror.Abort(c, apierror.CodeInternal)
+}
+
+// auditProfileImage registra a troca pelo identificador da foto; os links não
+// entram no log porque vencem
+func (h *UserHandler) auditProfileImage(c *gin.Context, user, updatedUser *models.User) {
+	e := audit.FromContext(c, audit.ActionProfileUpdated).SetTarget(audit.TargetUser, user.ID)
+	e.Changes = audit.Diff(
+		map[string]any{"profile_image_id": user.ProfileImageID},
+		map[string]any{"profile_image_id": updatedUser.ProfileImageID},
+	)
+	h.auditLog.Log(e)
+}
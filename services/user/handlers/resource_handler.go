@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/database"
+)
+
+// resourcesCacheControl permite ao cliente reusar o diretório por 5 minutos sem
+// revalidar; a cópia guardada continua servindo offline depois disso
+const resourcesCacheControl = "public, max-age=300"
+
+type ResourceHandler struct {
+	resourceRepo repository.ResourceStore
+	tx           database.Transactor
+	auditLog     *audit.Logger
+}
+
+func NewResourceHandler(resourceRepo repository.ResourceStore, tx database.Transactor, auditLog *audit.Logger) *ResourceHandler {
+	return &ResourceHandler{
+		resourceRepo: resourceRepo,
+		tx:           tx,
+		auditLog:     auditLog,
+	}
+}
+
+// resourcesVersion identifica o conteúdo da lista: muda quando um recurso dela
+// é criado, alterado ou removido
+func resourcesVersion(resources []*models.CrisisResource) string {
+	h := sha256.New()
+	for _, resource := range resources {
+		fmt.Fprintf(h, "%s:%d\n", resource.ID, resource.Version)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// GetResources lista os recursos de crise da região, dos mais prioritários aos
+// menos. A versão da lista também vem no corpo, para o cliente comparar com a
+// cópia guardada para uso offline.
+func (h *ResourceHandler) GetResources(c *gin.Context) {
+	var filter models.ResourceFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.AbortBinding(c, err)
+		return
+	}
+	filter.State = strings.ToUpper(filter.State)
+
+	listed, err := h.resourceRepo.List(c.Request.Context(), &filter)
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	now := time.Now()
+	resources := []*models.CrisisResource{}
+	var lastModified time.Time
+	for _, resource := range listed {
+		if filter.Language != "" && !resource.SpeaksLanguage(filter.Language) {
+			continue
+		}
+		if filter.OpenNow && !resource.IsOpen(now) {
+			continue
+		}
+		resources = append(resources, resource)
+		if resource.UpdatedAt.After(lastModified) {
+			lastModified = resource.UpdatedAt
+		}
+	}
+
+	version := resourcesVersion(resources)
+	etag := `W/"` + version + `"`
+	setValidators(c, etag, lastModified)
+	// O diretório é igual para todos; filtrado pelo horário, o resultado muda
+	// com o relógio e precisa ser revalidado
+	if filter.OpenNow {
+		c.Header("Cache-Control", "public, no-cache")
+	} else {
+		c.Header("Cache-Control", resourcesCacheControl)
+	}
+	if notModified(c, etag) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"version": version, "resources": resources})
+}
+
+func (h *ResourceHandler) GetResource(c *gin.Context) {
+	resource, err := h.resourceRepo.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeResourceNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	etag := versionETag(resource.Version)
+	setValidators(c, etag, resource.UpdatedAt)
+	c.Header("Cache-Control", resourcesCacheControl)
+	if notModified(c, etag) {
+		return
+	}
+
+	c.JSON(http.StatusOK, resource)
+}
+
+// bindResource lê o pedido e normaliza o estado para maiúsculas, como no filtro
+// da listagem. Recursos 24 horas não guardam horários.
+func bindResource(c *gin.Context) (*models.ResourceRequest, bool) {
+	var req models.ResourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.AbortBinding(c, err)
+		return nil, false
+	}
+	if req.State != nil {
+		state := strings.ToUpper(*req.State)
+		req.State = &state
+	}
+	if req.AlwaysOpen {
+		req.Hours = nil
+	}
+	return &req, true
+}
+
+func (h *ResourceHandler) CreateResource(c *gin.Context) {
+	req, ok := bindResource(c)
+	if !ok {
+		return
+	}
+
+	resource, err := h.resourceRepo.Create(c.Request.Context(), req)
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	e := audit.FromContext(c, audit.ActionResourceCreated).SetTarget(audit.TargetResource, resource.ID)
+	e.Changes = audit.Diff(nil, resource)
+	h.auditLog.Log(e)
+
+	setValidators(c, versionETag(resource.Version), resource.UpdatedAt)
+	c.JSON(http.StatusCreated, resource)
+}
+
+func (h *ResourceHandler) UpdateResource(c *gin.Context) {
+	req, ok := bindResource(c)
+	if !ok {
+		return
+	}
+
+	resourceID := c.Param("id")
+	var resource, updatedResource *models.CrisisResource
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if resource, err = h.resourceRepo.GetByID(ctx, resourceID); err != nil {
+			return err
+		}
+		version, err := checkIfMatch(c, resource.Version)
+		if err != nil {
+			return err
+		}
+		if err = h.resourceRepo.Update(ctx, resourceID, req, version); err != nil {
+			return err
+		}
+		updatedResource, err = h.resourceRepo.GetByID(ctx, resourceID)
+		return err
+	})
+	if err != nil {
+		if abortPrecondition(c, err) {
+			return
+		}
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeResourceNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	e := audit.FromContext(c, audit.ActionResourceUpdated).SetTarget(audit.TargetResource, resourceID)
+	e.Changes = audit.Diff(resource, updatedResource)
+	h.auditLog.Log(e)
+
+	setValidators(c, versionETag(updatedResource.Version), updatedResource.UpdatedAt)
+	c.JSON(http.StatusOK, updatedResource)
+}
+
+func (h *ResourceHandler) DeleteResource(c *gin.Context) {
+	resourceID := c.Param("id")
+	var resource *models.CrisisResource
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if resource, err = h.resourceRepo.GetByID(ctx, resourceID); err != nil {
+			return err
+		}
+		version, err := checkIfMatch(c, resource.Version)
+		if err != nil {
+			return err
+		}
+		return h.resourceRepo.Delete(ctx, resourceID, version)
+	})
+	if err != nil {
+		if abortPrecondition(c, err) {
+			return
+		}
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeResourceNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	e := audit.FromContext(c, audit.ActionResourceDeleted).SetTarget(audit.TargetResource, resourceID)
+	e.Changes = audit.Diff(resource, nil)
+	h.auditLog.Log(e)
+
+	c.JSON(http.StatusOK, gin.H{"message": message(c, "resource.deleted")})
+}
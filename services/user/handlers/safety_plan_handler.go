@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/services/user/safetyplan"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/database"
+	"github.com/meuapoio/shared/i18n"
+	"github.com/meuapoio/shared/sms"
+)
+
+// safetyPlanFilename é o nome sugerido ao baixar o PDF do plano
+const safetyPlanFilename = "meuapoio-plano-de-seguranca.pdf"
+
+var (
+	// errContactNotAccepted interrompe o compartilhamento com quem não aceitou o convite
+	errContactNotAccepted = errors.New("contato não aceitou o convite")
+	// errSafetyPlanNotFound distingue a falta do plano da falta do contato
+	errSafetyPlanNotFound = errors.New("plano de segurança não encontrado")
+)
+
+type SafetyPlanHandler struct {
+	planRepo    repository.SafetyPlanStore
+	contactRepo repository.ContactStore
+	userRepo    repository.UserStore
+	tx          database.Transactor
+	auditLog    *audit.Logger
+	smsSender   sms.Sender
+	shareURL    string
+	shareTTL    time.Duration
+}
+
+// NewSafetyPlanHandler cria o handler. Cada compartilhamento envia ao contato
+// por smsSender o link shareURL/<token>, válido por shareTTL.
+func NewSafetyPlanHandler(planRepo repository.SafetyPlanStore, contactRepo repository.ContactStore, userRepo repository.UserStore, tx database.Transactor, auditLog *audit.Logger, smsSender sms.Sender, shareURL string, shareTTL time.Duration) *SafetyPlanHandler {
+	return &SafetyPlanHandler{
+		planRepo:    planRepo,
+		contactRepo: contactRepo,
+		userRepo:    userRepo,
+		tx:          tx,
+		auditLog:    auditLog,
+		smsSender:   smsSender,
+		shareURL:    shareURL,
+		shareTTL:    shareTTL,
+	}
+}
+
+// canonicalSections devolve as sete seções na ordem de models.SafetySections,
+// com as omitidas vazias, sem os campos que só existem nas respostas
+func canonicalSections(sections []models.SafetyPlanSection) []models.SafetyPlanSection {
+	items := make(map[string][]models.SafetyPlanItem, len(sections))
+	for _, section := range sections {
+		items[section.Type] = section.Items
+	}
+
+	canonical := make([]models.SafetyPlanSection, len(models.SafetySections))
+	for i, sectionType := range models.SafetySections {
+		sectionItems := make([]models.SafetyPlanItem, len(items[sectionType]))
+		for j, item := range items[sectionType] {
+			item.ContactName, item.ContactPhone = nil, nil
+			sectionItems[j] = item
+		}
+		canonical[i] = models.SafetyPlanSection{Type: sectionType, Items: sectionItems}
+	}
+	return canonical
+}
+
+// checkPlanContacts responde VALIDATION_FAILED, regra contact, para itens que
+// citam um contato que não é do usuário
+func checkPlanContacts(c *gin.Context, sections []models.SafetyPlanSection, contacts []*models.EmergencyContact) bool {
+	owned := make(map[string]bool, len(contacts))
+	for _, contact := range contacts {
+		owned[contact.ID] = true
+	}
+
+	var problem *apierror.Problem
+	for i, section := range sections {
+		for j, item := range section.Items {
+			if item.ContactID == nil || owned[*item.ContactID] {
+				continue
+			}
+			if problem == nil {
+				problem = apierror.New(apierror.CodeValidationFailed)
+			}
+			problem.WithField(fmt.Sprintf("sections[%d].items[%d].contact_id", i, j), "contact", contactNotOwned)
+		}
+	}
+	if problem != nil {
+		apierror.AbortWith(c, problem)
+		return false
+	}
+	return true
+}
+
+// fillContacts completa os itens que citam contatos com o nome e o telefone
+// atuais. Contatos removidos depois de citados ficam só com o texto do item.
+func fillContacts(plan *models.SafetyPlan, contacts []*models.EmergencyContact) {
+	byID := make(map[string]*models.EmergencyContact, len(contacts))
+	for _, contact := range contacts {
+		byID[contact.ID] = contact
+	}
+	for _, section := range plan.Sections {
+		for i := range section.Items {
+			item := &section.Items[i]
+			if item.ContactID == nil {
+				continue
+			}
+			if contact, ok := byID[*item.ContactID]; ok {
+				name, phone := contact.Name, contact.PhoneDisplay
+				item.ContactName, item.ContactPhone = &name, &phone
+			}
+		}
+	}
+}
+
+// loadContacts busca os contatos do dono do plano e completa os itens
+func (h *SafetyPlanHandler) loadContacts(ctx context.Context, userID string, plan *models.SafetyPlan) error {
+	contacts, err := h.contactRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	fillContacts(plan, contacts)
+	return nil
+}
+
+// GetSafetyPlan retorna o plano vigente. Não há 304: o nome e o telefone dos
+// contatos citados podem mudar sem que mude a versão do plano.
+func (h *SafetyPlanHandler) GetSafetyPlan(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	ctx := c.Request.Context()
+	plan, err := h.planRepo.Get(ctx, userID.(string))
+	if err == nil {
+		err = h.loadContacts(ctx, userID.(string), plan)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeSafetyPlanNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	setValidators(c, versionETag(plan.Version), plan.UpdatedAt)
+	c.JSON(http.StatusOK, plan)
+}
+
+// SaveSafetyPlan cria o plano ou substitui o vigente, gerando uma nova versão no
+// histórico. Responde 201 na criação.
+func (h *SafetyPlanHandler) SaveSafetyPlan(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	var req models.SafetyPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.AbortBinding(c, err)
+		return
+	}
+
+	// Um contato removido depois desta leitura só deixa o item sem nome e telefone
+	contacts, err := h.contactRepo.GetByUserID(c.Request.Context(), userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+	if !checkPlanContacts(c, req.Sections, contacts) {
+		return
+	}
+
+	var created bool
+	var plan *models.SafetyPlan
+	err = h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		// Sem plano, a versão atual é 0: If-Match: * cria o plano normalmente
+		current := 0
+		existing, err := h.planRepo.Get(ctx, userID.(string))
+		switch {
+		case err == sql.ErrNoRows:
+			created = true
+		case err != nil:
+			return err
+		default:
+			current = existing.Version
+		}
+		version, err := checkIfMatch(c, current)
+		if err != nil {
+			return err
+		}
+		plan, err = h.planRepo.Save(ctx, userID.(string), canonicalSections(req.Sections), version)
+		return err
+	})
+	if err != nil {
+		if abortPrecondition(c, err) {
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	// O conteúdo do plano é dado de saúde: o evento registra só a nova versão
+	e := audit.FromContext(c, audit.ActionSafetyPlanUpdated).SetTarget(audit.TargetSafetyPlan, userID.(string))
+	e.Changes = map[string]audit.Change{"version": {After: plan.Version}}
+	h.auditLog.Log(e)
+
+	fillContacts(plan, contacts)
+	setValidators(c, versionETag(plan.Version), plan.UpdatedAt)
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, plan)
+}
+
+// DeleteSafetyPlan apaga o plano, o histórico e os links de compartilhamento
+func (h *SafetyPlanHandler) DeleteSafetyPlan(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		plan, err := h.planRepo.Get(ctx, userID.(string))
+		if err != nil {
+			return err
+		}
+		version, err := checkIfMatch(c, plan.Version)
+		if err != nil {
+			return err
+		}
+		return h.planRepo.Delete(ctx, userID.(string), version)
+	})
+	if err != nil {
+		if abortPrecondition(c, err) {
+			return
+		}
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeSafetyPlanNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	h.auditLog.Log(audit.FromContext(c, audit.ActionSafetyPlanDeleted).SetTarget(audit.TargetSafetyPlan, userID.(string)))
+
+	c.JSON(http.StatusOK, gin.H{"message": message(c, "safety_plan.deleted")})
+}
+
+// ListRevisions lista as versões do histórico, da mais recente à mais antiga
+func (h *SafetyPlanHandler) ListRevisions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	revisions, err := h.planRepo.ListRevisions(c.Request.Context(), userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+// GetRevision retorna o plano como estava numa versão do histórico
+func (h *SafetyPlanHandler) GetRevision(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		apierror.AbortWith(c, apierror.Field("version", "min", revisionVersionInvalid))
+		return
+	}
+
+	ctx := c.Request.Context()
+	plan, err := h.planRepo.GetRevision(ctx, userID.(string), version)
+	if err == nil {
+		err = h.loadContacts(ctx, userID.(string), plan)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeSafetyPlanNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// ExportPDF gera o plano vigente em PDF para impressão, no idioma da requisição
+func (h *SafetyPlanHandler) ExportPDF(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	ctx := c.Request.Context()
+	plan, err := h.planRepo.Get(ctx, userID.(string))
+	if err == nil {
+		err = h.loadContacts(ctx, userID.(string), plan)
+	}
+	var user *models.User
+	if err == nil {
+		user, err = h.userRepo.GetByID(ctx, userID.(string))
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeSafetyPlanNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	h.writePDF(c, plan, displayName(user))
+}
+
+func (h *SafetyPlanHandler) writePDF(c *gin.Context, plan *models.SafetyPlan, ownerName string) {
+	var buf bytes.Buffer
+	if err := safetyplan.Render(&buf, plan, ownerName, i18n.Language(c)); err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", safetyPlanFilename))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// displayName é o nome do usuário nas mensagens e no PDF: o nome completo ou,
+// sem ele, o username
+func displayName(user *models.User) string {
+	if user.FullName != nil && *user.FullName != "" {
+		return *user.FullName
+	}
+	return user.Username
+}
+
+// ShareSafetyPlan envia a um contato que aceitou o convite um link de leitura
+// do plano vigente, válido por shareTTL. O link acompanha as alterações
+// posteriores do plano até vencer ou ser revogado.
+func (h *SafetyPlanHandler) ShareSafetyPlan(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	var req models.ShareSafetyPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.AbortBinding(c, err)
+		return
+	}
+
+	token, hash, err := newInvitationToken()
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	var user *models.User
+	var contact *models.EmergencyContact
+	var share *models.SafetyPlanShare
+	err = h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if _, err = h.planRepo.Get(ctx, userID.(string)); err != nil {
+			if err == sql.ErrNoRows {
+				return errSafetyPlanNotFound
+			}
+			return err
+		}
+		if contact, err = h.contactRepo.GetByID(ctx, req.ContactID, userID.(string)); err != nil {
+			return err
+		}
+		if contact.Status != models.ContactStatusAccepted {
+			return errContactNotAccepted
+		}
+		if user, err = h.userRepo.GetByID(ctx, userID.(string)); err != nil {
+			return err
+		}
+		share, err = h.planRepo.CreateShare(ctx, userID.(string), contact.ID, hash, time.Now().Add(h.shareTTL))
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errSafetyPlanNotFound):
+			apierror.Abort(c, apierror.CodeSafetyPlanNotFound)
+		case err == sql.ErrNoRows:
+			apierror.Abort(c, apierror.CodeContactNotFound)
+		case errors.Is(err, errContactNotAccepted):
+			apierror.Abort(c, apierror.CodeContactNotAccepted)
+		default:
+			apierror.Abort(c, apierror.CodeInternal)
+		}
+		return
+	}
+
+	// Uma falha no envio não desfaz o link: o usuário pode revogá-lo e compartilhar de novo
+	link := strings.TrimSuffix(h.shareURL, "/") + "/" + token
+	body := fmt.Sprintf(message(c, "safety_plan.share_sms"), displayName(user), link)
+	if err := h.smsSender.Send(c.Request.Context(), contact.Phone, body); err != nil {
+		log.Printf("Erro ao enviar link do plano de segurança %s: %v", share.ID, err)
+	}
+
+	e := audit.FromContext(c, audit.ActionSafetyPlanShared).SetTarget(audit.TargetSafetyPlan, userID.(string))
+	e.Changes = map[string]audit.Change{"share_id": {After: share.ID}, "contact_id": {After: contact.ID}}
+	h.auditLog.Log(e)
+
+	c.JSON(http.StatusCreated, share)
+}
+
+// ListShares lista os links criados, inclusive os vencidos e revogados
+func (h *SafetyPlanHandler) ListShares(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	shares, err := h.planRepo.ListShares(c.Request.Context(), userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": shares})
+}
+
+// RevokeShare encerra um link antes do vencimento
+func (h *SafetyPlanHandler) RevokeShare(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	shareID := c.Param("id")
+	if err := h.planRepo.RevokeShare(c.Request.Context(), shareID, userID.(string), time.Now()); err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeSafetyPlanShareNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	e := audit.FromContext(c, audit.ActionSafetyPlanShareRevoked).SetTarget(audit.TargetSafetyPlan, userID.(string))
+	e.Changes = map[string]audit.Change{"share_id": {Before: shareID}}
+	h.auditLog.Log(e)
+
+	c.JSON(http.StatusOK, gin.H{"message": message(c, "safety_plan.share_revoked")})
+}
+
+// viewShared abre o plano pelo token do link, com os contatos citados
+// completados, ou responde SAFETY_PLAN_SHARE_NOT_FOUND
+func (h *SafetyPlanHandler) viewShared(c *gin.Context) (*models.SharedSafetyPlan, bool) {
+	ctx := c.Request.Context()
+	shared, err := h.planRepo.ViewShared(ctx, hashInvitationToken(c.Param("token")), time.Now())
+	if err == nil {
+		err = h.loadContacts(ctx, shared.UserID, shared.Plan)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeSafetyPlanShareNotFound)
+			return nil, false
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return nil, false
+	}
+	return shared, true
+}
+
+// GetSharedPlan mostra o plano ao contato. As rotas de compartilhamento são
+// públicas: o token do link é a credencial.
+func (h *SafetyPlanHandler) GetSharedPlan(c *gin.Context) {
+	shared, ok := h.viewShared(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, shared)
+}
+
+// GetSharedPlanPDF gera o PDF do plano compartilhado, no idioma de quem o abre
+func (h *SafetyPlanHandler) GetSharedPlanPDF(c *gin.Context) {
+	shared, ok := h.viewShared(c)
+	if !ok {
+		return
+	}
+
+	h.writePDF(c, shared.Plan, shared.OwnerName)
+}
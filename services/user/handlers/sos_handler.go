@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/services/user/sos"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/i18n"
+)
+
+type SOSHandler struct {
+	alertRepo         repository.AlertStore
+	contactRepo       repository.ContactStore
+	dispatcher        *sos.Dispatcher
+	auditLog          *audit.Logger
+	escalationTimeout time.Duration
+	maxPerHour        int
+}
+
+// NewSOSHandler cria o handler. Sem confirmação do contato principal em
+// escalationTimeout, o dispatcher avisa os demais contatos; cada usuário aciona
+// no máximo maxPerHour alertas por hora.
+func NewSOSHandler(alertRepo repository.AlertStore, contactRepo repository.ContactStore, dispatcher *sos.Dispatcher, auditLog *audit.Logger, escalationTimeout time.Duration, maxPerHour int) *SOSHandler {
+	return &SOSHandler{
+		alertRepo:         alertRepo,
+		contactRepo:       contactRepo,
+		dispatcher:        dispatcher,
+		auditLog:          auditLog,
+		escalationTimeout: escalationTimeout,
+		maxPerHour:        maxPerHour,
+	}
+}
+
+// TriggerSOS aciona um alerta para os contatos que aceitaram o convite. Enquanto
+// houver um alerta ativo, repetir o acionamento retorna o mesmo alerta sem
+// enviar novos avisos.
+func (h *SOSHandler) TriggerSOS(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	// O corpo é opcional: num pedido de ajuda a localização pode não estar disponível
+	var req models.SOSRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.AbortBinding(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if h.respondActive(c, userID.(string)) {
+		return
+	}
+
+	now := time.Now()
+	recent, err := h.alertRepo.ListCreatedSince(ctx, userID.(string), now.Add(-time.Hour))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+	if len(recent) >= h.maxPerHour {
+		retryAfter := recent[len(recent)-h.maxPerHour].Add(time.Hour).Sub(now)
+		seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+		c.Header("Retry-After", seconds)
+		apierror.AbortWith(c, apierror.New(apierror.CodeRateLimited).With("retry_after", seconds+"s"))
+		return
+	}
+
+	contacts, err := h.contactRepo.GetAccepted(ctx, userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+	if len(contacts) == 0 {
+		apierror.Abort(c, apierror.CodeSOSNoContacts)
+		return
+	}
+
+	alert := &models.Alert{
+		UserID:    userID.(string),
+		Location:  req.Location,
+		Language:  i18n.Language(c),
+		CreatedAt: now,
+	}
+	// Só há para quem escalonar com mais de um contato
+	if len(contacts) > 1 {
+		escalatesAt := now.Add(h.escalationTimeout)
+		alert.EscalatesAt = &escalatesAt
+	}
+	if err := h.alertRepo.Create(ctx, alert, contacts); err != nil {
+		if errors.Is(err, repository.ErrAlertActive) && h.respondActive(c, userID.(string)) {
+			return
+		}
+		if abortInactive(c, err) {
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	h.auditLog.Log(audit.FromContext(c, audit.ActionSOSTriggered).SetTarget(audit.TargetSOSAlert, alert.ID))
+
+	// O envio não depende do cliente continuar conectado; uma falha fica no log e
+	// o escalonamento segue avisando os demais contatos
+	if _, err := h.dispatcher.Notify(context.WithoutCancel(ctx), alert.ID, models.AlertLevelPrimary); err != nil {
+		log.Printf("Erro ao avisar contatos do alerta %s: %v", alert.ID, err)
+	}
+
+	created, err := h.alertRepo.GetByID(ctx, alert.ID, userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// respondActive responde com o alerta ativo do usuário, se houver
+func (h *SOSHandler) respondActive(c *gin.Context, userID string) bool {
+	active, err := h.alertRepo.GetActive(c.Request.Context(), userID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeInternal)
+			return true
+		}
+		return false
+	}
+	c.JSON(http.StatusOK, active)
+	return true
+}
+
+// GetAlerts lista os alertas do usuário, do mais recente ao mais antigo
+func (h *SOSHandler) GetAlerts(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	alerts, err := h.alertRepo.ListByUserID(c.Request.Context(), userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+// GetAlert mostra o alerta com a situação do aviso a cada contato
+func (h *SOSHandler) GetAlert(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	alert, err := h.alertRepo.GetByID(c.Request.Context(), c.Param("id"), userID.(string))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeSOSAlertNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// CancelAlert encerra o alerta, inclusive depois de confirmado, e avisa do
+// cancelamento os contatos que já tinham recebido o alerta
+func (h *SOSHandler) CancelAlert(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
+		return
+	}
+
+	ctx := c.Request.Context()
+	alertID := c.Param("id")
+	if err := h.alertRepo.Cancel(ctx, alertID, userID.(string), time.Now()); err != nil {
+		if err != sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeInternal)
+			return
+		}
+		// Sem linhas afetadas: o alerta não existe ou já foi cancelado
+		_, err := h.alertRepo.GetByID(ctx, alertID, userID.(string))
+		switch {
+		case err == sql.ErrNoRows:
+			apierror.Abort(c, apierror.CodeSOSAlertNotFound)
+		case err != nil:
+			apierror.Abort(c, apierror.CodeInternal)
+		default:
+			apierror.Abort(c, apierror.CodeSOSAlertClosed)
+		}
+		return
+	}
+
+	h.auditLog.Log(audit.FromContext(c, audit.ActionSOSCancelled).SetTarget(audit.TargetSOSAlert, alertID))
+	if err := h.dispatcher.NotifyCancelled(context.WithoutCancel(ctx), alertID); err != nil {
+		log.Printf("Erro ao avisar cancelamento do alerta %s: %v", alertID, err)
+	}
+
+	alert, err := h.alertRepo.GetByID(ctx, alertID, userID.(string))
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// GetAlertNotice mostra o alerta ao contato que abriu o link do aviso. Como nos
+// convites, as rotas são públicas e o token do link é a credencial.
+func (h *SOSHandler) GetAlertNotice(c *gin.Context) {
+	notice, err := h.alertRepo.GetNotice(c.Request.Context(), sos.HashToken(c.Param("token")), time.Now().Add(-sos.AckLinkTTL))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeSOSAlertNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, notice)
+}
+
+// AcknowledgeAlert registra que o contato viu o alerta; a primeira confirmação
+// interrompe o escalonamento
+func (h *SOSHandler) AcknowledgeAlert(c *gin.Context) {
+	now := time.Now()
+	notice, err := h.alertRepo.Acknowledge(c.Request.Context(), sos.HashToken(c.Param("token")), now.Add(-sos.AckLinkTTL), now)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeSOSAlertNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
+		return
+	}
+
+	h.auditLog.Log(audit.FromContext(c, audit.ActionSOSAcknowledged).SetTarget(audit.TargetSOSAlert, notice.AlertID))
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, notice)
+}
@@ -1,105 +1,187 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/meuapoio/services/user/models"
 	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/database"
 )
 
 type UserHandler struct {
-	userRepo *repository.UserRepository
+	userRepo            repository.UserStore
+	tx                  database.Transactor
+	auditLog            *audit.Logger
+	images              *ProfileImages
+	deletionGracePeriod time.Duration
+	phoneRegion         string
 }
 
-func NewUserHandler(userRepo *repository.UserRepository) *UserHandler {
-	return &UserHandler{userRepo: userRepo}
+func NewUserHandler(userRepo repository.UserStore, tx database.Transactor, auditLog *audit.Logger, images *ProfileImages, deletionGracePeriod time.Duration, phoneRegion string) *UserHandler {
+	return &UserHandler{
+		userRepo:            userRepo,
+		tx:                  tx,
+		auditLog:            auditLog,
+		images:              images,
+		deletionGracePeriod: deletionGracePeriod,
+		phoneRegion:         phoneRegion,
+	}
 }
 
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
 		return
 	}
 
-	user, err := h.userRepo.GetByID(userID.(string))
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID.(string))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+			apierror.Abort(c, apierror.CodeUserNotFound)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro interno do servidor"})
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	h.respondProfile(c, http.StatusOK, user)
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
 		return
 	}
 
 	var req models.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.AbortBinding(c, err)
+		return
+	}
+	if !normalizePhone(c, req.Phone, h.phoneRegion) {
 		return
 	}
 
-	// Verificar se usuário existe
-	_, err := h.userRepo.GetByID(userID.(string))
+	// Leitura, atualização e releitura na mesma transação, para que o diff
+	// auditado corresponda exatamente à alteração feita
+	var user, updatedUser *models.User
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if user, err = h.userRepo.GetByID(ctx, userID.(string)); err != nil {
+			return err
+		}
+		version, err := checkIfMatch(c, user.Version)
+		if err != nil {
+			return err
+		}
+		if err = h.userRepo.Update(ctx, userID.(string), &req, version); err != nil {
+			return err
+		}
+		updatedUser, err = h.userRepo.GetByID(ctx, userID.(string))
+		return err
+	})
 	if err != nil {
+		if abortPrecondition(c, err) {
+			return
+		}
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+			apierror.Abort(c, apierror.CodeUserNotFound)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro interno do servidor"})
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
-	// Atualizar usuário
-	if err := h.userRepo.Update(userID.(string), &req); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar usuário"})
+	e := audit.FromContext(c, audit.ActionProfileUpdated).SetTarget(audit.TargetUser, user.ID)
+	e.Changes = audit.Diff(user, updatedUser)
+	h.auditLog.Log(e)
+
+	h.respondProfile(c, http.StatusOK, updatedUser)
+}
+
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
 		return
 	}
 
-	// Buscar usuário atualizado
-	updatedUser, err := h.userRepo.GetByID(userID.(string))
+	// Agendar a exclusão definitiva; um novo login dentro do prazo cancela o pedido
+	scheduledFor := time.Now().Add(h.deletionGracePeriod)
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		user, err := h.userRepo.GetByID(ctx, userID.(string))
+		if err != nil {
+			return err
+		}
+		version, err := checkIfMatch(c, user.Version)
+		if err != nil {
+			return err
+		}
+		return h.userRepo.ScheduleDeletion(ctx, userID.(string), scheduledFor, version)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuário atualizado"})
+		if abortPrecondition(c, err) {
+			return
+		}
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.CodeUserNotFound)
+			return
+		}
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
-	c.JSON(http.StatusOK, updatedUser)
+	h.auditLog.Log(audit.FromContext(c, audit.ActionAccountDeletionRequested).SetTarget(audit.TargetUser, userID.(string)))
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":                message(c, "account.deletion_scheduled"),
+		"deletion_scheduled_for": scheduledFor,
+	})
 }
 
-func (h *UserHandler) DeleteAccount(c *gin.Context) {
+// abortInactive responde ACCOUNT_INACTIVE se a conta foi desativada depois do
+// ActiveAccountMiddleware, durante a escrita
+func abortInactive(c *gin.Context, err error) bool {
+	if !errors.Is(err, repository.ErrAccountInactive) {
+		return false
+	}
+	apierror.Abort(c, apierror.CodeAccountInactive)
+	return true
+}
+
+// GetSecurityActivity lista os eventos de segurança do próprio usuário
+func (h *UserHandler) GetSecurityActivity(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		apierror.Abort(c, apierror.CodeAuthTokenMissing)
 		return
 	}
 
-	// Verificar se usuário existe
-	_, err := h.userRepo.GetByID(userID.(string))
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro interno do servidor"})
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		apierror.AbortWith(c, apierror.Field("limit", "range", limitOutOfRange))
 		return
 	}
 
-	// Soft delete do usuário
-	if err := h.userRepo.SoftDelete(userID.(string)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar conta"})
+	events, err := h.auditLog.ListByActor(c.Request.Context(), userID.(string), limit)
+	if err != nil {
+		apierror.Abort(c, apierror.CodeInternal)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Conta deletada com sucesso"})
+	if events == nil {
+		events = []*audit.Event{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
 }
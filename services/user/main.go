@@ -1,19 +1,47 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/services/user/cleanup"
+	"github.com/meuapoio/services/user/erasure"
+	"github.com/meuapoio/services/user/export"
 	"github.com/meuapoio/services/user/handlers"
+	"github.com/meuapoio/services/user/migrations"
 	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/services/user/sos"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/blob"
 	"github.com/meuapoio/shared/config"
+	"github.com/meuapoio/shared/crypto"
 	"github.com/meuapoio/shared/database"
-	sharedmw "github.com/meuapoio/shared/middleware"
+	"github.com/meuapoio/shared/email"
+	"github.com/meuapoio/shared/migrate"
+	"github.com/meuapoio/shared/sms"
 )
 
 func main() {
 	// Carregar configurações
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Falha ao carregar configuração: ", err)
+	}
+
+	// Inspecionar a configuração não exige banco nem configuração válida
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := config.Run(cfg, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal("Erro na configuração: ", err)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Configuração inválida: ", err)
+	}
 
 	// Conectar ao banco de dados
 	db, err := database.ConnectPostgres(cfg)
@@ -22,50 +50,133 @@ func main() {
 	}
 	defer db.Close()
 
+	migrator, err := migrate.New(db.DB, "user", migrations.FS)
+	if err != nil {
+		log.Fatal("Falha ao carregar migrações:", err)
+	}
+
+	// Chaves da criptografia de dados pessoais
+	keyring, err := crypto.LoadKeyring(cfg.EncryptionKeys, cfg.EncryptionActiveKeyID, cfg.BlindIndexKey)
+	if err != nil {
+		log.Fatal("Falha ao carregar chaves de criptografia:", err)
+	}
+
+	// Subcomandos administrativos
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := migrate.Run(context.Background(), migrator, os.Args[2:], os.Stdout); err != nil {
+				log.Fatal("Erro na migração: ", err)
+			}
+		case "reencrypt":
+			runReencrypt(db, keyring)
+		case "normalize-phones":
+			runNormalizePhones(db, keyring, cfg.PhoneDefaultRegion)
+		case "admin":
+			runAdmin(db, keyring, os.Args[2:])
+		case "audit":
+			runAudit(db.DB, os.Args[2:])
+		default:
+			log.Fatalf("Comando desconhecido: %s (disponíveis: config, migrate, reencrypt, normalize-phones, admin, audit)", os.Args[1])
+		}
+		return
+	}
+
+	// Aplicar migrações pendentes; o advisory lock serializa réplicas subindo juntas
+	if cfg.AutoMigrate {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatal("Falha ao aplicar migrações:", err)
+		}
+		for _, m := range applied {
+			log.Printf("Migração aplicada: %04d_%s", m.Version, m.Name)
+		}
+	}
+
 	// Inicializar repositórios
-	userRepo := repository.NewUserRepository(db)
-	contactRepo := repository.NewContactRepository(db)
+	userRepo := repository.NewUserRepository(db, keyring)
+	contactRepo := repository.NewContactRepository(db, keyring, cfg.MaxContactsPerUser)
+	exportRepo := repository.NewExportRepository(db)
+	consentRepo := repository.NewConsentRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db, keyring)
+	alertRepo := repository.NewAlertRepository(db, keyring)
+	resourceRepo := repository.NewResourceRepository(db)
+	safetyPlanRepo := repository.NewSafetyPlanRepository(db, keyring)
 
-	// Inicializar handlers
-	userHandler := handlers.NewUserHandler(userRepo)
-	contactHandler := handlers.NewContactHandler(contactRepo)
-	authHandler := handlers.NewAuthHandler(userRepo, cfg.JWTSecret)
+	// Log de auditoria de eventos de segurança
+	auditLog := audit.NewLogger(db.DB, cfg.AuditHashChain)
 
-	// Configurar Gin
-	if cfg.Environment == "production" {
-		gin.SetMode(gin.ReleaseMode)
+	// Armazenamento das fotos de perfil
+	images, err := newBlobStore(cfg)
+	if err != nil {
+		log.Fatal("Falha ao iniciar armazenamento de arquivos:", err)
 	}
-	r := gin.Default()
-
-	// Middlewares globais
-	r.Use(gin.Logger())
-	r.Use(gin.Recovery())
-
-	// Rotas públicas (sem autenticação)
-	public := r.Group("/api/v1")
-	{
-		public.POST("/auth/register", authHandler.Register)
-		public.POST("/auth/login", authHandler.Login)
-		public.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{"status": "ok", "service": "user-service"})
-		})
+
+	// Exportação de dados em background (LGPD)
+	exporter := export.NewExporter(exportRepo, userRepo, contactRepo, consentRepo, alertRepo, safetyPlanRepo, images, auditLog, cfg.ExportDir, cfg.ExportLinkTTL)
+	if err := exporter.Start(2); err != nil {
+		log.Fatal("Falha ao iniciar exportação de dados:", err)
+	}
+	defer exporter.Stop()
+
+	// Exclusão definitiva das contas após o período de carência
+	eraser := erasure.NewEraser(userRepo, exportRepo, images, auditLog, time.Hour)
+	eraser.Start()
+	defer eraser.Stop()
+
+	// Limpeza das respostas de idempotência vencidas
+	idempotencyPurger := cleanup.NewIdempotencyPurger(idempotencyRepo, time.Hour)
+	idempotencyPurger.Start()
+	defer idempotencyPurger.Stop()
+
+	// Convites, alertas de SOS e planos compartilhados para os contatos de emergência. Ainda não há
+	// provedores de SMS, WhatsApp e email integrados: as mensagens vão para o log.
+	if cfg.IsProduction() {
+		log.Println("Aviso: convites e alertas de SOS registrados no log em vez de enviados")
+	}
+	smsSender := sms.NewLogSender(nil, "SMS")
+	var sosChannels []sos.Channel
+	for _, name := range cfg.SOSChannelList() {
+		switch name {
+		case sos.ChannelSMS:
+			sosChannels = append(sosChannels, sos.NewSMSChannel(smsSender))
+		case sos.ChannelWhatsApp:
+			sosChannels = append(sosChannels, sos.NewWhatsAppChannel(sms.NewLogSender(nil, "WhatsApp")))
+		case sos.ChannelEmail:
+			sosChannels = append(sosChannels, sos.NewEmailChannel(email.NewLogSender(nil)))
+		}
 	}
 
-	// Rotas protegidas (com autenticação)
-	protected := r.Group("/api/v1")
-	protected.Use(sharedmw.AuthMiddleware(cfg))
-	{
-		// Usuários
-		protected.GET("/users/profile", userHandler.GetProfile)
-		protected.PUT("/users/profile", userHandler.UpdateProfile)
-		protected.DELETE("/users/profile", userHandler.DeleteAccount)
-
-		// Contatos de emergência
-		protected.GET("/contacts", contactHandler.GetContacts)
-		protected.POST("/contacts", contactHandler.CreateContact)
-		protected.PUT("/contacts/:id", contactHandler.UpdateContact)
-		protected.DELETE("/contacts/:id", contactHandler.DeleteContact)
+	// Escalonamento dos alertas de SOS não confirmados pelo contato principal
+	dispatcher := sos.NewDispatcher(alertRepo, sosChannels, cfg.SOSAcknowledgeURL, 15*time.Second)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	// Inicializar handlers
+	profileImages := handlers.NewProfileImages(images, cfg.ProfileImageURLTTL, cfg.ProfileImageMaxSize)
+	userHandler := handlers.NewUserHandler(userRepo, db, auditLog, profileImages, cfg.AccountDeletionGracePeriod, cfg.PhoneDefaultRegion)
+	contactHandler := handlers.NewContactHandler(contactRepo, db, auditLog, smsSender, cfg.PhoneDefaultRegion, cfg.ContactInvitationURL, cfg.ContactInvitationTTL)
+	authHandler := handlers.NewAuthHandler(userRepo, consentRepo, db, auditLog, profileImages, cfg.JWTSecret)
+	exportHandler := handlers.NewExportHandler(exportRepo, exporter, db, auditLog, cfg.JWTSecret)
+	consentHandler := handlers.NewConsentHandler(consentRepo, db, auditLog)
+	sosHandler := handlers.NewSOSHandler(alertRepo, contactRepo, dispatcher, auditLog, cfg.SOSEscalationTimeout, cfg.SOSMaxPerHour)
+	resourceHandler := handlers.NewResourceHandler(resourceRepo, db, auditLog)
+	safetyPlanHandler := handlers.NewSafetyPlanHandler(safetyPlanRepo, contactRepo, userRepo, db, auditLog, smsSender, cfg.SafetyPlanShareURL, cfg.SafetyPlanShareTTL)
+
+	// Configurar Gin
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
 	}
+	r := newRouter(cfg, routeHandlers{
+		user:       userHandler,
+		contact:    contactHandler,
+		auth:       authHandler,
+		export:     exportHandler,
+		consent:    consentHandler,
+		sos:        sosHandler,
+		resource:   resourceHandler,
+		safetyPlan: safetyPlanHandler,
+	}, routeStores{consents: consentRepo, optIns: consentRepo, accounts: userRepo, languages: userRepo, admins: userRepo, idempotency: idempotencyRepo})
 
 	// Iniciar servidor
 	port := cfg.Port
@@ -75,3 +186,22 @@ func main() {
 	log.Printf("User Service rodando na porta %s", port)
 	log.Fatal(r.Run(":" + port))
 }
+
+// newBlobStore cria o armazenamento de BLOB_BACKEND. No local, os links
+// assinados apontam para GET /api/v1/images/:key do próprio serviço.
+func newBlobStore(cfg *config.Config) (blob.Store, error) {
+	if cfg.BlobBackend == "minio" {
+		store := blob.NewS3Store(blob.S3Config{
+			Endpoint:  cfg.MinIOEndpoint,
+			AccessKey: cfg.MinIOAccessKey,
+			SecretKey: cfg.MinIOSecretKey,
+			Bucket:    cfg.MinIOBucket,
+			Region:    cfg.MinIORegion,
+			UseSSL:    cfg.MinIOUseSSL,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return store, store.EnsureBucket(ctx)
+	}
+	return blob.NewFileStore(cfg.BlobDir, "/api/v1/images", cfg.JWTSecret)
+}
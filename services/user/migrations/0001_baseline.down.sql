@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS user_play_history;
+DROP TABLE IF EXISTS user_favorites;
+DROP TABLE IF EXISTS audios;
+DROP TABLE IF EXISTS emergency_contacts;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,32 @@
+-- Tabela de auditoria de eventos de segurança (append-only)
+-- actor_id não referencia users para que o histórico sobreviva à exclusão da conta
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id UUID,
+    action VARCHAR(64) NOT NULL, -- 'auth.login', 'contact.updated', ...
+    target_type VARCHAR(50),
+    target_id VARCHAR(64),
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    request_id VARCHAR(64),
+    changes JSON, -- JSON (e não JSONB) preserva o texto exato usado no hash
+    prev_hash CHAR(64),
+    hash CHAR(64),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Impede UPDATE e DELETE em audit_events
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events é append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_audit_events_append_only ON audit_events;
+CREATE TRIGGER trg_audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events(actor_id, id DESC);
+
+COMMENT ON TABLE audit_events IS 'Log de auditoria append-only de eventos de segurança';
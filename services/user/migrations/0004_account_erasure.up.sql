@@ -0,0 +1,9 @@
+-- Exclusão definitiva agendada (período de carência)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_for TIMESTAMP;
+
+-- Contas desativadas pelo antigo soft delete entram na fila de exclusão imediatamente
+UPDATE users
+SET deletion_scheduled_for = CURRENT_TIMESTAMP
+WHERE is_active = false AND deletion_scheduled_for IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_for ON users(deletion_scheduled_for) WHERE deletion_scheduled_for IS NOT NULL;
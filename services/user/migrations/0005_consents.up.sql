@@ -0,0 +1,34 @@
+-- Versões publicadas dos documentos que exigem aceite
+CREATE TABLE IF NOT EXISTS consent_documents (
+    type VARCHAR(50) NOT NULL, -- 'terms_of_use', 'privacy_policy', 'sensitive_health_data', 'contact'
+    version VARCHAR(20) NOT NULL,
+    url TEXT NOT NULL,
+    mandatory BOOLEAN NOT NULL DEFAULT false,
+    published_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (type, version)
+);
+
+-- Aceites dos usuários (histórico preservado: retiradas apenas preenchem withdrawn_at)
+CREATE TABLE IF NOT EXISTS user_consents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    version VARCHAR(20) NOT NULL,
+    ip_address VARCHAR(45),
+    granted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    withdrawn_at TIMESTAMP,
+    FOREIGN KEY (type, version) REFERENCES consent_documents(type, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_consents_user_id ON user_consents(user_id, type) WHERE withdrawn_at IS NULL;
+
+-- Documentos vigentes
+INSERT INTO consent_documents (type, version, url, mandatory) VALUES
+('terms_of_use', '1.0', 'https://meuapoio.com/termos/1.0', true),
+('privacy_policy', '1.0', 'https://meuapoio.com/privacidade/1.0', true),
+('sensitive_health_data', '1.0', 'https://meuapoio.com/dados-sensiveis/1.0', false),
+('contact', '1.0', 'https://meuapoio.com/contato/1.0', false)
+ON CONFLICT DO NOTHING;
+
+COMMENT ON TABLE consent_documents IS 'Versões dos termos, política de privacidade e opt-ins';
+COMMENT ON TABLE user_consents IS 'Histórico de aceites e retiradas de consentimento';
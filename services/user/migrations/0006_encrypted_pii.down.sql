@@ -0,0 +1,22 @@
+-- Só é possível voltar aos tipos originais enquanto não houver valores cifrados
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE birth_date LIKE 'enc:%' OR phone LIKE 'enc:%')
+       OR EXISTS (SELECT 1 FROM emergency_contacts WHERE name LIKE 'enc:%' OR phone LIKE 'enc:%') THEN
+        RAISE EXCEPTION 'existem dados cifrados; decifre-os antes de reverter esta migração';
+    END IF;
+END;
+$$;
+
+DROP INDEX IF EXISTS idx_emergency_contacts_phone_bidx;
+DROP INDEX IF EXISTS idx_users_phone_bidx;
+
+ALTER TABLE emergency_contacts DROP COLUMN IF EXISTS phone_bidx;
+ALTER TABLE emergency_contacts
+    ALTER COLUMN name TYPE VARCHAR(100),
+    ALTER COLUMN phone TYPE VARCHAR(20);
+
+ALTER TABLE users DROP COLUMN IF EXISTS phone_bidx;
+ALTER TABLE users
+    ALTER COLUMN birth_date TYPE DATE USING birth_date::date,
+    ALTER COLUMN phone TYPE VARCHAR(20);
@@ -0,0 +1,21 @@
+-- Dados pessoais cifrados pela aplicação (shared/crypto). Os valores existentes
+-- continuam legíveis como texto puro até rodar `user reencrypt`.
+ALTER TABLE users
+    ALTER COLUMN birth_date TYPE TEXT USING birth_date::text,
+    ALTER COLUMN phone TYPE TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_bidx CHAR(64);
+
+ALTER TABLE emergency_contacts
+    ALTER COLUMN name TYPE TEXT,
+    ALTER COLUMN phone TYPE TEXT;
+ALTER TABLE emergency_contacts ADD COLUMN IF NOT EXISTS phone_bidx CHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_users_phone_bidx ON users(phone_bidx);
+CREATE INDEX IF NOT EXISTS idx_emergency_contacts_phone_bidx ON emergency_contacts(user_id, phone_bidx);
+
+COMMENT ON COLUMN users.birth_date IS 'Cifrado (shared/crypto)';
+COMMENT ON COLUMN users.phone IS 'Cifrado (shared/crypto)';
+COMMENT ON COLUMN users.phone_bidx IS 'Blind index do telefone para buscas por igualdade';
+COMMENT ON COLUMN emergency_contacts.name IS 'Cifrado (shared/crypto)';
+COMMENT ON COLUMN emergency_contacts.phone IS 'Cifrado (shared/crypto)';
+COMMENT ON COLUMN emergency_contacts.phone_bidx IS 'Blind index do telefone para buscas por igualdade';
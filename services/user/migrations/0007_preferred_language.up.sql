@@ -0,0 +1,2 @@
+-- Idioma escolhido pelo usuário; NULL segue o Accept-Language da requisição
+ALTER TABLE users ADD COLUMN IF NOT EXISTS preferred_language VARCHAR(10);
@@ -0,0 +1,18 @@
+-- Respostas gravadas por Idempotency-Key (ver shared/middleware/idempotency.go).
+-- user_id é NULL nas rotas públicas; NULLS NOT DISTINCT mantém essas chaves únicas.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    route VARCHAR(255) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL,
+    status_code INTEGER, -- NULL enquanto a primeira requisição executa
+    headers JSONB,
+    body TEXT, -- cifrado: a resposta pode conter dados pessoais
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    CONSTRAINT idempotency_keys_scope UNIQUE NULLS NOT DISTINCT (user_id, route, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+COMMENT ON TABLE idempotency_keys IS 'Primeira resposta de cada Idempotency-Key, repetida nas retentativas';
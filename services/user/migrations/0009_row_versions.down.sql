@@ -0,0 +1,3 @@
+ALTER TABLE emergency_contacts DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE emergency_contacts DROP COLUMN IF EXISTS version;
+ALTER TABLE users DROP COLUMN IF EXISTS version;
@@ -0,0 +1,7 @@
+-- Versão de cada linha para ETag e If-Match: incrementada a cada alteração
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE emergency_contacts ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE emergency_contacts ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+UPDATE emergency_contacts SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE emergency_contacts ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
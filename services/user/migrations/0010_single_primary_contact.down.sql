@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_emergency_contacts_primary;
+ALTER TABLE emergency_contacts ALTER COLUMN is_primary DROP NOT NULL;
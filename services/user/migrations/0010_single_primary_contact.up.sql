@@ -0,0 +1,27 @@
+-- No máximo um contato principal por usuário. Antes de criar o índice, mantém
+-- como principal o mais recente entre os marcados (o que GetByUserID já listava
+-- primeiro) e promove o contato mais antigo de quem não tem nenhum principal.
+UPDATE emergency_contacts SET is_primary = false WHERE is_primary IS NULL;
+ALTER TABLE emergency_contacts ALTER COLUMN is_primary SET NOT NULL;
+
+UPDATE emergency_contacts c
+SET is_primary = false, updated_at = CURRENT_TIMESTAMP, version = version + 1
+WHERE c.is_primary AND EXISTS (
+    SELECT 1 FROM emergency_contacts o
+    WHERE o.user_id = c.user_id AND o.is_primary
+      AND (o.created_at, o.id) > (c.created_at, c.id)
+);
+
+UPDATE emergency_contacts
+SET is_primary = true, updated_at = CURRENT_TIMESTAMP, version = version + 1
+WHERE id IN (
+    SELECT DISTINCT ON (e.user_id) e.id
+    FROM emergency_contacts e
+    WHERE NOT EXISTS (
+        SELECT 1 FROM emergency_contacts p WHERE p.user_id = e.user_id AND p.is_primary
+    )
+    ORDER BY e.user_id, e.created_at, e.id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_emergency_contacts_primary
+    ON emergency_contacts(user_id) WHERE is_primary;
@@ -0,0 +1,5 @@
+ALTER TABLE emergency_contacts DROP COLUMN IF EXISTS phone_display;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_display;
+
+COMMENT ON COLUMN users.phone IS 'Cifrado (shared/crypto)';
+COMMENT ON COLUMN emergency_contacts.phone IS 'Cifrado (shared/crypto)';
@@ -0,0 +1,9 @@
+-- Telefones passam a ser gravados em E.164 (shared/phone), com a forma de
+-- exibição ao lado. As linhas existentes são convertidas por `user normalize-phones`.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_display TEXT;
+ALTER TABLE emergency_contacts ADD COLUMN IF NOT EXISTS phone_display TEXT;
+
+COMMENT ON COLUMN users.phone IS 'E.164, cifrado (shared/crypto)';
+COMMENT ON COLUMN users.phone_display IS 'Telefone formatado para exibição, cifrado (shared/crypto)';
+COMMENT ON COLUMN emergency_contacts.phone IS 'E.164, cifrado (shared/crypto)';
+COMMENT ON COLUMN emergency_contacts.phone_display IS 'Telefone formatado para exibição, cifrado (shared/crypto)';
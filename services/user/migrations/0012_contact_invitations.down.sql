@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_emergency_contacts_invitation;
+
+ALTER TABLE emergency_contacts
+    DROP COLUMN IF EXISTS responded_at,
+    DROP COLUMN IF EXISTS invitation_expires_at,
+    DROP COLUMN IF EXISTS invitation_sent_at,
+    DROP COLUMN IF EXISTS invitation_token_hash,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,16 @@
+-- Contatos de emergência precisam aceitar o convite recebido por SMS antes de
+-- receber alertas. Os contatos existentes ficam pendentes até o usuário reenviar
+-- o convite.
+ALTER TABLE emergency_contacts
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined')),
+    ADD COLUMN IF NOT EXISTS invitation_token_hash CHAR(64),
+    ADD COLUMN IF NOT EXISTS invitation_sent_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS invitation_expires_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS responded_at TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_emergency_contacts_invitation
+    ON emergency_contacts(invitation_token_hash);
+
+COMMENT ON COLUMN emergency_contacts.status IS 'Resposta ao convite: pending, accepted ou declined';
+COMMENT ON COLUMN emergency_contacts.invitation_token_hash IS 'SHA-256 do token do link enviado por SMS';
@@ -0,0 +1,4 @@
+ALTER TABLE emergency_contacts DROP COLUMN IF EXISTS email;
+
+DROP TABLE IF EXISTS sos_recipients;
+DROP TABLE IF EXISTS sos_alerts;
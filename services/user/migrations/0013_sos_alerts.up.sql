@@ -0,0 +1,50 @@
+-- Alertas de SOS. Cada alerta notifica primeiro o contato principal (nível 0) e,
+-- sem confirmação até escalates_at, os demais contatos que aceitaram o convite
+-- (nível 1).
+CREATE TABLE IF NOT EXISTS sos_alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'acknowledged', 'cancelled')),
+    level INTEGER NOT NULL DEFAULT 0,
+    location TEXT,
+    language VARCHAR(10) NOT NULL,
+    escalates_at TIMESTAMP,
+    acknowledged_at TIMESTAMP,
+    cancelled_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sos_alerts_user_created ON sos_alerts(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_sos_alerts_escalation ON sos_alerts(escalates_at) WHERE status = 'active';
+-- No máximo um alerta ativo por usuário
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sos_alerts_active ON sos_alerts(user_id) WHERE status = 'active';
+
+-- Contatos notificados por alerta. O nome é copiado do contato para que o
+-- histórico sobreviva à remoção dele.
+CREATE TABLE IF NOT EXISTS sos_recipients (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    alert_id UUID NOT NULL REFERENCES sos_alerts(id) ON DELETE CASCADE,
+    contact_id UUID REFERENCES emergency_contacts(id) ON DELETE SET NULL,
+    contact_name TEXT NOT NULL,
+    level INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'sending', 'notified', 'failed', 'acknowledged')),
+    channels TEXT NOT NULL DEFAULT '',
+    ack_token_hash CHAR(64),
+    notified_at TIMESTAMP,
+    acknowledged_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sos_recipients_alert ON sos_recipients(alert_id, level);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sos_recipients_ack_token ON sos_recipients(ack_token_hash);
+
+-- Email opcional do contato, usado pelo canal de email dos alertas
+ALTER TABLE emergency_contacts ADD COLUMN IF NOT EXISTS email TEXT;
+
+COMMENT ON TABLE sos_alerts IS 'Alertas de SOS e o andamento do escalonamento';
+COMMENT ON COLUMN sos_alerts.location IS 'Localização informada no acionamento, cifrada (shared/crypto)';
+COMMENT ON COLUMN sos_recipients.contact_name IS 'Cifrado (shared/crypto)';
+COMMENT ON COLUMN sos_recipients.ack_token_hash IS 'SHA-256 do token do link de confirmação';
+COMMENT ON COLUMN emergency_contacts.email IS 'Cifrado (shared/crypto)';
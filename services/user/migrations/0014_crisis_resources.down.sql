@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS crisis_resources;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
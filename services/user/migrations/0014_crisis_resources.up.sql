@@ -0,0 +1,39 @@
+-- Administradores editam o diretório de recursos de crise. O papel fica no banco,
+-- e não no JWT, para que a revogação valha na hora.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT false;
+
+-- Diretório de serviços de apoio em crise (linhas de ajuda, emergência, chats)
+CREATE TABLE IF NOT EXISTS crisis_resources (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    type VARCHAR(20) NOT NULL
+        CHECK (type IN ('hotline', 'emergency', 'chat', 'support_service')),
+    phone VARCHAR(20),
+    chat_url TEXT,
+    website_url TEXT,
+    country CHAR(2) NOT NULL,
+    state CHAR(2), -- NULL para serviços nacionais
+    languages TEXT NOT NULL DEFAULT 'pt-BR', -- tags BCP 47 separadas por vírgula
+    always_open BOOLEAN NOT NULL DEFAULT false,
+    timezone VARCHAR(50) NOT NULL,
+    hours JSONB NOT NULL DEFAULT '[]',
+    priority INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS idx_crisis_resources_region ON crisis_resources(country, state);
+
+-- Serviços nacionais do Brasil, todos gratuitos e 24 horas
+INSERT INTO crisis_resources (name, description, type, phone, website_url, country, always_open, timezone, priority) VALUES
+('CVV - Centro de Valorização da Vida', 'Apoio emocional e prevenção do suicídio, com sigilo, por telefone, chat e email', 'hotline', '188', 'https://cvv.org.br', 'BR', true, 'America/Sao_Paulo', 100),
+('SAMU', 'Serviço de Atendimento Móvel de Urgência', 'emergency', '192', NULL, 'BR', true, 'America/Sao_Paulo', 90),
+('Corpo de Bombeiros', 'Emergências e resgates', 'emergency', '193', NULL, 'BR', true, 'America/Sao_Paulo', 80),
+('Polícia Militar', 'Emergências policiais', 'emergency', '190', NULL, 'BR', true, 'America/Sao_Paulo', 70),
+('Central de Atendimento à Mulher - Ligue 180', 'Orientação e denúncia de violência contra a mulher', 'hotline', '180', NULL, 'BR', true, 'America/Sao_Paulo', 60),
+('Disque Direitos Humanos - Disque 100', 'Denúncias de violações de direitos humanos, inclusive contra crianças, idosos e pessoas LGBTQIA+', 'hotline', '100', NULL, 'BR', true, 'America/Sao_Paulo', 50);
+
+COMMENT ON TABLE crisis_resources IS 'Diretório de recursos de crise, editável por administradores';
+COMMENT ON COLUMN crisis_resources.hours IS 'Horários semanais: [{"days": [1,2,3,4,5], "opens": "08:00", "closes": "18:00"}], no fuso timezone';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS safety_plan_shares;
+DROP TABLE IF EXISTS safety_plan_revisions;
+DROP TABLE IF EXISTS safety_plans;
@@ -0,0 +1,40 @@
+-- Plano de segurança do usuário, um por conta. As seções ficam num único JSON
+-- cifrado: o plano descreve sinais de crise e estratégias de enfrentamento.
+CREATE TABLE IF NOT EXISTS safety_plans (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    sections TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- Cada versão gravada do plano, inclusive a vigente
+CREATE TABLE IF NOT EXISTS safety_plan_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    sections TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, version)
+);
+
+-- Links temporários de leitura do plano enviados a um contato de emergência
+CREATE TABLE IF NOT EXISTS safety_plan_shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    contact_id UUID NOT NULL REFERENCES emergency_contacts(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    last_viewed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_safety_plan_shares_user ON safety_plan_shares(user_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_safety_plan_shares_token ON safety_plan_shares(token_hash);
+
+COMMENT ON TABLE safety_plans IS 'Plano de segurança vigente de cada usuário';
+COMMENT ON COLUMN safety_plans.sections IS 'Seções em JSON, cifradas (shared/crypto)';
+COMMENT ON COLUMN safety_plan_revisions.sections IS 'Seções em JSON, cifradas (shared/crypto)';
+COMMENT ON COLUMN safety_plan_shares.token_hash IS 'SHA-256 do token do link de compartilhamento';
@@ -0,0 +1,4 @@
+-- Os valores convertidos continuam gravados; só a restrição sai
+ALTER TABLE emergency_contacts DROP CONSTRAINT IF EXISTS emergency_contacts_relationship_check;
+
+COMMENT ON COLUMN emergency_contacts.relationship IS NULL;
@@ -0,0 +1,63 @@
+-- relationship passa a aceitar só os valores de models.Relationships. Os textos
+-- livres gravados antes são convertidos pelos mesmos nomes e apelidos usados na
+-- importação de agendas (models.ParseRelationship), sem diferenciar
+-- maiúsculas nem acentos; o que não corresponder a nenhum vira 'other'.
+UPDATE emergency_contacts SET relationship = NULL WHERE btrim(relationship) = '';
+
+UPDATE emergency_contacts c
+SET relationship = CASE
+        WHEN r.folded IN ('mother', 'mae', 'mamae', 'mom', 'mum', 'mama', 'madre')
+            THEN 'mother'
+        WHEN r.folded IN ('father', 'pai', 'papai', 'dad', 'papa', 'padre')
+            THEN 'father'
+        WHEN r.folded IN ('spouse', 'conjuge', 'conyuge', 'esposa', 'esposo', 'marido', 'mulher', 'wife', 'husband')
+            THEN 'spouse'
+        WHEN r.folded IN ('partner', 'namorado(a) ou companheiro(a)', 'pareja', 'namorado', 'namorada',
+                          'companheiro', 'companheira', 'boyfriend', 'girlfriend', 'novio', 'novia')
+            THEN 'partner'
+        WHEN r.folded IN ('child', 'filho(a)', 'hijo(a)', 'filho', 'filha', 'son', 'daughter', 'hijo', 'hija')
+            THEN 'child'
+        WHEN r.folded IN ('sibling', 'irmao(a)', 'hermano(a)', 'irmao', 'irma', 'brother', 'sister', 'hermano', 'hermana')
+            THEN 'sibling'
+        WHEN r.folded IN ('grandparent', 'avo ou avo', 'abuelo(a)', 'avo', 'vovo', 'grandmother', 'grandfather',
+                          'abuelo', 'abuela')
+            THEN 'grandparent'
+        WHEN r.folded IN ('relative', 'outro familiar', 'other relative', 'otro familiar', 'familiar', 'parente',
+                          'tio', 'tia', 'primo', 'prima', 'sobrinho', 'sobrinha', 'cunhado', 'cunhada', 'sogro',
+                          'sogra', 'aunt', 'uncle', 'cousin')
+            THEN 'relative'
+        WHEN r.folded IN ('friend', 'amigo(a)', 'amigo', 'amiga')
+            THEN 'friend'
+        WHEN r.folded IN ('neighbor', 'vizinho(a)', 'vecino(a)', 'vizinho', 'vizinha', 'neighbour', 'vecino', 'vecina')
+            THEN 'neighbor'
+        WHEN r.folded IN ('coworker', 'colega de trabalho', 'companero(a) de trabajo', 'colega', 'colleague')
+            THEN 'coworker'
+        WHEN r.folded IN ('therapist', 'terapeuta', 'psicologo', 'psicologa', 'psiquiatra', 'psychologist', 'psychiatrist')
+            THEN 'therapist'
+        WHEN r.folded IN ('caregiver', 'cuidador(a)', 'cuidador', 'cuidadora')
+            THEN 'caregiver'
+        ELSE 'other'
+    END,
+    updated_at = CURRENT_TIMESTAMP,
+    version = version + 1
+FROM (
+    SELECT id, translate(lower(btrim(relationship)),
+                         'áàâãäéèêëíìîïóòôõöúùûüçñ',
+                         'aaaaaeeeeiiiiooooouuuucn') AS folded
+    FROM emergency_contacts
+    WHERE relationship IS NOT NULL
+) r
+WHERE c.id = r.id AND c.relationship IS DISTINCT FROM (
+    -- Linhas já convertidas não mudam de versão
+    CASE WHEN r.folded IN ('mother', 'father', 'spouse', 'partner', 'child', 'sibling', 'grandparent', 'relative',
+                           'friend', 'neighbor', 'coworker', 'therapist', 'caregiver', 'other')
+         THEN r.folded END
+);
+
+ALTER TABLE emergency_contacts
+    ADD CONSTRAINT emergency_contacts_relationship_check CHECK (relationship IN (
+        'mother', 'father', 'spouse', 'partner', 'child', 'sibling', 'grandparent', 'relative',
+        'friend', 'neighbor', 'coworker', 'therapist', 'caregiver', 'other'
+    ));
+
+COMMENT ON COLUMN emergency_contacts.relationship IS 'Parentesco: um dos valores de models.Relationships';
@@ -0,0 +1,4 @@
+-- As fotos enviadas continuam no armazenamento de arquivos até a exclusão da conta
+ALTER TABLE users DROP COLUMN IF EXISTS profile_image_id;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS profile_image_url TEXT;
@@ -0,0 +1,8 @@
+-- A foto de perfil passa a ser enviada ao serviço e guardada no armazenamento
+-- de arquivos (BLOB_BACKEND). As URLs externas gravadas antes eram escolhidas
+-- pelo cliente e não são confiáveis: são descartadas, não convertidas.
+ALTER TABLE users DROP COLUMN IF EXISTS profile_image_url;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS profile_image_id VARCHAR(32);
+
+COMMENT ON COLUMN users.profile_image_id IS 'Foto de perfil atual: chaves em models.ProfileImageKey';
@@ -0,0 +1,11 @@
+-- Eventos já anonimizados continuam sem IP, user agent e diff e passam a
+-- falhar em audit verify, que sem anonymized_at volta a conferir o conteúdo
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events é append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_audit_events_target;
+
+ALTER TABLE audit_events DROP COLUMN IF EXISTS anonymized_at;
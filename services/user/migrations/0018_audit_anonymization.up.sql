@@ -0,0 +1,33 @@
+-- A exclusão definitiva da conta anonimiza os eventos de auditoria do titular:
+-- IP, user agent e diff são apagados e anonymized_at marca o evento. O hash
+-- fica como estava, então a cadeia continua ligada, mas o conteúdo desses
+-- eventos deixa de ser conferido por audit verify.
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id);
+
+-- O trigger continua recusando UPDATE e DELETE, exceto a anonimização feita
+-- com a flag de sessão meuapoio.audit_anonymize (audit.Anonymize), que só pode
+-- limpar IP, user agent e diff e preencher anonymized_at
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+       AND current_setting('meuapoio.audit_anonymize', true) = 'on'
+       AND OLD.anonymized_at IS NULL
+       AND NEW.anonymized_at IS NOT NULL
+       AND NEW.ip_address IS NULL
+       AND NEW.user_agent IS NULL
+       AND NEW.changes IS NULL
+       AND (NEW.id, NEW.actor_id, NEW.action, NEW.target_type, NEW.target_id,
+            NEW.request_id, NEW.prev_hash, NEW.hash, NEW.created_at)
+           IS NOT DISTINCT FROM
+           (OLD.id, OLD.actor_id, OLD.action, OLD.target_type, OLD.target_id,
+            OLD.request_id, OLD.prev_hash, OLD.hash, OLD.created_at)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events é append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON COLUMN audit_events.anonymized_at IS 'Quando IP, user agent e diff foram apagados pela exclusão da conta';
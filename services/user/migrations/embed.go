@@ -0,0 +1,10 @@
+// Package migrations contém as migrações SQL do serviço de usuários, embutidas
+// no binário e aplicadas com `user migrate up` (ver shared/migrate).
+package migrations
+
+import "embed"
+
+// FS contém os arquivos NNNN_nome.up.sql / NNNN_nome.down.sql
+//
+//go:embed *.sql
+var FS embed.FS
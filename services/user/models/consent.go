@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// Tipos de consentimento
+const (
+	ConsentTermsOfUse          = "terms_of_use"
+	ConsentPrivacyPolicy       = "privacy_policy"
+	ConsentSensitiveHealthData = "sensitive_health_data"
+	ConsentContact             = "contact"
+)
+
+// ConsentDocument é uma versão publicada de um documento que exige aceite
+type ConsentDocument struct {
+	Type        string    `json:"type" db:"type"`
+	Version     string    `json:"version" db:"version"`
+	URL         string    `json:"url" db:"url"`
+	Mandatory   bool      `json:"mandatory" db:"mandatory"`
+	PublishedAt time.Time `json:"published_at" db:"published_at"`
+}
+
+// Consent registra o aceite de um usuário a uma versão de documento
+type Consent struct {
+	ID          string     `json:"id" db:"id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Type        string     `json:"type" db:"type"`
+	Version     string     `json:"version" db:"version"`
+	IPAddress   *string    `json:"ip_address" db:"ip_address"`
+	GrantedAt   time.Time  `json:"granted_at" db:"granted_at"`
+	WithdrawnAt *time.Time `json:"withdrawn_at" db:"withdrawn_at"`
+}
+
+type ConsentRequest struct {
+	Type    string `json:"type" binding:"required,oneof=terms_of_use privacy_policy sensitive_health_data contact"`
+	Version string `json:"version" binding:"required,max=20"`
+}
+
+type ConsentsResponse struct {
+	Consents []*Consent         `json:"consents"`
+	Pending  []*ConsentDocument `json:"pending"`
+}
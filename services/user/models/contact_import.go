@@ -0,0 +1,63 @@
+package models
+
+// Situação de cada contato do arquivo importado
+const (
+	// Válido e ainda não criado: só aparece na simulação (dry_run)
+	ImportRowValid     = "valid"
+	ImportRowCreated   = "created"
+	ImportRowDuplicate = "duplicate"
+	ImportRowInvalid   = "invalid"
+)
+
+// ImportFieldError é um campo inválido de um contato do arquivo, com os mesmos
+// field, rule e message dos erros de validação das requisições em JSON
+type ImportFieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ContactImportRow é o resultado de um contato do arquivo
+type ContactImportRow struct {
+	// Posição do contato no arquivo, a partir de 1, e linha onde ele começa
+	Row    int                `json:"row"`
+	Line   int                `json:"line"`
+	Status string             `json:"status"`
+	Name   string             `json:"name"`
+	Phone  string             `json:"phone"`
+	Errors []ImportFieldError `json:"errors,omitempty"`
+	// Contato já cadastrado com o mesmo telefone
+	DuplicateOf *string `json:"duplicate_of,omitempty"`
+	// Contato anterior do próprio arquivo com o mesmo telefone
+	DuplicateOfRow *int `json:"duplicate_of_row,omitempty"`
+	// Contato criado, quando não é simulação
+	ContactID *string `json:"contact_id,omitempty"`
+}
+
+// ContactImportResult é o relatório da importação. Na simulação nada é gravado
+// e os contatos que seriam criados vêm como "valid".
+type ContactImportResult struct {
+	DryRun     bool `json:"dry_run"`
+	Total      int  `json:"total"`
+	Valid      int  `json:"valid"`
+	Created    int  `json:"created"`
+	Duplicates int  `json:"duplicates"`
+	Invalid    int  `json:"invalid"`
+	// Limite de contatos por usuário, quando configurado, e quantos contatos
+	// válidos passam dele. Com OverLimit > 0 a importação real responde
+	// CONTACT_LIMIT_REACHED sem criar nenhum contato.
+	MaxContacts *int               `json:"max_contacts,omitempty"`
+	OverLimit   int                `json:"over_limit"`
+	Rows        []ContactImportRow `json:"rows"`
+}
+
+// ContactImportQuery são as opções da importação na query string
+type ContactImportQuery struct {
+	// Valida o arquivo e devolve o relatório sem criar contatos
+	DryRun bool `form:"dry_run" json:"dry_run"`
+}
+
+// ContactExportQuery escolhe o formato da exportação; o padrão é vCard
+type ContactExportQuery struct {
+	Format string `form:"format" json:"format" binding:"omitempty,oneof=vcf csv"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// Status de um pedido de exportação de dados (LGPD)
+const (
+	ExportStatusPending    = "pending"
+	ExportStatusProcessing = "processing"
+	ExportStatusCompleted  = "completed"
+	ExportStatusFailed     = "failed"
+	ExportStatusExpired    = "expired"
+)
+
+type DataExport struct {
+	ID          string     `json:"id" db:"id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Status      string     `json:"status" db:"status"`
+	FilePath    *string    `json:"-" db:"file_path"`
+	Error       *string    `json:"error,omitempty" db:"error"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time `json:"completed_at" db:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at" db:"expires_at"`
+	DownloadURL string     `json:"download_url,omitempty" db:"-"`
+}
+
+type FavoriteExport struct {
+	AudioID   string    `json:"audio_id" db:"audio_id"`
+	Title     string    `json:"title" db:"title"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type PlayHistoryExport struct {
+	AudioID              string    `json:"audio_id" db:"audio_id"`
+	Title                string    `json:"title" db:"title"`
+	PlayedAt             time.Time `json:"played_at" db:"played_at"`
+	CompletionPercentage int       `json:"completion_percentage" db:"completion_percentage"`
+}
+
+type NotificationExport struct {
+	ID           string     `json:"id" db:"id"`
+	Title        string     `json:"title" db:"title"`
+	Message      string     `json:"message" db:"message"`
+	Type         string     `json:"type" db:"type"`
+	IsRead       bool       `json:"is_read" db:"is_read"`
+	ScheduledFor *time.Time `json:"scheduled_for" db:"scheduled_for"`
+	SentAt       *time.Time `json:"sent_at" db:"sent_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
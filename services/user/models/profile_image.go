@@ -0,0 +1,14 @@
+package models
+
+import "fmt"
+
+// ProfileImagePrefix é o prefixo das chaves de todas as fotos do usuário no
+// blob.Store, usado para apagá-las junto com a conta
+func ProfileImagePrefix(userID string) string {
+	return "profile-" + userID + "-"
+}
+
+// ProfileImageKey é a chave da versão de lado size da foto imageID
+func ProfileImageKey(userID, imageID string, size int) string {
+	return fmt.Sprintf("%s%s-%d.jpg", ProfileImagePrefix(userID), imageID, size)
+}
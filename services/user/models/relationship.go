@@ -0,0 +1,131 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/meuapoio/shared/i18n"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Parentescos aceitos em relationship. A API grava e devolve o valor; o texto
+// exibido vem de RelationshipLabels, no idioma de quem lê.
+const (
+	RelationshipMother      = "mother"
+	RelationshipFather      = "father"
+	RelationshipSpouse      = "spouse"
+	RelationshipPartner     = "partner"
+	RelationshipChild       = "child"
+	RelationshipSibling     = "sibling"
+	RelationshipGrandparent = "grandparent"
+	RelationshipRelative    = "relative"
+	RelationshipFriend      = "friend"
+	RelationshipNeighbor    = "neighbor"
+	RelationshipCoworker    = "coworker"
+	RelationshipTherapist   = "therapist"
+	RelationshipCaregiver   = "caregiver"
+	RelationshipOther       = "other"
+)
+
+// Relationships é a ordem dos parentescos nas listas de escolha
+var Relationships = []string{
+	RelationshipMother,
+	RelationshipFather,
+	RelationshipSpouse,
+	RelationshipPartner,
+	RelationshipChild,
+	RelationshipSibling,
+	RelationshipGrandparent,
+	RelationshipRelative,
+	RelationshipFriend,
+	RelationshipNeighbor,
+	RelationshipCoworker,
+	RelationshipTherapist,
+	RelationshipCaregiver,
+	RelationshipOther,
+}
+
+// RelationshipLabels são os nomes exibidos de cada parentesco
+var RelationshipLabels = map[string]i18n.Text{
+	RelationshipMother:      {i18n.PortugueseBR: "Mãe", i18n.English: "Mother", i18n.Spanish: "Madre"},
+	RelationshipFather:      {i18n.PortugueseBR: "Pai", i18n.English: "Father", i18n.Spanish: "Padre"},
+	RelationshipSpouse:      {i18n.PortugueseBR: "Cônjuge", i18n.English: "Spouse", i18n.Spanish: "Cónyuge"},
+	RelationshipPartner:     {i18n.PortugueseBR: "Namorado(a) ou companheiro(a)", i18n.English: "Partner", i18n.Spanish: "Pareja"},
+	RelationshipChild:       {i18n.PortugueseBR: "Filho(a)", i18n.English: "Child", i18n.Spanish: "Hijo(a)"},
+	RelationshipSibling:     {i18n.PortugueseBR: "Irmão(ã)", i18n.English: "Sibling", i18n.Spanish: "Hermano(a)"},
+	RelationshipGrandparent: {i18n.PortugueseBR: "Avô ou avó", i18n.English: "Grandparent", i18n.Spanish: "Abuelo(a)"},
+	RelationshipRelative:    {i18n.PortugueseBR: "Outro familiar", i18n.English: "Other relative", i18n.Spanish: "Otro familiar"},
+	RelationshipFriend:      {i18n.PortugueseBR: "Amigo(a)", i18n.English: "Friend", i18n.Spanish: "Amigo(a)"},
+	RelationshipNeighbor:    {i18n.PortugueseBR: "Vizinho(a)", i18n.English: "Neighbor", i18n.Spanish: "Vecino(a)"},
+	RelationshipCoworker:    {i18n.PortugueseBR: "Colega de trabalho", i18n.English: "Coworker", i18n.Spanish: "Compañero(a) de trabajo"},
+	RelationshipTherapist:   {i18n.PortugueseBR: "Terapeuta", i18n.English: "Therapist", i18n.Spanish: "Terapeuta"},
+	RelationshipCaregiver:   {i18n.PortugueseBR: "Cuidador(a)", i18n.English: "Caregiver", i18n.Spanish: "Cuidador(a)"},
+	RelationshipOther:       {i18n.PortugueseBR: "Outro", i18n.English: "Other", i18n.Spanish: "Otro"},
+}
+
+// RelationshipOption é um parentesco com o nome no idioma da requisição, para
+// as listas de escolha dos clientes
+type RelationshipOption struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// relationshipAliases são outras formas comuns de escrever cada parentesco em
+// agendas exportadas, já sem acentos e em minúsculas. A migração
+// 0016_contact_relationships converte os valores antigos com a mesma lista.
+var relationshipAliases = map[string][]string{
+	RelationshipMother:      {"mae", "mamae", "mom", "mum", "mama"},
+	RelationshipFather:      {"papai", "dad", "papa"},
+	RelationshipSpouse:      {"esposa", "esposo", "marido", "mulher", "wife", "husband", "conjuge", "conyuge"},
+	RelationshipPartner:     {"namorado", "namorada", "companheiro", "companheira", "boyfriend", "girlfriend", "novio", "novia"},
+	RelationshipChild:       {"filho", "filha", "son", "daughter", "hijo", "hija"},
+	RelationshipSibling:     {"irmao", "irma", "brother", "sister", "hermano", "hermana"},
+	RelationshipGrandparent: {"avo", "vovo", "grandmother", "grandfather", "abuelo", "abuela"},
+	RelationshipRelative:    {"familiar", "parente", "tio", "tia", "primo", "prima", "sobrinho", "sobrinha", "cunhado", "cunhada", "sogro", "sogra", "aunt", "uncle", "cousin"},
+	RelationshipFriend:      {"amigo", "amiga"},
+	RelationshipNeighbor:    {"vizinho", "vizinha", "neighbour", "vecino", "vecina"},
+	RelationshipCoworker:    {"colega", "colleague"},
+	RelationshipTherapist:   {"psicologo", "psicologa", "psiquiatra", "psychologist", "psychiatrist"},
+	RelationshipCaregiver:   {"cuidador", "cuidadora"},
+	RelationshipOther:       {"outro", "outra", "otro", "otra"},
+}
+
+// ParseRelationship reconhece um parentesco escrito como valor da API, como um
+// dos nomes exibidos em qualquer idioma ou como um apelido comum, sem
+// diferenciar maiúsculas nem acentos. Usado na importação de agendas, que
+// trazem o parentesco como texto livre.
+func ParseRelationship(s string) (string, bool) {
+	key := foldRelationship(s)
+	if key == "" {
+		return "", false
+	}
+	for _, relationship := range Relationships {
+		if key == relationship {
+			return relationship, true
+		}
+		for _, label := range RelationshipLabels[relationship] {
+			if key == foldRelationship(label) {
+				return relationship, true
+			}
+		}
+		for _, alias := range relationshipAliases[relationship] {
+			if key == alias {
+				return relationship, true
+			}
+		}
+	}
+	return "", false
+}
+
+// foldRelationship deixa o texto em minúsculas, sem acentos e sem espaços nas
+// pontas
+func foldRelationship(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, strings.ToLower(strings.TrimSpace(s)))
+	if err != nil {
+		return ""
+	}
+	return folded
+}
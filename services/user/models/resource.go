@@ -0,0 +1,138 @@
+package models
+
+import (
+	"strings"
+	"time"
+	// Base de fusos embutida: as imagens mínimas não trazem /usr/share/zoneinfo e
+	// o horário de funcionamento depende do fuso de cada recurso
+	_ "time/tzdata"
+)
+
+// Tipos de recurso de crise
+const (
+	ResourceTypeHotline        = "hotline"
+	ResourceTypeEmergency      = "emergency"
+	ResourceTypeChat           = "chat"
+	ResourceTypeSupportService = "support_service"
+)
+
+// OpeningHours é um intervalo semanal de atendimento, no fuso do recurso. Se
+// closes não for depois de opens, o intervalo termina no dia seguinte ("22:00" a
+// "06:00"; "08:00" a "00:00" vai até a meia-noite).
+type OpeningHours struct {
+	// Dias da semana em que o intervalo começa; 0 é domingo
+	Days   []int  `json:"days" binding:"required,min=1,max=7,dive,min=0,max=6"`
+	Opens  string `json:"opens" binding:"required,datetime=15:04"`
+	Closes string `json:"closes" binding:"required,datetime=15:04"`
+}
+
+// CrisisResource é um serviço de apoio em crise do diretório público
+type CrisisResource struct {
+	ID          string         `json:"id" db:"id"`
+	Name        string         `json:"name" db:"name"`
+	Description *string        `json:"description" db:"description"`
+	Type        string         `json:"type" db:"type"`
+	Phone       *string        `json:"phone" db:"phone"`
+	ChatURL     *string        `json:"chat_url" db:"chat_url"`
+	WebsiteURL  *string        `json:"website_url" db:"website_url"`
+	Country     string         `json:"country" db:"country"`
+	State       *string        `json:"state" db:"state"`
+	Languages   []string       `json:"languages" db:"languages"`
+	AlwaysOpen  bool           `json:"always_open" db:"always_open"`
+	Timezone    string         `json:"timezone" db:"timezone"`
+	Hours       []OpeningHours `json:"hours" db:"hours"`
+	Priority    int            `json:"priority" db:"priority"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at"`
+	Version     int            `json:"version" db:"version"`
+}
+
+// ResourceRequest cria ou substitui (PUT) um recurso. Números curtos como "188"
+// não são E.164, então o telefone é gravado como informado.
+type ResourceRequest struct {
+	Name        string         `json:"name" binding:"required,max=100"`
+	Description *string        `json:"description" binding:"omitempty,max=500"`
+	Type        string         `json:"type" binding:"required,oneof=hotline emergency chat support_service"`
+	Phone       *string        `json:"phone" binding:"required_without_all=ChatURL WebsiteURL,omitempty,max=20"`
+	ChatURL     *string        `json:"chat_url" binding:"omitempty,url,max=300"`
+	WebsiteURL  *string        `json:"website_url" binding:"omitempty,url,max=300"`
+	Country     string         `json:"country" binding:"required,iso3166_1_alpha2"`
+	State       *string        `json:"state" binding:"omitempty,len=2,alpha"`
+	Languages   []string       `json:"languages" binding:"required,min=1,max=10,dive,bcp47_language_tag"`
+	AlwaysOpen  bool           `json:"always_open"`
+	Timezone    string         `json:"timezone" binding:"required,timezone"`
+	Hours       []OpeningHours `json:"hours" binding:"required_if=AlwaysOpen false,max=20,dive"`
+	Priority    int            `json:"priority" binding:"min=0,max=1000"`
+}
+
+// ResourceFilter restringe a listagem; campos vazios não filtram. Com State, a
+// lista traz os recursos do estado e os nacionais do país.
+type ResourceFilter struct {
+	Country  string `form:"country" binding:"omitempty,iso3166_1_alpha2"`
+	State    string `form:"state" binding:"omitempty,len=2,alpha"`
+	Type     string `form:"type" binding:"omitempty,oneof=hotline emergency chat support_service"`
+	Language string `form:"language" binding:"omitempty,bcp47_language_tag"`
+	OpenNow  bool   `form:"open_now"`
+}
+
+// IsOpen indica se o recurso atende no instante now
+func (r *CrisisResource) IsOpen(now time.Time) bool {
+	if r.AlwaysOpen {
+		return true
+	}
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return false
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	today := int(local.Weekday())
+	yesterday := (today + 6) % 7
+
+	for _, h := range r.Hours {
+		opens, okOpens := minuteOfDay(h.Opens)
+		closes, okCloses := minuteOfDay(h.Closes)
+		if !okOpens || !okCloses {
+			continue
+		}
+		for _, day := range h.Days {
+			if closes > opens {
+				if day == today && minute >= opens && minute < closes {
+					return true
+				}
+				continue
+			}
+			// Intervalo que atravessa a meia-noite
+			if (day == today && minute >= opens) || (day == yesterday && minute < closes) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SpeaksLanguage indica se o recurso atende no idioma lang; "pt" aceita "pt-BR"
+// e vice-versa
+func (r *CrisisResource) SpeaksLanguage(lang string) bool {
+	primary := func(tag string) string {
+		if i := strings.IndexByte(tag, '-'); i >= 0 {
+			tag = tag[:i]
+		}
+		return strings.ToLower(tag)
+	}
+	for _, language := range r.Languages {
+		if strings.EqualFold(language, lang) || primary(language) == primary(lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// minuteOfDay converte "HH:MM" em minutos desde a meia-noite
+func minuteOfDay(value string) (int, bool) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+)
+
+// Seções do plano de segurança (modelo de Stanley e Brown), na ordem em que o
+// plano é seguido numa crise
+const (
+	SafetySectionWarningSigns     = "warning_signs"
+	SafetySectionCopingStrategies = "coping_strategies"
+	SafetySectionDistractions     = "distractions"
+	SafetySectionSupportContacts  = "support_contacts"
+	SafetySectionProfessionals    = "professionals"
+	SafetySectionSafeEnvironment  = "safe_environment"
+	SafetySectionReasonsForLiving = "reasons_for_living"
+)
+
+// SafetySections é a ordem das seções nas respostas e no PDF
+var SafetySections = []string{
+	SafetySectionWarningSigns,
+	SafetySectionCopingStrategies,
+	SafetySectionDistractions,
+	SafetySectionSupportContacts,
+	SafetySectionProfessionals,
+	SafetySectionSafeEnvironment,
+	SafetySectionReasonsForLiving,
+}
+
+// SafetyPlanItem é uma linha de uma seção. Pode citar um contato de emergência
+// do usuário, cujo nome e telefone atuais vêm nas leituras, ou trazer o telefone
+// de um profissional ou serviço.
+type SafetyPlanItem struct {
+	Text      string  `json:"text" binding:"required,max=500"`
+	ContactID *string `json:"contact_id" binding:"omitempty,uuid"`
+	Phone     *string `json:"phone" binding:"omitempty,max=20"`
+	// Preenchidos nas leituras a partir do contato; não são gravados
+	ContactName  *string `json:"contact_name,omitempty" binding:"-"`
+	ContactPhone *string `json:"contact_phone,omitempty" binding:"-"`
+}
+
+type SafetyPlanSection struct {
+	Type  string           `json:"type" binding:"required,oneof=warning_signs coping_strategies distractions support_contacts professionals safe_environment reasons_for_living"`
+	Items []SafetyPlanItem `json:"items" binding:"max=20,dive"`
+}
+
+// SafetyPlan é o plano de segurança do usuário, um por conta. Cada alteração
+// gera uma nova versão, guardada no histórico.
+type SafetyPlan struct {
+	Sections  []SafetyPlanSection `json:"sections" db:"sections"`
+	CreatedAt time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at" db:"updated_at"`
+	Version   int                 `json:"version" db:"version"`
+}
+
+// SafetyPlanRequest substitui o plano inteiro (PUT); seções omitidas ficam vazias
+type SafetyPlanRequest struct {
+	Sections []SafetyPlanSection `json:"sections" binding:"required,max=7,unique=Type,dive"`
+}
+
+// SafetyPlanRevision é uma versão do histórico do plano
+type SafetyPlanRevision struct {
+	Version   int       `json:"version" db:"version"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// SafetyPlanShare é um link temporário que dá a um contato acesso de leitura ao
+// plano vigente
+type SafetyPlanShare struct {
+	ID           string     `json:"id" db:"id"`
+	ContactID    string     `json:"contact_id" db:"contact_id"`
+	ContactName  string     `json:"contact_name" db:"contact_name"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at" db:"revoked_at"`
+	LastViewedAt *time.Time `json:"last_viewed_at" db:"last_viewed_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+type ShareSafetyPlanRequest struct {
+	ContactID string `json:"contact_id" binding:"required,uuid"`
+}
+
+// SharedSafetyPlan é o plano visto pelo link de compartilhamento
+type SharedSafetyPlan struct {
+	OwnerName string      `json:"owner_name"`
+	Plan      *SafetyPlan `json:"plan"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	// Dono do plano, para completar os contatos citados
+	UserID string `json:"-"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+)
+
+// Status de um alerta de SOS
+const (
+	AlertStatusActive       = "active"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusCancelled    = "cancelled"
+)
+
+// Níveis de escalonamento: o contato principal é avisado primeiro e os demais só
+// se ninguém confirmar o alerta a tempo
+const (
+	AlertLevelPrimary = 0
+	AlertLevelOthers  = 1
+)
+
+// Status do aviso a cada contato
+const (
+	RecipientStatusPending      = "pending"
+	RecipientStatusSending      = "sending"
+	RecipientStatusNotified     = "notified"
+	RecipientStatusFailed       = "failed"
+	RecipientStatusAcknowledged = "acknowledged"
+)
+
+type Location struct {
+	Latitude  float64 `json:"latitude" binding:"min=-90,max=90"`
+	Longitude float64 `json:"longitude" binding:"min=-180,max=180"`
+	// Raio de precisão em metros informado pelo aparelho
+	Accuracy *float64 `json:"accuracy" binding:"omitempty,min=0"`
+}
+
+type SOSRequest struct {
+	Location *Location `json:"location"`
+}
+
+type Alert struct {
+	ID             string            `json:"id" db:"id"`
+	UserID         string            `json:"user_id" db:"user_id"`
+	Status         string            `json:"status" db:"status"`
+	Level          int               `json:"level" db:"level"`
+	Location       *Location         `json:"location" db:"location"`
+	Language       string            `json:"-" db:"language"`
+	EscalatesAt    *time.Time        `json:"escalates_at" db:"escalates_at"`
+	AcknowledgedAt *time.Time        `json:"acknowledged_at" db:"acknowledged_at"`
+	CancelledAt    *time.Time        `json:"cancelled_at" db:"cancelled_at"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at" db:"updated_at"`
+	Recipients     []*AlertRecipient `json:"recipients" db:"-"`
+	// Nome de quem acionou, usado nas mensagens aos contatos
+	UserName string `json:"-" db:"-"`
+}
+
+type AlertRecipient struct {
+	ID             string     `json:"id" db:"id"`
+	AlertID        string     `json:"-" db:"alert_id"`
+	ContactID      *string    `json:"contact_id" db:"contact_id"`
+	ContactName    string     `json:"contact_name" db:"contact_name"`
+	Level          int        `json:"level" db:"level"`
+	Status         string     `json:"status" db:"status"`
+	Channels       []string   `json:"channels" db:"channels"`
+	NotifiedAt     *time.Time `json:"notified_at" db:"notified_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at" db:"acknowledged_at"`
+	// Endereços atuais do contato, preenchidos só para o envio
+	Phone string  `json:"-" db:"-"`
+	Email *string `json:"-" db:"-"`
+}
+
+// AlertNotice é o que o contato vê ao abrir o link de confirmação do alerta
+type AlertNotice struct {
+	AlertID        string     `json:"-"`
+	UserName       string     `json:"user_name"`
+	Status         string     `json:"status"`
+	Location       *Location  `json:"location"`
+	CreatedAt      time.Time  `json:"created_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+}
@@ -2,34 +2,58 @@ package models
 
 import (
 	"time"
+
+	"github.com/meuapoio/shared/pagination"
+	"github.com/meuapoio/shared/sanitize"
 )
 
 type User struct {
-	ID              string     `json:"id" db:"id"`
-	Username        string     `json:"username" db:"username"`
-	Email           string     `json:"email" db:"email"`
-	PasswordHash    string     `json:"-" db:"password_hash"`
-	FullName        *string    `json:"full_name" db:"full_name"`
-	BirthDate       *time.Time `json:"birth_date" db:"birth_date"`
-	Phone           *string    `json:"phone" db:"phone"`
-	ProfileImageURL *string    `json:"profile_image_url" db:"profile_image_url"`
-	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
-	IsActive        bool       `json:"is_active" db:"is_active"`
+	ID           string     `json:"id" db:"id"`
+	Username     string     `json:"username" db:"username"`
+	Email        string     `json:"email" db:"email"`
+	PasswordHash string     `json:"-" db:"password_hash"`
+	FullName     *string    `json:"full_name" db:"full_name"`
+	BirthDate    *time.Time `json:"birth_date" db:"birth_date"`
+	Phone        *string    `json:"phone" db:"phone"`
+	PhoneDisplay *string    `json:"phone_display" db:"phone_display"`
+	// Identificador da foto de perfil atual (ver ProfileImageKey). Os links
+	// assinados são preenchidos pelos handlers a cada resposta.
+	ProfileImageID           *string `json:"-" db:"profile_image_id"`
+	ProfileImageURL          *string `json:"profile_image_url"`
+	ProfileImageThumbnailURL *string `json:"profile_image_thumbnail_url"`
+	// Idioma das respostas da API; sem valor, vale o Accept-Language
+	PreferredLanguage    *string    `json:"preferred_language" db:"preferred_language"`
+	CreatedAt            time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at" db:"updated_at"`
+	IsActive             bool       `json:"is_active" db:"is_active"`
+	DeletionScheduledFor *time.Time `json:"deletion_scheduled_for,omitempty" db:"deletion_scheduled_for"`
+	Version              int        `json:"version" db:"version"`
 }
 
 type CreateUserRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=50"`
-	Email    string `json:"email" binding:"required,email,max=100"`
-	Password string `json:"password" binding:"required,min=6"`
-	FullName string `json:"full_name" binding:"max=100"`
+	Username string           `json:"username" binding:"required,min=3,max=50"`
+	Email    string           `json:"email" binding:"required,email,max=100"`
+	Password string           `json:"password" binding:"required,min=6"`
+	FullName string           `json:"full_name" binding:"max=100"`
+	Consents []ConsentRequest `json:"consents" binding:"required,dive"`
+}
+
+// Sanitize limpa os textos livres antes da validação
+func (r *CreateUserRequest) Sanitize() {
+	r.FullName = sanitize.Text(r.FullName)
 }
 
 type UpdateUserRequest struct {
-	FullName        *string    `json:"full_name" binding:"omitempty,max=100"`
-	BirthDate       *time.Time `json:"birth_date"`
-	Phone           *string    `json:"phone" binding:"omitempty,max=20"`
-	ProfileImageURL *string    `json:"profile_image_url"`
+	FullName  *string    `json:"full_name" binding:"omitempty,max=100"`
+	BirthDate *time.Time `json:"birth_date"`
+	Phone     *string    `json:"phone" binding:"omitempty,max=20"`
+	// Tags suportadas em shared/i18n
+	PreferredLanguage *string `json:"preferred_language" binding:"omitempty,oneof=pt-BR en es"`
+}
+
+// Sanitize limpa os textos livres antes da validação
+func (r *UpdateUserRequest) Sanitize() {
+	r.FullName = sanitize.Ptr(r.FullName)
 }
 
 type LoginRequest struct {
@@ -40,28 +64,99 @@ type LoginRequest struct {
 type LoginResponse struct {
 	Token string `json:"token"`
 	User  User   `json:"user"`
+	// Indica que o login cancelou uma exclusão de conta agendada
+	DeletionCancelled bool `json:"deletion_cancelled,omitempty"`
 }
 
+// Resposta do contato ao convite enviado por SMS. Só contatos que aceitaram
+// recebem alertas.
+const (
+	ContactStatusPending  = "pending"
+	ContactStatusAccepted = "accepted"
+	ContactStatusDeclined = "declined"
+)
+
 type EmergencyContact struct {
-	ID           string    `json:"id" db:"id"`
-	UserID       string    `json:"user_id" db:"user_id"`
-	Name         string    `json:"name" db:"name"`
-	Phone        string    `json:"phone" db:"phone"`
-	Relationship *string   `json:"relationship" db:"relationship"`
-	IsPrimary    bool      `json:"is_primary" db:"is_primary"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	ID                  string     `json:"id" db:"id"`
+	UserID              string     `json:"user_id" db:"user_id"`
+	Name                string     `json:"name" db:"name"`
+	Phone               string     `json:"phone" db:"phone"`
+	PhoneDisplay        string     `json:"phone_display" db:"phone_display"`
+	Email               *string    `json:"email" db:"email"`
+	Relationship        *string    `json:"relationship" db:"relationship"`
+	IsPrimary           bool       `json:"is_primary" db:"is_primary"`
+	Status              string     `json:"status" db:"status"`
+	InvitationSentAt    *time.Time `json:"invitation_sent_at" db:"invitation_sent_at"`
+	InvitationExpiresAt *time.Time `json:"invitation_expires_at" db:"invitation_expires_at"`
+	RespondedAt         *time.Time `json:"responded_at" db:"responded_at"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+	Version             int        `json:"version" db:"version"`
 }
 
+// Ordenações da listagem de contatos. Nome e telefone são cifrados no banco e
+// não servem para ordenar.
+const (
+	// Principal primeiro, depois do mais recente ao mais antigo (padrão)
+	ContactSortPrimary   = "primary"
+	ContactSortCreatedAt = "created_at"
+	ContactSortUpdatedAt = "updated_at"
+)
+
+// Filtros da listagem de contatos, com os nomes usados na query string
+const (
+	ContactFilterStatus  = "status"
+	ContactFilterPrimary = "is_primary"
+)
+
+// SortKey retorna a chave de ordenação do contato na ordenação informada, na
+// ordem das colunas usadas pelo repositório e terminando no ID
+func (c *EmergencyContact) SortKey(sort string) []string {
+	switch sort {
+	case ContactSortCreatedAt:
+		return []string{pagination.Time(c.CreatedAt), c.ID}
+	case ContactSortUpdatedAt:
+		return []string{pagination.Time(c.UpdatedAt), c.ID}
+	}
+	return []string{pagination.Bool(c.IsPrimary), pagination.Time(c.CreatedAt), c.ID}
+}
+
+// ContactInvitation é o que a pessoa convidada vê ao abrir o link do convite
+type ContactInvitation struct {
+	ContactID   string    `json:"-"`
+	UserID      string    `json:"-"`
+	ContactName string    `json:"contact_name"`
+	InviterName string    `json:"inviter_name"`
+	Status      string    `json:"status"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// CreateContactRequest cria um contato. relationship é um dos valores de
+// Relationships.
 type CreateContactRequest struct {
 	Name         string  `json:"name" binding:"required,max=100"`
 	Phone        string  `json:"phone" binding:"required,max=20"`
-	Relationship *string `json:"relationship" binding:"omitempty,max=50"`
+	Email        *string `json:"email" binding:"omitempty,email,max=100"`
+	Relationship *string `json:"relationship" binding:"omitempty,oneof=mother father spouse partner child sibling grandparent relative friend neighbor coworker therapist caregiver other"`
 	IsPrimary    bool    `json:"is_primary"`
 }
 
+// Sanitize limpa os textos livres antes da validação
+func (r *CreateContactRequest) Sanitize() {
+	r.Name = sanitize.Text(r.Name)
+	r.Relationship = sanitize.Ptr(r.Relationship)
+}
+
 type UpdateContactRequest struct {
-	Name         *string `json:"name" binding:"omitempty,max=100"`
+	Name         *string `json:"name" binding:"omitempty,min=1,max=100"`
 	Phone        *string `json:"phone" binding:"omitempty,max=20"`
-	Relationship *string `json:"relationship" binding:"omitempty,max=50"`
+	Email        *string `json:"email" binding:"omitempty,email,max=100"`
+	Relationship *string `json:"relationship" binding:"omitempty,oneof=mother father spouse partner child sibling grandparent relative friend neighbor coworker therapist caregiver other"`
 	IsPrimary    *bool   `json:"is_primary"`
 }
+
+// Sanitize limpa os textos livres antes da validação
+func (r *UpdateContactRequest) Sanitize() {
+	r.Name = sanitize.Ptr(r.Name)
+	r.Relationship = sanitize.Ptr(r.Relationship)
+}
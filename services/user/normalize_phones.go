@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/crypto"
+	"github.com/meuapoio/shared/database"
+)
+
+// runNormalizePhones converte para E.164 os telefones de usuários e contatos
+// gravados antes da validação. Números sem código do país são interpretados em
+// PHONE_DEFAULT_REGION; os que não puderem ser interpretados ficam como estão e
+// são listados para correção manual.
+func runNormalizePhones(db *database.DB, keyring *crypto.Keyring, region string) {
+	ctx := context.Background()
+
+	log.Printf("Normalizando telefones (região padrão %s)", region)
+
+	users, invalid, err := repository.NewUserRepository(db, keyring).NormalizePhones(ctx, reencryptBatchSize, region)
+	if err != nil {
+		log.Fatalf("Erro ao normalizar telefones de usuários (%d atualizados): %v", users, err)
+	}
+	log.Printf("Usuários atualizados: %d", users)
+	for _, id := range invalid {
+		log.Printf("Telefone inválido no usuário %s", id)
+	}
+
+	contacts, invalid, err := repository.NewContactRepository(db, keyring, 0).NormalizePhones(ctx, reencryptBatchSize, region)
+	if err != nil {
+		log.Fatalf("Erro ao normalizar telefones de contatos (%d atualizados): %v", contacts, err)
+	}
+	log.Printf("Contatos atualizados: %d", contacts)
+	for _, id := range invalid {
+		log.Printf("Telefone inválido no contato %s", id)
+	}
+}
@@ -0,0 +1,186 @@
+// Package profileimage prepara as fotos de perfil enviadas pelos usuários:
+// confere o formato pelo conteúdo, aplica a orientação do EXIF, recorta o
+// quadrado central e gera as versões nos tamanhos padrão, recodificadas em JPEG.
+// A recodificação descarta os metadados do original (EXIF com GPS, XMP, ICC).
+package profileimage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+const (
+	// SizeLarge e SizeThumbnail são os lados, em pixels, das versões geradas
+	SizeLarge     = 512
+	SizeThumbnail = 128
+
+	// ContentType é o tipo de todas as versões geradas
+	ContentType = "image/jpeg"
+
+	// minSide é o menor lado aceito; fotos menores ficariam borradas na ampliação
+	minSide = 64
+	// maxPixels limita a imagem decodificada (cerca de 160 MB em RGBA), para que
+	// um arquivo pequeno com dimensões enormes não esgote a memória
+	maxPixels = 40_000_000
+
+	jpegQuality = 85
+)
+
+// Sizes são os tamanhos gerados por Process, do maior para o menor
+var Sizes = []int{SizeLarge, SizeThumbnail}
+
+// AcceptedTypes são os formatos aceitos no envio, identificados pelo conteúdo
+var AcceptedTypes = []string{"image/jpeg", "image/png"}
+
+var (
+	// ErrUnsupportedType indica um arquivo que não é JPEG nem PNG
+	ErrUnsupportedType = errors.New("formato de imagem não aceito")
+	// ErrCorrupt indica um arquivo que não pôde ser decodificado
+	ErrCorrupt = errors.New("imagem corrompida ou incompleta")
+	// ErrTooSmall e ErrTooLarge indicam dimensões fora dos limites
+	ErrTooSmall = errors.New("imagem menor que o mínimo")
+	ErrTooLarge = errors.New("imagem com pixels demais")
+)
+
+// Process decodifica a imagem enviada e retorna as versões de Sizes em JPEG,
+// indexadas pelo lado. Imagens com transparência ficam sobre fundo branco.
+func Process(data []byte) (map[int][]byte, error) {
+	if !accepted(http.DetectContentType(data)) {
+		return nil, ErrUnsupportedType
+	}
+
+	// As dimensões vêm do cabeçalho, antes de alocar a imagem inteira
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	if config.Width < minSide || config.Height < minSide {
+		return nil, ErrTooSmall
+	}
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+
+	// O recorte central é o mesmo antes ou depois da rotação, então a
+	// orientação é aplicada já no quadrado
+	square := orient(cropSquare(img), orientation(data))
+
+	versions := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resize(square, size), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		versions[size] = buf.Bytes()
+	}
+	return versions, nil
+}
+
+func accepted(contentType string) bool {
+	for _, t := range AcceptedTypes {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// cropSquare copia o quadrado central de img sobre fundo branco
+func cropSquare(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(square, square.Bounds(), img, origin, draw.Over)
+	return square
+}
+
+// orient aplica ao quadrado a transformação indicada pela orientação EXIF
+// (1 a 8), para que a imagem apareça como foi fotografada
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	n := src.Bounds().Dx() - 1
+	dst := image.NewRGBA(src.Bounds())
+	for y := 0; y <= n; y++ {
+		for x := 0; x <= n; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // espelhada na horizontal
+				sx, sy = n-x, y
+			case 3: // girada 180°
+				sx, sy = n-x, n-y
+			case 4: // espelhada na vertical
+				sx, sy = x, n-y
+			case 5: // transposta
+				sx, sy = y, x
+			case 6: // girar 90° no sentido horário
+				sx, sy = y, n-x
+			case 7: // transversa
+				sx, sy = n-y, n-x
+			case 8: // girar 90° no sentido anti-horário
+				sx, sy = n-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// resize redimensiona o quadrado para size×size pela média dos pixels de
+// origem que cada pixel de destino cobre. Na ampliação equivale ao vizinho
+// mais próximo.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, side, size)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, side, size)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[src.PixOffset(x0, sy):src.PixOffset(x1, sy)]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			count := (x1 - x0) * (y1 - y0)
+			offset := dst.PixOffset(x, y)
+			for i := range sum {
+				dst.Pix[offset+i] = uint8(sum[i] / count)
+			}
+		}
+	}
+	return dst
+}
+
+// span é o intervalo [start, end) de pixels de origem coberto pelo pixel de
+// destino i, com ao menos um pixel
+func span(i, side, size int) (int, int) {
+	start := i * side / size
+	end := (i + 1) * side / size
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
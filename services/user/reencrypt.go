@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/crypto"
+	"github.com/meuapoio/shared/database"
+)
+
+const reencryptBatchSize = 500
+
+// runReencrypt recifra todos os dados pessoais com a master key ativa.
+// Usado após a rotação de chaves (nova chave em ENCRYPTION_ACTIVE_KEY_ID, antigas
+// mantidas em ENCRYPTION_KEYS até o fim da execução) e para cifrar dados legados.
+func runReencrypt(db *database.DB, keyring *crypto.Keyring) {
+	ctx := context.Background()
+
+	log.Printf("Recifrando dados pessoais com a chave %q", keyring.ActiveKeyID())
+
+	users, err := repository.NewUserRepository(db, keyring).ReencryptAll(ctx, reencryptBatchSize)
+	if err != nil {
+		log.Fatalf("Erro ao recifrar usuários (%d atualizados): %v", users, err)
+	}
+	log.Printf("Usuários atualizados: %d", users)
+
+	contacts, err := repository.NewContactRepository(db, keyring, 0).ReencryptAll(ctx, reencryptBatchSize)
+	if err != nil {
+		log.Fatalf("Erro ao recifrar contatos (%d atualizados): %v", contacts, err)
+	}
+	log.Printf("Contatos atualizados: %d", contacts)
+
+	alerts, err := repository.NewAlertRepository(db, keyring).ReencryptAll(ctx, reencryptBatchSize)
+	if err != nil {
+		log.Fatalf("Erro ao recifrar alertas de SOS (%d atualizados): %v", alerts, err)
+	}
+	log.Printf("Registros de alertas de SOS atualizados: %d", alerts)
+
+	plans, err := repository.NewSafetyPlanRepository(db, keyring).ReencryptAll(ctx, reencryptBatchSize)
+	if err != nil {
+		log.Fatalf("Erro ao recifrar planos de segurança (%d atualizados): %v", plans, err)
+	}
+	log.Printf("Planos de segurança e versões atualizados: %d", plans)
+}
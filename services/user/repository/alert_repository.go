@@ -0,0 +1,476 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/crypto"
+	"github.com/meuapoio/shared/database"
+)
+
+type AlertRepository struct {
+	db      *database.DB
+	keyring *crypto.Keyring
+}
+
+// NewAlertRepository cria o repositório. A localização do alerta e o nome dos
+// contatos avisados são cifrados com o keyring.
+func NewAlertRepository(db *database.DB, keyring *crypto.Keyring) *AlertRepository {
+	return &AlertRepository{db: db, keyring: keyring}
+}
+
+// alertColumns junta o alerta (alias a) ao usuário que o acionou (alias u)
+const alertColumns = `
+	a.id, a.user_id, a.status, a.level, a.location, a.language, a.escalates_at,
+	a.acknowledged_at, a.cancelled_at, a.created_at, a.updated_at, COALESCE(u.full_name, u.username)
+`
+
+const recipientColumns = `
+	r.id, r.alert_id, r.contact_id, r.contact_name, r.level, r.status, r.channels, r.notified_at, r.acknowledged_at
+`
+
+func (r *AlertRepository) scanAlert(row rowScanner) (*models.Alert, error) {
+	alert := &models.Alert{}
+	var location *string
+	err := row.Scan(
+		&alert.ID, &alert.UserID, &alert.Status, &alert.Level, &location, &alert.Language,
+		&alert.EscalatesAt, &alert.AcknowledgedAt, &alert.CancelledAt, &alert.CreatedAt,
+		&alert.UpdatedAt, &alert.UserName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Location, err = r.decryptLocation(location); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// scanRecipient lê recipientColumns seguido, quando withAddress, do telefone e
+// do email do contato
+func (r *AlertRepository) scanRecipient(row rowScanner, withAddress bool) (*models.AlertRecipient, error) {
+	recipient := &models.AlertRecipient{}
+	var channels string
+	dest := []any{
+		&recipient.ID, &recipient.AlertID, &recipient.ContactID, &recipient.ContactName, &recipient.Level,
+		&recipient.Status, &channels, &recipient.NotifiedAt, &recipient.AcknowledgedAt,
+	}
+	var encPhone *string
+	if withAddress {
+		dest = append(dest, &encPhone, &recipient.Email)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if recipient.ContactName, err = r.keyring.Decrypt(recipient.ContactName); err != nil {
+		return nil, err
+	}
+	recipient.Channels = []string{}
+	if channels != "" {
+		recipient.Channels = strings.Split(channels, ",")
+	}
+	if !withAddress {
+		return recipient, nil
+	}
+	phone, err := r.keyring.DecryptPtr(encPhone)
+	if err != nil {
+		return nil, err
+	}
+	if phone != nil {
+		recipient.Phone = *phone
+	}
+	if recipient.Email, err = r.keyring.DecryptPtr(recipient.Email); err != nil {
+		return nil, err
+	}
+	return recipient, nil
+}
+
+func (r *AlertRepository) encryptLocation(location *models.Location) (*string, error) {
+	if location == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(location)
+	if err != nil {
+		return nil, err
+	}
+	value := string(data)
+	return r.keyring.EncryptPtr(&value)
+}
+
+func (r *AlertRepository) decryptLocation(value *string) (*models.Location, error) {
+	plaintext, err := r.keyring.DecryptPtr(value)
+	if err != nil || plaintext == nil {
+		return nil, err
+	}
+	location := &models.Location{}
+	if err := json.Unmarshal([]byte(*plaintext), location); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// Create bloqueia a linha do usuário para que dois acionamentos simultâneos não
+// criem dois alertas. Usa alert.CreatedAt como horário do acionamento.
+func (r *AlertRepository) Create(ctx context.Context, alert *models.Alert, contacts []*models.EmergencyContact) error {
+	location, err := r.encryptLocation(alert.Location)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(contacts))
+	for i, contact := range contacts {
+		if names[i], err = r.keyring.Encrypt(contact.Name); err != nil {
+			return err
+		}
+	}
+
+	insertAlert := `
+		INSERT INTO sos_alerts (user_id, location, language, escalates_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		RETURNING id, status, level, updated_at
+	`
+	insertRecipient := `
+		INSERT INTO sos_recipients (alert_id, contact_id, contact_name, level)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + strings.ReplaceAll(recipientColumns, "r.", "")
+
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		ctx, cancel := r.db.Timeout(ctx)
+		defer cancel()
+
+		exec := r.db.Executor(ctx)
+		var active bool
+		if err := lockActiveUser(ctx, exec, alert.UserID); err != nil {
+			return err
+		}
+		err := exec.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM sos_alerts WHERE user_id = $1 AND status = 'active')`, alert.UserID,
+		).Scan(&active)
+		if err != nil {
+			return err
+		}
+		if active {
+			return ErrAlertActive
+		}
+
+		err = exec.QueryRowContext(ctx, insertAlert,
+			alert.UserID, location, alert.Language, alert.EscalatesAt, alert.CreatedAt,
+		).Scan(&alert.ID, &alert.Status, &alert.Level, &alert.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		alert.Recipients = make([]*models.AlertRecipient, 0, len(contacts))
+		for i, contact := range contacts {
+			level := models.AlertLevelOthers
+			if i == 0 {
+				level = models.AlertLevelPrimary
+			}
+			recipient, err := r.scanRecipient(exec.QueryRowContext(ctx, insertRecipient, alert.ID, contact.ID, names[i], level), false)
+			if err != nil {
+				return err
+			}
+			alert.Recipients = append(alert.Recipients, recipient)
+		}
+		return nil
+	})
+}
+
+// loadRecipients preenche os avisos de cada alerta, na ordem de envio
+func (r *AlertRepository) loadRecipients(ctx context.Context, alerts ...*models.Alert) error {
+	query := `
+		SELECT ` + recipientColumns + `
+		FROM sos_recipients r
+		WHERE r.alert_id = $1
+		ORDER BY r.level, r.id
+	`
+
+	for _, alert := range alerts {
+		alert.Recipients = []*models.AlertRecipient{}
+		err := readRows(ctx, r.db, func(rows *sql.Rows) error {
+			recipient, err := r.scanRecipient(rows, false)
+			if err != nil {
+				return err
+			}
+			alert.Recipients = append(alert.Recipients, recipient)
+			return nil
+		}, query, alert.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getAlert busca um alerta com os avisos; lê da réplica fora de transação
+func (r *AlertRepository) getAlert(ctx context.Context, where string, args ...any) (*models.Alert, error) {
+	query := `
+		SELECT ` + alertColumns + `
+		FROM sos_alerts a
+		JOIN users u ON u.id = a.user_id
+		WHERE ` + where
+
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	alert, err := r.scanAlert(r.db.Reader(ctx).QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadRecipients(ctx, alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+func (r *AlertRepository) GetByID(ctx context.Context, id, userID string) (*models.Alert, error) {
+	return r.getAlert(ctx, `a.id = $1 AND a.user_id = $2`, id, userID)
+}
+
+func (r *AlertRepository) GetActive(ctx context.Context, userID string) (*models.Alert, error) {
+	return r.getAlert(ctx, `a.user_id = $1 AND a.status = 'active'`, userID)
+}
+
+// GetForDelivery busca o alerta sem verificar o dono, para o envio em background
+func (r *AlertRepository) GetForDelivery(ctx context.Context, id string) (*models.Alert, error) {
+	return r.getAlert(ctx, `a.id = $1`, id)
+}
+
+// ListByUserID retorna todos os alertas do usuário, do mais recente ao mais antigo
+func (r *AlertRepository) ListByUserID(ctx context.Context, userID string) ([]*models.Alert, error) {
+	query := `
+		SELECT ` + alertColumns + `
+		FROM sos_alerts a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.user_id = $1
+		ORDER BY a.created_at DESC
+	`
+
+	alerts := []*models.Alert{}
+	err := readRows(ctx, r.db, func(rows *sql.Rows) error {
+		alert, err := r.scanAlert(rows)
+		if err != nil {
+			return err
+		}
+		alerts = append(alerts, alert)
+		return nil
+	}, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRecipients(ctx, alerts...); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// ListCreatedSince retorna os horários dos acionamentos recentes, do mais antigo
+// ao mais recente
+func (r *AlertRepository) ListCreatedSince(ctx context.Context, userID string, since time.Time) ([]time.Time, error) {
+	query := `SELECT created_at FROM sos_alerts WHERE user_id = $1 AND created_at > $2 ORDER BY created_at`
+
+	var times []time.Time
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		var createdAt time.Time
+		if err := rows.Scan(&createdAt); err != nil {
+			return err
+		}
+		times = append(times, createdAt)
+		return nil
+	}, query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	return times, nil
+}
+
+func (r *AlertRepository) Cancel(ctx context.Context, id, userID string, now time.Time) error {
+	query := `
+		UPDATE sos_alerts
+		SET status = 'cancelled', cancelled_at = $3, escalates_at = NULL, updated_at = $3
+		WHERE id = $1 AND user_id = $2 AND status IN ('active', 'acknowledged')
+	`
+	result, err := execContext(ctx, r.db, query, id, userID, now)
+	return checkAffected(result, err, 0)
+}
+
+func (r *AlertRepository) ListDueEscalations(ctx context.Context, now time.Time) ([]string, error) {
+	query := `
+		SELECT id FROM sos_alerts
+		WHERE status = 'active' AND level = $1 AND escalates_at <= $2
+		ORDER BY escalates_at
+	`
+
+	var ids []string
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	}, query, models.AlertLevelPrimary, now)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *AlertRepository) Escalate(ctx context.Context, id string, now time.Time) (bool, error) {
+	query := `
+		UPDATE sos_alerts
+		SET level = $2, escalates_at = NULL, updated_at = $3
+		WHERE id = $1 AND status = 'active' AND level < $2 AND escalates_at <= $3
+	`
+	result, err := execContext(ctx, r.db, query, id, models.AlertLevelOthers, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *AlertRepository) ClaimRecipients(ctx context.Context, alertID string, level int) ([]*models.AlertRecipient, error) {
+	query := `
+		WITH r AS (
+			UPDATE sos_recipients
+			SET status = 'sending'
+			WHERE alert_id = $1 AND level <= $2 AND status = 'pending'
+			RETURNING *
+		)
+		SELECT ` + recipientColumns + `, c.phone, c.email
+		FROM r
+		LEFT JOIN emergency_contacts c ON c.id = r.contact_id AND c.status = 'accepted'
+		ORDER BY r.level, r.id
+	`
+
+	var recipients []*models.AlertRecipient
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		recipient, err := r.scanRecipient(rows, true)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, recipient)
+		return nil
+	}, query, alertID, level)
+	if err != nil {
+		return nil, err
+	}
+	return recipients, nil
+}
+
+func (r *AlertRepository) RecordDelivery(ctx context.Context, recipientID, tokenHash, status string, channels []string, now time.Time) error {
+	query := `
+		UPDATE sos_recipients
+		SET status = $2, channels = $3, ack_token_hash = $4, notified_at = $5
+		WHERE id = $1
+	`
+	_, err := execContext(ctx, r.db, query, recipientID, status, strings.Join(channels, ","), tokenHash, now)
+	return err
+}
+
+func (r *AlertRepository) ListNotified(ctx context.Context, alertID string) ([]*models.AlertRecipient, error) {
+	query := `
+		SELECT ` + recipientColumns + `, c.phone, c.email
+		FROM sos_recipients r
+		LEFT JOIN emergency_contacts c ON c.id = r.contact_id
+		WHERE r.alert_id = $1 AND r.status IN ('notified', 'acknowledged')
+		ORDER BY r.level, r.id
+	`
+
+	var recipients []*models.AlertRecipient
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		recipient, err := r.scanRecipient(rows, true)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, recipient)
+		return nil
+	}, query, alertID)
+	if err != nil {
+		return nil, err
+	}
+	return recipients, nil
+}
+
+func (r *AlertRepository) GetNotice(ctx context.Context, tokenHash string, since time.Time) (*models.AlertNotice, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT a.id, COALESCE(u.full_name, u.username), a.status, a.location, a.created_at, a.acknowledged_at
+		FROM sos_recipients r
+		JOIN sos_alerts a ON a.id = r.alert_id
+		JOIN users u ON u.id = a.user_id
+		WHERE r.ack_token_hash = $1 AND a.created_at > $2
+	`
+
+	notice := &models.AlertNotice{}
+	var location *string
+	err := r.db.Executor(ctx).QueryRowContext(ctx, query, tokenHash, since).Scan(
+		&notice.AlertID, &notice.UserName, &notice.Status, &location, &notice.CreatedAt, &notice.AcknowledgedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if notice.Location, err = r.decryptLocation(location); err != nil {
+		return nil, err
+	}
+	return notice, nil
+}
+
+// Acknowledge registra a confirmação do contato. A primeira confirmação encerra
+// o escalonamento; confirmar um alerta cancelado só registra o aviso como lido.
+func (r *AlertRepository) Acknowledge(ctx context.Context, tokenHash string, since, now time.Time) (*models.AlertNotice, error) {
+	ackRecipient := `
+		UPDATE sos_recipients r
+		SET status = 'acknowledged', acknowledged_at = COALESCE(r.acknowledged_at, $3)
+		FROM sos_alerts a
+		WHERE a.id = r.alert_id AND r.ack_token_hash = $1 AND a.created_at > $2
+		RETURNING r.alert_id
+	`
+	ackAlert := `
+		UPDATE sos_alerts
+		SET status = 'acknowledged', acknowledged_at = $2, escalates_at = NULL, updated_at = $2
+		WHERE id = $1 AND status = 'active'
+	`
+
+	var notice *models.AlertNotice
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		var alertID string
+		err := func() error {
+			ctx, cancel := r.db.Timeout(ctx)
+			defer cancel()
+			return r.db.Executor(ctx).QueryRowContext(ctx, ackRecipient, tokenHash, since, now).Scan(&alertID)
+		}()
+		if err != nil {
+			return err
+		}
+		if _, err := execContext(ctx, r.db, ackAlert, alertID, now); err != nil {
+			return err
+		}
+		notice, err = r.GetNotice(ctx, tokenHash, since)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notice, nil
+}
+
+// ReencryptAll recifra com a master key ativa a localização dos alertas e o nome
+// dos contatos avisados, como ContactRepository.ReencryptAll
+func (r *AlertRepository) ReencryptAll(ctx context.Context, batchSize int) (int, error) {
+	alerts, err := reencryptColumn(ctx, r.db, r.keyring, batchSize, "sos_alerts", "location")
+	if err != nil {
+		return alerts, err
+	}
+	recipients, err := reencryptColumn(ctx, r.db, r.keyring, batchSize, "sos_recipients", "contact_name")
+	return alerts + recipients, err
+}
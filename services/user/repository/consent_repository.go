@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/database"
+)
+
+type ConsentRepository struct {
+	db *database.DB
+}
+
+func NewConsentRepository(db *database.DB) *ConsentRepository {
+	return &ConsentRepository{db: db}
+}
+
+// currentDocumentsQuery seleciona a versão vigente de cada tipo de documento
+const currentDocumentsQuery = `
+	SELECT DISTINCT ON (type) type, version, url, mandatory, published_at
+	FROM consent_documents
+	WHERE published_at <= CURRENT_TIMESTAMP
+	ORDER BY type, published_at DESC
+`
+
+// GetCurrentDocuments retorna a versão vigente de cada documento
+func (r *ConsentRepository) GetCurrentDocuments(ctx context.Context) ([]*models.ConsentDocument, error) {
+	return r.queryDocuments(ctx, currentDocumentsQuery)
+}
+
+// GetPendingMandatory retorna os documentos obrigatórios vigentes que o usuário
+// ainda não aceitou (ou cujo aceite foi retirado)
+func (r *ConsentRepository) GetPendingMandatory(ctx context.Context, userID string) ([]*models.ConsentDocument, error) {
+	query := `
+		SELECT d.type, d.version, d.url, d.mandatory, d.published_at
+		FROM (` + currentDocumentsQuery + `) d
+		WHERE d.mandatory = true
+		  AND NOT EXISTS (
+		      SELECT 1 FROM user_consents c
+		      WHERE c.user_id = $1 AND c.type = d.type AND c.version = d.version
+		        AND c.withdrawn_at IS NULL
+		  )
+		ORDER BY d.type
+	`
+	return r.queryDocuments(ctx, query, userID)
+}
+
+// PendingMandatoryTypes implementa middleware.ConsentChecker
+func (r *ConsentRepository) PendingMandatoryTypes(ctx context.Context, userID string) ([]string, error) {
+	docs, err := r.GetPendingMandatory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		types = append(types, doc.Type)
+	}
+	return types, nil
+}
+
+// Grant registra o aceite. Aceites anteriores ativos do mesmo tipo são
+// substituídos, mantendo o histórico.
+func (r *ConsentRepository) Grant(ctx context.Context, userID string, req *models.ConsentRequest, ipAddress string) (*models.Consent, error) {
+	consent := &models.Consent{}
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		_, err := execContext(ctx, r.db, `
+			UPDATE user_consents SET withdrawn_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND type = $2 AND withdrawn_at IS NULL
+		`, userID, req.Type)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := r.db.Timeout(ctx)
+		defer cancel()
+
+		query := `
+			INSERT INTO user_consents (user_id, type, version, ip_address)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, user_id, type, version, ip_address, granted_at, withdrawn_at
+		`
+
+		return r.db.Executor(ctx).QueryRowContext(ctx, query, userID, req.Type, req.Version, ipAddress).Scan(
+			&consent.ID, &consent.UserID, &consent.Type, &consent.Version,
+			&consent.IPAddress, &consent.GrantedAt, &consent.WithdrawnAt,
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return consent, nil
+}
+
+// Withdraw retira o consentimento ativo do tipo informado.
+// Retorna sql.ErrNoRows se não houver consentimento ativo.
+func (r *ConsentRepository) Withdraw(ctx context.Context, userID, consentType string) error {
+	result, err := execContext(ctx, r.db, `
+		UPDATE user_consents SET withdrawn_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND type = $2 AND withdrawn_at IS NULL
+	`, userID, consentType)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// HasActiveConsent implementa middleware.OptInChecker: o aceite de qualquer
+// versão vale até ser retirado
+func (r *ConsentRepository) HasActiveConsent(ctx context.Context, userID, consentType string) (bool, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	var active bool
+	err := r.db.Executor(ctx).QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_consents
+			WHERE user_id = $1 AND type = $2 AND withdrawn_at IS NULL
+		)
+	`, userID, consentType).Scan(&active)
+	return active, err
+}
+
+// GetByUserID retorna o histórico completo de consentimentos do usuário
+func (r *ConsentRepository) GetByUserID(ctx context.Context, userID string) ([]*models.Consent, error) {
+	query := `
+		SELECT id, user_id, type, version, ip_address, granted_at, withdrawn_at
+		FROM user_consents
+		WHERE user_id = $1
+		ORDER BY granted_at DESC
+	`
+
+	consents := []*models.Consent{}
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		consent := &models.Consent{}
+		err := rows.Scan(
+			&consent.ID, &consent.UserID, &consent.Type, &consent.Version,
+			&consent.IPAddress, &consent.GrantedAt, &consent.WithdrawnAt,
+		)
+		if err != nil {
+			return err
+		}
+		consents = append(consents, consent)
+		return nil
+	}, query, userID)
+
+	return consents, err
+}
+
+func (r *ConsentRepository) queryDocuments(ctx context.Context, query string, args ...any) ([]*models.ConsentDocument, error) {
+	docs := []*models.ConsentDocument{}
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		doc := &models.ConsentDocument{}
+		if err := rows.Scan(&doc.Type, &doc.Version, &doc.URL, &doc.Mandatory, &doc.PublishedAt); err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+		return nil
+	}, query, args...)
+
+	return docs, err
+}
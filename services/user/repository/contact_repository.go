@@ -1,104 +1,669 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/crypto"
+	"github.com/meuapoio/shared/database"
+	"github.com/meuapoio/shared/pagination"
+	"github.com/meuapoio/shared/phone"
 )
 
 type ContactRepository struct {
-	db *sql.DB
+	db          *database.DB
+	keyring     *crypto.Keyring
+	maxContacts int
 }
 
-func NewContactRepository(db *sql.DB) *ContactRepository {
-	return &ContactRepository{db: db}
+// NewContactRepository cria o repositório. Nome, telefone e email dos contatos
+// são cifrados com o keyring antes de irem para o banco. Create recusa contatos
+// além de maxContacts por usuário; zero não limita, para as rotinas de
+// manutenção que não criam contatos.
+func NewContactRepository(db *database.DB, keyring *crypto.Keyring, maxContacts int) *ContactRepository {
+	return &ContactRepository{db: db, keyring: keyring, maxContacts: maxContacts}
 }
 
-func (r *ContactRepository) Create(userID string, contact *models.CreateContactRequest) (*models.EmergencyContact, error) {
-	newContact := &models.EmergencyContact{}
+func (r *ContactRepository) MaxContacts() int {
+	return r.maxContacts
+}
 
-	query := `
-		INSERT INTO emergency_contacts (user_id, name, phone, relationship, is_primary)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, user_id, name, phone, relationship, is_primary, created_at
-	`
+const contactColumns = `
+	id, user_id, name, phone, phone_display, email, relationship, is_primary, status,
+	invitation_sent_at, invitation_expires_at, responded_at, created_at, updated_at, version
+`
 
-	err := r.db.QueryRow(
-		query, userID, contact.Name, contact.Phone, contact.Relationship, contact.IsPrimary,
-	).Scan(
-		&newContact.ID, &newContact.UserID, &newContact.Name, &newContact.Phone,
-		&newContact.Relationship, &newContact.IsPrimary, &newContact.CreatedAt,
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *ContactRepository) scanContact(row rowScanner) (*models.EmergencyContact, error) {
+	contact := &models.EmergencyContact{}
+	var encDisplay *string
+	err := row.Scan(
+		&contact.ID, &contact.UserID, &contact.Name, &contact.Phone, &encDisplay, &contact.Email,
+		&contact.Relationship, &contact.IsPrimary, &contact.Status,
+		&contact.InvitationSentAt, &contact.InvitationExpiresAt, &contact.RespondedAt,
+		&contact.CreatedAt, &contact.UpdatedAt, &contact.Version,
 	)
+	if err != nil {
+		return nil, err
+	}
 
-	return newContact, err
+	if contact.Name, err = r.keyring.Decrypt(contact.Name); err != nil {
+		return nil, err
+	}
+	if contact.Phone, err = r.keyring.Decrypt(contact.Phone); err != nil {
+		return nil, err
+	}
+	if contact.Email, err = r.keyring.DecryptPtr(contact.Email); err != nil {
+		return nil, err
+	}
+	display, err := r.keyring.DecryptPtr(encDisplay)
+	if err != nil {
+		return nil, err
+	}
+	if display != nil {
+		contact.PhoneDisplay = *display
+	} else {
+		// Linha ainda não convertida por normalize-phones
+		contact.PhoneDisplay = phone.Display(contact.Phone)
+	}
+
+	return contact, nil
 }
 
-func (r *ContactRepository) GetByUserID(userID string) ([]*models.EmergencyContact, error) {
+// Create insere o contato. O primeiro contato do usuário é sempre o principal;
+// marcar um novo como principal desmarca o anterior na mesma transação. O
+// bloqueio do dono serializa os cadastros simultâneos, então a contagem do
+// limite não deixa passar contatos a mais.
+func (r *ContactRepository) Create(ctx context.Context, userID string, contact *models.CreateContactRequest) (*models.EmergencyContact, error) {
+	name, err := r.keyring.Encrypt(contact.Name)
+	if err != nil {
+		return nil, err
+	}
+	encPhone, err := r.keyring.Encrypt(contact.Phone)
+	if err != nil {
+		return nil, err
+	}
+	encDisplay, err := r.keyring.Encrypt(phone.Display(contact.Phone))
+	if err != nil {
+		return nil, err
+	}
+	email, err := r.keyring.EncryptPtr(contact.Email)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, user_id, name, phone, relationship, is_primary, created_at
-		FROM emergency_contacts 
-		WHERE user_id = $1 
-		ORDER BY is_primary DESC, created_at DESC
-	`
+		INSERT INTO emergency_contacts (user_id, name, phone, phone_bidx, phone_display, relationship, is_primary, email)
+		VALUES ($1, $2, $3, $4, $5, $6,
+			$7 OR NOT EXISTS (SELECT 1 FROM emergency_contacts WHERE user_id = $1 AND is_primary), $8)
+		RETURNING ` + contactColumns
+
+	var created *models.EmergencyContact
+	err = r.db.WithTx(ctx, func(ctx context.Context) error {
+		if err := r.lockOwner(ctx, userID); err != nil {
+			return err
+		}
+		if err := r.checkLimit(ctx, userID); err != nil {
+			return err
+		}
+		if contact.IsPrimary {
+			if err := r.demotePrimary(ctx, userID, nil); err != nil {
+				return err
+			}
+		}
+
+		ctx, cancel := r.db.Timeout(ctx)
+		defer cancel()
 
-	rows, err := r.db.Query(query, userID)
+		created, err = r.scanContact(r.db.Executor(ctx).QueryRowContext(
+			ctx, query, userID, name, encPhone, phoneIndex(r.keyring, contact.Phone), encDisplay, contact.Relationship, contact.IsPrimary, email,
+		))
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return created, nil
+}
+
+// GetByUserID lê da réplica quando configurada, exceto dentro de uma transação
+func (r *ContactRepository) GetByUserID(ctx context.Context, userID string) ([]*models.EmergencyContact, error) {
+	query := `
+		SELECT ` + contactColumns + `
+		FROM emergency_contacts
+		WHERE user_id = $1
+		ORDER BY is_primary DESC, created_at DESC
+	`
 
 	var contacts []*models.EmergencyContact
-	for rows.Next() {
-		contact := &models.EmergencyContact{}
-		err := rows.Scan(
-			&contact.ID, &contact.UserID, &contact.Name, &contact.Phone,
-			&contact.Relationship, &contact.IsPrimary, &contact.CreatedAt,
-		)
+	err := readRows(ctx, r.db, func(rows *sql.Rows) error {
+		contact, err := r.scanContact(rows)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		contacts = append(contacts, contact)
+		return nil
+	}, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+// contactSortColumns são as colunas de cada ordenação da listagem, na ordem de
+// EmergencyContact.SortKey
+var contactSortColumns = map[string][]string{
+	models.ContactSortPrimary:   {"is_primary", "created_at", "id"},
+	models.ContactSortCreatedAt: {"created_at", "id"},
+	models.ContactSortUpdatedAt: {"updated_at", "id"},
+}
+
+// List lê da réplica quando configurada, exceto dentro de uma transação
+func (r *ContactRepository) List(ctx context.Context, userID string, req pagination.Request) ([]*models.EmergencyContact, error) {
+	columns, ok := contactSortColumns[req.Sort]
+	if !ok {
+		columns = contactSortColumns[models.ContactSortPrimary]
 	}
 
-	if err := rows.Err(); err != nil {
+	where := []string{"user_id = $1"}
+	args := []any{userID}
+	if status, ok := req.Filters[models.ContactFilterStatus]; ok {
+		args = append(args, status)
+		where = append(where, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if primary, ok := req.Filters[models.ContactFilterPrimary]; ok {
+		args = append(args, primary == "true")
+		where = append(where, fmt.Sprintf("is_primary = $%d", len(args)))
+	}
+	condition, orderBy, keysetArgs, err := req.Keyset(columns, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, keysetArgs...)
+	args = append(args, req.Limit+1)
+
+	query := `
+		SELECT ` + contactColumns + `
+		FROM emergency_contacts
+		WHERE ` + strings.Join(where, " AND ") + ` AND ` + condition + `
+		ORDER BY ` + orderBy + `
+		LIMIT $` + strconv.Itoa(len(args))
+
+	var contacts []*models.EmergencyContact
+	err = readRows(ctx, r.db, func(rows *sql.Rows) error {
+		contact, err := r.scanContact(rows)
+		if err != nil {
+			return err
+		}
+		contacts = append(contacts, contact)
+		return nil
+	}, query, args...)
+	if err != nil {
 		return nil, err
 	}
 
 	return contacts, nil
 }
 
-func (r *ContactRepository) GetByID(id, userID string) (*models.EmergencyContact, error) {
-	contact := &models.EmergencyContact{}
+// GetByID lê da réplica quando configurada, exceto dentro de uma transação
+func (r *ContactRepository) GetByID(ctx context.Context, id, userID string) (*models.EmergencyContact, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, user_id, name, phone, relationship, is_primary, created_at
-		FROM emergency_contacts 
+		SELECT ` + contactColumns + `
+		FROM emergency_contacts
 		WHERE id = $1 AND user_id = $2
 	`
 
-	err := r.db.QueryRow(query, id, userID).Scan(
-		&contact.ID, &contact.UserID, &contact.Name, &contact.Phone,
-		&contact.Relationship, &contact.IsPrimary, &contact.CreatedAt,
-	)
+	return r.scanContact(r.db.Reader(ctx).QueryRowContext(ctx, query, id, userID))
+}
+
+// GetByPhone busca um contato do usuário pelo telefone usando o blind index
+func (r *ContactRepository) GetByPhone(ctx context.Context, userID, phone string) (*models.EmergencyContact, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
 
-	return contact, err
+	query := `
+		SELECT ` + contactColumns + `
+		FROM emergency_contacts
+		WHERE user_id = $1 AND phone_bidx = $2
+		ORDER BY created_at
+		LIMIT 1
+	`
+
+	return r.scanContact(r.db.Executor(ctx).QueryRowContext(ctx, query, userID, phoneIndex(r.keyring, phone)))
 }
 
-func (r *ContactRepository) Update(id, userID string, req *models.UpdateContactRequest) error {
+// Update aplica os campos informados. Com version diferente de zero, retorna
+// ErrVersionConflict se o contato já estiver em outra versão. Marcar o contato
+// como principal desmarca o anterior; desmarcá-lo promove o contato mais antigo,
+// e o único contato do usuário continua principal.
+func (r *ContactRepository) Update(ctx context.Context, id, userID string, req *models.UpdateContactRequest, version int) error {
+	name, err := r.keyring.EncryptPtr(req.Name)
+	if err != nil {
+		return err
+	}
+	encPhone, err := r.keyring.EncryptPtr(req.Phone)
+	if err != nil {
+		return err
+	}
+	email, err := r.keyring.EncryptPtr(req.Email)
+	if err != nil {
+		return err
+	}
+
+	var phoneBidx, encDisplay *string
+	if req.Phone != nil {
+		index := phoneIndex(r.keyring, *req.Phone)
+		phoneBidx = &index
+		display := phone.Display(*req.Phone)
+		if encDisplay, err = r.keyring.EncryptPtr(&display); err != nil {
+			return err
+		}
+	}
+
 	query := `
-		UPDATE emergency_contacts 
+		UPDATE emergency_contacts
 		SET name = COALESCE($3, name),
 		    phone = COALESCE($4, phone),
-		    relationship = COALESCE($5, relationship),
-		    is_primary = COALESCE($6, is_primary)
-		WHERE id = $1 AND user_id = $2
+		    phone_bidx = COALESCE($5, phone_bidx),
+		    phone_display = COALESCE($9, phone_display),
+		    email = COALESCE($10, email),
+		    relationship = COALESCE($6, relationship),
+		    is_primary = COALESCE($7, is_primary),
+		    updated_at = CURRENT_TIMESTAMP,
+		    version = version + 1
+		WHERE id = $1 AND user_id = $2 AND ($8 = 0 OR version = $8)
+	`
+
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		if err := r.lockOwner(ctx, userID); err != nil {
+			return err
+		}
+		if req.IsPrimary != nil && *req.IsPrimary {
+			if err := r.demotePrimary(ctx, userID, &id); err != nil {
+				return err
+			}
+		}
+		if phoneBidx != nil {
+			if err := r.resetInvitation(ctx, id, userID, *phoneBidx); err != nil {
+				return err
+			}
+		}
+
+		result, err := execContext(ctx, r.db, query,
+			id, userID, name, encPhone, phoneBidx, req.Relationship, req.IsPrimary, version, encDisplay, email,
+		)
+		if err := checkAffected(result, err, version); err != nil {
+			return err
+		}
+		return r.ensurePrimary(ctx, userID, &id)
+	})
+}
+
+// SetPrimary torna o contato o principal do usuário, desmarcando o anterior na
+// mesma transação. version segue a regra de Update.
+func (r *ContactRepository) SetPrimary(ctx context.Context, id, userID string, version int) error {
+	query := `
+		UPDATE emergency_contacts
+		SET is_primary = true, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1 AND user_id = $2 AND ($3 = 0 OR version = $3)
 	`
 
-	_, err := r.db.Exec(query, id, userID, req.Name, req.Phone, req.Relationship, req.IsPrimary)
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		if err := r.lockOwner(ctx, userID); err != nil {
+			return err
+		}
+		if err := r.demotePrimary(ctx, userID, &id); err != nil {
+			return err
+		}
+		result, err := execContext(ctx, r.db, query, id, userID, version)
+		return checkAffected(result, err, version)
+	})
+}
+
+// Delete remove o contato; version segue a regra de Update. Se era o principal,
+// o contato mais antigo que restar é promovido.
+func (r *ContactRepository) Delete(ctx context.Context, id, userID string, version int) error {
+	query := `DELETE FROM emergency_contacts WHERE id = $1 AND user_id = $2 AND ($3 = 0 OR version = $3)`
+
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		if err := r.lockOwner(ctx, userID); err != nil {
+			return err
+		}
+		result, err := execContext(ctx, r.db, query, id, userID, version)
+		if err := checkAffected(result, err, version); err != nil {
+			return err
+		}
+		return r.ensurePrimary(ctx, userID, nil)
+	})
+}
+
+// resetInvitation descarta o convite e a resposta quando o telefone muda: quem
+// aceitou foi a pessoa do número anterior
+func (r *ContactRepository) resetInvitation(ctx context.Context, id, userID, phoneBidx string) error {
+	query := `
+		UPDATE emergency_contacts
+		SET status = 'pending', invitation_token_hash = NULL, invitation_sent_at = NULL,
+		    invitation_expires_at = NULL, responded_at = NULL
+		WHERE id = $1 AND user_id = $2 AND phone_bidx IS DISTINCT FROM $3
+	`
+	_, err := execContext(ctx, r.db, query, id, userID, phoneBidx)
 	return err
 }
 
-func (r *ContactRepository) Delete(id, userID string) error {
-	query := `DELETE FROM emergency_contacts WHERE id = $1 AND user_id = $2`
-	_, err := r.db.Exec(query, id, userID)
+// GetAccepted lê da réplica quando configurada, exceto dentro de uma transação
+func (r *ContactRepository) GetAccepted(ctx context.Context, userID string) ([]*models.EmergencyContact, error) {
+	query := `
+		SELECT ` + contactColumns + `
+		FROM emergency_contacts
+		WHERE user_id = $1 AND status = 'accepted'
+		ORDER BY is_primary DESC, created_at
+	`
+
+	var contacts []*models.EmergencyContact
+	err := readRows(ctx, r.db, func(rows *sql.Rows) error {
+		contact, err := r.scanContact(rows)
+		if err != nil {
+			return err
+		}
+		contacts = append(contacts, contact)
+		return nil
+	}, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+// invitationReturning são as colunas de models.ContactInvitation nas consultas
+// que juntam o contato ao usuário que o cadastrou (alias c e u)
+const invitationReturning = `
+	c.id, c.user_id, c.name, COALESCE(u.full_name, u.username), c.status, c.invitation_expires_at
+`
+
+func (r *ContactRepository) scanInvitation(row rowScanner) (*models.ContactInvitation, error) {
+	invitation := &models.ContactInvitation{}
+	err := row.Scan(
+		&invitation.ContactID, &invitation.UserID, &invitation.ContactName,
+		&invitation.InviterName, &invitation.Status, &invitation.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.ContactName, err = r.keyring.Decrypt(invitation.ContactName); err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// SetInvitation grava o hash do token de um novo convite, invalidando o anterior
+func (r *ContactRepository) SetInvitation(ctx context.Context, id, userID, tokenHash string, sentAt, expiresAt time.Time) (*models.ContactInvitation, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE emergency_contacts c
+		SET invitation_token_hash = $3, invitation_sent_at = $4,
+		    invitation_expires_at = $5, updated_at = CURRENT_TIMESTAMP, version = c.version + 1
+		FROM users u
+		WHERE c.id = $1 AND c.user_id = $2 AND u.id = c.user_id
+		RETURNING ` + invitationReturning
+
+	return r.scanInvitation(r.db.Executor(ctx).QueryRowContext(ctx, query, id, userID, tokenHash, sentAt, expiresAt))
+}
+
+// GetInvitation busca o convite vigente pelo hash do token
+func (r *ContactRepository) GetInvitation(ctx context.Context, tokenHash string, now time.Time) (*models.ContactInvitation, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + invitationReturning + `
+		FROM emergency_contacts c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.invitation_token_hash = $1 AND c.invitation_expires_at > $2 AND u.is_active
+	`
+
+	return r.scanInvitation(r.db.Executor(ctx).QueryRowContext(ctx, query, tokenHash, now))
+}
+
+// RespondInvitation grava a resposta ao convite. Enquanto o link vale, a pessoa
+// convidada pode mudar de ideia.
+func (r *ContactRepository) RespondInvitation(ctx context.Context, tokenHash, status string, now time.Time) (*models.ContactInvitation, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE emergency_contacts c
+		SET status = $2, responded_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP,
+		    version = c.version + 1
+		FROM users u
+		WHERE c.invitation_token_hash = $1 AND c.invitation_expires_at > $3
+		  AND u.id = c.user_id AND u.is_active
+		RETURNING ` + invitationReturning
+
+	return r.scanInvitation(r.db.Executor(ctx).QueryRowContext(ctx, query, tokenHash, status, now))
+}
+
+// lockOwner bloqueia a linha do usuário até o fim da transação, serializando as
+// alterações de contato principal feitas em paralelo para o mesmo usuário.
+// Retorna ErrAccountInactive se a conta foi desativada.
+func (r *ContactRepository) lockOwner(ctx context.Context, userID string) error {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	return lockActiveUser(ctx, r.db.Executor(ctx), userID)
+}
+
+// checkLimit retorna *ContactLimitError se o usuário já tiver maxContacts
+// contatos. Roda depois de lockOwner, na mesma transação.
+func (r *ContactRepository) checkLimit(ctx context.Context, userID string) error {
+	if r.maxContacts <= 0 {
+		return nil
+	}
+
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	var count int
+	err := r.db.Executor(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM emergency_contacts WHERE user_id = $1`, userID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count >= r.maxContacts {
+		return &ContactLimitError{Max: r.maxContacts}
+	}
+	return nil
+}
+
+// demotePrimary desmarca o contato principal atual, exceto exceptID. Precisa
+// rodar antes de marcar outro, por causa do índice único parcial.
+func (r *ContactRepository) demotePrimary(ctx context.Context, userID string, exceptID *string) error {
+	query := `
+		UPDATE emergency_contacts
+		SET is_primary = false, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE user_id = $1 AND is_primary AND id IS DISTINCT FROM $2
+	`
+	_, err := execContext(ctx, r.db, query, userID, exceptID)
+	return err
+}
+
+// ensurePrimary promove o contato mais antigo quando o usuário ficou sem
+// principal, deixando avoidID por último na escolha
+func (r *ContactRepository) ensurePrimary(ctx context.Context, userID string, avoidID *string) error {
+	query := `
+		UPDATE emergency_contacts
+		SET is_primary = true, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = (
+			SELECT id FROM emergency_contacts
+			WHERE user_id = $1
+			  AND NOT EXISTS (SELECT 1 FROM emergency_contacts WHERE user_id = $1 AND is_primary)
+			ORDER BY id IS NOT DISTINCT FROM $2, created_at, id
+			LIMIT 1
+		)
+	`
+	_, err := execContext(ctx, r.db, query, userID, avoidID)
 	return err
 }
+
+// ReencryptAll recifra com a master key ativa os contatos ainda em texto puro ou
+// cifrados com chaves antigas, em lotes. Retorna quantos contatos foram atualizados.
+func (r *ContactRepository) ReencryptAll(ctx context.Context, batchSize int) (int, error) {
+	type row struct {
+		id, name, phone     string
+		phoneDisplay, email *string
+	}
+
+	updated := 0
+	lastID := minUUID
+	for {
+		var batch []row
+		err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+			var item row
+			if err := rows.Scan(&item.id, &item.name, &item.phone, &item.phoneDisplay, &item.email); err != nil {
+				return err
+			}
+			batch = append(batch, item)
+			return nil
+		}, `SELECT id, name, phone, phone_display, email FROM emergency_contacts WHERE id > $1 ORDER BY id LIMIT $2`, lastID, batchSize)
+		if err != nil {
+			return updated, err
+		}
+
+		for _, item := range batch {
+			if !r.keyring.NeedsRotation(item.name) && !r.keyring.NeedsRotation(item.phone) &&
+				!needsRotation(r.keyring, item.phoneDisplay) && !needsRotation(r.keyring, item.email) {
+				continue
+			}
+
+			name, err := r.keyring.Decrypt(item.name)
+			if err != nil {
+				return updated, err
+			}
+			phone, err := r.keyring.Decrypt(item.phone)
+			if err != nil {
+				return updated, err
+			}
+
+			encName, err := r.keyring.Encrypt(name)
+			if err != nil {
+				return updated, err
+			}
+			encPhone, err := r.keyring.Encrypt(phone)
+			if err != nil {
+				return updated, err
+			}
+			display, err := r.keyring.DecryptPtr(item.phoneDisplay)
+			if err != nil {
+				return updated, err
+			}
+			if display, err = r.keyring.EncryptPtr(display); err != nil {
+				return updated, err
+			}
+			email, err := r.keyring.DecryptPtr(item.email)
+			if err != nil {
+				return updated, err
+			}
+			if email, err = r.keyring.EncryptPtr(email); err != nil {
+				return updated, err
+			}
+
+			rotated, err := rotateRow(ctx, r.db, `
+				UPDATE emergency_contacts SET name = $2, phone = $3, phone_bidx = $4, phone_display = $5, email = $6
+				WHERE id = $1 AND name = $7 AND phone = $8
+				  AND phone_display IS NOT DISTINCT FROM $9 AND email IS NOT DISTINCT FROM $10`,
+				item.id, encName, encPhone, phoneIndex(r.keyring, phone), display, email,
+				item.name, item.phone, item.phoneDisplay, item.email,
+			)
+			if err != nil {
+				return updated, err
+			}
+			if rotated {
+				updated++
+			}
+		}
+
+		if len(batch) < batchSize {
+			return updated, nil
+		}
+		lastID = batch[len(batch)-1].id
+	}
+}
+
+// NormalizePhones converte para E.164, em lotes, os telefones de contatos
+// gravados antes da validação, como UserRepository.NormalizePhones
+func (r *ContactRepository) NormalizePhones(ctx context.Context, batchSize int, region string) (int, []string, error) {
+	type row struct {
+		id, phone    string
+		phoneDisplay *string
+	}
+
+	updated := 0
+	var invalid []string
+	lastID := minUUID
+	for {
+		var batch []row
+		err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+			var item row
+			if err := rows.Scan(&item.id, &item.phone, &item.phoneDisplay); err != nil {
+				return err
+			}
+			batch = append(batch, item)
+			return nil
+		}, `SELECT id, phone, phone_display FROM emergency_contacts WHERE id > $1 ORDER BY id LIMIT $2`, lastID, batchSize)
+		if err != nil {
+			return updated, invalid, err
+		}
+
+		for _, item := range batch {
+			raw, err := r.keyring.Decrypt(item.phone)
+			if err != nil {
+				return updated, invalid, err
+			}
+
+			number, err := phone.Parse(raw, region)
+			if err != nil {
+				invalid = append(invalid, item.id)
+				continue
+			}
+			if number.E164() == raw && item.phoneDisplay != nil {
+				continue
+			}
+
+			encPhone, err := r.keyring.Encrypt(number.E164())
+			if err != nil {
+				return updated, invalid, err
+			}
+			encDisplay, err := r.keyring.Encrypt(number.Display())
+			if err != nil {
+				return updated, invalid, err
+			}
+
+			_, err = execContext(ctx, r.db,
+				`UPDATE emergency_contacts SET phone = $2, phone_bidx = $3, phone_display = $4, version = version + 1 WHERE id = $1`,
+				item.id, encPhone, phoneIndex(r.keyring, number.E164()), encDisplay,
+			)
+			if err != nil {
+				return updated, invalid, err
+			}
+			updated++
+		}
+
+		if len(batch) < batchSize {
+			return updated, invalid, nil
+		}
+		lastID = batch[len(batch)-1].id
+	}
+}
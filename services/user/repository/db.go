@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/meuapoio/shared/database"
+)
+
+// queryRows executa a consulta com o timeout padrão, dentro da transação do
+// contexto se houver, chamando fn para cada linha do resultado
+func queryRows(ctx context.Context, db *database.DB, fn func(rows *sql.Rows) error, query string, args ...any) error {
+	return scanRows(ctx, db, db.Executor, fn, query, args...)
+}
+
+// readRows é como queryRows, mas fora de transação lê da réplica quando configurada
+func readRows(ctx context.Context, db *database.DB, fn func(rows *sql.Rows) error, query string, args ...any) error {
+	return scanRows(ctx, db, db.Reader, fn, query, args...)
+}
+
+func scanRows(ctx context.Context, db *database.DB, executor func(context.Context) database.Executor,
+	fn func(rows *sql.Rows) error, query string, args ...any) error {
+	ctx, cancel := db.Timeout(ctx)
+	defer cancel()
+
+	rows, err := executor(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// execContext executa o comando com o timeout padrão, dentro da transação do
+// contexto se houver
+func execContext(ctx context.Context, db *database.DB, query string, args ...any) (sql.Result, error) {
+	ctx, cancel := db.Timeout(ctx)
+	defer cancel()
+
+	return db.Executor(ctx).ExecContext(ctx, query, args...)
+}
+
+// lockActiveUser bloqueia a linha do usuário até o fim da transação. Retorna
+// ErrAccountInactive se a conta foi desativada ou já não existe.
+func lockActiveUser(ctx context.Context, exec database.Executor, userID string) error {
+	var active bool
+	err := exec.QueryRowContext(ctx, `SELECT is_active FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&active)
+	if err == sql.ErrNoRows || (err == nil && !active) {
+		return ErrAccountInactive
+	}
+	return err
+}
+
+// checkAffected trata o resultado de uma escrita condicionada a version: sem
+// linhas afetadas, a versão informada já não é a atual ou, sem versão, o
+// registro não existe
+func checkAffected(result sql.Result, err error, version int) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+	if version != 0 {
+		return ErrVersionConflict
+	}
+	return sql.ErrNoRows
+}
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/database"
+)
+
+type ExportRepository struct {
+	db *database.DB
+}
+
+func NewExportRepository(db *database.DB) *ExportRepository {
+	return &ExportRepository{db: db}
+}
+
+const exportColumns = `id, user_id, status, file_path, error, created_at, completed_at, expires_at`
+
+func scanExport(row rowScanner) (*models.DataExport, error) {
+	export := &models.DataExport{}
+	err := row.Scan(
+		&export.ID, &export.UserID, &export.Status, &export.FilePath, &export.Error,
+		&export.CreatedAt, &export.CompletedAt, &export.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+func (r *ExportRepository) Create(ctx context.Context, userID string) (*models.DataExport, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO data_exports (user_id, status)
+		VALUES ($1, $2)
+		RETURNING ` + exportColumns
+
+	return scanExport(r.db.Executor(ctx).QueryRowContext(ctx, query, userID, models.ExportStatusPending))
+}
+
+func (r *ExportRepository) GetByID(ctx context.Context, id, userID string) (*models.DataExport, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + exportColumns + ` FROM data_exports WHERE id = $1 AND user_id = $2`
+	return scanExport(r.db.Executor(ctx).QueryRowContext(ctx, query, id, userID))
+}
+
+// GetByIDForDownload busca o pedido sem filtrar por usuário; o acesso é autorizado
+// pela assinatura do link
+func (r *ExportRepository) GetByIDForDownload(ctx context.Context, id string) (*models.DataExport, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + exportColumns + ` FROM data_exports WHERE id = $1`
+	return scanExport(r.db.Executor(ctx).QueryRowContext(ctx, query, id))
+}
+
+// GetActiveByUserID retorna um pedido ainda em andamento, se existir
+func (r *ExportRepository) GetActiveByUserID(ctx context.Context, userID string) (*models.DataExport, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + exportColumns + `
+		FROM data_exports
+		WHERE user_id = $1 AND status IN ($2, $3)
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	return scanExport(r.db.Executor(ctx).QueryRowContext(ctx, query, userID, models.ExportStatusPending, models.ExportStatusProcessing))
+}
+
+// ListPendingIDs retorna os pedidos que ainda precisam ser processados (ex.: após um restart)
+func (r *ExportRepository) ListPendingIDs(ctx context.Context) ([]string, error) {
+	query := `SELECT id FROM data_exports WHERE status IN ($1, $2) ORDER BY created_at`
+	return r.listStrings(ctx, query, models.ExportStatusPending, models.ExportStatusProcessing)
+}
+
+// ListExpired retorna os pedidos concluídos cujo link de download já expirou
+func (r *ExportRepository) ListExpired(ctx context.Context, now time.Time) ([]*models.DataExport, error) {
+	query := `
+		SELECT ` + exportColumns + `
+		FROM data_exports
+		WHERE status = $1 AND expires_at < $2
+	`
+
+	var exports []*models.DataExport
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		export, err := scanExport(rows)
+		if err != nil {
+			return err
+		}
+		exports = append(exports, export)
+		return nil
+	}, query, models.ExportStatusCompleted, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return exports, nil
+}
+
+func (r *ExportRepository) GetForProcessing(ctx context.Context, id string) (*models.DataExport, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE data_exports SET status = $2
+		WHERE id = $1 AND status IN ($3, $2)
+		RETURNING ` + exportColumns
+
+	return scanExport(r.db.Executor(ctx).QueryRowContext(ctx, query, id, models.ExportStatusProcessing, models.ExportStatusPending))
+}
+
+func (r *ExportRepository) MarkCompleted(ctx context.Context, id, filePath string, expiresAt time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET status = $2, file_path = $3, completed_at = CURRENT_TIMESTAMP, expires_at = $4
+		WHERE id = $1
+	`
+	_, err := execContext(ctx, r.db, query, id, models.ExportStatusCompleted, filePath, expiresAt)
+	return err
+}
+
+func (r *ExportRepository) MarkFailed(ctx context.Context, id, reason string) error {
+	query := `UPDATE data_exports SET status = $2, error = $3 WHERE id = $1`
+	_, err := execContext(ctx, r.db, query, id, models.ExportStatusFailed, reason)
+	return err
+}
+
+func (r *ExportRepository) MarkExpired(ctx context.Context, id string) error {
+	query := `UPDATE data_exports SET status = $2, file_path = NULL WHERE id = $1`
+	_, err := execContext(ctx, r.db, query, id, models.ExportStatusExpired)
+	return err
+}
+
+// ListFilePaths retorna os arquivos de exportação ainda em disco de um usuário
+func (r *ExportRepository) ListFilePaths(ctx context.Context, userID string) ([]string, error) {
+	query := `SELECT file_path FROM data_exports WHERE user_id = $1 AND file_path IS NOT NULL`
+	return r.listStrings(ctx, query, userID)
+}
+
+func (r *ExportRepository) GetFavorites(ctx context.Context, userID string) ([]*models.FavoriteExport, error) {
+	query := `
+		SELECT f.audio_id, a.title, f.created_at
+		FROM user_favorites f
+		JOIN audios a ON a.id = f.audio_id
+		WHERE f.user_id = $1
+		ORDER BY f.created_at
+	`
+
+	favorites := []*models.FavoriteExport{}
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		favorite := &models.FavoriteExport{}
+		if err := rows.Scan(&favorite.AudioID, &favorite.Title, &favorite.CreatedAt); err != nil {
+			return err
+		}
+		favorites = append(favorites, favorite)
+		return nil
+	}, query, userID)
+
+	return favorites, err
+}
+
+func (r *ExportRepository) GetPlayHistory(ctx context.Context, userID string) ([]*models.PlayHistoryExport, error) {
+	query := `
+		SELECT h.audio_id, a.title, h.played_at, h.completion_percentage
+		FROM user_play_history h
+		JOIN audios a ON a.id = h.audio_id
+		WHERE h.user_id = $1
+		ORDER BY h.played_at
+	`
+
+	history := []*models.PlayHistoryExport{}
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		entry := &models.PlayHistoryExport{}
+		if err := rows.Scan(&entry.AudioID, &entry.Title, &entry.PlayedAt, &entry.CompletionPercentage); err != nil {
+			return err
+		}
+		history = append(history, entry)
+		return nil
+	}, query, userID)
+
+	return history, err
+}
+
+func (r *ExportRepository) GetNotifications(ctx context.Context, userID string) ([]*models.NotificationExport, error) {
+	query := `
+		SELECT id, title, message, type, is_read, scheduled_for, sent_at, created_at
+		FROM notifications
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	notifications := []*models.NotificationExport{}
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		n := &models.NotificationExport{}
+		if err := rows.Scan(&n.ID, &n.Title, &n.Message, &n.Type, &n.IsRead, &n.ScheduledFor, &n.SentAt, &n.CreatedAt); err != nil {
+			return err
+		}
+		notifications = append(notifications, n)
+		return nil
+	}, query, userID)
+
+	return notifications, err
+}
+
+func (r *ExportRepository) listStrings(ctx context.Context, query string, args ...any) ([]string, error) {
+	var values []string
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return err
+		}
+		values = append(values, value)
+		return nil
+	}, query, args...)
+
+	return values, err
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/meuapoio/shared/crypto"
+	"github.com/meuapoio/shared/database"
+	sharedmw "github.com/meuapoio/shared/middleware"
+)
+
+// IdempotencyRepository implementa sharedmw.IdempotencyStore sobre a tabela
+// idempotency_keys, o que vale também entre várias instâncias do serviço
+type IdempotencyRepository struct {
+	db      *database.DB
+	keyring *crypto.Keyring
+}
+
+// NewIdempotencyRepository cria o repositório. Os corpos gravados são cifrados
+// com o keyring, como os demais dados pessoais.
+func NewIdempotencyRepository(db *database.DB, keyring *crypto.Keyring) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db, keyring: keyring}
+}
+
+var _ sharedmw.IdempotencyStore = (*IdempotencyRepository)(nil)
+
+// nullableUserID grava as chaves de rotas públicas com user_id NULL
+func nullableUserID(userID string) *string {
+	if userID == "" {
+		return nil
+	}
+	return &userID
+}
+
+// Reserve insere a chave ou, se o registro existente já expirou, o substitui. O
+// ON CONFLICT garante que apenas uma requisição concorrente obtém a reserva.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, key sharedmw.IdempotencyKey, fingerprint string, expiresAt time.Time) (*sharedmw.IdempotentResponse, error) {
+	// Uma segunda tentativa cobre a chave liberada entre o INSERT e o SELECT
+	for attempt := 0; attempt < 2; attempt++ {
+		reserved, err := r.insert(ctx, key, fingerprint, expiresAt)
+		if err != nil || reserved {
+			return nil, err
+		}
+
+		existing, err := r.get(ctx, key)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		return existing, err
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *IdempotencyRepository) insert(ctx context.Context, key sharedmw.IdempotencyKey, fingerprint string, expiresAt time.Time) (bool, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO idempotency_keys (user_id, route, idempotency_key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, route, idempotency_key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, headers = NULL, body = NULL,
+			created_at = CURRENT_TIMESTAMP, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= $6
+		RETURNING true
+	`
+
+	var reserved bool
+	err := r.db.Executor(ctx).QueryRowContext(ctx, query,
+		nullableUserID(key.UserID), key.Route, key.Key, fingerprint, expiresAt, time.Now(),
+	).Scan(&reserved)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return reserved, err
+}
+
+func (r *IdempotencyRepository) get(ctx context.Context, key sharedmw.IdempotencyKey) (*sharedmw.IdempotentResponse, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT fingerprint, status_code, headers, body
+		FROM idempotency_keys
+		WHERE user_id IS NOT DISTINCT FROM $1 AND route = $2 AND idempotency_key = $3
+	`
+
+	var (
+		response   sharedmw.IdempotentResponse
+		statusCode sql.NullInt64
+		headers    []byte
+		body       sql.NullString
+	)
+	err := r.db.Executor(ctx).QueryRowContext(ctx, query, nullableUserID(key.UserID), key.Route, key.Key).
+		Scan(&response.Fingerprint, &statusCode, &headers, &body)
+	if err != nil {
+		return nil, err
+	}
+	if !statusCode.Valid {
+		return &response, nil
+	}
+
+	response.Completed = true
+	response.StatusCode = int(statusCode.Int64)
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &response.Header); err != nil {
+			return nil, err
+		}
+	}
+	plaintext, err := r.keyring.Decrypt(body.String)
+	if err != nil {
+		return nil, err
+	}
+	response.Body = []byte(plaintext)
+	return &response, nil
+}
+
+// Complete grava a resposta da chave reservada pela mesma requisição
+func (r *IdempotencyRepository) Complete(ctx context.Context, key sharedmw.IdempotencyKey, response *sharedmw.IdempotentResponse, expiresAt time.Time) error {
+	headers, err := json.Marshal(headerOrEmpty(response.Header))
+	if err != nil {
+		return err
+	}
+	body, err := r.keyring.Encrypt(string(response.Body))
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $5, headers = $6, body = $7, expires_at = $8
+		WHERE user_id IS NOT DISTINCT FROM $1 AND route = $2 AND idempotency_key = $3 AND fingerprint = $4
+	`
+	_, err = execContext(ctx, r.db, query,
+		nullableUserID(key.UserID), key.Route, key.Key, response.Fingerprint,
+		response.StatusCode, headers, body, expiresAt,
+	)
+	return err
+}
+
+// Release remove a reserva de uma requisição que não terminou com sucesso
+func (r *IdempotencyRepository) Release(ctx context.Context, key sharedmw.IdempotencyKey) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE user_id IS NOT DISTINCT FROM $1 AND route = $2 AND idempotency_key = $3 AND status_code IS NULL
+	`
+	_, err := execContext(ctx, r.db, query, nullableUserID(key.UserID), key.Route, key.Key)
+	return err
+}
+
+// DeleteExpired apaga as chaves vencidas e retorna quantas foram removidas
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := execContext(ctx, r.db, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func headerOrEmpty(header http.Header) http.Header {
+	if header == nil {
+		return http.Header{}
+	}
+	return header
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/pagination"
+)
+
+// ErrVersionConflict indica que a linha foi alterada por outra escrita depois da
+// versão informada. As escritas que recebem version só verificam quando ela é
+// diferente de zero; sem versão, uma escrita em registro inexistente retorna
+// sql.ErrNoRows.
+var ErrVersionConflict = errors.New("registro alterado desde a versão informada")
+
+// ErrAccountInactive indica que a conta foi desativada (exclusão agendada) ou
+// não existe mais. Escritas em nome do usuário verificam a conta na mesma
+// transação, para que um token emitido antes da desativação não as conclua.
+var ErrAccountInactive = errors.New("conta desativada")
+
+// ErrAlertActive indica que o usuário já tem um alerta de SOS ativo
+var ErrAlertActive = errors.New("já existe um alerta ativo")
+
+// ContactLimitError indica que o usuário já tem o máximo de contatos de
+// emergência permitido
+type ContactLimitError struct {
+	Max int
+}
+
+func (e *ContactLimitError) Error() string {
+	return fmt.Sprintf("limite de %d contatos de emergência atingido", e.Max)
+}
+
+// UserStore é o contrato de persistência de usuários usado pelos handlers e pelas
+// rotinas em background. Implementado por UserRepository (PostgreSQL) e por
+// memory.UserRepository (testes). Buscas sem resultado retornam sql.ErrNoRows.
+type UserStore interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	// GetByIDIncludingDeactivated também encontra contas no período de carência
+	// da exclusão, que ainda podem exportar seus dados
+	GetByIDIncludingDeactivated(ctx context.Context, id string) (*models.User, error)
+	// IsActive informa se a conta existe e não está com a exclusão agendada
+	IsActive(ctx context.Context, id string) (bool, error)
+	GetByPhone(ctx context.Context, phone string) (*models.User, error)
+	Update(ctx context.Context, id string, req *models.UpdateUserRequest, version int) error
+	// SetProfileImage troca a foto de perfil atual; imageID nil remove a foto
+	SetProfileImage(ctx context.Context, id string, imageID *string, version int) error
+	PreferredLanguage(ctx context.Context, id string) (string, error)
+	ScheduleDeletion(ctx context.Context, id string, scheduledFor time.Time, version int) error
+	CancelDeletion(ctx context.Context, id string) error
+	ListDueForErasure(ctx context.Context, now time.Time) ([]string, error)
+	Erase(ctx context.Context, id string, now time.Time) (bool, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	// IsAdmin informa se o usuário ativo pode editar o diretório de recursos de crise
+	IsAdmin(ctx context.Context, id string) (bool, error)
+	// SetAdmin concede ou revoga o papel de administrador da conta com o email
+	SetAdmin(ctx context.Context, email string, admin bool) error
+}
+
+// ContactStore é o contrato de persistência dos contatos de emergência. Toda
+// operação recebe o dono do contato; contatos de outro usuário se comportam
+// como inexistentes. Quem tem contatos tem exatamente um principal: as escritas
+// desmarcam o anterior ou promovem outro na mesma transação. Trocar o telefone
+// de um contato descarta a resposta ao convite anterior.
+type ContactStore interface {
+	// Create retorna *ContactLimitError se o usuário já tiver o máximo de
+	// contatos configurado no repositório
+	Create(ctx context.Context, userID string, contact *models.CreateContactRequest) (*models.EmergencyContact, error)
+	// MaxContacts é o limite aplicado por Create; zero não limita
+	MaxContacts() int
+	GetByUserID(ctx context.Context, userID string) ([]*models.EmergencyContact, error)
+	// List lê uma página dos contatos na ordenação e com os filtros de req
+	// (models.ContactSort* e models.ContactFilter*). Traz até req.Limit+1
+	// contatos, para pagination.NewPage saber se há próxima página.
+	List(ctx context.Context, userID string, req pagination.Request) ([]*models.EmergencyContact, error)
+	GetByID(ctx context.Context, id, userID string) (*models.EmergencyContact, error)
+	GetByPhone(ctx context.Context, userID, phone string) (*models.EmergencyContact, error)
+	Update(ctx context.Context, id, userID string, req *models.UpdateContactRequest, version int) error
+	SetPrimary(ctx context.Context, id, userID string, version int) error
+	Delete(ctx context.Context, id, userID string, version int) error
+	// GetAccepted lista os contatos que aceitaram o convite, os únicos que
+	// recebem alertas
+	GetAccepted(ctx context.Context, userID string) ([]*models.EmergencyContact, error)
+	// SetInvitation grava o hash do token de um novo convite, invalidando o anterior
+	SetInvitation(ctx context.Context, id, userID, tokenHash string, sentAt, expiresAt time.Time) (*models.ContactInvitation, error)
+	// GetInvitation e RespondInvitation não recebem o dono: o token identifica o
+	// contato. Convites vencidos ou de contas inativas retornam sql.ErrNoRows.
+	GetInvitation(ctx context.Context, tokenHash string, now time.Time) (*models.ContactInvitation, error)
+	RespondInvitation(ctx context.Context, tokenHash, status string, now time.Time) (*models.ContactInvitation, error)
+}
+
+// AlertStore é o contrato de persistência dos alertas de SOS. As consultas pelo
+// dono seguem a regra do ContactStore; as demais são usadas pelo envio em
+// background e pelos links de confirmação, identificados pelo hash do token.
+type AlertStore interface {
+	// Create grava o alerta com um aviso pendente por contato, o primeiro no nível
+	// do principal e os demais no seguinte. Retorna ErrAlertActive se o usuário
+	// já tiver um alerta ativo.
+	Create(ctx context.Context, alert *models.Alert, contacts []*models.EmergencyContact) error
+	GetByID(ctx context.Context, id, userID string) (*models.Alert, error)
+	GetActive(ctx context.Context, userID string) (*models.Alert, error)
+	ListByUserID(ctx context.Context, userID string) ([]*models.Alert, error)
+	ListCreatedSince(ctx context.Context, userID string, since time.Time) ([]time.Time, error)
+	// Cancel encerra um alerta ativo ou confirmado
+	Cancel(ctx context.Context, id, userID string, now time.Time) error
+
+	ListDueEscalations(ctx context.Context, now time.Time) ([]string, error)
+	// Escalate passa o alerta para o nível seguinte; false se ele já foi
+	// confirmado, cancelado ou escalonado
+	Escalate(ctx context.Context, id string, now time.Time) (bool, error)
+	GetForDelivery(ctx context.Context, id string) (*models.Alert, error)
+	// ClaimRecipients marca como em envio os avisos pendentes até o nível e os
+	// retorna com o telefone e o email atuais dos contatos que ainda aceitam
+	ClaimRecipients(ctx context.Context, alertID string, level int) ([]*models.AlertRecipient, error)
+	RecordDelivery(ctx context.Context, recipientID, tokenHash, status string, channels []string, now time.Time) error
+	// ListNotified retorna os avisos já entregues, com os endereços atuais
+	ListNotified(ctx context.Context, alertID string) ([]*models.AlertRecipient, error)
+
+	// GetNotice e Acknowledge ignoram os links de alertas criados antes de since
+	GetNotice(ctx context.Context, tokenHash string, since time.Time) (*models.AlertNotice, error)
+	Acknowledge(ctx context.Context, tokenHash string, since, now time.Time) (*models.AlertNotice, error)
+}
+
+// ResourceStore é o contrato de persistência do diretório de recursos de crise.
+// List filtra por região e tipo, do recurso mais prioritário ao menos; idioma e
+// horário de funcionamento são avaliados por quem lista.
+type ResourceStore interface {
+	List(ctx context.Context, filter *models.ResourceFilter) ([]*models.CrisisResource, error)
+	GetByID(ctx context.Context, id string) (*models.CrisisResource, error)
+	Create(ctx context.Context, req *models.ResourceRequest) (*models.CrisisResource, error)
+	// Update substitui todos os campos; version segue a regra de ErrVersionConflict
+	Update(ctx context.Context, id string, req *models.ResourceRequest, version int) error
+	Delete(ctx context.Context, id string, version int) error
+}
+
+// SafetyPlanStore é o contrato de persistência do plano de segurança, um por
+// usuário. Sem plano, as leituras retornam sql.ErrNoRows.
+type SafetyPlanStore interface {
+	Get(ctx context.Context, userID string) (*models.SafetyPlan, error)
+	// Save cria o plano ou grava uma nova versão, guardada também no histórico;
+	// version segue a regra de ErrVersionConflict
+	Save(ctx context.Context, userID string, sections []models.SafetyPlanSection, version int) (*models.SafetyPlan, error)
+	// Delete apaga o plano com o histórico e os links de compartilhamento
+	Delete(ctx context.Context, userID string, version int) error
+	ListRevisions(ctx context.Context, userID string) ([]*models.SafetyPlanRevision, error)
+	GetRevision(ctx context.Context, userID string, version int) (*models.SafetyPlan, error)
+
+	CreateShare(ctx context.Context, userID, contactID, tokenHash string, expiresAt time.Time) (*models.SafetyPlanShare, error)
+	ListShares(ctx context.Context, userID string) ([]*models.SafetyPlanShare, error)
+	RevokeShare(ctx context.Context, id, userID string, now time.Time) error
+	// ViewShared não recebe o dono: o hash do token identifica o link. Links
+	// vencidos, revogados, de contas inativas ou de contatos que deixaram de
+	// aceitar retornam sql.ErrNoRows.
+	ViewShared(ctx context.Context, tokenHash string, now time.Time) (*models.SharedSafetyPlan, error)
+}
+
+// ConsentStore é o contrato de persistência dos documentos e aceites
+type ConsentStore interface {
+	GetCurrentDocuments(ctx context.Context) ([]*models.ConsentDocument, error)
+	GetPendingMandatory(ctx context.Context, userID string) ([]*models.ConsentDocument, error)
+	PendingMandatoryTypes(ctx context.Context, userID string) ([]string, error)
+	Grant(ctx context.Context, userID string, req *models.ConsentRequest, ipAddress string) (*models.Consent, error)
+	Withdraw(ctx context.Context, userID, consentType string) error
+	HasActiveConsent(ctx context.Context, userID, consentType string) (bool, error)
+	GetByUserID(ctx context.Context, userID string) ([]*models.Consent, error)
+}
+
+var (
+	_ UserStore       = (*UserRepository)(nil)
+	_ ContactStore    = (*ContactRepository)(nil)
+	_ AlertStore      = (*AlertRepository)(nil)
+	_ ResourceStore   = (*ResourceRepository)(nil)
+	_ SafetyPlanStore = (*SafetyPlanRepository)(nil)
+	_ ConsentStore    = (*ConsentRepository)(nil)
+)
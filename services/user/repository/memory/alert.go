@@ -0,0 +1,341 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+)
+
+var _ repository.AlertStore = (*AlertRepository)(nil)
+
+// AlertRepository é a versão em memória de repository.AlertRepository
+type AlertRepository struct {
+	s *Store
+}
+
+func copyRecipient(recipient *models.AlertRecipient) *models.AlertRecipient {
+	c := *recipient
+	c.Channels = append([]string{}, recipient.Channels...)
+	return &c
+}
+
+func copyAlert(alert *models.Alert) *models.Alert {
+	c := *alert
+	c.Recipients = make([]*models.AlertRecipient, len(alert.Recipients))
+	for i, recipient := range alert.Recipients {
+		c.Recipients[i] = copyRecipient(recipient)
+	}
+	return &c
+}
+
+func (r *AlertRepository) Create(ctx context.Context, alert *models.Alert, contacts []*models.EmergencyContact) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkActive(alert.UserID); err != nil {
+		return err
+	}
+	for _, existing := range r.s.alerts {
+		if existing.UserID == alert.UserID && existing.Status == models.AlertStatusActive {
+			return repository.ErrAlertActive
+		}
+	}
+
+	alert.ID = newID()
+	alert.Status = models.AlertStatusActive
+	alert.Level = models.AlertLevelPrimary
+	alert.UpdatedAt = alert.CreatedAt
+	alert.Recipients = make([]*models.AlertRecipient, 0, len(contacts))
+	for i, contact := range contacts {
+		level := models.AlertLevelOthers
+		if i == 0 {
+			level = models.AlertLevelPrimary
+		}
+		contactID := contact.ID
+		alert.Recipients = append(alert.Recipients, &models.AlertRecipient{
+			ID:          newID(),
+			AlertID:     alert.ID,
+			ContactID:   &contactID,
+			ContactName: contact.Name,
+			Level:       level,
+			Status:      models.RecipientStatusPending,
+			Channels:    []string{},
+		})
+	}
+
+	r.s.alerts = append(r.s.alerts, copyAlert(alert))
+	return nil
+}
+
+func (r *AlertRepository) GetByID(ctx context.Context, id, userID string) (*models.Alert, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if alert := r.find(id); alert != nil && alert.UserID == userID {
+		return r.output(alert), nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *AlertRepository) GetActive(ctx context.Context, userID string) (*models.Alert, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, alert := range r.s.alerts {
+		if alert.UserID == userID && alert.Status == models.AlertStatusActive {
+			return r.output(alert), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *AlertRepository) GetForDelivery(ctx context.Context, id string) (*models.Alert, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if alert := r.find(id); alert != nil {
+		return r.output(alert), nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+// ListByUserID ordena como a consulta SQL: do mais recente ao mais antigo
+func (r *AlertRepository) ListByUserID(ctx context.Context, userID string) ([]*models.Alert, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	alerts := []*models.Alert{}
+	for _, alert := range r.s.alerts {
+		if alert.UserID == userID {
+			alerts = append(alerts, r.output(alert))
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].CreatedAt.After(alerts[j].CreatedAt) })
+	return alerts, nil
+}
+
+func (r *AlertRepository) ListCreatedSince(ctx context.Context, userID string, since time.Time) ([]time.Time, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var times []time.Time
+	for _, alert := range r.s.alerts {
+		if alert.UserID == userID && alert.CreatedAt.After(since) {
+			times = append(times, alert.CreatedAt)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times, nil
+}
+
+func (r *AlertRepository) Cancel(ctx context.Context, id, userID string, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	alert := r.find(id)
+	if alert == nil || alert.UserID != userID || alert.Status == models.AlertStatusCancelled {
+		return sql.ErrNoRows
+	}
+	alert.Status = models.AlertStatusCancelled
+	alert.CancelledAt = &now
+	alert.EscalatesAt = nil
+	alert.UpdatedAt = now
+	return nil
+}
+
+func (r *AlertRepository) ListDueEscalations(ctx context.Context, now time.Time) ([]string, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var due []*models.Alert
+	for _, alert := range r.s.alerts {
+		if r.escalationDue(alert, now) {
+			due = append(due, alert)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].EscalatesAt.Before(*due[j].EscalatesAt) })
+
+	var ids []string
+	for _, alert := range due {
+		ids = append(ids, alert.ID)
+	}
+	return ids, nil
+}
+
+func (r *AlertRepository) Escalate(ctx context.Context, id string, now time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	alert := r.find(id)
+	if alert == nil || !r.escalationDue(alert, now) {
+		return false, nil
+	}
+	alert.Level = models.AlertLevelOthers
+	alert.EscalatesAt = nil
+	alert.UpdatedAt = now
+	return true, nil
+}
+
+func (r *AlertRepository) ClaimRecipients(ctx context.Context, alertID string, level int) ([]*models.AlertRecipient, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	alert := r.find(alertID)
+	if alert == nil {
+		return nil, nil
+	}
+
+	var recipients []*models.AlertRecipient
+	for _, recipient := range alert.Recipients {
+		if recipient.Level <= level && recipient.Status == models.RecipientStatusPending {
+			recipient.Status = models.RecipientStatusSending
+			recipients = append(recipients, r.withAddress(recipient, true))
+		}
+	}
+	return recipients, nil
+}
+
+func (r *AlertRepository) RecordDelivery(ctx context.Context, recipientID, tokenHash, status string, channels []string, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, alert := range r.s.alerts {
+		for _, recipient := range alert.Recipients {
+			if recipient.ID == recipientID {
+				recipient.Status = status
+				recipient.Channels = append([]string{}, channels...)
+				recipient.NotifiedAt = &now
+				r.s.alertTokens[recipientID] = tokenHash
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func (r *AlertRepository) ListNotified(ctx context.Context, alertID string) ([]*models.AlertRecipient, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	alert := r.find(alertID)
+	if alert == nil {
+		return nil, nil
+	}
+
+	var recipients []*models.AlertRecipient
+	for _, recipient := range alert.Recipients {
+		if recipient.Status == models.RecipientStatusNotified || recipient.Status == models.RecipientStatusAcknowledged {
+			recipients = append(recipients, r.withAddress(recipient, false))
+		}
+	}
+	return recipients, nil
+}
+
+func (r *AlertRepository) GetNotice(ctx context.Context, tokenHash string, since time.Time) (*models.AlertNotice, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if alert, _ := r.findToken(tokenHash, since); alert != nil {
+		return r.notice(alert), nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *AlertRepository) Acknowledge(ctx context.Context, tokenHash string, since, now time.Time) (*models.AlertNotice, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	alert, recipient := r.findToken(tokenHash, since)
+	if alert == nil {
+		return nil, sql.ErrNoRows
+	}
+	recipient.Status = models.RecipientStatusAcknowledged
+	if recipient.AcknowledgedAt == nil {
+		recipient.AcknowledgedAt = &now
+	}
+	if alert.Status == models.AlertStatusActive {
+		alert.Status = models.AlertStatusAcknowledged
+		alert.AcknowledgedAt = &now
+		alert.EscalatesAt = nil
+		alert.UpdatedAt = now
+	}
+	return r.notice(alert), nil
+}
+
+// find, escalationDue, findToken, withAddress, output e notice devem ser
+// chamados com o lock obtido
+func (r *AlertRepository) find(id string) *models.Alert {
+	for _, alert := range r.s.alerts {
+		if alert.ID == id {
+			return alert
+		}
+	}
+	return nil
+}
+
+func (r *AlertRepository) escalationDue(alert *models.Alert, now time.Time) bool {
+	return alert.Status == models.AlertStatusActive && alert.Level < models.AlertLevelOthers &&
+		alert.EscalatesAt != nil && !alert.EscalatesAt.After(now)
+}
+
+func (r *AlertRepository) findToken(tokenHash string, since time.Time) (*models.Alert, *models.AlertRecipient) {
+	for _, alert := range r.s.alerts {
+		if !alert.CreatedAt.After(since) {
+			continue
+		}
+		for _, recipient := range alert.Recipients {
+			if hash, ok := r.s.alertTokens[recipient.ID]; ok && hash == tokenHash {
+				return alert, recipient
+			}
+		}
+	}
+	return nil, nil
+}
+
+// withAddress completa o aviso com o telefone e o email atuais do contato; no
+// primeiro envio, como na consulta SQL, só se o contato ainda aceita alertas
+func (r *AlertRepository) withAddress(recipient *models.AlertRecipient, acceptedOnly bool) *models.AlertRecipient {
+	c := copyRecipient(recipient)
+	if recipient.ContactID == nil {
+		return c
+	}
+	for _, contact := range r.s.contacts {
+		if contact.ID == *recipient.ContactID && (!acceptedOnly || contact.Status == models.ContactStatusAccepted) {
+			c.Phone = contact.Phone
+			c.Email = contact.Email
+		}
+	}
+	return c
+}
+
+func (r *AlertRepository) output(alert *models.Alert) *models.Alert {
+	c := copyAlert(alert)
+	c.UserName = r.userName(alert.UserID)
+	return c
+}
+
+func (r *AlertRepository) notice(alert *models.Alert) *models.AlertNotice {
+	return &models.AlertNotice{
+		AlertID:        alert.ID,
+		UserName:       r.userName(alert.UserID),
+		Status:         alert.Status,
+		Location:       alert.Location,
+		CreatedAt:      alert.CreatedAt,
+		AcknowledgedAt: alert.AcknowledgedAt,
+	}
+}
+
+func (r *AlertRepository) userName(userID string) string {
+	user, ok := r.s.users[userID]
+	if !ok {
+		return ""
+	}
+	if user.FullName != nil {
+		return *user.FullName
+	}
+	return user.Username
+}
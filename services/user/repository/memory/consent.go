@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+)
+
+var _ repository.ConsentStore = (*ConsentRepository)(nil)
+
+// ConsentRepository é a versão em memória de repository.ConsentRepository
+type ConsentRepository struct {
+	s *Store
+}
+
+// Publish cadastra uma versão de documento, como os INSERTs em consent_documents
+func (r *ConsentRepository) Publish(doc models.ConsentDocument) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if doc.PublishedAt.IsZero() {
+		doc.PublishedAt = r.s.timestamp()
+	}
+	r.s.documents = append(r.s.documents, &doc)
+}
+
+func (r *ConsentRepository) GetCurrentDocuments(ctx context.Context) ([]*models.ConsentDocument, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.currentDocuments(), nil
+}
+
+func (r *ConsentRepository) GetPendingMandatory(ctx context.Context, userID string) ([]*models.ConsentDocument, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	pending := []*models.ConsentDocument{}
+	for _, doc := range r.currentDocuments() {
+		if doc.Mandatory && !r.hasActive(userID, doc.Type, doc.Version) {
+			pending = append(pending, doc)
+		}
+	}
+	return pending, nil
+}
+
+func (r *ConsentRepository) PendingMandatoryTypes(ctx context.Context, userID string) ([]string, error) {
+	docs, err := r.GetPendingMandatory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		types = append(types, doc.Type)
+	}
+	return types, nil
+}
+
+func (r *ConsentRepository) Grant(ctx context.Context, userID string, req *models.ConsentRequest, ipAddress string) (*models.Consent, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if !r.documentExists(req.Type, req.Version) {
+		// Equivale à violação da FK para consent_documents
+		return nil, sql.ErrNoRows
+	}
+
+	now := r.s.timestamp()
+	for _, consent := range r.s.consents {
+		if consent.UserID == userID && consent.Type == req.Type && consent.WithdrawnAt == nil {
+			withdrawnAt := now
+			consent.WithdrawnAt = &withdrawnAt
+		}
+	}
+
+	consent := &models.Consent{
+		ID:        newID(),
+		UserID:    userID,
+		Type:      req.Type,
+		Version:   req.Version,
+		IPAddress: &ipAddress,
+		GrantedAt: now,
+	}
+	r.s.consents = append(r.s.consents, consent)
+
+	c := *consent
+	return &c, nil
+}
+
+func (r *ConsentRepository) Withdraw(ctx context.Context, userID, consentType string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	withdrawn := false
+	now := r.s.timestamp()
+	for _, consent := range r.s.consents {
+		if consent.UserID == userID && consent.Type == consentType && consent.WithdrawnAt == nil {
+			withdrawnAt := now
+			consent.WithdrawnAt = &withdrawnAt
+			withdrawn = true
+		}
+	}
+
+	if !withdrawn {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *ConsentRepository) HasActiveConsent(ctx context.Context, userID, consentType string) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, consent := range r.s.consents {
+		if consent.UserID == userID && consent.Type == consentType && consent.WithdrawnAt == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *ConsentRepository) GetByUserID(ctx context.Context, userID string) ([]*models.Consent, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	consents := []*models.Consent{}
+	for i := len(r.s.consents) - 1; i >= 0; i-- {
+		if consent := r.s.consents[i]; consent.UserID == userID {
+			c := *consent
+			consents = append(consents, &c)
+		}
+	}
+
+	sort.SliceStable(consents, func(i, j int) bool { return consents[i].GrantedAt.After(consents[j].GrantedAt) })
+	return consents, nil
+}
+
+// currentDocuments retorna a versão publicada mais recente de cada tipo, ordenada
+// por tipo. Deve ser chamado com o lock obtido.
+func (r *ConsentRepository) currentDocuments() []*models.ConsentDocument {
+	now := r.s.now()
+	current := map[string]*models.ConsentDocument{}
+	for _, doc := range r.s.documents {
+		if doc.PublishedAt.After(now) {
+			continue
+		}
+		if latest, ok := current[doc.Type]; !ok || doc.PublishedAt.After(latest.PublishedAt) {
+			current[doc.Type] = doc
+		}
+	}
+
+	docs := []*models.ConsentDocument{}
+	for _, doc := range current {
+		d := *doc
+		docs = append(docs, &d)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Type < docs[j].Type })
+	return docs
+}
+
+func (r *ConsentRepository) hasActive(userID, consentType, version string) bool {
+	for _, consent := range r.s.consents {
+		if consent.UserID == userID && consent.Type == consentType &&
+			consent.Version == version && consent.WithdrawnAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ConsentRepository) documentExists(consentType, version string) bool {
+	for _, doc := range r.s.documents {
+		if doc.Type == consentType && doc.Version == version {
+			return true
+		}
+	}
+	return false
+}
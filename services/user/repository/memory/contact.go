@@ -0,0 +1,393 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/pagination"
+	"github.com/meuapoio/shared/phone"
+)
+
+var _ repository.ContactStore = (*ContactRepository)(nil)
+
+// ContactRepository é a versão em memória de repository.ContactRepository
+type ContactRepository struct {
+	s *Store
+}
+
+func copyContact(contact *models.EmergencyContact) *models.EmergencyContact {
+	c := *contact
+	return &c
+}
+
+func (r *ContactRepository) Create(ctx context.Context, userID string, req *models.CreateContactRequest) (*models.EmergencyContact, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkActive(userID); err != nil {
+		return nil, err
+	}
+	if r.s.maxContacts > 0 && r.count(userID) >= r.s.maxContacts {
+		return nil, &repository.ContactLimitError{Max: r.s.maxContacts}
+	}
+
+	if req.IsPrimary {
+		r.demotePrimary(userID, "")
+	}
+
+	now := r.s.timestamp()
+	contact := &models.EmergencyContact{
+		ID:           newID(),
+		UserID:       userID,
+		Name:         req.Name,
+		Phone:        req.Phone,
+		PhoneDisplay: phone.Display(req.Phone),
+		Email:        req.Email,
+		Relationship: req.Relationship,
+		IsPrimary:    req.IsPrimary || r.primary(userID) == nil,
+		Status:       models.ContactStatusPending,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Version:      1,
+	}
+	r.s.contacts = append(r.s.contacts, contact)
+	return copyContact(contact), nil
+}
+
+// count conta os contatos do usuário; chamado com r.s.mu travado
+func (r *ContactRepository) count(userID string) int {
+	n := 0
+	for _, contact := range r.s.contacts {
+		if contact.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *ContactRepository) MaxContacts() int {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	return r.s.maxContacts
+}
+
+// GetByUserID ordena como a consulta SQL: primários primeiro, depois os mais recentes
+func (r *ContactRepository) GetByUserID(ctx context.Context, userID string) ([]*models.EmergencyContact, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var contacts []*models.EmergencyContact
+	for i := len(r.s.contacts) - 1; i >= 0; i-- {
+		if contact := r.s.contacts[i]; contact.UserID == userID {
+			contacts = append(contacts, copyContact(contact))
+		}
+	}
+
+	sort.SliceStable(contacts, func(i, j int) bool {
+		if contacts[i].IsPrimary != contacts[j].IsPrimary {
+			return contacts[i].IsPrimary
+		}
+		return contacts[i].CreatedAt.After(contacts[j].CreatedAt)
+	})
+	return contacts, nil
+}
+
+// List aplica filtros, ordenação e cursor como a consulta SQL, comparando as
+// chaves de EmergencyContact.SortKey
+func (r *ContactRepository) List(ctx context.Context, userID string, req pagination.Request) ([]*models.EmergencyContact, error) {
+	if err := req.CheckKey(len((&models.EmergencyContact{}).SortKey(req.Sort))); err != nil {
+		return nil, err
+	}
+
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	status, byStatus := req.Filters[models.ContactFilterStatus]
+	primary, byPrimary := req.Filters[models.ContactFilterPrimary]
+	contacts := []*models.EmergencyContact{}
+	for _, contact := range r.s.contacts {
+		if contact.UserID != userID || (byStatus && contact.Status != status) ||
+			(byPrimary && contact.IsPrimary != (primary == "true")) || !req.Includes(contact.SortKey(req.Sort)) {
+			continue
+		}
+		contacts = append(contacts, copyContact(contact))
+	}
+
+	sort.Slice(contacts, func(i, j int) bool {
+		return req.Less(contacts[i].SortKey(req.Sort), contacts[j].SortKey(req.Sort))
+	})
+	if len(contacts) > req.Limit+1 {
+		contacts = contacts[:req.Limit+1]
+	}
+	return contacts, nil
+}
+
+func (r *ContactRepository) GetByID(ctx context.Context, id, userID string) (*models.EmergencyContact, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if contact := r.find(id, userID); contact != nil {
+		return copyContact(contact), nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *ContactRepository) GetByPhone(ctx context.Context, userID, phone string) (*models.EmergencyContact, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, contact := range r.s.contacts {
+		if contact.UserID == userID && digits(contact.Phone) == digits(phone) {
+			return copyContact(contact), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *ContactRepository) Update(ctx context.Context, id, userID string, req *models.UpdateContactRequest, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkActive(userID); err != nil {
+		return err
+	}
+	contact := r.find(id, userID)
+	if contact == nil || (version != 0 && contact.Version != version) {
+		return notAffected(version)
+	}
+	if req.IsPrimary != nil && *req.IsPrimary {
+		r.demotePrimary(userID, id)
+	}
+
+	if req.Name != nil {
+		contact.Name = *req.Name
+	}
+	if req.Phone != nil {
+		if digits(*req.Phone) != digits(contact.Phone) {
+			r.resetInvitation(contact)
+		}
+		contact.Phone = *req.Phone
+		contact.PhoneDisplay = phone.Display(*req.Phone)
+	}
+	if req.Email != nil {
+		contact.Email = req.Email
+	}
+	if req.Relationship != nil {
+		contact.Relationship = req.Relationship
+	}
+	if req.IsPrimary != nil {
+		contact.IsPrimary = *req.IsPrimary
+	}
+	contact.UpdatedAt = r.s.timestamp()
+	contact.Version++
+	r.ensurePrimary(userID, id)
+	return nil
+}
+
+func (r *ContactRepository) SetPrimary(ctx context.Context, id, userID string, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkActive(userID); err != nil {
+		return err
+	}
+	contact := r.find(id, userID)
+	if contact == nil || (version != 0 && contact.Version != version) {
+		return notAffected(version)
+	}
+	r.demotePrimary(userID, id)
+	contact.IsPrimary = true
+	contact.UpdatedAt = r.s.timestamp()
+	contact.Version++
+	return nil
+}
+
+func (r *ContactRepository) Delete(ctx context.Context, id, userID string, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkActive(userID); err != nil {
+		return err
+	}
+	for i, contact := range r.s.contacts {
+		if contact.ID == id && contact.UserID == userID && (version == 0 || contact.Version == version) {
+			r.s.contacts = append(r.s.contacts[:i], r.s.contacts[i+1:]...)
+			delete(r.s.invitations, id)
+			r.ensurePrimary(userID, "")
+			// Equivale ao ON DELETE SET NULL de sos_recipients.contact_id
+			for _, alert := range r.s.alerts {
+				for _, recipient := range alert.Recipients {
+					if recipient.ContactID != nil && *recipient.ContactID == id {
+						recipient.ContactID = nil
+					}
+				}
+			}
+			// Equivale ao ON DELETE CASCADE de safety_plan_shares.contact_id
+			shares := r.s.planShares[:0]
+			for _, share := range r.s.planShares {
+				if share.share.ContactID != id {
+					shares = append(shares, share)
+				}
+			}
+			r.s.planShares = shares
+			return nil
+		}
+	}
+	return notAffected(version)
+}
+
+// GetAccepted ordena como a consulta SQL: o principal primeiro, depois os mais antigos
+func (r *ContactRepository) GetAccepted(ctx context.Context, userID string) ([]*models.EmergencyContact, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var contacts []*models.EmergencyContact
+	for _, contact := range r.s.contacts {
+		if contact.UserID == userID && contact.Status == models.ContactStatusAccepted {
+			contacts = append(contacts, copyContact(contact))
+		}
+	}
+
+	sort.SliceStable(contacts, func(i, j int) bool {
+		if contacts[i].IsPrimary != contacts[j].IsPrimary {
+			return contacts[i].IsPrimary
+		}
+		return contacts[i].CreatedAt.Before(contacts[j].CreatedAt)
+	})
+	return contacts, nil
+}
+
+func (r *ContactRepository) SetInvitation(ctx context.Context, id, userID, tokenHash string, sentAt, expiresAt time.Time) (*models.ContactInvitation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	contact := r.find(id, userID)
+	if contact == nil {
+		return nil, sql.ErrNoRows
+	}
+	r.s.invitations[id] = tokenHash
+	contact.InvitationSentAt = &sentAt
+	contact.InvitationExpiresAt = &expiresAt
+	contact.UpdatedAt = r.s.timestamp()
+	contact.Version++
+	return r.invitation(contact), nil
+}
+
+func (r *ContactRepository) GetInvitation(ctx context.Context, tokenHash string, now time.Time) (*models.ContactInvitation, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if contact := r.findInvitation(tokenHash, now); contact != nil {
+		return r.invitation(contact), nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *ContactRepository) RespondInvitation(ctx context.Context, tokenHash, status string, now time.Time) (*models.ContactInvitation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	contact := r.findInvitation(tokenHash, now)
+	if contact == nil {
+		return nil, sql.ErrNoRows
+	}
+	timestamp := r.s.timestamp()
+	contact.Status = status
+	contact.RespondedAt = &timestamp
+	contact.UpdatedAt = timestamp
+	contact.Version++
+	return r.invitation(contact), nil
+}
+
+// resetInvitation, findInvitation e invitation devem ser chamados com o lock obtido
+func (r *ContactRepository) resetInvitation(contact *models.EmergencyContact) {
+	delete(r.s.invitations, contact.ID)
+	contact.Status = models.ContactStatusPending
+	contact.InvitationSentAt = nil
+	contact.InvitationExpiresAt = nil
+	contact.RespondedAt = nil
+}
+
+// findInvitation ignora convites vencidos e de contas inativas, como a consulta SQL
+func (r *ContactRepository) findInvitation(tokenHash string, now time.Time) *models.EmergencyContact {
+	for _, contact := range r.s.contacts {
+		hash, invited := r.s.invitations[contact.ID]
+		user, ok := r.s.users[contact.UserID]
+		if invited && hash == tokenHash && ok && user.IsActive &&
+			contact.InvitationExpiresAt != nil && contact.InvitationExpiresAt.After(now) {
+			return contact
+		}
+	}
+	return nil
+}
+
+func (r *ContactRepository) invitation(contact *models.EmergencyContact) *models.ContactInvitation {
+	inviter := r.s.users[contact.UserID]
+	name := inviter.Username
+	if inviter.FullName != nil {
+		name = *inviter.FullName
+	}
+	return &models.ContactInvitation{
+		ContactID:   contact.ID,
+		UserID:      contact.UserID,
+		ContactName: contact.Name,
+		InviterName: name,
+		Status:      contact.Status,
+		ExpiresAt:   *contact.InvitationExpiresAt,
+	}
+}
+
+// primary, demotePrimary e ensurePrimary reproduzem as regras de contato
+// principal do PostgreSQL e devem ser chamados com o lock obtido
+func (r *ContactRepository) primary(userID string) *models.EmergencyContact {
+	for _, contact := range r.s.contacts {
+		if contact.UserID == userID && contact.IsPrimary {
+			return contact
+		}
+	}
+	return nil
+}
+
+func (r *ContactRepository) demotePrimary(userID, exceptID string) {
+	if contact := r.primary(userID); contact != nil && contact.ID != exceptID {
+		contact.IsPrimary = false
+		contact.UpdatedAt = r.s.timestamp()
+		contact.Version++
+	}
+}
+
+// ensurePrimary promove o contato mais antigo, deixando avoidID por último
+func (r *ContactRepository) ensurePrimary(userID, avoidID string) {
+	if r.primary(userID) != nil {
+		return
+	}
+	var oldest *models.EmergencyContact
+	for _, contact := range r.s.contacts {
+		if contact.UserID != userID {
+			continue
+		}
+		if oldest == nil || (oldest.ID == avoidID && contact.ID != avoidID) ||
+			(contact.ID != avoidID && contact.CreatedAt.Before(oldest.CreatedAt)) {
+			oldest = contact
+		}
+	}
+	if oldest != nil {
+		oldest.IsPrimary = true
+		oldest.UpdatedAt = r.s.timestamp()
+		oldest.Version++
+	}
+}
+
+// find deve ser chamado com o lock obtido
+func (r *ContactRepository) find(id, userID string) *models.EmergencyContact {
+	for _, contact := range r.s.contacts {
+		if contact.ID == id && contact.UserID == userID {
+			return contact
+		}
+	}
+	return nil
+}
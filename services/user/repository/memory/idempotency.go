@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	sharedmw "github.com/meuapoio/shared/middleware"
+)
+
+var _ sharedmw.IdempotencyStore = (*IdempotencyRepository)(nil)
+
+// IdempotencyRepository é a versão em memória de repository.IdempotencyRepository
+type IdempotencyRepository struct {
+	s *Store
+}
+
+type idempotencyEntry struct {
+	response  sharedmw.IdempotentResponse
+	expiresAt time.Time
+}
+
+func (r *IdempotencyRepository) Reserve(ctx context.Context, key sharedmw.IdempotencyKey, fingerprint string, expiresAt time.Time) (*sharedmw.IdempotentResponse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if entry, ok := r.s.idempotency[key]; ok && entry.expiresAt.After(r.s.now()) {
+		response := copyResponse(entry.response)
+		return &response, nil
+	}
+
+	r.s.idempotency[key] = &idempotencyEntry{
+		response:  sharedmw.IdempotentResponse{Fingerprint: fingerprint},
+		expiresAt: expiresAt,
+	}
+	return nil, nil
+}
+
+func (r *IdempotencyRepository) Complete(ctx context.Context, key sharedmw.IdempotencyKey, response *sharedmw.IdempotentResponse, expiresAt time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	entry, ok := r.s.idempotency[key]
+	if !ok || entry.response.Fingerprint != response.Fingerprint {
+		return nil
+	}
+	entry.response = copyResponse(*response)
+	entry.expiresAt = expiresAt
+	return nil
+}
+
+func (r *IdempotencyRepository) Release(ctx context.Context, key sharedmw.IdempotencyKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if entry, ok := r.s.idempotency[key]; ok && !entry.response.Completed {
+		delete(r.s.idempotency, key)
+	}
+	return nil
+}
+
+// DeleteExpired apaga as chaves vencidas e retorna quantas foram removidas
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var deleted int64
+	for key, entry := range r.s.idempotency {
+		if !entry.expiresAt.After(now) {
+			delete(r.s.idempotency, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func copyResponse(response sharedmw.IdempotentResponse) sharedmw.IdempotentResponse {
+	response.Header = http.Header(response.Header).Clone()
+	response.Body = append([]byte(nil), response.Body...)
+	return response
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+)
+
+var _ repository.ResourceStore = (*ResourceRepository)(nil)
+
+// ResourceRepository é a versão em memória de repository.ResourceRepository.
+// Ao contrário da migração, começa sem os serviços nacionais.
+type ResourceRepository struct {
+	s *Store
+}
+
+func copyResource(resource *models.CrisisResource) *models.CrisisResource {
+	c := *resource
+	c.Languages = append([]string{}, resource.Languages...)
+	c.Hours = make([]models.OpeningHours, len(resource.Hours))
+	for i, h := range resource.Hours {
+		h.Days = append([]int{}, h.Days...)
+		c.Hours[i] = h
+	}
+	return &c
+}
+
+// List ordena como a consulta SQL: prioridade decrescente e depois nome
+func (r *ResourceRepository) List(ctx context.Context, filter *models.ResourceFilter) ([]*models.CrisisResource, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	resources := []*models.CrisisResource{}
+	for _, resource := range r.s.resources {
+		if filter.Country != "" && resource.Country != filter.Country {
+			continue
+		}
+		if filter.State != "" && resource.State != nil && *resource.State != filter.State {
+			continue
+		}
+		if filter.Type != "" && resource.Type != filter.Type {
+			continue
+		}
+		resources = append(resources, copyResource(resource))
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		if resources[i].Priority != resources[j].Priority {
+			return resources[i].Priority > resources[j].Priority
+		}
+		return resources[i].Name < resources[j].Name
+	})
+	return resources, nil
+}
+
+func (r *ResourceRepository) GetByID(ctx context.Context, id string) (*models.CrisisResource, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if _, resource := r.find(id); resource != nil {
+		return copyResource(resource), nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *ResourceRepository) Create(ctx context.Context, req *models.ResourceRequest) (*models.CrisisResource, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.timestamp()
+	resource := &models.CrisisResource{ID: newID(), CreatedAt: now, Version: 1}
+	applyResource(resource, req)
+	resource.UpdatedAt = now
+
+	r.s.resources = append(r.s.resources, resource)
+	return copyResource(resource), nil
+}
+
+func (r *ResourceRepository) Update(ctx context.Context, id string, req *models.ResourceRequest, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	_, resource := r.find(id)
+	if resource == nil || (version != 0 && resource.Version != version) {
+		return notAffected(version)
+	}
+	applyResource(resource, req)
+	resource.UpdatedAt = r.s.timestamp()
+	resource.Version++
+	return nil
+}
+
+func (r *ResourceRepository) Delete(ctx context.Context, id string, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i, resource := r.find(id)
+	if resource == nil || (version != 0 && resource.Version != version) {
+		return notAffected(version)
+	}
+	r.s.resources = append(r.s.resources[:i], r.s.resources[i+1:]...)
+	return nil
+}
+
+// find deve ser chamado com o lock obtido
+func (r *ResourceRepository) find(id string) (int, *models.CrisisResource) {
+	for i, resource := range r.s.resources {
+		if resource.ID == id {
+			return i, resource
+		}
+	}
+	return -1, nil
+}
+
+// applyResource copia os campos do pedido, como o INSERT e o UPDATE do PostgreSQL
+func applyResource(resource *models.CrisisResource, req *models.ResourceRequest) {
+	resource.Name = req.Name
+	resource.Description = req.Description
+	resource.Type = req.Type
+	resource.Phone = req.Phone
+	resource.ChatURL = req.ChatURL
+	resource.WebsiteURL = req.WebsiteURL
+	resource.Country = req.Country
+	resource.State = req.State
+	resource.Languages = append([]string{}, req.Languages...)
+	resource.AlwaysOpen = req.AlwaysOpen
+	resource.Timezone = req.Timezone
+	resource.Hours = []models.OpeningHours{}
+	for _, h := range req.Hours {
+		h.Days = append([]int{}, h.Days...)
+		resource.Hours = append(resource.Hours, h)
+	}
+	resource.Priority = req.Priority
+}
@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+)
+
+var _ repository.SafetyPlanStore = (*SafetyPlanRepository)(nil)
+
+// SafetyPlanRepository é a versão em memória de repository.SafetyPlanRepository
+type SafetyPlanRepository struct {
+	s *Store
+}
+
+// planRevision guarda uma versão do histórico com o dono, como a tabela
+// safety_plan_revisions
+type planRevision struct {
+	userID string
+	plan   *models.SafetyPlan
+}
+
+// planShare guarda o link com o dono e o hash do token, que ficam fora do model
+type planShare struct {
+	userID    string
+	tokenHash string
+	share     *models.SafetyPlanShare
+}
+
+func copySections(sections []models.SafetyPlanSection) []models.SafetyPlanSection {
+	c := make([]models.SafetyPlanSection, len(sections))
+	for i, section := range sections {
+		section.Items = append([]models.SafetyPlanItem{}, section.Items...)
+		c[i] = section
+	}
+	return c
+}
+
+func copyPlan(plan *models.SafetyPlan) *models.SafetyPlan {
+	c := *plan
+	c.Sections = copySections(plan.Sections)
+	return &c
+}
+
+func copyShare(share *planShare) *planShare {
+	s := *share.share
+	return &planShare{userID: share.userID, tokenHash: share.tokenHash, share: &s}
+}
+
+func (r *SafetyPlanRepository) Get(ctx context.Context, userID string) (*models.SafetyPlan, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if plan, ok := r.s.plans[userID]; ok {
+		return copyPlan(plan), nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *SafetyPlanRepository) Save(ctx context.Context, userID string, sections []models.SafetyPlanSection, version int) (*models.SafetyPlan, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.timestamp()
+	plan, ok := r.s.plans[userID]
+	if !ok {
+		plan = &models.SafetyPlan{CreatedAt: now}
+		r.s.plans[userID] = plan
+	} else if version != 0 && plan.Version != version {
+		return nil, repository.ErrVersionConflict
+	}
+	plan.Sections = copySections(sections)
+	plan.UpdatedAt = now
+	plan.Version++
+
+	r.s.planRevisions = append(r.s.planRevisions, &planRevision{userID: userID, plan: copyPlan(plan)})
+	return copyPlan(plan), nil
+}
+
+func (r *SafetyPlanRepository) Delete(ctx context.Context, userID string, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	plan, ok := r.s.plans[userID]
+	if !ok || (version != 0 && plan.Version != version) {
+		return notAffected(version)
+	}
+	r.s.deleteSafetyPlan(userID)
+	return nil
+}
+
+// deleteSafetyPlan remove o plano, o histórico e os links do usuário. Chamado
+// com o lock de escrita.
+func (s *Store) deleteSafetyPlan(userID string) {
+	delete(s.plans, userID)
+
+	revisions := s.planRevisions[:0]
+	for _, revision := range s.planRevisions {
+		if revision.userID != userID {
+			revisions = append(revisions, revision)
+		}
+	}
+	s.planRevisions = revisions
+
+	shares := s.planShares[:0]
+	for _, share := range s.planShares {
+		if share.userID != userID {
+			shares = append(shares, share)
+		}
+	}
+	s.planShares = shares
+}
+
+// ListRevisions ordena como a consulta SQL: da versão mais recente para a mais antiga
+func (r *SafetyPlanRepository) ListRevisions(ctx context.Context, userID string) ([]*models.SafetyPlanRevision, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	revisions := []*models.SafetyPlanRevision{}
+	for _, revision := range r.s.planRevisions {
+		if revision.userID == userID {
+			revisions = append(revisions, &models.SafetyPlanRevision{
+				Version:   revision.plan.Version,
+				CreatedAt: revision.plan.UpdatedAt,
+			})
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Version > revisions[j].Version })
+	return revisions, nil
+}
+
+func (r *SafetyPlanRepository) GetRevision(ctx context.Context, userID string, version int) (*models.SafetyPlan, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, revision := range r.s.planRevisions {
+		if revision.userID == userID && revision.plan.Version == version {
+			return copyPlan(revision.plan), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *SafetyPlanRepository) CreateShare(ctx context.Context, userID, contactID, tokenHash string, expiresAt time.Time) (*models.SafetyPlanShare, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var contactName string
+	found := false
+	for _, contact := range r.s.contacts {
+		if contact.ID == contactID {
+			contactName, found = contact.Name, true
+		}
+	}
+	// Equivale à foreign key para emergency_contacts
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+
+	share := &planShare{
+		userID:    userID,
+		tokenHash: tokenHash,
+		share: &models.SafetyPlanShare{
+			ID:          newID(),
+			ContactID:   contactID,
+			ContactName: contactName,
+			ExpiresAt:   expiresAt,
+			CreatedAt:   r.s.timestamp(),
+		},
+	}
+	r.s.planShares = append(r.s.planShares, share)
+	return copyShare(share).share, nil
+}
+
+// ListShares ordena como a consulta SQL: do link mais recente ao mais antigo
+func (r *SafetyPlanRepository) ListShares(ctx context.Context, userID string) ([]*models.SafetyPlanShare, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	shares := []*models.SafetyPlanShare{}
+	for _, share := range r.s.planShares {
+		if share.userID == userID {
+			shares = append(shares, copyShare(share).share)
+		}
+	}
+	sort.SliceStable(shares, func(i, j int) bool { return shares[i].CreatedAt.After(shares[j].CreatedAt) })
+	return shares, nil
+}
+
+func (r *SafetyPlanRepository) RevokeShare(ctx context.Context, id, userID string, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, share := range r.s.planShares {
+		if share.share.ID == id && share.userID == userID && share.share.RevokedAt == nil {
+			share.share.RevokedAt = &now
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *SafetyPlanRepository) ViewShared(ctx context.Context, tokenHash string, now time.Time) (*models.SharedSafetyPlan, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, share := range r.s.planShares {
+		if share.tokenHash != tokenHash || share.share.RevokedAt != nil || !share.share.ExpiresAt.After(now) {
+			continue
+		}
+		user, ok := r.s.users[share.userID]
+		if !ok || !user.IsActive || !r.s.contactAccepted(share.share.ContactID) {
+			continue
+		}
+		plan, ok := r.s.plans[share.userID]
+		if !ok {
+			continue
+		}
+
+		share.share.LastViewedAt = &now
+		ownerName := user.Username
+		if user.FullName != nil {
+			ownerName = *user.FullName
+		}
+		return &models.SharedSafetyPlan{
+			OwnerName: ownerName,
+			Plan:      copyPlan(plan),
+			ExpiresAt: share.share.ExpiresAt,
+			UserID:    share.userID,
+		}, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+// contactAccepted informa se o contato existe e aceitou o convite. Chamado com
+// o lock.
+func (s *Store) contactAccepted(contactID string) bool {
+	for _, contact := range s.contacts {
+		if contact.ID == contactID {
+			return contact.Status == models.ContactStatusAccepted
+		}
+	}
+	return false
+}
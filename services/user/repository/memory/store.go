@@ -0,0 +1,254 @@
+// Package memory implementa os repositórios do serviço de usuários em memória,
+// com a mesma semântica das versões em PostgreSQL (propriedade dos registros,
+// soft delete, unicidade e sql.ErrNoRows para buscas sem resultado). Usado nos
+// testes dos handlers, que assim não dependem de um banco.
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/database"
+	sharedmw "github.com/meuapoio/shared/middleware"
+)
+
+// ErrDuplicate equivale à violação de UNIQUE do PostgreSQL
+var ErrDuplicate = errors.New("registro duplicado")
+
+// Store guarda os dados compartilhados pelos repositórios, o que permite que a
+// exclusão de um usuário remova também os contatos e consentimentos dele.
+type Store struct {
+	txMu      sync.Mutex
+	mu        sync.RWMutex
+	users     map[string]*models.User
+	phones    map[string]string // telefone (apenas dígitos) por ID do usuário
+	admins    map[string]bool   // papel de administrador por ID do usuário
+	contacts  []*models.EmergencyContact
+	documents []*models.ConsentDocument
+	consents  []*models.Consent
+	// Hash do token do convite por ID do contato (fora do model, como no banco)
+	invitations map[string]string
+	alerts      []*models.Alert
+	// Hash do token de confirmação por ID do aviso ao contato
+	alertTokens map[string]string
+	resources   []*models.CrisisResource
+	// Plano de segurança por ID do usuário, com o histórico e os links
+	plans         map[string]*models.SafetyPlan
+	planRevisions []*planRevision
+	planShares    []*planShare
+	// Chaves de idempotência ficam fora das transações, como na tabela do PostgreSQL
+	// (o middleware as grava antes e depois do handler)
+	idempotency map[sharedmw.IdempotencyKey]*idempotencyEntry
+	now         func() time.Time
+	// Limite de contatos por usuário; zero não limita
+	maxContacts int
+}
+
+// NewStore cria um Store vazio
+func NewStore() *Store {
+	return &Store{
+		users:       make(map[string]*models.User),
+		phones:      make(map[string]string),
+		admins:      make(map[string]bool),
+		invitations: make(map[string]string),
+		alertTokens: make(map[string]string),
+		plans:       make(map[string]*models.SafetyPlan),
+		idempotency: make(map[sharedmw.IdempotencyKey]*idempotencyEntry),
+		now:         time.Now,
+	}
+}
+
+// SetClock substitui o relógio usado nos timestamps, para testes dependentes de tempo
+func (s *Store) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// SetMaxContacts limita os contatos de emergência por usuário, como
+// MAX_CONTACTS_PER_USER no repositório em PostgreSQL
+func (s *Store) SetMaxContacts(max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxContacts = max
+}
+
+// Users retorna o repositório de usuários sobre o Store
+func (s *Store) Users() *UserRepository {
+	return &UserRepository{s: s}
+}
+
+// Contacts retorna o repositório de contatos sobre o Store
+func (s *Store) Contacts() *ContactRepository {
+	return &ContactRepository{s: s}
+}
+
+// Consents retorna o repositório de consentimentos sobre o Store
+func (s *Store) Consents() *ConsentRepository {
+	return &ConsentRepository{s: s}
+}
+
+// Alerts retorna o repositório de alertas de SOS sobre o Store
+func (s *Store) Alerts() *AlertRepository {
+	return &AlertRepository{s: s}
+}
+
+// Resources retorna o repositório do diretório de recursos de crise sobre o Store
+func (s *Store) Resources() *ResourceRepository {
+	return &ResourceRepository{s: s}
+}
+
+// SafetyPlans retorna o repositório de planos de segurança sobre o Store
+func (s *Store) SafetyPlans() *SafetyPlanRepository {
+	return &SafetyPlanRepository{s: s}
+}
+
+// Idempotency retorna o repositório de chaves de idempotência sobre o Store
+func (s *Store) Idempotency() *IdempotencyRepository {
+	return &IdempotencyRepository{s: s}
+}
+
+var _ database.Transactor = (*Store)(nil)
+
+type txKey struct{}
+
+// WithTx implementa database.Transactor. As transações são serializadas e, se fn
+// falhar, o Store volta ao estado anterior. Chamadas aninhadas participam da
+// transação já aberta.
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) != nil {
+		return fn(ctx)
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	restore := s.snapshot()
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		restore()
+		return err
+	}
+	return nil
+}
+
+// snapshot copia o estado atual e retorna a função que o restaura
+func (s *Store) snapshot() func() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make(map[string]*models.User, len(s.users))
+	for id, user := range s.users {
+		users[id] = copyUser(user)
+	}
+	phones := make(map[string]string, len(s.phones))
+	for id, phone := range s.phones {
+		phones[id] = phone
+	}
+	admins := make(map[string]bool, len(s.admins))
+	for id, admin := range s.admins {
+		admins[id] = admin
+	}
+	contacts := make([]*models.EmergencyContact, len(s.contacts))
+	for i, contact := range s.contacts {
+		contacts[i] = copyContact(contact)
+	}
+	documents := make([]*models.ConsentDocument, len(s.documents))
+	for i, doc := range s.documents {
+		d := *doc
+		documents[i] = &d
+	}
+	consents := make([]*models.Consent, len(s.consents))
+	for i, consent := range s.consents {
+		c := *consent
+		consents[i] = &c
+	}
+	invitations := make(map[string]string, len(s.invitations))
+	for id, hash := range s.invitations {
+		invitations[id] = hash
+	}
+	alerts := make([]*models.Alert, len(s.alerts))
+	for i, alert := range s.alerts {
+		alerts[i] = copyAlert(alert)
+	}
+	alertTokens := make(map[string]string, len(s.alertTokens))
+	for id, hash := range s.alertTokens {
+		alertTokens[id] = hash
+	}
+	resources := make([]*models.CrisisResource, len(s.resources))
+	for i, resource := range s.resources {
+		resources[i] = copyResource(resource)
+	}
+	plans := make(map[string]*models.SafetyPlan, len(s.plans))
+	for id, plan := range s.plans {
+		plans[id] = copyPlan(plan)
+	}
+	planRevisions := make([]*planRevision, len(s.planRevisions))
+	for i, revision := range s.planRevisions {
+		planRevisions[i] = &planRevision{userID: revision.userID, plan: copyPlan(revision.plan)}
+	}
+	planShares := make([]*planShare, len(s.planShares))
+	for i, share := range s.planShares {
+		planShares[i] = copyShare(share)
+	}
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.users, s.phones, s.contacts, s.documents, s.consents = users, phones, contacts, documents, consents
+		s.invitations, s.alerts, s.alertTokens = invitations, alerts, alertTokens
+		s.admins, s.resources = admins, resources
+		s.plans, s.planRevisions, s.planShares = plans, planRevisions, planShares
+	}
+}
+
+// newID gera um UUID v4
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// digits mantém apenas os dígitos do telefone, como o blind index do PostgreSQL
+func digits(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
+// notAffected reproduz o erro de uma escrita condicionada à versão que não
+// afetou nenhuma linha
+func notAffected(version int) error {
+	if version != 0 {
+		return repository.ErrVersionConflict
+	}
+	return sql.ErrNoRows
+}
+
+// checkActive reproduz o lockActiveUser das escritas em nome do usuário
+func (s *Store) checkActive(userID string) error {
+	if user, ok := s.users[userID]; !ok || !user.IsActive {
+		return repository.ErrAccountInactive
+	}
+	return nil
+}
+
+// timestamp arredonda para microssegundos, a precisão do TIMESTAMP do PostgreSQL
+func (s *Store) timestamp() time.Time {
+	return s.now().UTC().Truncate(time.Microsecond)
+}
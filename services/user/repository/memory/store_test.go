@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/meuapoio/services/user/models"
+)
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	store := NewStore()
+	users := store.Users()
+	ctx := context.Background()
+
+	errAbort := errors.New("abortar")
+	var userID string
+	err := store.WithTx(ctx, func(ctx context.Context) error {
+		user := &models.User{Username: "maria", Email: "maria@meuapoio.com"}
+		if err := users.Create(ctx, user); err != nil {
+			return err
+		}
+		userID = user.ID
+
+		// Transações aninhadas participam da transação externa
+		return store.WithTx(ctx, func(ctx context.Context) error {
+			return errAbort
+		})
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("err = %v, esperado %v", err, errAbort)
+	}
+
+	if _, err := users.GetByID(ctx, userID); err != sql.ErrNoRows {
+		t.Fatalf("usuário deveria ter sido descartado no rollback, err = %v", err)
+	}
+	if exists, _ := users.EmailExists(ctx, "maria@meuapoio.com"); exists {
+		t.Fatal("email ainda consta após o rollback")
+	}
+}
+
+func TestWithTxCommits(t *testing.T) {
+	store := NewStore()
+	users := store.Users()
+	ctx := context.Background()
+
+	user := &models.User{Username: "maria", Email: "maria@meuapoio.com"}
+	err := store.WithTx(ctx, func(ctx context.Context) error {
+		return users.Create(ctx, user)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := users.GetByID(ctx, user.ID); err != nil {
+		t.Fatalf("usuário não persistido: %v", err)
+	}
+	if err := users.Create(ctx, &models.User{Username: "maria", Email: "outra@meuapoio.com"}); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("err = %v, esperado ErrDuplicate", err)
+	}
+}
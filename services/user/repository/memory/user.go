@@ -0,0 +1,310 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/shared/phone"
+)
+
+var _ repository.UserStore = (*UserRepository)(nil)
+
+// UserRepository é a versão em memória de repository.UserRepository
+type UserRepository struct {
+	s *Store
+}
+
+func copyUser(user *models.User) *models.User {
+	c := *user
+	return &c
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, existing := range r.s.users {
+		if existing.Email == user.Email || existing.Username == user.Username {
+			return ErrDuplicate
+		}
+	}
+
+	now := r.s.timestamp()
+	user.ID = newID()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.IsActive = true
+	user.Version = 1
+
+	r.s.users[user.ID] = copyUser(user)
+	return nil
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, user := range r.s.users {
+		if user.Email == email && (user.IsActive || user.DeletionScheduledFor != nil) {
+			return copyUser(user), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	user, ok := r.s.users[id]
+	if !ok || !user.IsActive {
+		return nil, sql.ErrNoRows
+	}
+	return copyUser(user), nil
+}
+
+func (r *UserRepository) GetByIDIncludingDeactivated(ctx context.Context, id string) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	user, ok := r.s.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyUser(user), nil
+}
+
+func (r *UserRepository) IsActive(ctx context.Context, id string) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	user, ok := r.s.users[id]
+	return ok && user.IsActive, nil
+}
+
+func (r *UserRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for id, stored := range r.s.phones {
+		if user := r.s.users[id]; stored == digits(phone) && user.IsActive {
+			return copyUser(user), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *UserRepository) Update(ctx context.Context, id string, req *models.UpdateUserRequest, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok || !user.IsActive {
+		return notAffected(version)
+	}
+	if version != 0 && user.Version != version {
+		return repository.ErrVersionConflict
+	}
+
+	if req.FullName != nil {
+		user.FullName = req.FullName
+	}
+	if req.BirthDate != nil {
+		// A coluna guarda apenas a data
+		date := time.Date(req.BirthDate.Year(), req.BirthDate.Month(), req.BirthDate.Day(), 0, 0, 0, 0, time.UTC)
+		user.BirthDate = &date
+	}
+	if req.Phone != nil {
+		display := phone.Display(*req.Phone)
+		user.Phone = req.Phone
+		user.PhoneDisplay = &display
+		r.s.phones[id] = digits(*req.Phone)
+	}
+	if req.PreferredLanguage != nil {
+		user.PreferredLanguage = req.PreferredLanguage
+	}
+	user.UpdatedAt = r.s.timestamp()
+	user.Version++
+	return nil
+}
+
+func (r *UserRepository) SetProfileImage(ctx context.Context, id string, imageID *string, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok || !user.IsActive {
+		return notAffected(version)
+	}
+	if version != 0 && user.Version != version {
+		return repository.ErrVersionConflict
+	}
+
+	user.ProfileImageID = imageID
+	user.UpdatedAt = r.s.timestamp()
+	user.Version++
+	return nil
+}
+
+func (r *UserRepository) PreferredLanguage(ctx context.Context, id string) (string, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	user, ok := r.s.users[id]
+	if !ok || !user.IsActive {
+		return "", sql.ErrNoRows
+	}
+	if user.PreferredLanguage == nil {
+		return "", nil
+	}
+	return *user.PreferredLanguage, nil
+}
+
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id string, scheduledFor time.Time, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok || !user.IsActive || (version != 0 && user.Version != version) {
+		return notAffected(version)
+	}
+	user.IsActive = false
+	user.DeletionScheduledFor = &scheduledFor
+	user.UpdatedAt = r.s.timestamp()
+	user.Version++
+	return nil
+}
+
+func (r *UserRepository) CancelDeletion(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if user, ok := r.s.users[id]; ok && user.DeletionScheduledFor != nil {
+		user.IsActive = true
+		user.DeletionScheduledFor = nil
+		user.UpdatedAt = r.s.timestamp()
+		user.Version++
+	}
+	return nil
+}
+
+func (r *UserRepository) ListDueForErasure(ctx context.Context, now time.Time) ([]string, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var due []*models.User
+	for _, user := range r.s.users {
+		if !user.IsActive && user.DeletionScheduledFor != nil && !user.DeletionScheduledFor.After(now) {
+			due = append(due, user)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].DeletionScheduledFor.Before(*due[j].DeletionScheduledFor) })
+
+	var ids []string
+	for _, user := range due {
+		ids = append(ids, user.ID)
+	}
+	return ids, nil
+}
+
+// Erase remove o usuário e todos os registros vinculados a ele no Store
+func (r *UserRepository) Erase(ctx context.Context, id string, now time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok || user.IsActive || user.DeletionScheduledFor == nil || user.DeletionScheduledFor.After(now) {
+		return false, nil
+	}
+
+	contacts := r.s.contacts[:0]
+	for _, contact := range r.s.contacts {
+		if contact.UserID != id {
+			contacts = append(contacts, contact)
+		} else {
+			delete(r.s.invitations, contact.ID)
+		}
+	}
+	r.s.contacts = contacts
+
+	consents := r.s.consents[:0]
+	for _, consent := range r.s.consents {
+		if consent.UserID != id {
+			consents = append(consents, consent)
+		}
+	}
+	r.s.consents = consents
+
+	alerts := r.s.alerts[:0]
+	for _, alert := range r.s.alerts {
+		if alert.UserID != id {
+			alerts = append(alerts, alert)
+		} else {
+			for _, recipient := range alert.Recipients {
+				delete(r.s.alertTokens, recipient.ID)
+			}
+		}
+	}
+	r.s.alerts = alerts
+	r.s.deleteSafetyPlan(id)
+
+	for key := range r.s.idempotency {
+		if key.UserID == id {
+			delete(r.s.idempotency, key)
+		}
+	}
+
+	delete(r.s.users, id)
+	delete(r.s.phones, id)
+	delete(r.s.admins, id)
+	return true, nil
+}
+
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, user := range r.s.users {
+		if user.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *UserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, user := range r.s.users {
+		if user.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *UserRepository) IsAdmin(ctx context.Context, id string) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	user, ok := r.s.users[id]
+	return ok && user.IsActive && r.s.admins[id], nil
+}
+
+func (r *UserRepository) SetAdmin(ctx context.Context, email string, admin bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, user := range r.s.users {
+		if user.Email == email {
+			r.s.admins[id] = admin
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/meuapoio/shared/crypto"
+	"github.com/meuapoio/shared/database"
+)
+
+// minUUID é o ponto de partida da paginação por chave nas rotinas em lote
+const minUUID = "00000000-0000-0000-0000-000000000000"
+
+const dateLayout = "2006-01-02"
+
+// encryptDate cifra uma data (sem horário) no formato AAAA-MM-DD
+func encryptDate(keyring *crypto.Keyring, date *time.Time) (*string, error) {
+	if date == nil {
+		return nil, nil
+	}
+	value := date.Format(dateLayout)
+	return keyring.EncryptPtr(&value)
+}
+
+// decryptDate decifra uma data gravada por encryptDate. Também aceita o formato
+// legado retornado pela coluna DATE antes da migração (AAAA-MM-DDT00:00:00Z).
+func decryptDate(keyring *crypto.Keyring, value *string) (*time.Time, error) {
+	plaintext, err := keyring.DecryptPtr(value)
+	if err != nil || plaintext == nil {
+		return nil, err
+	}
+
+	raw := *plaintext
+	if len(raw) > len(dateLayout) {
+		raw = raw[:len(dateLayout)]
+	}
+
+	date, err := time.Parse(dateLayout, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+// phoneIndex calcula o blind index do telefone considerando apenas os dígitos,
+// para que formatações diferentes do mesmo número coincidam
+func phoneIndex(keyring *crypto.Keyring, phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+	return keyring.BlindIndex(digits)
+}
+
+// needsRotation indica se um campo opcional precisa ser (re)cifrado com a chave ativa
+func needsRotation(keyring *crypto.Keyring, value *string) bool {
+	return value != nil && keyring.NeedsRotation(*value)
+}
+
+// rotateRow grava a linha recifrada somente se os valores cifrados ainda forem
+// os lidos no lote. Sem linhas afetadas, a linha foi alterada (ou removida)
+// depois da leitura e a escrita concorrente prevalece; se ela ainda não usou a
+// chave ativa, a próxima execução a recifra.
+func rotateRow(ctx context.Context, db *database.DB, query string, args ...any) (bool, error) {
+	result, err := execContext(ctx, db, query, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// reencryptColumn recifra em lotes uma coluna cifrada opcional de uma tabela com
+// chave id; table e column são constantes do código, nunca entrada do usuário
+func reencryptColumn(ctx context.Context, db *database.DB, keyring *crypto.Keyring, batchSize int, table, column string) (int, error) {
+	type row struct {
+		id    string
+		value *string
+	}
+
+	updated := 0
+	lastID := minUUID
+	for {
+		var batch []row
+		err := queryRows(ctx, db, func(rows *sql.Rows) error {
+			var item row
+			if err := rows.Scan(&item.id, &item.value); err != nil {
+				return err
+			}
+			batch = append(batch, item)
+			return nil
+		}, `SELECT id, `+column+` FROM `+table+` WHERE id > $1 ORDER BY id LIMIT $2`, lastID, batchSize)
+		if err != nil {
+			return updated, err
+		}
+
+		for _, item := range batch {
+			if !needsRotation(keyring, item.value) {
+				continue
+			}
+			value, err := keyring.DecryptPtr(item.value)
+			if err != nil {
+				return updated, err
+			}
+			if value, err = keyring.EncryptPtr(value); err != nil {
+				return updated, err
+			}
+			rotated, err := rotateRow(ctx, db,
+				`UPDATE `+table+` SET `+column+` = $2 WHERE id = $1 AND `+column+` IS NOT DISTINCT FROM $3`,
+				item.id, value, item.value,
+			)
+			if err != nil {
+				return updated, err
+			}
+			if rotated {
+				updated++
+			}
+		}
+
+		if len(batch) < batchSize {
+			return updated, nil
+		}
+		lastID = batch[len(batch)-1].id
+	}
+}
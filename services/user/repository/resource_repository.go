@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/database"
+)
+
+// ResourceRepository guarda o diretório de recursos de crise. Os dados são
+// públicos e não são cifrados.
+type ResourceRepository struct {
+	db *database.DB
+}
+
+func NewResourceRepository(db *database.DB) *ResourceRepository {
+	return &ResourceRepository{db: db}
+}
+
+const resourceColumns = `
+	id, name, description, type, phone, chat_url, website_url, country, state, languages,
+	always_open, timezone, hours, priority, created_at, updated_at, version
+`
+
+func scanResource(row rowScanner) (*models.CrisisResource, error) {
+	resource := &models.CrisisResource{}
+	var languages string
+	var hours []byte
+	err := row.Scan(
+		&resource.ID, &resource.Name, &resource.Description, &resource.Type, &resource.Phone,
+		&resource.ChatURL, &resource.WebsiteURL, &resource.Country, &resource.State, &languages,
+		&resource.AlwaysOpen, &resource.Timezone, &hours, &resource.Priority,
+		&resource.CreatedAt, &resource.UpdatedAt, &resource.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	resource.Languages = strings.Split(languages, ",")
+	resource.Hours = []models.OpeningHours{}
+	if err := json.Unmarshal(hours, &resource.Hours); err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+// resourceArgs converte o pedido nos valores das colunas name a priority
+func resourceArgs(req *models.ResourceRequest) ([]any, error) {
+	hours := req.Hours
+	if hours == nil {
+		hours = []models.OpeningHours{}
+	}
+	encoded, err := json.Marshal(hours)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		req.Name, req.Description, req.Type, req.Phone, req.ChatURL, req.WebsiteURL, req.Country,
+		req.State, strings.Join(req.Languages, ","), req.AlwaysOpen, req.Timezone, string(encoded), req.Priority,
+	}, nil
+}
+
+// List lê da réplica quando configurada
+func (r *ResourceRepository) List(ctx context.Context, filter *models.ResourceFilter) ([]*models.CrisisResource, error) {
+	query := `
+		SELECT ` + resourceColumns + `
+		FROM crisis_resources
+		WHERE ($1 = '' OR country = $1)
+		  AND ($2 = '' OR state IS NULL OR state = $2)
+		  AND ($3 = '' OR type = $3)
+		ORDER BY priority DESC, name
+	`
+
+	resources := []*models.CrisisResource{}
+	err := readRows(ctx, r.db, func(rows *sql.Rows) error {
+		resource, err := scanResource(rows)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, resource)
+		return nil
+	}, query, filter.Country, filter.State, filter.Type)
+	if err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+func (r *ResourceRepository) GetByID(ctx context.Context, id string) (*models.CrisisResource, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + resourceColumns + ` FROM crisis_resources WHERE id = $1`
+	return scanResource(r.db.Reader(ctx).QueryRowContext(ctx, query, id))
+}
+
+func (r *ResourceRepository) Create(ctx context.Context, req *models.ResourceRequest) (*models.CrisisResource, error) {
+	args, err := resourceArgs(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO crisis_resources (
+			name, description, type, phone, chat_url, website_url, country, state, languages,
+			always_open, timezone, hours, priority
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING ` + resourceColumns
+
+	return scanResource(r.db.Executor(ctx).QueryRowContext(ctx, query, args...))
+}
+
+func (r *ResourceRepository) Update(ctx context.Context, id string, req *models.ResourceRequest, version int) error {
+	args, err := resourceArgs(req)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE crisis_resources
+		SET name = $1, description = $2, type = $3, phone = $4, chat_url = $5, website_url = $6,
+		    country = $7, state = $8, languages = $9, always_open = $10, timezone = $11, hours = $12,
+		    priority = $13, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $14 AND ($15 = 0 OR version = $15)
+	`
+	result, err := execContext(ctx, r.db, query, append(args, id, version)...)
+	return checkAffected(result, err, version)
+}
+
+func (r *ResourceRepository) Delete(ctx context.Context, id string, version int) error {
+	query := `DELETE FROM crisis_resources WHERE id = $1 AND ($2 = 0 OR version = $2)`
+	result, err := execContext(ctx, r.db, query, id, version)
+	return checkAffected(result, err, version)
+}
@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/crypto"
+	"github.com/meuapoio/shared/database"
+)
+
+type SafetyPlanRepository struct {
+	db      *database.DB
+	keyring *crypto.Keyring
+}
+
+// NewSafetyPlanRepository cria o repositório. As seções do plano e do histórico
+// são cifradas com o keyring.
+func NewSafetyPlanRepository(db *database.DB, keyring *crypto.Keyring) *SafetyPlanRepository {
+	return &SafetyPlanRepository{db: db, keyring: keyring}
+}
+
+// shareColumns junta o link (alias s) ao contato (alias c)
+const shareColumns = `
+	s.id, s.contact_id, c.name, s.expires_at, s.revoked_at, s.last_viewed_at, s.created_at
+`
+
+func (r *SafetyPlanRepository) encryptSections(sections []models.SafetyPlanSection) (string, error) {
+	data, err := json.Marshal(sections)
+	if err != nil {
+		return "", err
+	}
+	return r.keyring.Encrypt(string(data))
+}
+
+func (r *SafetyPlanRepository) decryptSections(value string) ([]models.SafetyPlanSection, error) {
+	plaintext, err := r.keyring.Decrypt(value)
+	if err != nil {
+		return nil, err
+	}
+	sections := []models.SafetyPlanSection{}
+	if err := json.Unmarshal([]byte(plaintext), &sections); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+func (r *SafetyPlanRepository) scanPlan(row rowScanner) (*models.SafetyPlan, error) {
+	plan := &models.SafetyPlan{}
+	var sections string
+	if err := row.Scan(&sections, &plan.CreatedAt, &plan.UpdatedAt, &plan.Version); err != nil {
+		return nil, err
+	}
+	var err error
+	if plan.Sections, err = r.decryptSections(sections); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (r *SafetyPlanRepository) scanShare(row rowScanner) (*models.SafetyPlanShare, error) {
+	share := &models.SafetyPlanShare{}
+	err := row.Scan(
+		&share.ID, &share.ContactID, &share.ContactName, &share.ExpiresAt,
+		&share.RevokedAt, &share.LastViewedAt, &share.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if share.ContactName, err = r.keyring.Decrypt(share.ContactName); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// Get lê do primário: o plano costuma ser relido logo depois de salvo
+func (r *SafetyPlanRepository) Get(ctx context.Context, userID string) (*models.SafetyPlan, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `SELECT sections, created_at, updated_at, version FROM safety_plans WHERE user_id = $1`
+	return r.scanPlan(r.db.Executor(ctx).QueryRowContext(ctx, query, userID))
+}
+
+// Save usa um único upsert para que duas primeiras gravações simultâneas não
+// criem dois planos
+func (r *SafetyPlanRepository) Save(ctx context.Context, userID string, sections []models.SafetyPlanSection, version int) (*models.SafetyPlan, error) {
+	encrypted, err := r.encryptSections(sections)
+	if err != nil {
+		return nil, err
+	}
+
+	upsert := `
+		INSERT INTO safety_plans (user_id, sections)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET sections = EXCLUDED.sections, updated_at = CURRENT_TIMESTAMP, version = safety_plans.version + 1
+		WHERE $3 = 0 OR safety_plans.version = $3
+		RETURNING created_at, updated_at, version
+	`
+	insertRevision := `
+		INSERT INTO safety_plan_revisions (user_id, version, sections, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	plan := &models.SafetyPlan{Sections: sections}
+	err = r.db.WithTx(ctx, func(ctx context.Context) error {
+		err := func() error {
+			ctx, cancel := r.db.Timeout(ctx)
+			defer cancel()
+			return r.db.Executor(ctx).QueryRowContext(ctx, upsert, userID, encrypted, version).
+				Scan(&plan.CreatedAt, &plan.UpdatedAt, &plan.Version)
+		}()
+		if err == sql.ErrNoRows {
+			return ErrVersionConflict
+		}
+		if err != nil {
+			return err
+		}
+		_, err = execContext(ctx, r.db, insertRevision, userID, plan.Version, encrypted, plan.UpdatedAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (r *SafetyPlanRepository) Delete(ctx context.Context, userID string, version int) error {
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		result, err := execContext(ctx, r.db,
+			`DELETE FROM safety_plans WHERE user_id = $1 AND ($2 = 0 OR version = $2)`, userID, version)
+		if err := checkAffected(result, err, version); err != nil {
+			return err
+		}
+		for _, table := range []string{"safety_plan_revisions", "safety_plan_shares"} {
+			if _, err := execContext(ctx, r.db, `DELETE FROM `+table+` WHERE user_id = $1`, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListRevisions ordena da versão mais recente para a mais antiga
+func (r *SafetyPlanRepository) ListRevisions(ctx context.Context, userID string) ([]*models.SafetyPlanRevision, error) {
+	query := `
+		SELECT version, created_at
+		FROM safety_plan_revisions
+		WHERE user_id = $1
+		ORDER BY version DESC
+	`
+
+	revisions := []*models.SafetyPlanRevision{}
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		revision := &models.SafetyPlanRevision{}
+		if err := rows.Scan(&revision.Version, &revision.CreatedAt); err != nil {
+			return err
+		}
+		revisions = append(revisions, revision)
+		return nil
+	}, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetRevision retorna o plano como estava na versão informada
+func (r *SafetyPlanRepository) GetRevision(ctx context.Context, userID string, version int) (*models.SafetyPlan, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT r.sections, p.created_at, r.created_at, r.version
+		FROM safety_plan_revisions r
+		JOIN safety_plans p ON p.user_id = r.user_id
+		WHERE r.user_id = $1 AND r.version = $2
+	`
+	return r.scanPlan(r.db.Executor(ctx).QueryRowContext(ctx, query, userID, version))
+}
+
+func (r *SafetyPlanRepository) CreateShare(ctx context.Context, userID, contactID, tokenHash string, expiresAt time.Time) (*models.SafetyPlanShare, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH s AS (
+			INSERT INTO safety_plan_shares (user_id, contact_id, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING *
+		)
+		SELECT ` + shareColumns + `
+		FROM s
+		JOIN emergency_contacts c ON c.id = s.contact_id
+	`
+	return r.scanShare(r.db.Executor(ctx).QueryRowContext(ctx, query, userID, contactID, tokenHash, expiresAt))
+}
+
+// ListShares ordena do link mais recente ao mais antigo
+func (r *SafetyPlanRepository) ListShares(ctx context.Context, userID string) ([]*models.SafetyPlanShare, error) {
+	query := `
+		SELECT ` + shareColumns + `
+		FROM safety_plan_shares s
+		JOIN emergency_contacts c ON c.id = s.contact_id
+		WHERE s.user_id = $1
+		ORDER BY s.created_at DESC
+	`
+
+	shares := []*models.SafetyPlanShare{}
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		share, err := r.scanShare(rows)
+		if err != nil {
+			return err
+		}
+		shares = append(shares, share)
+		return nil
+	}, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// RevokeShare encerra um link ainda não revogado
+func (r *SafetyPlanRepository) RevokeShare(ctx context.Context, id, userID string, now time.Time) error {
+	query := `
+		UPDATE safety_plan_shares
+		SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+	result, err := execContext(ctx, r.db, query, id, userID, now)
+	return checkAffected(result, err, 0)
+}
+
+// ViewShared registra a visualização e retorna o plano vigente. O link só vale
+// antes de vencer, sem revogação, com a conta ativa e enquanto o contato aceitar
+// ser contato de emergência.
+func (r *SafetyPlanRepository) ViewShared(ctx context.Context, tokenHash string, now time.Time) (*models.SharedSafetyPlan, error) {
+	view := `
+		UPDATE safety_plan_shares s
+		SET last_viewed_at = $2
+		FROM users u, emergency_contacts c
+		WHERE s.token_hash = $1 AND s.revoked_at IS NULL AND s.expires_at > $2
+		  AND u.id = s.user_id AND u.is_active = true
+		  AND c.id = s.contact_id AND c.status = 'accepted'
+		RETURNING s.user_id, COALESCE(u.full_name, u.username), s.expires_at
+	`
+
+	shared := &models.SharedSafetyPlan{}
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		err := func() error {
+			ctx, cancel := r.db.Timeout(ctx)
+			defer cancel()
+			return r.db.Executor(ctx).QueryRowContext(ctx, view, tokenHash, now).
+				Scan(&shared.UserID, &shared.OwnerName, &shared.ExpiresAt)
+		}()
+		if err != nil {
+			return err
+		}
+		shared.Plan, err = r.Get(ctx, shared.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shared, nil
+}
+
+// ReencryptAll recifra com a master key ativa as seções do plano e do histórico,
+// como ContactRepository.ReencryptAll
+func (r *SafetyPlanRepository) ReencryptAll(ctx context.Context, batchSize int) (int, error) {
+	plans, err := reencryptColumn(ctx, r.db, r.keyring, batchSize, "safety_plans", "sections")
+	if err != nil {
+		return plans, err
+	}
+	revisions, err := reencryptColumn(ctx, r.db, r.keyring, batchSize, "safety_plan_revisions", "sections")
+	return plans + revisions, err
+}
@@ -1,106 +1,505 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/audit"
+	"github.com/meuapoio/shared/crypto"
+	"github.com/meuapoio/shared/database"
+	"github.com/meuapoio/shared/phone"
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db      *database.DB
+	keyring *crypto.Keyring
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+// NewUserRepository cria o repositório. Telefone e data de nascimento são
+// cifrados com o keyring antes de irem para o banco.
+func NewUserRepository(db *database.DB, keyring *crypto.Keyring) *UserRepository {
+	return &UserRepository{db: db, keyring: keyring}
 }
 
-func (r *UserRepository) Create(user *models.User) error {
+const userColumns = `
+	id, username, email, password_hash, full_name, birth_date,
+	phone, phone_display, profile_image_id, preferred_language, created_at, updated_at,
+	is_active, deletion_scheduled_for, version
+`
+
+func (r *UserRepository) scanUser(row *sql.Row) (*models.User, error) {
+	user := &models.User{}
+	var birthDate, encPhone, encDisplay *string
+
+	err := row.Scan(
+		&user.ID, &user.Username, &user.Email, &user.PasswordHash,
+		&user.FullName, &birthDate, &encPhone, &encDisplay, &user.ProfileImageID, &user.PreferredLanguage,
+		&user.CreatedAt, &user.UpdatedAt, &user.IsActive, &user.DeletionScheduledFor,
+		&user.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Phone, err = r.keyring.DecryptPtr(encPhone); err != nil {
+		return nil, err
+	}
+	if user.PhoneDisplay, err = r.keyring.DecryptPtr(encDisplay); err != nil {
+		return nil, err
+	}
+	if user.PhoneDisplay == nil && user.Phone != nil {
+		// Linha ainda não convertida por normalize-phones
+		display := phone.Display(*user.Phone)
+		user.PhoneDisplay = &display
+	}
+	if user.BirthDate, err = decryptDate(r.keyring, birthDate); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
 	query := `
 		INSERT INTO users (username, email, password_hash, full_name)
 		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, updated_at, is_active
+		RETURNING id, created_at, updated_at, is_active, version
 	`
 
-	return r.db.QueryRow(
-		query, user.Username, user.Email, user.PasswordHash, user.FullName,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.IsActive)
+	return r.db.Executor(ctx).QueryRowContext(
+		ctx, query, user.Username, user.Email, user.PasswordHash, user.FullName,
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.IsActive, &user.Version)
 }
 
-func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
-	user := &models.User{}
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, username, email, password_hash, full_name, birth_date, 
-		       phone, profile_image_url, created_at, updated_at, is_active
+		SELECT ` + userColumns + `
 		FROM users 
-		WHERE email = $1 AND is_active = true
+		WHERE email = $1 AND (is_active = true OR deletion_scheduled_for IS NOT NULL)
 	`
 
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash,
-		&user.FullName, &user.BirthDate, &user.Phone, &user.ProfileImageURL,
-		&user.CreatedAt, &user.UpdatedAt, &user.IsActive,
-	)
+	return r.scanUser(r.db.Executor(ctx).QueryRowContext(ctx, query, email))
+}
 
-	if err != nil {
-		return nil, err
-	}
+// GetByID lê da réplica quando configurada, exceto dentro de uma transação
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
 
-	return user, nil
+	query := `
+		SELECT ` + userColumns + `
+		FROM users 
+		WHERE id = $1 AND is_active = true
+	`
+
+	return r.scanUser(r.db.Reader(ctx).QueryRowContext(ctx, query, id))
 }
 
-func (r *UserRepository) GetByID(id string) (*models.User, error) {
-	user := &models.User{}
+// GetByIDIncludingDeactivated lê do primário, como a exportação que o usa
+func (r *UserRepository) GetByIDIncludingDeactivated(ctx context.Context, id string) (*models.User, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = $1
+	`
+
+	return r.scanUser(r.db.Executor(ctx).QueryRowContext(ctx, query, id))
+}
+
+// GetByPhone busca um usuário ativo pelo telefone usando o blind index
+func (r *UserRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, username, email, password_hash, full_name, birth_date, 
-		       phone, profile_image_url, created_at, updated_at, is_active
+		SELECT ` + userColumns + `
 		FROM users 
-		WHERE id = $1 AND is_active = true
+		WHERE phone_bidx = $1 AND is_active = true
 	`
 
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash,
-		&user.FullName, &user.BirthDate, &user.Phone, &user.ProfileImageURL,
-		&user.CreatedAt, &user.UpdatedAt, &user.IsActive,
-	)
+	return r.scanUser(r.db.Executor(ctx).QueryRowContext(ctx, query, phoneIndex(r.keyring, phone)))
+}
 
+// Update aplica os campos informados. Com version diferente de zero, retorna
+// ErrVersionConflict se a linha já estiver em outra versão.
+func (r *UserRepository) Update(ctx context.Context, id string, req *models.UpdateUserRequest, version int) error {
+	encPhone, err := r.keyring.EncryptPtr(req.Phone)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	birthDate, err := encryptDate(r.keyring, req.BirthDate)
+	if err != nil {
+		return err
 	}
 
-	return user, nil
-}
+	var phoneBidx, phoneDisplay *string
+	if req.Phone != nil {
+		index := phoneIndex(r.keyring, *req.Phone)
+		phoneBidx = &index
+		display := phone.Display(*req.Phone)
+		if phoneDisplay, err = r.keyring.EncryptPtr(&display); err != nil {
+			return err
+		}
+	}
 
-func (r *UserRepository) Update(id string, req *models.UpdateUserRequest) error {
 	query := `
 		UPDATE users 
 		SET full_name = COALESCE($2, full_name),
 		    birth_date = COALESCE($3, birth_date),
 		    phone = COALESCE($4, phone),
-		    profile_image_url = COALESCE($5, profile_image_url),
-		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1 AND is_active = true
+		    phone_bidx = COALESCE($5, phone_bidx),
+		    phone_display = COALESCE($8, phone_display),
+		    preferred_language = COALESCE($6, preferred_language),
+		    updated_at = CURRENT_TIMESTAMP,
+		    version = version + 1
+		WHERE id = $1 AND is_active = true AND ($7 = 0 OR version = $7)
+		`
+
+	result, err := execContext(ctx, r.db, query,
+		id, req.FullName, birthDate, encPhone, phoneBidx, req.PreferredLanguage, version, phoneDisplay,
+	)
+	return checkAffected(result, err, version)
+}
+
+// SetProfileImage troca a foto de perfil atual; imageID nil remove a foto.
+// version segue a regra de Update.
+func (r *UserRepository) SetProfileImage(ctx context.Context, id string, imageID *string, version int) error {
+	query := `
+		UPDATE users
+		SET profile_image_id = $2, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1 AND is_active = true AND ($3 = 0 OR version = $3)
 	`
+	result, err := execContext(ctx, r.db, query, id, imageID, version)
+	return checkAffected(result, err, version)
+}
 
-	_, err := r.db.Exec(query, id, req.FullName, req.BirthDate, req.Phone, req.ProfileImageURL)
-	return err
+// PreferredLanguage retorna o idioma escolhido no perfil ("" se nenhum). Lê da
+// réplica quando configurada, pois é consultado a cada requisição autenticada.
+func (r *UserRepository) PreferredLanguage(ctx context.Context, id string) (string, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	var lang sql.NullString
+	err := r.db.Reader(ctx).QueryRowContext(ctx,
+		`SELECT preferred_language FROM users WHERE id = $1 AND is_active = true`, id,
+	).Scan(&lang)
+	return lang.String, err
 }
 
-func (r *UserRepository) SoftDelete(id string) error {
-	query := `UPDATE users SET is_active = false WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+// ScheduleDeletion desativa a conta e agenda a exclusão definitiva para depois
+// do período de carência. version segue a regra de Update.
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id string, scheduledFor time.Time, version int) error {
+	query := `
+		UPDATE users
+		SET is_active = false, deletion_scheduled_for = $2, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1 AND is_active = true AND ($3 = 0 OR version = $3)
+	`
+	result, err := execContext(ctx, r.db, query, id, scheduledFor, version)
+	return checkAffected(result, err, version)
+}
+
+// CancelDeletion reativa uma conta cuja exclusão ainda não foi executada
+func (r *UserRepository) CancelDeletion(ctx context.Context, id string) error {
+	query := `
+		UPDATE users
+		SET is_active = true, deletion_scheduled_for = NULL, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1 AND deletion_scheduled_for IS NOT NULL
+	`
+	_, err := execContext(ctx, r.db, query, id)
 	return err
 }
 
-func (r *UserRepository) EmailExists(email string) (bool, error) {
+// ListDueForErasure retorna as contas cujo período de carência terminou
+func (r *UserRepository) ListDueForErasure(ctx context.Context, now time.Time) ([]string, error) {
+	query := `
+		SELECT id FROM users
+		WHERE is_active = false AND deletion_scheduled_for <= $1
+		ORDER BY deletion_scheduled_for
+	`
+
+	var ids []string
+	err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	}, query, now)
+
+	return ids, err
+}
+
+// Erase remove definitivamente a conta e todos os dados pessoais vinculados.
+// As condições do SELECT ... FOR UPDATE garantem que uma conta reativada no meio
+// do caminho não seja apagada. Retorna false se nada foi removido.
+func (r *UserRepository) Erase(ctx context.Context, id string, now time.Time) (bool, error) {
+	erased := false
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		ctx, cancel := r.db.Timeout(ctx)
+		defer cancel()
+		tx := r.db.Executor(ctx)
+
+		var lockedID string
+		err := tx.QueryRowContext(ctx, `
+			SELECT id FROM users
+			WHERE id = $1 AND is_active = false AND deletion_scheduled_for <= $2
+			FOR UPDATE
+		`, id, now).Scan(&lockedID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// As tabelas filhas também têm ON DELETE CASCADE; a remoção explícita deixa
+		// claro o que é apagado e continua valendo se alguma FK mudar no futuro
+		for _, table := range personalDataTables {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, id); err != nil {
+				return err
+			}
+		}
+
+		// audit_events é mantida por obrigação legal, mas sem IP, user agent e
+		// diff do titular
+		if err := audit.Anonymize(ctx, tx, id, now); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id); err != nil {
+			return err
+		}
+
+		erased = true
+		return nil
+	})
+
+	return erased, err
+}
+
+// personalDataTables lista as tabelas com dados pessoais vinculados a users.user_id
+var personalDataTables = []string{
+	"emergency_contacts",
+	"user_consents",
+	"user_favorites",
+	"user_play_history",
+	"notifications",
+	"data_exports",
+	"idempotency_keys",
+	"sos_alerts",
+	"safety_plan_shares",
+	"safety_plan_revisions",
+	"safety_plans",
+}
+
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`
-	err := r.db.QueryRow(query, email).Scan(&exists)
+	err := r.db.Executor(ctx).QueryRowContext(ctx, query, email).Scan(&exists)
 	return exists, err
 }
 
-func (r *UserRepository) UsernameExists(username string) (bool, error) {
+func (r *UserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)`
-	err := r.db.QueryRow(query, username).Scan(&exists)
+	err := r.db.Executor(ctx).QueryRowContext(ctx, query, username).Scan(&exists)
 	return exists, err
 }
+
+// IsActive lê do primário: a desativação precisa valer na requisição seguinte
+func (r *UserRepository) IsActive(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	var active bool
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND is_active = true)`
+	err := r.db.Executor(ctx).QueryRowContext(ctx, query, id).Scan(&active)
+	return active, err
+}
+
+// IsAdmin lê do primário: uma revogação precisa valer na requisição seguinte
+func (r *UserRepository) IsAdmin(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := r.db.Timeout(ctx)
+	defer cancel()
+
+	var admin bool
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND is_active = true AND is_admin = true)`
+	err := r.db.Executor(ctx).QueryRowContext(ctx, query, id).Scan(&admin)
+	return admin, err
+}
+
+func (r *UserRepository) SetAdmin(ctx context.Context, email string, admin bool) error {
+	query := `UPDATE users SET is_admin = $2 WHERE email = $1`
+	result, err := execContext(ctx, r.db, query, email, admin)
+	return checkAffected(result, err, 0)
+}
+
+// ReencryptAll recifra com a master key ativa os campos ainda em texto puro ou
+// cifrados com chaves antigas, em lotes. Retorna quantos usuários foram atualizados.
+func (r *UserRepository) ReencryptAll(ctx context.Context, batchSize int) (int, error) {
+	type row struct {
+		id                             string
+		birthDate, phone, phoneDisplay *string
+	}
+
+	updated := 0
+	lastID := minUUID
+	for {
+		var batch []row
+		err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+			var item row
+			if err := rows.Scan(&item.id, &item.birthDate, &item.phone, &item.phoneDisplay); err != nil {
+				return err
+			}
+			batch = append(batch, item)
+			return nil
+		}, `SELECT id, birth_date, phone, phone_display FROM users WHERE id > $1 ORDER BY id LIMIT $2`, lastID, batchSize)
+		if err != nil {
+			return updated, err
+		}
+
+		for _, item := range batch {
+			if !needsRotation(r.keyring, item.birthDate) && !needsRotation(r.keyring, item.phone) &&
+				!needsRotation(r.keyring, item.phoneDisplay) {
+				continue
+			}
+
+			phone, err := r.keyring.DecryptPtr(item.phone)
+			if err != nil {
+				return updated, err
+			}
+			phoneDisplay, err := r.keyring.DecryptPtr(item.phoneDisplay)
+			if err != nil {
+				return updated, err
+			}
+			birthDate, err := r.keyring.DecryptPtr(item.birthDate)
+			if err != nil {
+				return updated, err
+			}
+
+			var phoneBidx *string
+			if phone != nil {
+				index := phoneIndex(r.keyring, *phone)
+				phoneBidx = &index
+			}
+			if phone, err = r.keyring.EncryptPtr(phone); err != nil {
+				return updated, err
+			}
+			if phoneDisplay, err = r.keyring.EncryptPtr(phoneDisplay); err != nil {
+				return updated, err
+			}
+			if birthDate, err = r.keyring.EncryptPtr(birthDate); err != nil {
+				return updated, err
+			}
+
+			rotated, err := rotateRow(ctx, r.db, `
+				UPDATE users SET birth_date = $2, phone = $3, phone_bidx = $4, phone_display = $5
+				WHERE id = $1 AND birth_date IS NOT DISTINCT FROM $6
+				  AND phone IS NOT DISTINCT FROM $7 AND phone_display IS NOT DISTINCT FROM $8`,
+				item.id, birthDate, phone, phoneBidx, phoneDisplay,
+				item.birthDate, item.phone, item.phoneDisplay,
+			)
+			if err != nil {
+				return updated, err
+			}
+			if rotated {
+				updated++
+			}
+		}
+
+		if len(batch) < batchSize {
+			return updated, nil
+		}
+		lastID = batch[len(batch)-1].id
+	}
+}
+
+// NormalizePhones converte para E.164, em lotes, os telefones gravados antes da
+// validação, interpretando os números sem código do país na região informada.
+// Retorna quantos usuários foram atualizados e os IDs cujo telefone não pôde ser
+// interpretado, que ficam como estão.
+func (r *UserRepository) NormalizePhones(ctx context.Context, batchSize int, region string) (int, []string, error) {
+	type row struct {
+		id                  string
+		phone, phoneDisplay *string
+	}
+
+	updated := 0
+	var invalid []string
+	lastID := minUUID
+	for {
+		var batch []row
+		err := queryRows(ctx, r.db, func(rows *sql.Rows) error {
+			var item row
+			if err := rows.Scan(&item.id, &item.phone, &item.phoneDisplay); err != nil {
+				return err
+			}
+			batch = append(batch, item)
+			return nil
+		}, `SELECT id, phone, phone_display FROM users WHERE id > $1 ORDER BY id LIMIT $2`, lastID, batchSize)
+		if err != nil {
+			return updated, invalid, err
+		}
+
+		for _, item := range batch {
+			raw, err := r.keyring.DecryptPtr(item.phone)
+			if err != nil {
+				return updated, invalid, err
+			}
+			if raw == nil {
+				continue
+			}
+
+			number, err := phone.Parse(*raw, region)
+			if err != nil {
+				invalid = append(invalid, item.id)
+				continue
+			}
+			if number.E164() == *raw && item.phoneDisplay != nil {
+				continue
+			}
+
+			e164, display := number.E164(), number.Display()
+			encPhone, err := r.keyring.EncryptPtr(&e164)
+			if err != nil {
+				return updated, invalid, err
+			}
+			encDisplay, err := r.keyring.EncryptPtr(&display)
+			if err != nil {
+				return updated, invalid, err
+			}
+
+			_, err = execContext(ctx, r.db,
+				`UPDATE users SET phone = $2, phone_bidx = $3, phone_display = $4, version = version + 1 WHERE id = $1`,
+				item.id, encPhone, phoneIndex(r.keyring, e164), encDisplay,
+			)
+			if err != nil {
+				return updated, invalid, err
+			}
+			updated++
+		}
+
+		if len(batch) < batchSize {
+			return updated, invalid, nil
+		}
+		lastID = batch[len(batch)-1].id
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/services/user/handlers"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/config"
+	"github.com/meuapoio/shared/i18n"
+	sharedmw "github.com/meuapoio/shared/middleware"
+	"github.com/meuapoio/shared/sanitize"
+)
+
+// routeHandlers reúne os handlers expostos pelo serviço
+type routeHandlers struct {
+	user       *handlers.UserHandler
+	contact    *handlers.ContactHandler
+	auth       *handlers.AuthHandler
+	export     *handlers.ExportHandler
+	consent    *handlers.ConsentHandler
+	sos        *handlers.SOSHandler
+	resource   *handlers.ResourceHandler
+	safetyPlan *handlers.SafetyPlanHandler
+}
+
+// routeStores reúne os repositórios consultados pelos middlewares
+type routeStores struct {
+	consents    sharedmw.ConsentChecker
+	optIns      sharedmw.OptInChecker
+	accounts    sharedmw.AccountChecker
+	languages   i18n.PreferenceStore
+	admins      sharedmw.AdminChecker
+	idempotency sharedmw.IdempotencyStore
+}
+
+// newRouter monta o engine com todas as rotas do serviço. Separado do main para
+// que os testes exercitem as mesmas rotas e middlewares da aplicação.
+func newRouter(cfg *config.Config, h routeHandlers, stores routeStores) *gin.Engine {
+	// Limpeza dos textos livres antes das regras de binding
+	sanitize.RegisterValidator()
+
+	r := gin.Default()
+	r.NoRoute(apierror.NoRoute)
+
+	// Middlewares globais
+	r.Use(gin.Logger())
+	r.Use(gin.Recovery())
+	r.Use(sharedmw.RequestID())
+	r.Use(i18n.Middleware())
+
+	// Retentativas com a mesma Idempotency-Key repetem a primeira resposta; nas
+	// rotas autenticadas a chave é separada por usuário
+	idempotency := sharedmw.IdempotencyMiddleware(stores.idempotency, cfg.IdempotencyTTL, cfg.IdempotencyMaxBody)
+
+	// Rotas públicas (sem autenticação)
+	public := r.Group("/api/v1")
+	public.Use(idempotency)
+	{
+		public.POST("/auth/register", h.auth.Register)
+		public.POST("/auth/login", h.auth.Login)
+		// Protegida pela assinatura do link temporário
+		public.GET("/users/export/:id/download", h.export.DownloadExport)
+		// Fotos de perfil no armazenamento local, protegidas pela assinatura do link
+		public.GET("/images/:key", h.user.GetImage)
+		public.GET("/consents/documents", h.consent.GetDocuments)
+		// Convite do contato de emergência, protegido pelo token do SMS
+		public.GET("/invitations/:token", h.contact.GetInvitation)
+		public.POST("/invitations/:token/accept", h.contact.AcceptInvitation)
+		public.POST("/invitations/:token/decline", h.contact.DeclineInvitation)
+		// Confirmação do alerta de SOS pelo contato, protegida pelo token do aviso
+		public.GET("/alerts/:token", h.sos.GetAlertNotice)
+		public.POST("/alerts/:token/acknowledge", h.sos.AcknowledgeAlert)
+		// Diretório de recursos de crise, consultado também sem conta
+		public.GET("/resources", h.resource.GetResources)
+		public.GET("/resources/:id", h.resource.GetResource)
+		// Plano de segurança compartilhado, protegido pelo token do SMS
+		public.GET("/shared-plans/:token", h.safetyPlan.GetSharedPlan)
+		public.GET("/shared-plans/:token/pdf", h.safetyPlan.GetSharedPlanPDF)
+		public.GET("/health", func(c *gin.Context) {
+			c.JSON(200, gin.H{"status": "ok", "service": "user-service"})
+		})
+	}
+
+	// Exportação dos dados, liberada também durante o período de carência da
+	// exclusão da conta e com aceite pendente
+	exports := r.Group("/api/v1")
+	exports.Use(sharedmw.AuthMiddleware(cfg))
+	exports.Use(i18n.UserPreferenceMiddleware(stores.languages))
+	exports.Use(idempotency)
+	{
+		exports.POST("/users/export", h.export.RequestExport)
+		exports.GET("/users/export/:id", h.export.GetExport)
+	}
+
+	// Rotas autenticadas liberadas mesmo com aceite pendente: gestão de
+	// consentimentos, exclusão da conta e o SOS e a leitura do plano de
+	// segurança, que não podem ser bloqueados por termos desatualizados numa
+	// crise. Nenhuma rota daqui em diante aceita contas desativadas.
+	authenticated := r.Group("/api/v1")
+	authenticated.Use(sharedmw.AuthMiddleware(cfg))
+	authenticated.Use(i18n.UserPreferenceMiddleware(stores.languages))
+	authenticated.Use(sharedmw.ActiveAccountMiddleware(stores.accounts))
+	authenticated.Use(idempotency)
+	{
+		authenticated.GET("/consents", h.consent.GetConsents)
+		authenticated.POST("/consents", h.consent.GrantConsent)
+		authenticated.DELETE("/consents/:type", h.consent.WithdrawConsent)
+
+		authenticated.DELETE("/users/profile", h.user.DeleteAccount)
+
+		authenticated.POST("/sos", h.sos.TriggerSOS)
+		authenticated.GET("/sos", h.sos.GetAlerts)
+		authenticated.GET("/sos/:id", h.sos.GetAlert)
+		authenticated.POST("/sos/:id/cancel", h.sos.CancelAlert)
+
+		authenticated.GET("/safety-plan", h.safetyPlan.GetSafetyPlan)
+		authenticated.GET("/safety-plan/pdf", h.safetyPlan.ExportPDF)
+	}
+
+	// Rotas protegidas (com autenticação e aceite dos documentos obrigatórios)
+	protected := r.Group("/api/v1")
+	protected.Use(sharedmw.AuthMiddleware(cfg))
+	protected.Use(i18n.UserPreferenceMiddleware(stores.languages))
+	protected.Use(sharedmw.ActiveAccountMiddleware(stores.accounts))
+	protected.Use(sharedmw.ConsentMiddleware(stores.consents))
+	protected.Use(idempotency)
+	// Os convites por SMS são enviados aos contatos em nome do usuário e exigem
+	// o opt-in de contato. O SOS não passa por aqui: numa crise os contatos são
+	// avisados mesmo sem ele.
+	contactOptIn := sharedmw.RequireConsent(stores.optIns, models.ConsentContact)
+	// O conteúdo do plano de segurança é dado de saúde: gravar e compartilhar
+	// exigem o opt-in de dados sensíveis
+	healthOptIn := sharedmw.RequireConsent(stores.optIns, models.ConsentSensitiveHealthData)
+	{
+		// Usuários
+		protected.GET("/users/profile", h.user.GetProfile)
+		protected.PUT("/users/profile", h.user.UpdateProfile)
+		protected.POST("/users/profile/image", h.user.UploadProfileImage)
+		protected.DELETE("/users/profile/image", h.user.DeleteProfileImage)
+		protected.GET("/users/security-activity", h.user.GetSecurityActivity)
+
+		// Contatos de emergência
+		protected.GET("/contacts", h.contact.GetContacts)
+		protected.POST("/contacts", contactOptIn, h.contact.CreateContact)
+		protected.POST("/contacts/import", contactOptIn, h.contact.ImportContacts)
+		protected.GET("/contacts/export", h.contact.ExportContacts)
+		protected.GET("/contacts/relationships", h.contact.GetRelationships)
+		protected.GET("/contacts/:id", h.contact.GetContact)
+		protected.PUT("/contacts/:id", contactOptIn, h.contact.UpdateContact)
+		protected.DELETE("/contacts/:id", h.contact.DeleteContact)
+		protected.POST("/contacts/:id/primary", h.contact.SetPrimaryContact)
+		protected.POST("/contacts/:id/invitation", contactOptIn, h.contact.ResendInvitation)
+
+		// Plano de segurança
+		protected.PUT("/safety-plan", healthOptIn, h.safetyPlan.SaveSafetyPlan)
+		protected.DELETE("/safety-plan", h.safetyPlan.DeleteSafetyPlan)
+		protected.GET("/safety-plan/revisions", h.safetyPlan.ListRevisions)
+		protected.GET("/safety-plan/revisions/:version", h.safetyPlan.GetRevision)
+		protected.GET("/safety-plan/shares", h.safetyPlan.ListShares)
+		protected.POST("/safety-plan/shares", sharedmw.RequireConsent(stores.optIns, models.ConsentSensitiveHealthData, models.ConsentContact), h.safetyPlan.ShareSafetyPlan)
+		protected.DELETE("/safety-plan/shares/:id", h.safetyPlan.RevokeShare)
+	}
+
+	// Rotas de administração: como as protegidas, restritas a administradores
+	admin := r.Group("/api/v1")
+	admin.Use(sharedmw.AuthMiddleware(cfg))
+	admin.Use(i18n.UserPreferenceMiddleware(stores.languages))
+	admin.Use(sharedmw.ActiveAccountMiddleware(stores.accounts))
+	admin.Use(sharedmw.ConsentMiddleware(stores.consents))
+	admin.Use(sharedmw.AdminMiddleware(stores.admins))
+	admin.Use(idempotency)
+	{
+		admin.POST("/resources", h.resource.CreateResource)
+		admin.PUT("/resources/:id", h.resource.UpdateResource)
+		admin.DELETE("/resources/:id", h.resource.DeleteResource)
+	}
+
+	return r
+}
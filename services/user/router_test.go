@@ -0,0 +1,1850 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/meuapoio/api"
+	"github.com/meuapoio/services/user/handlers"
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+	"github.com/meuapoio/services/user/repository/memory"
+	"github.com/meuapoio/services/user/sos"
+	"github.com/meuapoio/shared/blob"
+	"github.com/meuapoio/shared/config"
+	"github.com/meuapoio/shared/pagination"
+	"github.com/meuapoio/shared/utils"
+)
+
+const testSecret = "segredo-de-teste"
+
+// testImageMaxSize é o limite de envio das fotos de perfil nos testes
+const testImageMaxSize = 256 << 10
+
+// testEscalationTimeout é curto para que o teste do SOS possa esperar o escalonamento
+const testEscalationTimeout = 200 * time.Millisecond
+
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	store  *memory.Store
+	sms    *smsOutbox
+	email  *emailOutbox
+	sos    *sos.Dispatcher
+}
+
+// smsOutbox guarda os SMS enviados pelo serviço no lugar de um provedor
+type smsOutbox struct {
+	mu       sync.Mutex
+	messages map[string][]string
+}
+
+func (o *smsOutbox) Send(ctx context.Context, to, body string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages[to] = append(o.messages[to], body)
+	return nil
+}
+
+// last retorna o último SMS enviado para o telefone
+func (o *smsOutbox) last(to string) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if messages := o.messages[to]; len(messages) > 0 {
+		return messages[len(messages)-1]
+	}
+	return ""
+}
+
+// emailOutbox guarda os emails enviados, só com o corpo
+type emailOutbox struct {
+	smsOutbox
+}
+
+func (o *emailOutbox) Send(ctx context.Context, to, subject, body string) error {
+	return o.smsOutbox.Send(ctx, to, body)
+}
+
+// linkToken extrai o token do link com o prefixo url no último SMS enviado ao telefone
+func (s *testServer) linkToken(phone, url string) string {
+	s.t.Helper()
+	body := s.sms.last(phone)
+	i := strings.Index(body, url)
+	if i < 0 {
+		s.t.Fatalf("SMS sem link %s: %q", url, body)
+	}
+	return strings.Fields(body[i+len(url):])[0]
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	store := memory.NewStore()
+	consentRepo := store.Consents()
+	for _, doc := range currentDocuments() {
+		consentRepo.Publish(doc)
+	}
+
+	outbox := &smsOutbox{messages: map[string][]string{}}
+	emails := &emailOutbox{smsOutbox{messages: map[string][]string{}}}
+	channels := []sos.Channel{sos.NewSMSChannel(outbox), sos.NewEmailChannel(emails)}
+	dispatcher := sos.NewDispatcher(store.Alerts(), channels, "https://meuapoio.com/alertas", time.Minute)
+	images, err := blob.NewFileStore(t.TempDir(), "/api/v1/images", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profileImages := handlers.NewProfileImages(images, time.Hour, testImageMaxSize)
+	cfg := &config.Config{JWTSecret: testSecret, IdempotencyTTL: time.Hour, IdempotencyMaxBody: 2 * testImageMaxSize}
+	router := newRouter(cfg, routeHandlers{
+		user:       handlers.NewUserHandler(store.Users(), store, nil, profileImages, 30*24*time.Hour, "BR"),
+		contact:    handlers.NewContactHandler(store.Contacts(), store, nil, outbox, "BR", "https://meuapoio.com/convites", 7*24*time.Hour),
+		auth:       handlers.NewAuthHandler(store.Users(), consentRepo, store, nil, profileImages, testSecret),
+		export:     handlers.NewExportHandler(nil, nil, store, nil, testSecret),
+		consent:    handlers.NewConsentHandler(consentRepo, store, nil),
+		sos:        handlers.NewSOSHandler(store.Alerts(), store.Contacts(), dispatcher, nil, testEscalationTimeout, 3),
+		resource:   handlers.NewResourceHandler(store.Resources(), store, nil),
+		safetyPlan: handlers.NewSafetyPlanHandler(store.SafetyPlans(), store.Contacts(), store.Users(), store, nil, outbox, "https://meuapoio.com/planos", 72*time.Hour),
+	}, routeStores{consents: consentRepo, optIns: consentRepo, accounts: store.Users(), languages: store.Users(), admins: store.Users(), idempotency: store.Idempotency()})
+
+	return &testServer{t: t, router: router, store: store, sms: outbox, email: emails, sos: dispatcher}
+}
+
+func currentDocuments() []models.ConsentDocument {
+	published := time.Now().Add(-time.Hour)
+	return []models.ConsentDocument{
+		{Type: models.ConsentTermsOfUse, Version: "1.0", URL: "https://meuapoio.com/termos/1.0", Mandatory: true, PublishedAt: published},
+		{Type: models.ConsentPrivacyPolicy, Version: "1.0", URL: "https://meuapoio.com/privacidade/1.0", Mandatory: true, PublishedAt: published},
+		{Type: models.ConsentSensitiveHealthData, Version: "1.0", URL: "https://meuapoio.com/dados-sensiveis/1.0", PublishedAt: published},
+		{Type: models.ConsentContact, Version: "1.0", URL: "https://meuapoio.com/contato/1.0", PublishedAt: published},
+	}
+}
+
+func mandatoryConsents() []map[string]string {
+	return []map[string]string{
+		{"type": models.ConsentTermsOfUse, "version": "1.0"},
+		{"type": models.ConsentPrivacyPolicy, "version": "1.0"},
+	}
+}
+
+// signupConsents são os aceites de register: os obrigatórios e os opt-ins que
+// liberam os convites por SMS e o plano de segurança
+func signupConsents() []map[string]string {
+	return append(mandatoryConsents(),
+		map[string]string{"type": models.ConsentSensitiveHealthData, "version": "1.0"},
+		map[string]string{"type": models.ConsentContact, "version": "1.0"},
+	)
+}
+
+// do executa a requisição e decodifica a resposta JSON em out (se não for nil)
+func (s *testServer) do(method, path, token string, body any, out any) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("%s %s: resposta não é JSON: %v (%s)", method, path, err, w.Body.String())
+		}
+	}
+	return w
+}
+
+func (s *testServer) expectStatus(w *httptest.ResponseRecorder, status int) {
+	s.t.Helper()
+	if w.Code != status {
+		s.t.Fatalf("status = %d, esperado %d (%s)", w.Code, status, w.Body.String())
+	}
+}
+
+// register cria um usuário com os aceites obrigatórios e retorna o token e o ID
+func (s *testServer) register(username string) (string, string) {
+	s.t.Helper()
+
+	var resp models.LoginResponse
+	w := s.do(http.MethodPost, "/api/v1/auth/register", "", map[string]any{
+		"username": username,
+		"email":    username + "@meuapoio.com",
+		"password": "senha123",
+		"consents": signupConsents(),
+	}, &resp)
+	s.expectStatus(w, http.StatusCreated)
+
+	return resp.Token, resp.User.ID
+}
+
+// A especificação servida pelo gateway deve descrever exatamente as rotas do serviço
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+	s := newTestServer(t)
+	spec, err := api.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := map[api.Route]bool{}
+	for _, route := range spec.Routes() {
+		documented[route] = true
+	}
+
+	registered := map[api.Route]bool{}
+	for _, info := range s.router.Routes() {
+		route := api.Route{Method: info.Method, Path: info.Path}
+		registered[route] = true
+		if !documented[route] {
+			t.Errorf("rota %s não está em api/openapi.yaml", route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			t.Errorf("rota %s documentada mas não registrada", route)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/health", "", nil, nil), http.StatusOK)
+}
+
+func TestRegister(t *testing.T) {
+	s := newTestServer(t)
+	s.register("maria")
+
+	tests := []struct {
+		name   string
+		body   map[string]any
+		status int
+	}{
+		{"email duplicado", map[string]any{
+			"username": "outra", "email": "maria@meuapoio.com", "password": "senha123", "consents": mandatoryConsents(),
+		}, http.StatusConflict},
+		{"username duplicado", map[string]any{
+			"username": "maria", "email": "outra@meuapoio.com", "password": "senha123", "consents": mandatoryConsents(),
+		}, http.StatusConflict},
+		{"sem aceites obrigatórios", map[string]any{
+			"username": "joao", "email": "joao@meuapoio.com", "password": "senha123", "consents": []map[string]string{},
+		}, http.StatusBadRequest},
+		{"versão antiga dos termos", map[string]any{
+			"username": "joao", "email": "joao@meuapoio.com", "password": "senha123",
+			"consents": append(mandatoryConsents(), map[string]string{"type": models.ConsentContact, "version": "0.9"}),
+		}, http.StatusBadRequest},
+		{"senha curta", map[string]any{
+			"username": "joao", "email": "joao@meuapoio.com", "password": "123", "consents": mandatoryConsents(),
+		}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.t = t
+			s.expectStatus(s.do(http.MethodPost, "/api/v1/auth/register", "", tt.body, nil), tt.status)
+		})
+	}
+}
+
+func TestRegisterRequiresConsentCode(t *testing.T) {
+	s := newTestServer(t)
+
+	var resp map[string]any
+	w := s.do(http.MethodPost, "/api/v1/auth/register", "", map[string]any{
+		"username": "joao", "email": "joao@meuapoio.com", "password": "senha123",
+		"consents": []map[string]string{{"type": models.ConsentTermsOfUse, "version": "1.0"}},
+	}, &resp)
+
+	s.expectStatus(w, http.StatusBadRequest)
+	if resp["code"] != "CONSENT_REQUIRED" {
+		t.Fatalf("code = %v, esperado CONSENT_REQUIRED", resp["code"])
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	s.register("maria")
+
+	var resp models.LoginResponse
+	w := s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"email": "maria@meuapoio.com", "password": "senha123",
+	}, &resp)
+	s.expectStatus(w, http.StatusOK)
+	if resp.Token == "" || resp.User.Username != "maria" {
+		t.Fatalf("resposta inesperada: %+v", resp)
+	}
+
+	w = s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"email": "maria@meuapoio.com", "password": "errada",
+	}, nil)
+	s.expectStatus(w, http.StatusUnauthorized)
+
+	w = s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"email": "ninguem@meuapoio.com", "password": "senha123",
+	}, nil)
+	s.expectStatus(w, http.StatusUnauthorized)
+}
+
+func TestAuthenticatedRoutesRequireToken(t *testing.T) {
+	s := newTestServer(t)
+
+	routes := []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/consents"},
+		{http.MethodPost, "/api/v1/consents"},
+		{http.MethodDelete, "/api/v1/consents/contact"},
+		{http.MethodDelete, "/api/v1/users/profile"},
+		{http.MethodPost, "/api/v1/users/export"},
+		{http.MethodGet, "/api/v1/users/export/123"},
+		{http.MethodGet, "/api/v1/users/profile"},
+		{http.MethodPut, "/api/v1/users/profile"},
+		{http.MethodGet, "/api/v1/users/security-activity"},
+		{http.MethodGet, "/api/v1/contacts"},
+		{http.MethodPost, "/api/v1/contacts"},
+		{http.MethodPut, "/api/v1/contacts/123"},
+		{http.MethodDelete, "/api/v1/contacts/123"},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			s.t = t
+			s.expectStatus(s.do(route.method, route.path, "", nil, nil), http.StatusUnauthorized)
+			s.expectStatus(s.do(route.method, route.path, "token-invalido", nil, nil), http.StatusUnauthorized)
+		})
+	}
+}
+
+func TestProfile(t *testing.T) {
+	s := newTestServer(t)
+	token, userID := s.register("maria")
+
+	var user models.User
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/users/profile", token, nil, &user), http.StatusOK)
+	if user.ID != userID {
+		t.Fatalf("perfil de outro usuário: %s", user.ID)
+	}
+
+	w := s.do(http.MethodPut, "/api/v1/users/profile", token, map[string]any{
+		"full_name":  "Maria Silva",
+		"phone":      "+55 11 99999-0000",
+		"birth_date": "1990-05-20T00:00:00Z",
+	}, &user)
+	s.expectStatus(w, http.StatusOK)
+	if user.FullName == nil || *user.FullName != "Maria Silva" || user.Phone == nil || user.BirthDate == nil {
+		t.Fatalf("perfil não atualizado: %+v", user)
+	}
+
+	w = s.do(http.MethodPut, "/api/v1/users/profile", token, map[string]any{"phone": "123456789012345678901"}, nil)
+	s.expectStatus(w, http.StatusBadRequest)
+}
+
+func TestSecurityActivity(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	var resp struct {
+		Events []any `json:"events"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/users/security-activity", token, nil, &resp), http.StatusOK)
+	if resp.Events == nil {
+		t.Fatal("events deve ser uma lista, não null")
+	}
+
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/users/security-activity?limit=0", token, nil, nil), http.StatusBadRequest)
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/users/security-activity?limit=201", token, nil, nil), http.StatusBadRequest)
+}
+
+func TestDeleteAccountAndCancelOnLogin(t *testing.T) {
+	s := newTestServer(t)
+	token, userID := s.register("maria")
+
+	var contact models.EmergencyContact
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{
+		"name": "João", "phone": "11999990000",
+	}, &contact), http.StatusCreated)
+
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/users/profile", token, nil, nil), http.StatusAccepted)
+
+	// Conta desativada: o token ainda tem assinatura válida, mas não dá mais acesso
+	for _, r := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/users/profile"},
+		{http.MethodDelete, "/api/v1/users/profile"},
+		{http.MethodGet, "/api/v1/contacts"},
+		{http.MethodPost, "/api/v1/contacts"},
+		{http.MethodPut, "/api/v1/contacts/" + contact.ID},
+		{http.MethodPost, "/api/v1/sos"},
+		{http.MethodGet, "/api/v1/consents"},
+	} {
+		var problem struct {
+			Code string `json:"code"`
+		}
+		s.expectStatus(s.do(r.method, r.path, token, map[string]any{"name": "Ana", "phone": "11988887777"}, &problem), http.StatusForbidden)
+		if problem.Code != "ACCOUNT_INACTIVE" {
+			t.Errorf("%s %s: code = %q, esperado ACCOUNT_INACTIVE", r.method, r.path, problem.Code)
+		}
+	}
+
+	// Uma escrita que já passou pelo middleware também é barrada, na transação
+	_, err := s.store.Contacts().Create(context.Background(), userID, &models.CreateContactRequest{Name: "Ana", Phone: "+5511988887777"})
+	if !errors.Is(err, repository.ErrAccountInactive) {
+		t.Fatalf("Create com a conta desativada = %v, esperado ErrAccountInactive", err)
+	}
+	alert := &models.Alert{UserID: userID, CreatedAt: time.Now()}
+	if err := s.store.Alerts().Create(context.Background(), alert, []*models.EmergencyContact{&contact}); !errors.Is(err, repository.ErrAccountInactive) {
+		t.Fatalf("alerta com a conta desativada = %v, esperado ErrAccountInactive", err)
+	}
+
+	var resp models.LoginResponse
+	w := s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"email": "maria@meuapoio.com", "password": "senha123",
+	}, &resp)
+	s.expectStatus(w, http.StatusOK)
+	if !resp.DeletionCancelled || !resp.User.IsActive {
+		t.Fatalf("login deveria cancelar a exclusão: %+v", resp)
+	}
+
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/users/profile", resp.Token, nil, nil), http.StatusOK)
+}
+
+func TestContacts(t *testing.T) {
+	s := newTestServer(t)
+	token, userID := s.register("maria")
+
+	var contact models.EmergencyContact
+	w := s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{
+		"name": "João", "phone": "11999990000", "relationship": "sibling",
+	}, &contact)
+	s.expectStatus(w, http.StatusCreated)
+	if contact.ID == "" || contact.UserID != userID {
+		t.Fatalf("contato inválido: %+v", contact)
+	}
+
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "Sem telefone"}, nil), http.StatusBadRequest)
+
+	w = s.do(http.MethodPut, "/api/v1/contacts/"+contact.ID, token, map[string]any{"is_primary": true}, &contact)
+	s.expectStatus(w, http.StatusOK)
+	if !contact.IsPrimary || contact.Name != "João" {
+		t.Fatalf("contato não atualizado: %+v", contact)
+	}
+
+	var list struct {
+		Items []models.EmergencyContact `json:"items"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts", token, nil, &list), http.StatusOK)
+	if len(list.Items) != 1 {
+		t.Fatalf("esperado 1 contato, obtido %d", len(list.Items))
+	}
+
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/contacts/"+contact.ID, token, nil, nil), http.StatusOK)
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/contacts/"+contact.ID, token, nil, nil), http.StatusNotFound)
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/contacts/"+contact.ID, token, map[string]any{"name": "X"}, nil), http.StatusNotFound)
+
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts", token, nil, &list), http.StatusOK)
+	if list.Items == nil || len(list.Items) != 0 {
+		t.Fatalf("esperada lista vazia, obtido %+v", list.Items)
+	}
+}
+
+func TestContactsAreScopedToOwner(t *testing.T) {
+	s := newTestServer(t)
+	ownerToken, _ := s.register("maria")
+	otherToken, _ := s.register("joao")
+
+	var contact models.EmergencyContact
+	w := s.do(http.MethodPost, "/api/v1/contacts", ownerToken, map[string]any{"name": "Ana", "phone": "11988887777"}, &contact)
+	s.expectStatus(w, http.StatusCreated)
+
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/contacts/"+contact.ID, otherToken, map[string]any{"name": "X"}, nil), http.StatusNotFound)
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/contacts/"+contact.ID, otherToken, nil, nil), http.StatusNotFound)
+
+	var list struct {
+		Items []models.EmergencyContact `json:"items"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts", otherToken, nil, &list), http.StatusOK)
+	if len(list.Items) != 0 {
+		t.Fatalf("usuário vê contatos de outro: %+v", list.Items)
+	}
+}
+
+func TestContactsPagination(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	phones := []string{"11911110000", "11922220000", "11933330000", "11944440000", "11955550000"}
+	for i, phone := range phones {
+		s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "Contato " + strconv.Itoa(i), "phone": phone}, nil), http.StatusCreated)
+	}
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/invitations/"+s.linkToken("+5511933330000", "https://meuapoio.com/convites/")+"/accept", "", nil, nil), http.StatusOK)
+
+	type page struct {
+		Items      []models.EmergencyContact `json:"items"`
+		NextCursor *string                   `json:"next_cursor"`
+	}
+
+	// Percorre todas as páginas; nenhum contato se repete ou fica de fora
+	walk := func(query string) []models.EmergencyContact {
+		var all []models.EmergencyContact
+		path := "/api/v1/contacts?limit=2" + query
+		for pages := 0; pages < 10; pages++ {
+			var p page
+			s.expectStatus(s.do(http.MethodGet, path, token, nil, &p), http.StatusOK)
+			if len(p.Items) > 2 {
+				t.Fatalf("página maior que o limite: %d", len(p.Items))
+			}
+			all = append(all, p.Items...)
+			if p.NextCursor == nil {
+				return all
+			}
+			path = "/api/v1/contacts?limit=2" + query + "&cursor=" + url.QueryEscape(*p.NextCursor)
+		}
+		t.Fatal("paginação não terminou")
+		return nil
+	}
+
+	all := walk("")
+	seen := map[string]bool{}
+	for _, contact := range all {
+		seen[contact.ID] = true
+	}
+	if len(all) != len(phones) || len(seen) != len(phones) || !all[0].IsPrimary {
+		t.Fatalf("paginação padrão inesperada: %+v", all)
+	}
+	for i := 2; i < len(all); i++ {
+		if all[i].CreatedAt.After(all[i-1].CreatedAt) {
+			t.Errorf("contatos fora da ordem do mais recente ao mais antigo: %+v", all)
+		}
+	}
+
+	ascending := walk("&sort=-created_at")
+	for i := 1; i < len(ascending); i++ {
+		if ascending[i].CreatedAt.Before(ascending[i-1].CreatedAt) {
+			t.Errorf("sort=-created_at deveria ir do mais antigo ao mais recente: %+v", ascending)
+		}
+	}
+	if accepted := walk("&status=accepted"); len(accepted) != 1 || accepted[0].Phone != "+5511933330000" {
+		t.Errorf("filtro por status inesperado: %+v", accepted)
+	}
+	if others := walk("&is_primary=false"); len(others) != len(phones)-1 {
+		t.Errorf("filtro por is_primary inesperado: %+v", others)
+	}
+
+	// O cursor só vale para a mesma consulta
+	var first page
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts?limit=2", token, nil, &first), http.StatusOK)
+	var problem struct {
+		Errors []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts?limit=2&sort=updated_at&cursor="+url.QueryEscape(*first.NextCursor), token, nil, &problem), http.StatusBadRequest)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "cursor" {
+		t.Errorf("erro inesperado: %+v", problem)
+	}
+	// Cursor forjado pelo cliente, com um id que não é UUID
+	forged := pagination.Request{Sort: models.ContactSortPrimary, Desc: true}.Cursor([]string{"true", "2026-01-02 03:04:05.000000", "1 OR 1=1"})
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts?limit=2&cursor="+forged, token, nil, &problem), http.StatusBadRequest)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "cursor" {
+		t.Errorf("cursor forjado: %+v", problem)
+	}
+	for _, query := range []string{"limit=0", "limit=101", "sort=name", "status=blocked", "cursor=abc"} {
+		s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts?"+query, token, nil, nil), http.StatusBadRequest)
+	}
+}
+
+func TestContactLimit(t *testing.T) {
+	s := newTestServer(t)
+	s.store.SetMaxContacts(2)
+	token, _ := s.register("maria")
+
+	for _, phone := range []string{"11911110000", "11922220000"} {
+		s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "Contato", "phone": phone}, nil), http.StatusCreated)
+	}
+
+	var problem struct {
+		Code        string `json:"code"`
+		MaxContacts int    `json:"max_contacts"`
+	}
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "Terceiro", "phone": "11933330000"}, &problem), http.StatusConflict)
+	if problem.Code != "CONTACT_LIMIT_REACHED" || problem.MaxContacts != 2 {
+		t.Errorf("erro inesperado: %+v", problem)
+	}
+
+	importFile := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("name,phone\nTerceiro,11933330000\nQuarto,11955550000\n"))
+		req.Header.Set("Content-Type", "text/csv")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A simulação confere o mesmo limite e mostra quantos contatos ficariam de fora
+	w := importFile("/api/v1/contacts/import?dry_run=true")
+	s.expectStatus(w, http.StatusOK)
+	var report models.ContactImportResult
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid != 2 || report.OverLimit != 2 || report.MaxContacts == nil || *report.MaxContacts != 2 {
+		t.Errorf("simulação não mostra o limite: %+v", report)
+	}
+
+	// A importação é tudo ou nada: nenhum contato do arquivo entra
+	w = importFile("/api/v1/contacts/import")
+	s.expectStatus(w, http.StatusConflict)
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Code != "CONTACT_LIMIT_REACHED" || problem.MaxContacts != 2 {
+		t.Errorf("erro inesperado na importação: %+v (%v)", problem, err)
+	}
+
+	// Depois de remover um contato há espaço para outro
+	var page struct {
+		Items []models.EmergencyContact `json:"items"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts", token, nil, &page), http.StatusOK)
+	if len(page.Items) != 2 {
+		t.Fatalf("contatos = %+v", page.Items)
+	}
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/contacts/"+page.Items[1].ID, token, nil, nil), http.StatusOK)
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "Terceiro", "phone": "11933330000"}, nil), http.StatusCreated)
+
+	// O limite é por usuário
+	other, _ := s.register("joana")
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", other, map[string]any{"name": "Contato", "phone": "11944440000"}, nil), http.StatusCreated)
+}
+
+func TestFreeTextIsSanitized(t *testing.T) {
+	s := newTestServer(t)
+
+	var resp models.LoginResponse
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/auth/register", "", map[string]any{
+		"username":  "maria",
+		"email":     "maria@meuapoio.com",
+		"password":  "senha123",
+		"full_name": "<script>alert(1)</script>Maria\u202e  da\tSilva",
+		"consents":  signupConsents(),
+	}, &resp), http.StatusCreated)
+	token := resp.Token
+
+	var user models.User
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/users/profile", token, nil, &user), http.StatusOK)
+	if user.FullName == nil || *user.FullName != "alert(1) Maria da Silva" {
+		t.Errorf("full_name = %v", user.FullName)
+	}
+
+	var contact models.EmergencyContact
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{
+		"name": " <b>Jose\u0301</b>\n", "phone": "11988887777", "relationship": "sibling",
+	}, &contact), http.StatusCreated)
+	if contact.Name != "Jos\u00e9" || contact.Relationship == nil || *contact.Relationship != models.RelationshipSibling {
+		t.Errorf("contato = %+v", contact)
+	}
+
+	var problem struct {
+		Errors []struct{ Field, Rule string }
+	}
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "<img src=x>", "phone": "11977776666"}, &problem), http.StatusBadRequest)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "name" || problem.Errors[0].Rule != "required" {
+		t.Errorf("nome só com marcação: %+v", problem.Errors)
+	}
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/contacts/"+contact.ID, token, map[string]any{"name": "<p></p>"}, nil), http.StatusBadRequest)
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/contacts/"+contact.ID, token, map[string]any{"relationship": "irmã"}, &problem), http.StatusBadRequest)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "relationship" || problem.Errors[0].Rule != "oneof" {
+		t.Errorf("parentesco fora da lista: %+v", problem.Errors)
+	}
+
+	// Os nomes dos parentescos seguem o idioma do usuário
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/users/profile", token, map[string]any{"preferred_language": "es"}, nil), http.StatusOK)
+	var relationships struct {
+		Items []models.RelationshipOption `json:"items"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts/relationships", token, nil, &relationships), http.StatusOK)
+	if len(relationships.Items) != len(models.Relationships) || relationships.Items[0] != (models.RelationshipOption{Value: "mother", Label: "Madre"}) {
+		t.Errorf("parentescos = %+v", relationships.Items)
+	}
+}
+
+func TestConsents(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	var docs struct {
+		Documents []models.ConsentDocument `json:"documents"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/consents/documents", "", nil, &docs), http.StatusOK)
+	if len(docs.Documents) != 4 {
+		t.Fatalf("esperados 4 documentos, obtidos %d", len(docs.Documents))
+	}
+
+	var consents models.ConsentsResponse
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/consents", token, nil, &consents), http.StatusOK)
+	if len(consents.Consents) != 4 || len(consents.Pending) != 0 {
+		t.Fatalf("consentimentos inesperados: %+v", consents)
+	}
+
+	// Opt-in opcional: sem ele os convites por SMS ficam bloqueados
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/consents/"+models.ConsentContact, token, nil, nil), http.StatusOK)
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/consents/"+models.ConsentContact, token, nil, nil), http.StatusNotFound)
+
+	var withdrawn struct {
+		Code    string   `json:"code"`
+		Pending []string `json:"pending"`
+	}
+	contact := map[string]any{"name": "Ana", "phone": "11988887777"}
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, contact, &withdrawn), http.StatusForbidden)
+	if withdrawn.Code != "CONSENT_REQUIRED" || len(withdrawn.Pending) != 1 || withdrawn.Pending[0] != models.ConsentContact {
+		t.Fatalf("opt-in retirado: %+v", withdrawn)
+	}
+	if sms := s.sms.last("+5511988887777"); sms != "" {
+		t.Errorf("SMS enviado sem opt-in: %q", sms)
+	}
+	// As demais rotas protegidas continuam liberadas
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts", token, nil, nil), http.StatusOK)
+
+	w := s.do(http.MethodPost, "/api/v1/consents", token, map[string]string{"type": models.ConsentContact, "version": "0.9"}, nil)
+	s.expectStatus(w, http.StatusBadRequest)
+	w = s.do(http.MethodPost, "/api/v1/consents", token, map[string]string{"type": models.ConsentContact, "version": "1.0"}, nil)
+	s.expectStatus(w, http.StatusCreated)
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, contact, nil), http.StatusCreated)
+	if s.sms.last("+5511988887777") == "" {
+		t.Error("convite não enviado depois do novo aceite")
+	}
+
+	// Retirar os termos bloqueia as rotas protegidas, mas não a gestão de consentimentos
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/consents/"+models.ConsentTermsOfUse, token, nil, nil), http.StatusOK)
+
+	var blocked map[string]any
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/users/profile", token, nil, &blocked), http.StatusForbidden)
+	if blocked["code"] != "CONSENT_REQUIRED" {
+		t.Fatalf("code = %v, esperado CONSENT_REQUIRED", blocked["code"])
+	}
+
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/consents", token, nil, &consents), http.StatusOK)
+	if len(consents.Pending) != 1 || consents.Pending[0].Type != models.ConsentTermsOfUse {
+		t.Fatalf("pendências inesperadas: %+v", consents.Pending)
+	}
+
+	w = s.do(http.MethodPost, "/api/v1/consents", token, map[string]string{"type": models.ConsentTermsOfUse, "version": "1.0"}, nil)
+	s.expectStatus(w, http.StatusCreated)
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/users/profile", token, nil, nil), http.StatusOK)
+}
+
+func TestExportDownloadRejectsInvalidSignature(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []string{
+		"/api/v1/users/export/123/download",
+		"/api/v1/users/export/123/download?expires=abc&signature=x",
+		"/api/v1/users/export/123/download?expires=4102444800&signature=invalida",
+	}
+
+	for _, path := range tests {
+		s.expectStatus(s.do(http.MethodGet, path, "", nil, nil), http.StatusForbidden)
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	s := newTestServer(t)
+	token, userID := s.register("maria")
+
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &utils.Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		},
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, method, path, token string
+		body                      any
+		status                    int
+		code                      string
+	}{
+		{"validação", http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "Ana"}, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"corpo inválido", http.MethodPost, "/api/v1/contacts", token, "não é um objeto", http.StatusBadRequest, "MALFORMED_REQUEST"},
+		{"contato inexistente", http.MethodDelete, "/api/v1/contacts/inexistente", token, nil, http.StatusNotFound, "CONTACT_NOT_FOUND"},
+		{"sem token", http.MethodGet, "/api/v1/contacts", "", nil, http.StatusUnauthorized, "AUTH_TOKEN_MISSING"},
+		{"token expirado", http.MethodGet, "/api/v1/contacts", expired, nil, http.StatusUnauthorized, "AUTH_TOKEN_EXPIRED"},
+		{"rota inexistente", http.MethodGet, "/api/v1/inexistente", "", nil, http.StatusNotFound, "ROUTE_NOT_FOUND"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.t = t
+
+			var problem map[string]any
+			w := s.do(tt.method, tt.path, tt.token, tt.body, &problem)
+			s.expectStatus(w, tt.status)
+
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if problem["code"] != tt.code || problem["status"] != float64(tt.status) || problem["instance"] != tt.path {
+				t.Errorf("resposta inesperada: %v", problem)
+			}
+		})
+	}
+
+	var problem struct {
+		Errors []struct{ Field, Rule string }
+	}
+	s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "Ana"}, &problem)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "phone" || problem.Errors[0].Rule != "required" {
+		t.Fatalf("errors = %+v", problem.Errors)
+	}
+}
+
+func TestLocalizedMessages(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	request := func(acceptLanguage string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/contacts", bytes.NewReader([]byte(`{"name": "Ana"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept-Language", acceptLanguage)
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+
+		var problem map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatal(err)
+		}
+		return w, problem
+	}
+	fieldMessage := func(problem map[string]any) string {
+		return problem["errors"].([]any)[0].(map[string]any)["message"].(string)
+	}
+
+	w, problem := request("en-US,en;q=0.9")
+	if w.Header().Get("Content-Language") != "en" || problem["title"] != "Invalid data" {
+		t.Fatalf("esperada resposta em inglês: %s %v", w.Header().Get("Content-Language"), problem)
+	}
+	if msg := fieldMessage(problem); msg != "phone is a required field" {
+		t.Fatalf("mensagem de validação = %q", msg)
+	}
+
+	w, problem = request("")
+	if w.Header().Get("Content-Language") != "pt-BR" || problem["title"] != "Dados inválidos" {
+		t.Fatalf("esperada resposta em pt-BR: %v", problem)
+	}
+
+	// A preferência do perfil tem precedência sobre o Accept-Language
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/users/profile", token, map[string]any{"preferred_language": "es"}, nil), http.StatusOK)
+	w, problem = request("en")
+	if w.Header().Get("Content-Language") != "es" || problem["title"] != "Datos inválidos" {
+		t.Fatalf("esperada resposta em espanhol: %v", problem)
+	}
+
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/users/profile", token, map[string]any{"preferred_language": "fr"}, nil), http.StatusBadRequest)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	s := newTestServer(t)
+
+	send := func(path, token, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Cadastro repetido devolve a mesma resposta em vez de EMAIL_IN_USE
+	register := `{"username":"maria","email":"maria@meuapoio.com","password":"senha123",` +
+		`"consents":[{"type":"terms_of_use","version":"1.0"},{"type":"privacy_policy","version":"1.0"},{"type":"contact","version":"1.0"}]}`
+	first := send("/api/v1/auth/register", "", "cadastro-1", register)
+	s.expectStatus(first, http.StatusCreated)
+	retry := send("/api/v1/auth/register", "", "cadastro-1", register)
+	s.expectStatus(retry, http.StatusCreated)
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retentativa não repetiu a resposta: %s", retry.Body.String())
+	}
+
+	var resp models.LoginResponse
+	if err := json.Unmarshal(first.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	contact := `{"name":"João","phone":"11999990000"}`
+	s.expectStatus(send("/api/v1/contacts", resp.Token, "contato-1", contact), http.StatusCreated)
+	s.expectStatus(send("/api/v1/contacts", resp.Token, "contato-1", contact), http.StatusCreated)
+
+	var list struct {
+		Items []models.EmergencyContact `json:"items"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts", resp.Token, nil, &list), http.StatusOK)
+	if len(list.Items) != 1 {
+		t.Fatalf("retentativa duplicou o contato: %d contatos", len(list.Items))
+	}
+
+	// Mesma chave com outro corpo
+	w := send("/api/v1/contacts", resp.Token, "contato-1", `{"name":"Ana","phone":"11988887777"}`)
+	s.expectStatus(w, http.StatusUnprocessableEntity)
+	var problem map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem["code"] != "IDEMPOTENCY_KEY_REUSED" {
+		t.Fatalf("resposta inesperada: %s", w.Body.String())
+	}
+
+	// A chave é separada por usuário
+	otherToken, _ := s.register("ana")
+	s.expectStatus(send("/api/v1/contacts", otherToken, "contato-1", contact), http.StatusCreated)
+}
+
+func TestConditionalRequests(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	send := func(method, path, header, etag string, body any) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			reader = bytes.NewReader(data)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(header, etag)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Perfil: 304 enquanto não muda, 412 com um If-Match desatualizado
+	w := s.do(http.MethodGet, "/api/v1/users/profile", token, nil, nil)
+	s.expectStatus(w, http.StatusOK)
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("validadores ausentes: %v", w.Header())
+	}
+	if w = send(http.MethodGet, "/api/v1/users/profile", "If-None-Match", etag, nil); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("If-None-Match: status = %d, corpo = %q", w.Code, w.Body.String())
+	}
+
+	w = send(http.MethodPut, "/api/v1/users/profile", "If-Match", etag, map[string]any{"full_name": "Maria"})
+	s.expectStatus(w, http.StatusOK)
+	current := w.Header().Get("ETag")
+	if current == etag {
+		t.Fatalf("ETag não mudou após a alteração: %s", current)
+	}
+	s.expectStatus(send(http.MethodGet, "/api/v1/users/profile", "If-None-Match", etag, nil), http.StatusOK)
+
+	w = send(http.MethodPut, "/api/v1/users/profile", "If-Match", etag, map[string]any{"full_name": "Outra"})
+	s.expectStatus(w, http.StatusPreconditionFailed)
+	if w.Header().Get("ETag") != current {
+		t.Errorf("412 deveria trazer o ETag atual %s, obtido %s", current, w.Header().Get("ETag"))
+	}
+
+	// Contatos: a lista muda de ETag quando um contato muda
+	var contact models.EmergencyContact
+	w = s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "João", "phone": "11999990000"}, &contact)
+	s.expectStatus(w, http.StatusCreated)
+	contactETag := w.Header().Get("ETag")
+
+	w = s.do(http.MethodGet, "/api/v1/contacts", token, nil, nil)
+	listETag := w.Header().Get("ETag")
+	s.expectStatus(send(http.MethodGet, "/api/v1/contacts", "If-None-Match", listETag, nil), http.StatusNotModified)
+	s.expectStatus(send(http.MethodGet, "/api/v1/contacts/"+contact.ID, "If-None-Match", contactETag, nil), http.StatusNotModified)
+
+	s.expectStatus(send(http.MethodPut, "/api/v1/contacts/"+contact.ID, "If-Match", contactETag, map[string]any{"name": "João Silva"}), http.StatusOK)
+	s.expectStatus(send(http.MethodGet, "/api/v1/contacts", "If-None-Match", listETag, nil), http.StatusOK)
+	s.expectStatus(send(http.MethodDelete, "/api/v1/contacts/"+contact.ID, "If-Match", contactETag, nil), http.StatusPreconditionFailed)
+	s.expectStatus(send(http.MethodDelete, "/api/v1/contacts/"+contact.ID, "If-Match", "*", nil), http.StatusOK)
+}
+
+func TestPrimaryContact(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	create := func(name, phone string, primary bool) models.EmergencyContact {
+		var contact models.EmergencyContact
+		s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{
+			"name": name, "phone": phone, "is_primary": primary,
+		}, &contact), http.StatusCreated)
+		return contact
+	}
+	primaries := func() []string {
+		var list struct {
+			Items []models.EmergencyContact `json:"items"`
+		}
+		s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts", token, nil, &list), http.StatusOK)
+		var names []string
+		for _, contact := range list.Items {
+			if contact.IsPrimary {
+				names = append(names, contact.Name)
+			}
+		}
+		return names
+	}
+	expectPrimary := func(name string) {
+		t.Helper()
+		if got := primaries(); len(got) != 1 || got[0] != name {
+			t.Fatalf("principais = %v, esperado [%s]", got, name)
+		}
+	}
+
+	// O primeiro contato já é o principal
+	joao := create("João", "11999990000", false)
+	expectPrimary("João")
+
+	// Um novo principal desmarca o anterior
+	ana := create("Ana", "11988887777", true)
+	expectPrimary("Ana")
+	pedro := create("Pedro", "11977776666", false)
+
+	var contact models.EmergencyContact
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts/"+pedro.ID+"/primary", token, nil, &contact), http.StatusOK)
+	if !contact.IsPrimary {
+		t.Fatalf("contato não virou principal: %+v", contact)
+	}
+	expectPrimary("Pedro")
+
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/contacts/"+ana.ID, token, map[string]any{"is_primary": true}, nil), http.StatusOK)
+	expectPrimary("Ana")
+
+	// Remover o principal promove o contato mais antigo
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/contacts/"+ana.ID, token, nil, nil), http.StatusOK)
+	expectPrimary("João")
+
+	// Desmarcar o principal também promove outro; o único contato continua principal
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/contacts/"+joao.ID, token, map[string]any{"is_primary": false}, nil), http.StatusOK)
+	expectPrimary("Pedro")
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/contacts/"+joao.ID, token, nil, nil), http.StatusOK)
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/contacts/"+pedro.ID, token, map[string]any{"is_primary": false}, nil), http.StatusOK)
+	expectPrimary("Pedro")
+
+	otherToken, _ := s.register("ana")
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts/"+pedro.ID+"/primary", otherToken, nil, nil), http.StatusNotFound)
+}
+
+func TestPhoneNormalization(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	var contact models.EmergencyContact
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{
+		"name": "João", "phone": "(11) 98888-7777",
+	}, &contact), http.StatusCreated)
+	if contact.Phone != "+5511988887777" || contact.PhoneDisplay != "+55 11 98888-7777" {
+		t.Fatalf("telefone não normalizado: %q / %q", contact.Phone, contact.PhoneDisplay)
+	}
+
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/contacts/"+contact.ID, token, map[string]any{"phone": "+1 212 555 0100"}, &contact), http.StatusOK)
+	if contact.Phone != "+12125550100" || contact.PhoneDisplay != "+1 212-555-0100" {
+		t.Fatalf("telefone não normalizado: %q / %q", contact.Phone, contact.PhoneDisplay)
+	}
+
+	var user models.User
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/users/profile", token, map[string]any{"phone": "21 3333-4444"}, &user), http.StatusOK)
+	if user.Phone == nil || *user.Phone != "+552133334444" || *user.PhoneDisplay != "+55 21 3333-4444" {
+		t.Fatalf("telefone do perfil não normalizado: %+v", user)
+	}
+
+	var problem struct {
+		Code   string `json:"code"`
+		Errors []struct {
+			Field string `json:"field"`
+			Rule  string `json:"rule"`
+		} `json:"errors"`
+	}
+	w := s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "Ana", "phone": "8888-7777"}, &problem)
+	s.expectStatus(w, http.StatusBadRequest)
+	if problem.Code != "VALIDATION_FAILED" || len(problem.Errors) != 1 || problem.Errors[0].Field != "phone" || problem.Errors[0].Rule != "phone" {
+		t.Fatalf("resposta inesperada: %s", w.Body.String())
+	}
+}
+
+func TestContactInvitations(t *testing.T) {
+	s := newTestServer(t)
+	token, userID := s.register("maria")
+
+	invitationToken := func(phone string) string {
+		return s.linkToken(phone, "https://meuapoio.com/convites/")
+	}
+	accepted := func() int {
+		contacts, err := s.store.Contacts().GetAccepted(context.Background(), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(contacts)
+	}
+
+	var contact models.EmergencyContact
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{
+		"name": "João", "phone": "11999990000",
+	}, &contact), http.StatusCreated)
+	if contact.Status != models.ContactStatusPending || contact.InvitationSentAt == nil {
+		t.Fatalf("contato deveria começar pendente com convite enviado: %+v", contact)
+	}
+	invitation := invitationToken("+5511999990000")
+
+	var view models.ContactInvitation
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/invitations/"+invitation, "", nil, &view), http.StatusOK)
+	if view.InviterName != "maria" || view.ContactName != "João" || view.Status != models.ContactStatusPending {
+		t.Fatalf("convite inesperado: %+v", view)
+	}
+	if accepted() != 0 {
+		t.Fatal("contato pendente não pode receber alertas")
+	}
+
+	// O reenvio respeita o intervalo mínimo entre SMS
+	w := s.do(http.MethodPost, "/api/v1/contacts/"+contact.ID+"/invitation", token, nil, nil)
+	s.expectStatus(w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 sem Retry-After")
+	}
+
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/invitations/"+invitation+"/accept", "", nil, &view), http.StatusOK)
+	if view.Status != models.ContactStatusAccepted || accepted() != 1 {
+		t.Fatalf("aceite não registrado: %+v", view)
+	}
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts/"+contact.ID+"/invitation", token, nil, nil), http.StatusConflict)
+
+	// Enquanto o link vale, a pessoa pode mudar de ideia
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/invitations/"+invitation+"/decline", "", nil, &view), http.StatusOK)
+	if view.Status != models.ContactStatusDeclined || accepted() != 0 {
+		t.Fatalf("recusa não registrada: %+v", view)
+	}
+
+	// Outro telefone é outra pessoa: volta a pendente e o link antigo deixa de valer
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/contacts/"+contact.ID, token, map[string]any{"phone": "11988887777"}, &contact), http.StatusOK)
+	if contact.Status != models.ContactStatusPending || contact.RespondedAt != nil {
+		t.Fatalf("troca de telefone deveria zerar o convite: %+v", contact)
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/invitations/"+invitation, "", nil, nil), http.StatusNotFound)
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/invitations/"+invitationToken("+5511988887777")+"/accept", "", nil, nil), http.StatusOK)
+	if accepted() != 1 {
+		t.Fatal("novo número aceitou o convite")
+	}
+
+	// Alterar só o nome não reenvia o convite
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/contacts/"+contact.ID, token, map[string]any{"name": "João Silva"}, &contact), http.StatusOK)
+	if contact.Status != models.ContactStatusAccepted {
+		t.Fatalf("status alterado sem troca de telefone: %+v", contact)
+	}
+
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/invitations/token-invalido/accept", "", nil, nil), http.StatusNotFound)
+}
+
+func TestSOSAlert(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/sos", token, nil, nil), http.StatusUnprocessableEntity)
+
+	// Dois contatos que aceitaram o convite; só a Ana tem email
+	contacts := map[string]models.EmergencyContact{}
+	for _, req := range []map[string]any{
+		{"name": "João", "phone": "11999990000"},
+		{"name": "Ana", "phone": "11988887777", "email": "ana@exemplo.com"},
+	} {
+		var contact models.EmergencyContact
+		s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, req, &contact), http.StatusCreated)
+		s.expectStatus(s.do(http.MethodPost, "/api/v1/invitations/"+s.linkToken(contact.Phone, "https://meuapoio.com/convites/")+"/accept", "", nil, nil), http.StatusOK)
+		contacts[contact.Name] = contact
+	}
+	joao, ana := contacts["João"], contacts["Ana"]
+	if ana.Email == nil || *ana.Email != "ana@exemplo.com" {
+		t.Fatalf("email do contato não gravado: %+v", ana)
+	}
+
+	// O contato principal é avisado na hora, com a localização
+	var alert models.Alert
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/sos", token, map[string]any{
+		"location": map[string]any{"latitude": -23.55, "longitude": -46.63},
+	}, &alert), http.StatusCreated)
+	if alert.Status != models.AlertStatusActive || alert.EscalatesAt == nil || len(alert.Recipients) != 2 {
+		t.Fatalf("alerta inesperado: %+v", alert)
+	}
+	if r := alert.Recipients[0]; r.ContactName != "João" || r.Status != models.RecipientStatusNotified || len(r.Channels) != 1 {
+		t.Fatalf("contato principal não avisado: %+v", r)
+	}
+	if r := alert.Recipients[1]; r.Status != models.RecipientStatusPending {
+		t.Fatalf("demais contatos só depois do escalonamento: %+v", r)
+	}
+	if body := s.sms.last(joao.Phone); !strings.Contains(body, "maria") || !strings.Contains(body, "maps.google.com/?q=-23.55") {
+		t.Fatalf("aviso sem nome ou localização: %q", body)
+	}
+	if strings.Contains(s.sms.last(ana.Phone), "https://meuapoio.com/alertas/") {
+		t.Fatal("contato não principal avisado antes do prazo")
+	}
+
+	// Repetir o acionamento não gera novo alerta nem novos avisos
+	var again models.Alert
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/sos", token, nil, &again), http.StatusOK)
+	if again.ID != alert.ID {
+		t.Fatalf("esperado o alerta ativo %s, recebido %s", alert.ID, again.ID)
+	}
+
+	// Sem confirmação no prazo, os demais contatos são avisados por todos os canais
+	if n := s.sos.RunOnce(context.Background()); n != 0 {
+		t.Fatalf("alerta escalonado antes do prazo (%d)", n)
+	}
+	time.Sleep(testEscalationTimeout + 50*time.Millisecond)
+	if n := s.sos.RunOnce(context.Background()); n != 1 {
+		t.Fatalf("esperado 1 alerta escalonado, recebido %d", n)
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/sos/"+alert.ID, token, nil, &alert), http.StatusOK)
+	if r := alert.Recipients[1]; alert.Level != models.AlertLevelOthers || r.Status != models.RecipientStatusNotified || len(r.Channels) != 2 {
+		t.Fatalf("escalonamento não registrado: %+v %+v", alert, r)
+	}
+	if !strings.Contains(s.email.last("ana@exemplo.com"), "https://meuapoio.com/alertas/") {
+		t.Fatal("email do alerta não enviado")
+	}
+
+	// O contato confirma pelo link, sem login
+	ack := s.linkToken(ana.Phone, "https://meuapoio.com/alertas/")
+	var notice models.AlertNotice
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/alerts/"+ack, "", nil, &notice), http.StatusOK)
+	if notice.UserName != "maria" || notice.Status != models.AlertStatusActive || notice.Location == nil {
+		t.Fatalf("aviso inesperado: %+v", notice)
+	}
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/alerts/"+ack+"/acknowledge", "", nil, &notice), http.StatusOK)
+	if notice.Status != models.AlertStatusAcknowledged || notice.AcknowledgedAt == nil {
+		t.Fatalf("confirmação não registrada: %+v", notice)
+	}
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/alerts/token-invalido/acknowledge", "", nil, nil), http.StatusNotFound)
+
+	// Outro usuário não vê o alerta
+	other, _ := s.register("pedro")
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/sos/"+alert.ID, other, nil, nil), http.StatusNotFound)
+
+	// Cancelar avisa quem já recebeu o alerta
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/sos/"+alert.ID+"/cancel", token, nil, &alert), http.StatusOK)
+	if alert.Status != models.AlertStatusCancelled || !strings.Contains(s.sms.last(joao.Phone), "cancelou") {
+		t.Fatalf("cancelamento não registrado: %+v", alert)
+	}
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/sos/"+alert.ID+"/cancel", token, nil, nil), http.StatusConflict)
+
+	// Limite de acionamentos por hora
+	for i := 0; i < 2; i++ {
+		s.expectStatus(s.do(http.MethodPost, "/api/v1/sos", token, nil, &alert), http.StatusCreated)
+		s.expectStatus(s.do(http.MethodPost, "/api/v1/sos/"+alert.ID+"/cancel", token, nil, nil), http.StatusOK)
+	}
+	w := s.do(http.MethodPost, "/api/v1/sos", token, nil, nil)
+	s.expectStatus(w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 sem Retry-After")
+	}
+
+	var history struct {
+		Alerts []models.Alert `json:"alerts"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/sos", token, nil, &history), http.StatusOK)
+	if len(history.Alerts) != 3 {
+		t.Fatalf("esperados 3 alertas no histórico, recebidos %d", len(history.Alerts))
+	}
+}
+
+func TestCrisisResources(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	national := map[string]any{
+		"name": "CVV", "type": "hotline", "phone": "188", "country": "BR",
+		"languages": []string{"pt-BR"}, "always_open": true, "timezone": "America/Sao_Paulo", "priority": 100,
+	}
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/resources", token, national, nil), http.StatusForbidden)
+	if err := s.store.Users().SetAdmin(context.Background(), "maria@meuapoio.com", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Atende só depois de amanhã, então está fechado agora
+	loc, _ := time.LoadLocation("America/Sao_Paulo")
+	closedDay := (int(time.Now().In(loc).Weekday()) + 2) % 7
+	resources := map[string]models.CrisisResource{}
+	for _, req := range []map[string]any{
+		national,
+		{
+			"name": "Chat SP", "type": "chat", "chat_url": "https://exemplo.com/chat", "country": "BR", "state": "sp",
+			"languages": []string{"pt-BR"}, "timezone": "America/Sao_Paulo",
+			"hours": []map[string]any{{"days": []int{0, 1, 2, 3, 4, 5, 6}, "opens": "00:00", "closes": "00:00"}},
+		},
+		{
+			"name": "Apoio RJ", "type": "support_service", "phone": "2133330000", "country": "BR", "state": "RJ",
+			"languages": []string{"pt-BR", "es"}, "timezone": "America/Sao_Paulo",
+			"hours": []map[string]any{{"days": []int{closedDay}, "opens": "00:00", "closes": "23:59"}},
+		},
+	} {
+		var resource models.CrisisResource
+		s.expectStatus(s.do(http.MethodPost, "/api/v1/resources", token, req, &resource), http.StatusCreated)
+		resources[resource.Name] = resource
+	}
+	if chat := resources["Chat SP"]; chat.State == nil || *chat.State != "SP" {
+		t.Errorf("estado deveria ser normalizado: %+v", chat)
+	}
+
+	// Sem telefone nem links, e sem horários fora do regime 24 horas
+	for _, req := range []map[string]any{
+		{"name": "X", "type": "hotline", "country": "BR", "languages": []string{"pt-BR"}, "always_open": true, "timezone": "America/Sao_Paulo"},
+		{"name": "X", "type": "hotline", "phone": "188", "country": "BR", "languages": []string{"pt-BR"}, "timezone": "America/Sao_Paulo"},
+	} {
+		s.expectStatus(s.do(http.MethodPost, "/api/v1/resources", token, req, nil), http.StatusBadRequest)
+	}
+
+	list := func(query string) []string {
+		t.Helper()
+		var resp struct {
+			Resources []models.CrisisResource `json:"resources"`
+		}
+		s.expectStatus(s.do(http.MethodGet, "/api/v1/resources"+query, "", nil, &resp), http.StatusOK)
+		var names []string
+		for _, resource := range resp.Resources {
+			names = append(names, resource.Name)
+		}
+		return names
+	}
+	for query, want := range map[string]string{
+		"?country=BR&state=SP":                      "CVV,Chat SP",
+		"?country=BR&open_now=true":                 "CVV,Chat SP",
+		"?country=BR&language=es":                   "Apoio RJ",
+		"?country=PT":                               "",
+		"?country=BR&type=hotline":                  "CVV",
+		"?country=BR&state=rj&type=support_service": "Apoio RJ",
+	} {
+		if got := strings.Join(list(query), ","); got != want {
+			t.Errorf("GET /resources%s = %q, esperado %q", query, got, want)
+		}
+	}
+
+	// A lista pode ser guardada offline e revalidada pelo ETag
+	w := s.do(http.MethodGet, "/api/v1/resources?country=BR", "", nil, nil)
+	etag := w.Header().Get("ETag")
+	if w.Header().Get("Cache-Control") != "public, max-age=300" {
+		t.Errorf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/resources?country=BR", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.expectStatus(w, http.StatusNotModified)
+
+	cvv := resources["CVV"]
+	national["description"] = "Apoio emocional e prevenção do suicídio"
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/resources/"+cvv.ID, token, national, nil), http.StatusOK)
+	if w = s.do(http.MethodGet, "/api/v1/resources?country=BR", "", nil, nil); w.Header().Get("ETag") == etag {
+		t.Error("ETag da lista não mudou após a alteração")
+	}
+
+	rj := resources["Apoio RJ"]
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/resources/"+rj.ID, token, nil, nil), http.StatusOK)
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/resources/"+rj.ID, "", nil, nil), http.StatusNotFound)
+
+	// A revogação vale na requisição seguinte
+	if err := s.store.Users().SetAdmin(context.Background(), "maria@meuapoio.com", false); err != nil {
+		t.Fatal(err)
+	}
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/resources/"+cvv.ID, token, nil, nil), http.StatusForbidden)
+}
+
+// O plano é dado de saúde: sem o opt-in de dados sensíveis não é gravado nem
+// compartilhado, mas continua legível e pode ser apagado
+func TestSafetyPlanRequiresHealthConsent(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	var contact models.EmergencyContact
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "João", "phone": "11999990000"}, &contact), http.StatusCreated)
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/invitations/"+s.linkToken("+5511999990000", "https://meuapoio.com/convites/")+"/accept", "", nil, nil), http.StatusOK)
+	plan := map[string]any{"sections": []map[string]any{{"type": "warning_signs", "items": []map[string]any{{"text": "Não consigo dormir"}}}}}
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/safety-plan", token, plan, nil), http.StatusCreated)
+
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/consents/"+models.ConsentSensitiveHealthData, token, nil, nil), http.StatusOK)
+
+	var problem struct {
+		Code    string   `json:"code"`
+		Pending []string `json:"pending"`
+	}
+	for _, req := range []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodPut, "/api/v1/safety-plan", plan},
+		{http.MethodPost, "/api/v1/safety-plan/shares", map[string]any{"contact_id": contact.ID}},
+	} {
+		problem.Code, problem.Pending = "", nil
+		s.expectStatus(s.do(req.method, req.path, token, req.body, &problem), http.StatusForbidden)
+		if problem.Code != "CONSENT_REQUIRED" || len(problem.Pending) != 1 || problem.Pending[0] != models.ConsentSensitiveHealthData {
+			t.Errorf("%s %s: %+v", req.method, req.path, problem)
+		}
+	}
+	if sms := s.sms.last("+5511999990000"); strings.Contains(sms, "https://meuapoio.com/planos/") {
+		t.Errorf("plano compartilhado sem opt-in: %q", sms)
+	}
+
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/safety-plan", token, nil, nil), http.StatusOK)
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/safety-plan", token, nil, nil), http.StatusOK)
+
+	w := s.do(http.MethodPost, "/api/v1/consents", token, map[string]string{"type": models.ConsentSensitiveHealthData, "version": "1.0"}, nil)
+	s.expectStatus(w, http.StatusCreated)
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/safety-plan", token, plan, nil), http.StatusCreated)
+}
+
+func TestSafetyPlan(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+	otherToken, _ := s.register("ana")
+
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/safety-plan", token, nil, nil), http.StatusNotFound)
+
+	var contact, pending, foreign models.EmergencyContact
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "João", "phone": "11999990000"}, &contact), http.StatusCreated)
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/invitations/"+s.linkToken("+5511999990000", "https://meuapoio.com/convites/")+"/accept", "", nil, nil), http.StatusOK)
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "Pedro", "phone": "11977776666"}, &pending), http.StatusCreated)
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", otherToken, map[string]any{"name": "Lia", "phone": "11955554444"}, &foreign), http.StatusCreated)
+
+	plan := func(contactID string) map[string]any {
+		return map[string]any{"sections": []map[string]any{
+			{"type": "support_contacts", "items": []map[string]any{{"text": "Ligar para o João", "contact_id": contactID}}},
+			{"type": "warning_signs", "items": []map[string]any{{"text": "Não consigo dormir"}}},
+			{"type": "professionals", "items": []map[string]any{{"text": "Dra. Helena", "phone": "1133334444"}}},
+		}}
+	}
+
+	// Só contatos do próprio usuário podem ser citados
+	var problem struct {
+		Errors []struct {
+			Field string `json:"field"`
+			Rule  string `json:"rule"`
+		} `json:"errors"`
+	}
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/safety-plan", token, plan(foreign.ID), &problem), http.StatusBadRequest)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "sections[0].items[0].contact_id" || problem.Errors[0].Rule != "contact" {
+		t.Fatalf("erro inesperado: %+v", problem)
+	}
+	duplicated := map[string]any{"sections": []map[string]any{{"type": "warning_signs", "items": []any{}}, {"type": "warning_signs", "items": []any{}}}}
+	s.expectStatus(s.do(http.MethodPut, "/api/v1/safety-plan", token, duplicated, nil), http.StatusBadRequest)
+
+	var saved models.SafetyPlan
+	w := s.do(http.MethodPut, "/api/v1/safety-plan", token, plan(contact.ID), &saved)
+	s.expectStatus(w, http.StatusCreated)
+	if len(saved.Sections) != len(models.SafetySections) || saved.Sections[0].Type != models.SafetySectionWarningSigns {
+		t.Fatalf("seções deveriam vir todas, na ordem do plano: %+v", saved.Sections)
+	}
+	support := saved.Sections[3].Items[0]
+	if support.ContactName == nil || *support.ContactName != "João" || support.ContactPhone == nil {
+		t.Fatalf("contato citado não foi completado: %+v", support)
+	}
+	etag := w.Header().Get("ETag")
+
+	// A nova versão exige o ETag atual e entra no histórico
+	send := func(method, path, ifMatch string, body any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+	edited := plan(contact.ID)
+	edited["sections"] = append(edited["sections"].([]map[string]any), map[string]any{
+		"type": "reasons_for_living", "items": []map[string]any{{"text": "Minha filha"}},
+	})
+	s.expectStatus(send(http.MethodPut, "/api/v1/safety-plan", etag, edited), http.StatusOK)
+	s.expectStatus(send(http.MethodPut, "/api/v1/safety-plan", etag, edited), http.StatusPreconditionFailed)
+
+	var revisions struct {
+		Revisions []models.SafetyPlanRevision `json:"revisions"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/safety-plan/revisions", token, nil, &revisions), http.StatusOK)
+	if len(revisions.Revisions) != 2 || revisions.Revisions[0].Version != 2 {
+		t.Fatalf("histórico inesperado: %+v", revisions.Revisions)
+	}
+	var first models.SafetyPlan
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/safety-plan/revisions/1", token, nil, &first), http.StatusOK)
+	if len(first.Sections[6].Items) != 0 {
+		t.Fatalf("versão 1 não deveria ter motivos para viver: %+v", first.Sections[6])
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/safety-plan/revisions/9", token, nil, nil), http.StatusNotFound)
+
+	w = s.do(http.MethodGet, "/api/v1/safety-plan/pdf", token, nil, nil)
+	s.expectStatus(w, http.StatusOK)
+	if w.Header().Get("Content-Type") != "application/pdf" || !strings.HasPrefix(w.Body.String(), "%PDF-") {
+		t.Fatalf("PDF inválido: %s %q", w.Header().Get("Content-Type"), w.Body.String()[:min(20, w.Body.Len())])
+	}
+
+	// Compartilhamento só com quem aceitou ser contato
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/safety-plan/shares", token, map[string]any{"contact_id": pending.ID}, nil), http.StatusConflict)
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/safety-plan/shares", token, map[string]any{"contact_id": foreign.ID}, nil), http.StatusNotFound)
+
+	var share models.SafetyPlanShare
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/safety-plan/shares", token, map[string]any{"contact_id": contact.ID}, &share), http.StatusCreated)
+	if share.ContactName != "João" || time.Until(share.ExpiresAt) < 71*time.Hour {
+		t.Fatalf("link inesperado: %+v", share)
+	}
+	link := s.linkToken("+5511999990000", "https://meuapoio.com/planos/")
+
+	var shared models.SharedSafetyPlan
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/shared-plans/"+link, "", nil, &shared), http.StatusOK)
+	if shared.OwnerName != "maria" || shared.Plan == nil || shared.Plan.Version != 2 {
+		t.Fatalf("plano compartilhado inesperado: %+v", shared)
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/shared-plans/"+link+"/pdf", "", nil, nil), http.StatusOK)
+
+	var shares struct {
+		Shares []models.SafetyPlanShare `json:"shares"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/safety-plan/shares", token, nil, &shares), http.StatusOK)
+	if len(shares.Shares) != 1 || shares.Shares[0].LastViewedAt == nil {
+		t.Fatalf("visualização não registrada: %+v", shares.Shares)
+	}
+
+	// Outro usuário não revoga o link; a revogação vale na hora
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/safety-plan/shares/"+share.ID, otherToken, nil, nil), http.StatusNotFound)
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/safety-plan/shares/"+share.ID, token, nil, nil), http.StatusOK)
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/shared-plans/"+link, "", nil, nil), http.StatusNotFound)
+
+	// Apagar o plano leva junto o histórico
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/safety-plan", token, nil, nil), http.StatusOK)
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/safety-plan/revisions/1", token, nil, nil), http.StatusNotFound)
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/safety-plan/shares", token, map[string]any{"contact_id": contact.ID}, nil), http.StatusNotFound)
+}
+
+func TestContactImportExport(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	var existing models.EmergencyContact
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/contacts", token, map[string]any{"name": "João", "phone": "11999990000"}, &existing), http.StatusCreated)
+
+	upload := func(path, contentType, body string, out any) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if out != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+				t.Fatalf("resposta não é JSON: %v (%s)", err, w.Body.String())
+			}
+		}
+		return w
+	}
+
+	// Planilha em português: separador ";" e nomes de coluna traduzidos
+	file := "nome;telefone;e-mail;parentesco\n" +
+		"Ana Souza;(11) 98888-7777;ana@example.com;irmã\n" +
+		"João de novo;+55 11 99999-0000;;\n" +
+		";123;não é email;\n" +
+		"Ana repetida;11 98888 7777;;\n" +
+		"Pedro;21 97777-6666;;amigo\n"
+
+	var report models.ContactImportResult
+	s.expectStatus(upload("/api/v1/contacts/import?dry_run=true", "text/csv", file, &report), http.StatusOK)
+	if !report.DryRun || report.Total != 5 || report.Valid != 2 || report.Duplicates != 2 || report.Invalid != 1 || report.Created != 0 {
+		t.Fatalf("relatório da simulação inesperado: %+v", report)
+	}
+	rows := report.Rows
+	if rows[0].Status != models.ImportRowValid || rows[0].Phone != "+5511988887777" || rows[0].Line != 2 {
+		t.Errorf("linha 1: %+v", rows[0])
+	}
+	if rows[1].Status != models.ImportRowDuplicate || rows[1].DuplicateOf == nil || *rows[1].DuplicateOf != existing.ID {
+		t.Errorf("linha 2 deveria duplicar o contato existente: %+v", rows[1])
+	}
+	fields := map[string]bool{}
+	for _, fe := range rows[2].Errors {
+		fields[fe.Field+":"+fe.Rule] = true
+	}
+	if rows[2].Status != models.ImportRowInvalid || !fields["name:required"] || !fields["email:email"] || !fields["phone:phone"] {
+		t.Errorf("linha 3 deveria ter erros de name, email e phone: %+v", rows[2])
+	}
+	if rows[3].Status != models.ImportRowDuplicate || rows[3].DuplicateOfRow == nil || *rows[3].DuplicateOfRow != 1 {
+		t.Errorf("linha 4 deveria duplicar a linha 1: %+v", rows[3])
+	}
+
+	var contacts struct {
+		Items []models.EmergencyContact `json:"items"`
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts", token, nil, &contacts), http.StatusOK)
+	if len(contacts.Items) != 1 {
+		t.Fatalf("a simulação não deveria criar contatos: %+v", contacts.Items)
+	}
+
+	s.expectStatus(upload("/api/v1/contacts/import", "text/csv", file, &report), http.StatusCreated)
+	if report.DryRun || report.Created != 2 || report.Valid != 0 || report.Rows[0].Status != models.ImportRowCreated || report.Rows[0].ContactID == nil {
+		t.Fatalf("relatório da importação inesperado: %+v", report)
+	}
+	if !strings.Contains(s.sms.last("+5521977776666"), "https://meuapoio.com/convites/") {
+		t.Error("contato importado deveria receber o convite")
+	}
+
+	// Importar de novo não duplica ninguém
+	s.expectStatus(upload("/api/v1/contacts/import", "text/csv", file, &report), http.StatusOK)
+	if report.Created != 0 || report.Duplicates != 4 {
+		t.Fatalf("reimportação deveria só apontar duplicados: %+v", report)
+	}
+
+	vcf := "BEGIN:VCARD\r\nVERSION:3.0\r\nN:Lima;Carla;;;\r\nTEL;TYPE=HOME:1133334444\r\nTEL;TYPE=CELL:11955554444\r\nEND:VCARD\r\n" +
+		"BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Bruno\r\nTEL;VALUE=uri:tel:+55-31-98765-4321\r\nEMAIL:bruno@example.com\r\nEND:VCARD\r\n"
+	s.expectStatus(upload("/api/v1/contacts/import", "text/vcard; charset=utf-8", vcf, &report), http.StatusCreated)
+	if report.Created != 2 || report.Rows[0].Name != "Carla Lima" || report.Rows[0].Phone != "+5511955554444" || report.Rows[1].Line != 7 {
+		t.Fatalf("importação do vCard inesperada: %+v", report)
+	}
+
+	// Formato, arquivo e tamanho
+	var problem struct {
+		Code     string   `json:"code"`
+		Line     int      `json:"line"`
+		Accepted []string `json:"accepted"`
+	}
+	s.expectStatus(upload("/api/v1/contacts/import", "application/json", "{}", &problem), http.StatusUnsupportedMediaType)
+	if problem.Code != "UNSUPPORTED_MEDIA_TYPE" || len(problem.Accepted) == 0 {
+		t.Errorf("problema inesperado: %+v", problem)
+	}
+	s.expectStatus(upload("/api/v1/contacts/import", "text/vcard", "BEGIN:VCARD\nVERSION:3.0\nFN Ana\nEND:VCARD\n", &problem), http.StatusBadRequest)
+	if problem.Code != "CONTACT_FILE_INVALID" || problem.Line != 3 {
+		t.Errorf("problema inesperado: %+v", problem)
+	}
+	s.expectStatus(upload("/api/v1/contacts/import", "text/csv", "email\nana@example.com\n", nil), http.StatusBadRequest)
+	s.expectStatus(upload("/api/v1/contacts/import", "text/csv", "name,phone\n"+strings.Repeat("Ana,11988887777\n", 101), nil), http.StatusBadRequest)
+	s.expectStatus(upload("/api/v1/contacts/import", "text/csv", "name,phone\n"+strings.Repeat("x", 1<<20), nil), http.StatusBadRequest)
+
+	// Exportação: o CSV exportado volta inteiro como duplicado
+	w := s.do(http.MethodGet, "/api/v1/contacts/export?format=csv", token, nil, nil)
+	s.expectStatus(w, http.StatusOK)
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") || !strings.Contains(w.Header().Get("Content-Disposition"), "meuapoio-contatos.csv") {
+		t.Errorf("headers inesperados: %v", w.Header())
+	}
+	exported := w.Body.String()
+	if !strings.HasPrefix(exported, "name,phone,email,relationship,is_primary,status\n") || !strings.Contains(exported, "Ana Souza,'+5511988887777,ana@example.com,sibling,false,pending") {
+		t.Errorf("CSV inesperado: %s", exported)
+	}
+	s.expectStatus(upload("/api/v1/contacts/import?dry_run=true", "text/csv", exported, &report), http.StatusOK)
+	if report.Total != 5 || report.Duplicates != 5 {
+		t.Errorf("CSV exportado deveria voltar como duplicado: %+v", report)
+	}
+
+	w = s.do(http.MethodGet, "/api/v1/contacts/export", token, nil, nil)
+	s.expectStatus(w, http.StatusOK)
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/vcard") || strings.Count(w.Body.String(), "BEGIN:VCARD") != 5 ||
+		!strings.Contains(w.Body.String(), "UID:urn:uuid:"+existing.ID) || !strings.Contains(w.Body.String(), "TEL;TYPE=cell;VALUE=uri:tel:+5511999990000") {
+		t.Errorf("vCard inesperado: %s", w.Body.String())
+	}
+	s.expectStatus(upload("/api/v1/contacts/import?dry_run=true", "text/vcard", w.Body.String(), &report), http.StatusOK)
+	if report.Duplicates != 5 {
+		t.Errorf("vCard exportado deveria voltar como duplicado: %+v", report)
+	}
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/contacts/export?format=pdf", token, nil, nil), http.StatusBadRequest)
+}
+
+// testImage gera uma foto width×height com a metade esquerda vermelha e a
+// direita azul, em JPEG ou PNG
+func testImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= width/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation insere depois do SOI um segmento EXIF com a tag Orientation
+// e uma coordenada de GPS, que não pode sobreviver ao processamento
+func withOrientation(data []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // cabeçalho big-endian, IFD0 no byte 8
+		0, 2, // duas entradas
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, // Orientation (SHORT)
+		0x88, 0x25, 0, 4, 0, 0, 0, 1, 0, 0, 0, 0, // ponteiro para o IFD de GPS
+		0, 0, 0, 0,
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := append([]byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}, payload...)
+	return append(append([]byte{0xFF, 0xD8}, segment...), data[2:]...)
+}
+
+// uploadImage envia a foto no campo image de um formulário multipart
+func (s *testServer) uploadImage(token string, data []byte, ifMatch string, out any) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("image", "foto.jpg")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/profile/image", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("resposta não é JSON: %v (%s)", err, w.Body.String())
+		}
+	}
+	return w
+}
+
+// fetchImage baixa a foto pelo link assinado e a decodifica
+func (s *testServer) fetchImage(link string) image.Image {
+	s.t.Helper()
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, link, nil))
+	s.expectStatus(w, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); ct != "image/jpeg" {
+		s.t.Fatalf("Content-Type = %q", ct)
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("Exif")) {
+		s.t.Error("foto servida mantém o EXIF do original")
+	}
+	img, err := jpeg.Decode(w.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return img
+}
+
+func TestProfileImage(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("maria")
+
+	var user models.User
+	s.expectStatus(s.do(http.MethodGet, "/api/v1/users/profile", token, nil, &user), http.StatusOK)
+	if user.ProfileImageURL != nil || user.ProfileImageThumbnailURL != nil {
+		t.Fatalf("perfil novo com foto: %+v", user)
+	}
+
+	// Foto deitada (200×100) com EXIF pedindo giro de 90° no sentido horário:
+	// depois do recorte e do giro, o vermelho fica em cima e o azul embaixo
+	w := s.uploadImage(token, withOrientation(testImage(t, "jpeg", 200, 100), 6), "", &user)
+	s.expectStatus(w, http.StatusOK)
+	if user.ProfileImageURL == nil || user.ProfileImageThumbnailURL == nil {
+		t.Fatalf("perfil sem os links da foto: %+v", user)
+	}
+	etag := w.Header().Get("ETag")
+	if !strings.Contains(etag, "-") {
+		t.Errorf("ETag do perfil com foto deveria incluir o vencimento dos links: %s", etag)
+	}
+
+	large := s.fetchImage(*user.ProfileImageURL)
+	if b := large.Bounds(); b.Dx() != 512 || b.Dy() != 512 {
+		t.Errorf("foto grande com %dx%d", b.Dx(), b.Dy())
+	}
+	thumbnail := s.fetchImage(*user.ProfileImageThumbnailURL)
+	if b := thumbnail.Bounds(); b.Dx() != 128 || b.Dy() != 128 {
+		t.Errorf("miniatura com %dx%d", b.Dx(), b.Dy())
+	}
+	top, _, _, _ := thumbnail.At(64, 16).RGBA()
+	_, _, bottom, _ := thumbnail.At(64, 112).RGBA()
+	if top>>8 < 200 || bottom>>8 < 200 {
+		t.Errorf("orientação do EXIF não aplicada: topo R=%d, base B=%d", top>>8, bottom>>8)
+	}
+
+	// Link adulterado ou de outra chave
+	tampered := strings.Replace(*user.ProfileImageURL, "signature=", "signature=0", 1)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tampered, nil))
+	s.expectStatus(w, http.StatusForbidden)
+
+	// O ETag com o vencimento dos links vale na revalidação e no If-Match
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.expectStatus(w, http.StatusNotModified)
+
+	var login models.LoginResponse
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": "maria@meuapoio.com", "password": "senha123"}, &login), http.StatusOK)
+	if login.User.ProfileImageURL == nil {
+		t.Error("login sem o link da foto")
+	}
+
+	// Trocar a foto apaga a anterior
+	previous := *user.ProfileImageURL
+	s.expectStatus(s.uploadImage(token, testImage(t, "png", 300, 300), etag, &user), http.StatusOK)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, previous, nil))
+	s.expectStatus(w, http.StatusNotFound)
+	s.fetchImage(*user.ProfileImageURL)
+	s.expectStatus(s.uploadImage(token, testImage(t, "png", 300, 300), etag, nil), http.StatusPreconditionFailed)
+
+	// Conteúdo que não é foto, foto pequena demais, envio grande demais
+	var problem map[string]any
+	s.expectStatus(s.uploadImage(token, []byte("GIF89a não é aceito"), "", &problem), http.StatusUnsupportedMediaType)
+	if problem["code"] != "UNSUPPORTED_MEDIA_TYPE" || problem["accepted"] == nil {
+		t.Errorf("problema inesperado: %v", problem)
+	}
+	s.expectStatus(s.uploadImage(token, testImage(t, "png", 32, 32), "", &problem), http.StatusBadRequest)
+	if problem["code"] != "IMAGE_INVALID" {
+		t.Errorf("foto de 32x32 aceita: %v", problem)
+	}
+	s.expectStatus(s.uploadImage(token, testImage(t, "jpeg", 200, 100)[:200], "", &problem), http.StatusBadRequest)
+	s.expectStatus(s.uploadImage(token, make([]byte, testImageMaxSize+1), "", &problem), http.StatusBadRequest)
+	if problem["code"] != "IMAGE_INVALID" || problem["max_bytes"] != float64(testImageMaxSize) {
+		t.Errorf("envio acima do limite: %v", problem)
+	}
+	s.expectStatus(s.do(http.MethodPost, "/api/v1/users/profile/image", token, map[string]string{"image": "x"}, nil), http.StatusUnsupportedMediaType)
+
+	// Remover a foto apaga os arquivos; remover de novo não é erro
+	current := *user.ProfileImageThumbnailURL
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/users/profile/image", token, nil, &user), http.StatusOK)
+	if user.ProfileImageURL != nil || user.ProfileImageThumbnailURL != nil {
+		t.Errorf("foto continua no perfil: %+v", user)
+	}
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, current, nil))
+	s.expectStatus(w, http.StatusNotFound)
+	s.expectStatus(s.do(http.MethodDelete, "/api/v1/users/profile/image", token, nil, nil), http.StatusOK)
+}
@@ -0,0 +1,122 @@
+// Package safetyplan gera a versão para impressão do plano de segurança, no
+// idioma de quem a pede.
+package safetyplan
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/i18n"
+	"github.com/meuapoio/shared/pdf"
+)
+
+// texts são os textos fixos do documento; as seções usam o tipo como chave
+var texts = i18n.Catalog{
+	// Título: nome do dono do plano
+	"title": {
+		i18n.PortugueseBR: "Plano de segurança de %s",
+		i18n.English:      "Safety plan for %s",
+		i18n.Spanish:      "Plan de seguridad de %s",
+	},
+	// Versão e data da última alteração
+	"updated": {
+		i18n.PortugueseBR: "Versão %d, atualizada em %s",
+		i18n.English:      "Version %d, updated on %s",
+		i18n.Spanish:      "Versión %d, actualizada el %s",
+	},
+	"date": {
+		i18n.PortugueseBR: "02/01/2006",
+		i18n.English:      "January 2, 2006",
+		i18n.Spanish:      "02/01/2006",
+	},
+	"empty": {
+		i18n.PortugueseBR: "Nada preenchido ainda.",
+		i18n.English:      "Nothing filled in yet.",
+		i18n.Spanish:      "Nada completado todavía.",
+	},
+	"emergency": {
+		i18n.PortugueseBR: "Em uma emergência, ligue 192 (SAMU) ou 188 (CVV, 24 horas, gratuito).",
+		i18n.English:      "In an emergency in Brazil, call 192 (SAMU) or 188 (CVV, 24 hours, free).",
+		i18n.Spanish:      "En una emergencia en Brasil, llama al 192 (SAMU) o al 188 (CVV, 24 horas, gratuito).",
+	},
+
+	models.SafetySectionWarningSigns: {
+		i18n.PortugueseBR: "1. Sinais de alerta",
+		i18n.English:      "1. Warning signs",
+		i18n.Spanish:      "1. Señales de alerta",
+	},
+	models.SafetySectionCopingStrategies: {
+		i18n.PortugueseBR: "2. O que posso fazer sozinho para me acalmar",
+		i18n.English:      "2. Things I can do on my own to cope",
+		i18n.Spanish:      "2. Lo que puedo hacer por mi cuenta para calmarme",
+	},
+	models.SafetySectionDistractions: {
+		i18n.PortugueseBR: "3. Pessoas e lugares que me ajudam a distrair",
+		i18n.English:      "3. People and places that help me take my mind off things",
+		i18n.Spanish:      "3. Personas y lugares que me ayudan a distraerme",
+	},
+	models.SafetySectionSupportContacts: {
+		i18n.PortugueseBR: "4. Pessoas a quem posso pedir ajuda",
+		i18n.English:      "4. People I can ask for help",
+		i18n.Spanish:      "4. Personas a las que puedo pedir ayuda",
+	},
+	models.SafetySectionProfessionals: {
+		i18n.PortugueseBR: "5. Profissionais e serviços que posso procurar",
+		i18n.English:      "5. Professionals and services I can contact",
+		i18n.Spanish:      "5. Profesionales y servicios a los que puedo acudir",
+	},
+	models.SafetySectionSafeEnvironment: {
+		i18n.PortugueseBR: "6. Como deixar o ambiente mais seguro",
+		i18n.English:      "6. Making my environment safe",
+		i18n.Spanish:      "6. Cómo hacer mi entorno más seguro",
+	},
+	models.SafetySectionReasonsForLiving: {
+		i18n.PortugueseBR: "7. Meus motivos para viver",
+		i18n.English:      "7. My reasons for living",
+		i18n.Spanish:      "7. Mis razones para vivir",
+	},
+}
+
+// Render grava em w o PDF do plano, com todas as seções na ordem de
+// models.SafetySections, inclusive as vazias, para que o plano impresso possa
+// ser completado à mão. Os contatos citados devem vir já preenchidos.
+func Render(w io.Writer, plan *models.SafetyPlan, ownerName, lang string) error {
+	doc := pdf.New()
+	doc.Title(fmt.Sprintf(texts.Get(lang, "title"), ownerName))
+	doc.Note(fmt.Sprintf(texts.Get(lang, "updated"), plan.Version, plan.UpdatedAt.Format(texts.Get(lang, "date"))))
+
+	items := make(map[string][]models.SafetyPlanItem, len(plan.Sections))
+	for _, section := range plan.Sections {
+		items[section.Type] = section.Items
+	}
+	for _, sectionType := range models.SafetySections {
+		doc.Heading(texts.Get(lang, sectionType))
+		if len(items[sectionType]) == 0 {
+			doc.Note(texts.Get(lang, "empty"))
+		}
+		for _, item := range items[sectionType] {
+			doc.Bullet(itemText(item))
+		}
+	}
+
+	doc.Heading(texts.Get(lang, "emergency"))
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// itemText junta ao texto o nome e o telefone do contato citado ou o telefone
+// informado no item
+func itemText(item models.SafetyPlanItem) string {
+	text := item.Text
+	switch {
+	case item.ContactName != nil && item.ContactPhone != nil:
+		text += fmt.Sprintf(" - %s, %s", *item.ContactName, *item.ContactPhone)
+	case item.ContactName != nil:
+		text += " - " + *item.ContactName
+	case item.Phone != nil:
+		text += " - " + *item.Phone
+	}
+	return text
+}
@@ -0,0 +1,76 @@
+package sos
+
+import (
+	"context"
+	"errors"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/shared/email"
+	"github.com/meuapoio/shared/sms"
+)
+
+// Nomes dos canais, como em SOS_CHANNELS e AlertRecipient.Channels
+const (
+	ChannelSMS      = "sms"
+	ChannelWhatsApp = "whatsapp"
+	ChannelEmail    = "email"
+)
+
+// ErrNoAddress indica que o contato não tem endereço para o canal (por exemplo,
+// email não cadastrado); o aviso segue pelos demais canais
+var ErrNoAddress = errors.New("contato sem endereço para o canal")
+
+// Channel entrega o aviso a um contato por um meio. subject só é usado pelos
+// canais que têm assunto.
+type Channel interface {
+	Name() string
+	Deliver(ctx context.Context, recipient *models.AlertRecipient, subject, body string) error
+}
+
+// phoneChannel envia para o telefone do contato (SMS ou WhatsApp)
+type phoneChannel struct {
+	name   string
+	sender sms.Sender
+}
+
+// NewSMSChannel envia os avisos por SMS
+func NewSMSChannel(sender sms.Sender) Channel {
+	return &phoneChannel{name: ChannelSMS, sender: sender}
+}
+
+// NewWhatsAppChannel envia os avisos por WhatsApp; sender é o adaptador do
+// provedor de WhatsApp, com a mesma interface do SMS
+func NewWhatsAppChannel(sender sms.Sender) Channel {
+	return &phoneChannel{name: ChannelWhatsApp, sender: sender}
+}
+
+func (c *phoneChannel) Name() string {
+	return c.name
+}
+
+func (c *phoneChannel) Deliver(ctx context.Context, recipient *models.AlertRecipient, subject, body string) error {
+	if recipient.Phone == "" {
+		return ErrNoAddress
+	}
+	return c.sender.Send(ctx, recipient.Phone, body)
+}
+
+type emailChannel struct {
+	sender email.Sender
+}
+
+// NewEmailChannel envia os avisos para o email do contato, quando cadastrado
+func NewEmailChannel(sender email.Sender) Channel {
+	return &emailChannel{sender: sender}
+}
+
+func (c *emailChannel) Name() string {
+	return ChannelEmail
+}
+
+func (c *emailChannel) Deliver(ctx context.Context, recipient *models.AlertRecipient, subject, body string) error {
+	if recipient.Email == nil || *recipient.Email == "" {
+		return ErrNoAddress
+	}
+	return c.sender.Send(ctx, *recipient.Email, subject, body)
+}
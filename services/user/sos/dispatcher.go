@@ -0,0 +1,205 @@
+// Package sos envia os alertas de SOS aos contatos de emergência e escalona para
+// os demais contatos os alertas que o contato principal não confirmou a tempo.
+package sos
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+	"github.com/meuapoio/services/user/repository"
+)
+
+// AckLinkTTL é a validade do link de confirmação, contada do acionamento
+const AckLinkTTL = 24 * time.Hour
+
+// HashToken retorna o hash gravado no banco; o token em si só existe no aviso
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// Dispatcher envia os avisos de cada nível do alerta e, em background, escalona
+// os alertas vencidos.
+type Dispatcher struct {
+	alertRepo repository.AlertStore
+	channels  []Channel
+	ackURL    string
+	interval  time.Duration
+	done      chan struct{}
+	// ctx é cancelado no Stop, interrompendo as consultas em andamento
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewDispatcher cria o Dispatcher. ackURL é a página que recebe o token de
+// confirmação como último segmento do caminho.
+func NewDispatcher(alertRepo repository.AlertStore, channels []Channel, ackURL string, interval time.Duration) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		alertRepo: alertRepo,
+		channels:  channels,
+		ackURL:    ackURL,
+		interval:  interval,
+		done:      make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start inicia o escalonamento em background
+func (d *Dispatcher) Start() {
+	go d.run()
+}
+
+// Stop encerra o escalonamento em background
+func (d *Dispatcher) Stop() {
+	d.cancel()
+	close(d.done)
+}
+
+func (d *Dispatcher) run() {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	d.RunOnce(d.ctx)
+	for {
+		select {
+		case <-ticker.C:
+			d.RunOnce(d.ctx)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// RunOnce escalona os alertas cujo prazo de confirmação terminou e retorna
+// quantos foram escalonados
+func (d *Dispatcher) RunOnce(ctx context.Context) int {
+	now := time.Now()
+	ids, err := d.alertRepo.ListDueEscalations(ctx, now)
+	if err != nil {
+		log.Printf("Erro ao buscar alertas para escalonar: %v", err)
+		return 0
+	}
+
+	escalated := 0
+	for _, id := range ids {
+		// Escalate só vale uma vez: outra réplica ou uma confirmação pode ter
+		// chegado entre a listagem e a atualização
+		ok, err := d.alertRepo.Escalate(ctx, id, now)
+		if err != nil {
+			log.Printf("Erro ao escalonar alerta %s: %v", id, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		escalated++
+		if _, err := d.Notify(ctx, id, models.AlertLevelOthers); err != nil {
+			log.Printf("Erro ao avisar contatos do alerta %s: %v", id, err)
+		}
+	}
+	return escalated
+}
+
+// Notify avisa os contatos do alerta até o nível informado que ainda não foram
+// avisados e retorna quantos receberam o aviso por pelo menos um canal. Cada
+// contato recebe um link de confirmação próprio.
+func (d *Dispatcher) Notify(ctx context.Context, alertID string, level int) (int, error) {
+	alert, err := d.alertRepo.GetForDelivery(ctx, alertID)
+	if err != nil {
+		return 0, err
+	}
+	recipients, err := d.alertRepo.ClaimRecipients(ctx, alertID, level)
+	if err != nil {
+		return 0, err
+	}
+
+	subject := fmt.Sprintf(messages.Get(alert.Language, "sos.subject"), alert.UserName)
+	place := ""
+	if alert.Location != nil {
+		place = fmt.Sprintf(messages.Get(alert.Language, "sos.location"), mapsLink(alert.Location))
+	}
+
+	notified := 0
+	var errs []error
+	for _, recipient := range recipients {
+		token, hash, err := newToken()
+		if err != nil {
+			return notified, err
+		}
+		link := strings.TrimSuffix(d.ackURL, "/") + "/" + token
+		body := fmt.Sprintf(messages.Get(alert.Language, "sos.alert"), alert.UserName, place, link)
+
+		channels := d.deliver(ctx, recipient, subject, body)
+		status := models.RecipientStatusNotified
+		if len(channels) == 0 {
+			status = models.RecipientStatusFailed
+		} else {
+			notified++
+		}
+		if err := d.alertRepo.RecordDelivery(ctx, recipient.ID, hash, status, channels, time.Now()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return notified, errors.Join(errs...)
+}
+
+// NotifyCancelled avisa do cancelamento os contatos que já receberam o alerta
+func (d *Dispatcher) NotifyCancelled(ctx context.Context, alertID string) error {
+	alert, err := d.alertRepo.GetForDelivery(ctx, alertID)
+	if err != nil {
+		return err
+	}
+	recipients, err := d.alertRepo.ListNotified(ctx, alertID)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf(messages.Get(alert.Language, "sos.subject"), alert.UserName)
+	body := fmt.Sprintf(messages.Get(alert.Language, "sos.cancelled"), alert.UserName)
+	for _, recipient := range recipients {
+		d.deliver(ctx, recipient, subject, body)
+	}
+	return nil
+}
+
+// deliver envia por todos os canais configurados e retorna os que funcionaram.
+// Falhas de um canal não impedem os demais: num pedido de ajuda, receber em
+// dobro é melhor que não receber.
+func (d *Dispatcher) deliver(ctx context.Context, recipient *models.AlertRecipient, subject, body string) []string {
+	delivered := []string{}
+	for _, channel := range d.channels {
+		err := channel.Deliver(ctx, recipient, subject, body)
+		switch {
+		case err == nil:
+			delivered = append(delivered, channel.Name())
+		case errors.Is(err, ErrNoAddress):
+		default:
+			log.Printf("Erro ao enviar alerta por %s para o aviso %s: %v", channel.Name(), recipient.ID, err)
+		}
+	}
+	return delivered
+}
+
+func mapsLink(location *models.Location) string {
+	return fmt.Sprintf("https://maps.google.com/?q=%.6f,%.6f", location.Latitude, location.Longitude)
+}
@@ -0,0 +1,33 @@
+package sos
+
+import (
+	"github.com/meuapoio/shared/i18n"
+)
+
+// messages são os textos enviados aos contatos, no idioma de quem acionou o alerta
+var messages = i18n.Catalog{
+	// Assunto do email: nome de quem acionou
+	"sos.subject": {
+		i18n.PortugueseBR: "Alerta de SOS de %s",
+		i18n.English:      "SOS alert from %s",
+		i18n.Spanish:      "Alerta de SOS de %s",
+	},
+	// Aviso: nome de quem acionou, trecho da localização (pode ser vazio) e link de confirmação
+	"sos.alert": {
+		i18n.PortugueseBR: "ALERTA MeuApoio: %s pediu ajuda agora e indicou você como contato de apoio.%s Confirme que recebeu: %s",
+		i18n.English:      "MeuApoio ALERT: %s just asked for help and listed you as a support contact.%s Confirm you got this: %s",
+		i18n.Spanish:      "ALERTA MeuApoio: %s acaba de pedir ayuda y te indicó como contacto de apoyo.%s Confirma que lo recibiste: %s",
+	},
+	// Trecho com o link do mapa
+	"sos.location": {
+		i18n.PortugueseBR: " Localização: %s.",
+		i18n.English:      " Location: %s.",
+		i18n.Spanish:      " Ubicación: %s.",
+	},
+	// Aviso de cancelamento: nome de quem acionou
+	"sos.cancelled": {
+		i18n.PortugueseBR: "MeuApoio: %s cancelou o alerta de SOS.",
+		i18n.English:      "MeuApoio: %s cancelled the SOS alert.",
+		i18n.Spanish:      "MeuApoio: %s canceló la alerta de SOS.",
+	},
+}
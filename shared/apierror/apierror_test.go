@@ -0,0 +1,101 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCatalogueIsDocumented(t *testing.T) {
+	doc, err := os.ReadFile("../../docs/errors.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range Codes() {
+		if !strings.Contains(string(doc), "`"+string(code)+"`") {
+			t.Errorf("código %s não está em docs/errors.md", code)
+		}
+	}
+}
+
+type request struct {
+	Name  string `json:"name" binding:"required,max=5"`
+	Items []struct {
+		Type string `json:"type" binding:"required"`
+	} `json:"items" binding:"dive"`
+}
+
+func bind(t *testing.T, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/recurso", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("request_id", "req-1")
+
+	var req request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		AbortBinding(c, err)
+	}
+
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("resposta não é JSON: %s", w.Body.String())
+	}
+	return w, resp
+}
+
+func TestAbortBindingUsesJSONFieldNames(t *testing.T) {
+	w, resp := bind(t, `{"name": "muito longo", "items": [{}]}`)
+
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("status = %d, content-type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if resp["code"] != string(CodeValidationFailed) || resp["request_id"] != "req-1" || resp["instance"] != "/recurso" {
+		t.Fatalf("resposta inesperada: %v", resp)
+	}
+
+	fields := map[string]string{}
+	for _, item := range resp["errors"].([]any) {
+		fe := item.(map[string]any)
+		fields[fe["field"].(string)] = fe["rule"].(string)
+	}
+	if fields["name"] != "max" || fields["items[0].type"] != "required" {
+		t.Fatalf("errors = %v", resp["errors"])
+	}
+}
+
+func TestAbortBindingMalformedBody(t *testing.T) {
+	_, resp := bind(t, `{"name": `)
+	if resp["code"] != string(CodeMalformedRequest) {
+		t.Fatalf("code = %v", resp["code"])
+	}
+
+	_, resp = bind(t, `{"name": 10}`)
+	if resp["code"] != string(CodeValidationFailed) {
+		t.Fatalf("code = %v", resp["code"])
+	}
+}
+
+func TestExtensionsAreInlined(t *testing.T) {
+	body, err := json.Marshal(New(CodeConsentRequired).With("pending", []string{"terms_of_use"}).With("code", "outro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp map[string]any
+	json.Unmarshal(body, &resp)
+	if resp["code"] != string(CodeConsentRequired) || resp["status"] != float64(http.StatusForbidden) {
+		t.Fatalf("campos padrão alterados: %s", body)
+	}
+	if pending, ok := resp["pending"].([]any); !ok || pending[0] != "terms_of_use" {
+		t.Fatalf("extensão ausente: %s", body)
+	}
+}
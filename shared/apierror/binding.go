@@ -0,0 +1,101 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/meuapoio/shared/i18n"
+)
+
+func init() {
+	// Os erros de validação passam a usar os nomes JSON dos campos em vez dos
+	// nomes das structs Go, com mensagens traduzidas
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+		if err := i18n.RegisterValidator(v); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// AbortBinding converte o erro de ShouldBindJSON/ShouldBindQuery em
+// VALIDATION_FAILED, com um item por campo rejeitado, ou MALFORMED_REQUEST quando
+// o corpo nem chega a ser um JSON válido para a estrutura esperada
+func AbortBinding(c *gin.Context, err error) {
+	AbortWith(c, FromBinding(err))
+}
+
+// FromBinding monta o Problem correspondente a um erro de binding
+func FromBinding(err error) *Problem {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		p := New(CodeValidationFailed)
+		for _, fe := range validationErrs {
+			fe := fe
+			p.Errors = append(p.Errors, FieldError{
+				Field:     fieldPath(fe),
+				Rule:      fe.Tag(),
+				translate: func(lang string) string { return i18n.ValidationMessage(lang, fe) },
+			})
+		}
+		p.localize(i18n.Default)
+		return p
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		kind := typeErr.Type.Kind().String()
+		return Field(typeErr.Field, "type", i18n.Text{
+			i18n.PortugueseBR: "deve ser do tipo " + kind,
+			i18n.English:      "must be of type " + kind,
+			i18n.Spanish:      "debe ser del tipo " + kind,
+		})
+	}
+
+	if errors.Is(err, io.EOF) {
+		return New(CodeMalformedRequest).WithDetail(i18n.Text{
+			i18n.PortugueseBR: "Corpo da requisição vazio",
+			i18n.English:      "Empty request body",
+			i18n.Spanish:      "Cuerpo de la solicitud vacío",
+		})
+	}
+	return New(CodeMalformedRequest)
+}
+
+// Field cria um VALIDATION_FAILED para um único campo, para validações feitas
+// fora das tags binding (como parâmetros de query)
+func Field(field, rule string, message i18n.Text) *Problem {
+	return New(CodeValidationFailed).WithField(field, rule, message)
+}
+
+// WithField acrescenta um campo rejeitado, com a mensagem traduzida ao responder
+func (p *Problem) WithField(field, rule string, message i18n.Text) *Problem {
+	p.Errors = append(p.Errors, FieldError{Field: field, Rule: rule, Message: message.In(i18n.Default), translate: message.In})
+	return p
+}
+
+// fieldPath remove o nome da struct raiz do namespace ("CreateUserRequest.consents[0].type")
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fe.Field()
+}
@@ -0,0 +1,330 @@
+package apierror
+
+import (
+	"net/http"
+
+	"github.com/meuapoio/shared/i18n"
+)
+
+// Code identifica o tipo do erro de forma estável. Clientes devem decidir o que
+// fazer pelo código, nunca pelo texto de title ou detail. A lista completa, com o
+// comportamento esperado do cliente, está em docs/errors.md.
+type Code string
+
+const (
+	// Gerais
+	CodeInternal           Code = "INTERNAL_ERROR"
+	CodeMalformedRequest   Code = "MALFORMED_REQUEST"
+	CodeValidationFailed   Code = "VALIDATION_FAILED"
+	CodeRouteNotFound      Code = "ROUTE_NOT_FOUND"
+	CodeRateLimited        Code = "RATE_LIMITED"
+	CodeServiceUnavailable Code = "SERVICE_UNAVAILABLE"
+	CodeUnsupportedMedia   Code = "UNSUPPORTED_MEDIA_TYPE"
+
+	// Idempotência
+	CodeIdempotencyKeyInUse  Code = "IDEMPOTENCY_KEY_IN_USE"
+	CodeIdempotencyKeyReused Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeRequestTooLarge      Code = "REQUEST_TOO_LARGE"
+
+	// Requisições condicionais
+	CodePreconditionFailed Code = "PRECONDITION_FAILED"
+
+	// Autenticação
+	CodeAuthTokenMissing       Code = "AUTH_TOKEN_MISSING"
+	CodeAuthTokenMalformed     Code = "AUTH_TOKEN_MALFORMED"
+	CodeAuthTokenInvalid       Code = "AUTH_TOKEN_INVALID"
+	CodeAuthTokenExpired       Code = "AUTH_TOKEN_EXPIRED"
+	CodeAuthInvalidCredentials Code = "AUTH_INVALID_CREDENTIALS"
+	CodeAdminRequired          Code = "ADMIN_REQUIRED"
+	CodeAccountInactive        Code = "ACCOUNT_INACTIVE"
+
+	// Usuários
+	CodeUserNotFound    Code = "USER_NOT_FOUND"
+	CodeEmailInUse      Code = "EMAIL_IN_USE"
+	CodeUsernameInUse   Code = "USERNAME_IN_USE"
+	CodeContactNotFound Code = "CONTACT_NOT_FOUND"
+	CodeContactLimit    Code = "CONTACT_LIMIT_REACHED"
+
+	// Convites dos contatos de emergência
+	CodeContactNotPending  Code = "CONTACT_NOT_PENDING"
+	CodeInvitationNotFound Code = "INVITATION_NOT_FOUND"
+
+	// Importação de contatos
+	CodeContactFileInvalid Code = "CONTACT_FILE_INVALID"
+
+	// Fotos de perfil
+	CodeImageInvalid     Code = "IMAGE_INVALID"
+	CodeImageNotFound    Code = "IMAGE_NOT_FOUND"
+	CodeImageLinkInvalid Code = "IMAGE_LINK_INVALID"
+
+	// Alertas de SOS
+	CodeSOSNoContacts    Code = "SOS_NO_CONTACTS"
+	CodeSOSAlertNotFound Code = "SOS_ALERT_NOT_FOUND"
+	CodeSOSAlertClosed   Code = "SOS_ALERT_CLOSED"
+
+	// Recursos de crise
+	CodeResourceNotFound Code = "RESOURCE_NOT_FOUND"
+
+	// Plano de segurança
+	CodeSafetyPlanNotFound      Code = "SAFETY_PLAN_NOT_FOUND"
+	CodeSafetyPlanShareNotFound Code = "SAFETY_PLAN_SHARE_NOT_FOUND"
+	CodeContactNotAccepted      Code = "CONTACT_NOT_ACCEPTED"
+
+	// Consentimentos
+	CodeConsentRequired        Code = "CONSENT_REQUIRED"
+	CodeConsentVersionOutdated Code = "CONSENT_VERSION_OUTDATED"
+	CodeConsentNotFound        Code = "CONSENT_NOT_FOUND"
+
+	// Exportação de dados
+	CodeExportNotFound    Code = "EXPORT_NOT_FOUND"
+	CodeExportLinkInvalid Code = "EXPORT_LINK_INVALID"
+	CodeExportExpired     Code = "EXPORT_EXPIRED"
+)
+
+type definition struct {
+	status int
+	title  i18n.Text
+}
+
+// catalogue associa cada código ao status HTTP e ao título em cada idioma
+var catalogue = map[Code]definition{
+	CodeInternal: {http.StatusInternalServerError, i18n.Text{
+		i18n.PortugueseBR: "Erro interno do servidor",
+		i18n.English:      "Internal server error",
+		i18n.Spanish:      "Error interno del servidor",
+	}},
+	CodeMalformedRequest: {http.StatusBadRequest, i18n.Text{
+		i18n.PortugueseBR: "Corpo da requisição inválido",
+		i18n.English:      "Malformed request body",
+		i18n.Spanish:      "Cuerpo de la solicitud inválido",
+	}},
+	CodeValidationFailed: {http.StatusBadRequest, i18n.Text{
+		i18n.PortugueseBR: "Dados inválidos",
+		i18n.English:      "Invalid data",
+		i18n.Spanish:      "Datos inválidos",
+	}},
+	CodeRouteNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Rota não encontrada",
+		i18n.English:      "Route not found",
+		i18n.Spanish:      "Ruta no encontrada",
+	}},
+	CodeRateLimited: {http.StatusTooManyRequests, i18n.Text{
+		i18n.PortugueseBR: "Limite de requisições excedido",
+		i18n.English:      "Rate limit exceeded",
+		i18n.Spanish:      "Límite de solicitudes excedido",
+	}},
+	CodeServiceUnavailable: {http.StatusBadGateway, i18n.Text{
+		i18n.PortugueseBR: "Serviço temporariamente indisponível",
+		i18n.English:      "Service temporarily unavailable",
+		i18n.Spanish:      "Servicio temporalmente no disponible",
+	}},
+	CodeUnsupportedMedia: {http.StatusUnsupportedMediaType, i18n.Text{
+		i18n.PortugueseBR: "Formato do corpo não suportado",
+		i18n.English:      "Unsupported request body format",
+		i18n.Spanish:      "Formato del cuerpo no soportado",
+	}},
+
+	CodeIdempotencyKeyInUse: {http.StatusConflict, i18n.Text{
+		i18n.PortugueseBR: "Requisição com a mesma Idempotency-Key ainda em andamento",
+		i18n.English:      "A request with the same Idempotency-Key is still in progress",
+		i18n.Spanish:      "Una solicitud con la misma Idempotency-Key aún está en curso",
+	}},
+	CodeIdempotencyKeyReused: {http.StatusUnprocessableEntity, i18n.Text{
+		i18n.PortugueseBR: "Idempotency-Key já usada com outra requisição",
+		i18n.English:      "Idempotency-Key already used with a different request",
+		i18n.Spanish:      "Idempotency-Key ya usada con otra solicitud",
+	}},
+	CodeRequestTooLarge: {http.StatusRequestEntityTooLarge, i18n.Text{
+		i18n.PortugueseBR: "Corpo da requisição grande demais",
+		i18n.English:      "Request body too large",
+		i18n.Spanish:      "Cuerpo de la solicitud demasiado grande",
+	}},
+
+	CodePreconditionFailed: {http.StatusPreconditionFailed, i18n.Text{
+		i18n.PortugueseBR: "O recurso foi alterado desde a última leitura",
+		i18n.English:      "The resource has changed since it was last read",
+		i18n.Spanish:      "El recurso ha cambiado desde la última lectura",
+	}},
+
+	CodeAuthTokenMissing: {http.StatusUnauthorized, i18n.Text{
+		i18n.PortugueseBR: "Token de autorização necessário",
+		i18n.English:      "Authorization token required",
+		i18n.Spanish:      "Se requiere token de autorización",
+	}},
+	CodeAuthTokenMalformed: {http.StatusUnauthorized, i18n.Text{
+		i18n.PortugueseBR: "Formato de token inválido",
+		i18n.English:      "Invalid token format",
+		i18n.Spanish:      "Formato de token inválido",
+	}},
+	CodeAuthTokenInvalid: {http.StatusUnauthorized, i18n.Text{
+		i18n.PortugueseBR: "Token inválido",
+		i18n.English:      "Invalid token",
+		i18n.Spanish:      "Token inválido",
+	}},
+	CodeAuthTokenExpired: {http.StatusUnauthorized, i18n.Text{
+		i18n.PortugueseBR: "Token expirado",
+		i18n.English:      "Token expired",
+		i18n.Spanish:      "Token expirado",
+	}},
+	CodeAuthInvalidCredentials: {http.StatusUnauthorized, i18n.Text{
+		i18n.PortugueseBR: "Credenciais inválidas",
+		i18n.English:      "Invalid credentials",
+		i18n.Spanish:      "Credenciales inválidas",
+	}},
+	CodeAdminRequired: {http.StatusForbidden, i18n.Text{
+		i18n.PortugueseBR: "Operação restrita a administradores",
+		i18n.English:      "Operation restricted to administrators",
+		i18n.Spanish:      "Operación restringida a administradores",
+	}},
+	CodeAccountInactive: {http.StatusForbidden, i18n.Text{
+		i18n.PortugueseBR: "Conta com exclusão agendada; entre novamente para cancelar a exclusão",
+		i18n.English:      "Account scheduled for deletion; log in again to cancel the deletion",
+		i18n.Spanish:      "Cuenta con eliminación programada; inicie sesión de nuevo para cancelar la eliminación",
+	}},
+
+	CodeUserNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Usuário não encontrado",
+		i18n.English:      "User not found",
+		i18n.Spanish:      "Usuario no encontrado",
+	}},
+	CodeEmailInUse: {http.StatusConflict, i18n.Text{
+		i18n.PortugueseBR: "Email já está em uso",
+		i18n.English:      "Email already in use",
+		i18n.Spanish:      "El email ya está en uso",
+	}},
+	CodeUsernameInUse: {http.StatusConflict, i18n.Text{
+		i18n.PortugueseBR: "Username já está em uso",
+		i18n.English:      "Username already in use",
+		i18n.Spanish:      "El nombre de usuario ya está en uso",
+	}},
+	CodeContactNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Contato não encontrado",
+		i18n.English:      "Contact not found",
+		i18n.Spanish:      "Contacto no encontrado",
+	}},
+	CodeContactLimit: {http.StatusConflict, i18n.Text{
+		i18n.PortugueseBR: "Limite de contatos de emergência atingido",
+		i18n.English:      "Emergency contact limit reached",
+		i18n.Spanish:      "Límite de contactos de emergencia alcanzado",
+	}},
+
+	CodeContactNotPending: {http.StatusConflict, i18n.Text{
+		i18n.PortugueseBR: "O contato já respondeu ao convite",
+		i18n.English:      "The contact has already answered the invitation",
+		i18n.Spanish:      "El contacto ya respondió a la invitación",
+	}},
+	CodeInvitationNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Convite inválido ou expirado",
+		i18n.English:      "Invalid or expired invitation",
+		i18n.Spanish:      "Invitación inválida o expirada",
+	}},
+
+	CodeContactFileInvalid: {http.StatusBadRequest, i18n.Text{
+		i18n.PortugueseBR: "Arquivo de contatos inválido",
+		i18n.English:      "Invalid contacts file",
+		i18n.Spanish:      "Archivo de contactos inválido",
+	}},
+
+	CodeImageInvalid: {http.StatusBadRequest, i18n.Text{
+		i18n.PortugueseBR: "Imagem inválida",
+		i18n.English:      "Invalid image",
+		i18n.Spanish:      "Imagen inválida",
+	}},
+	CodeImageNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Imagem não encontrada",
+		i18n.English:      "Image not found",
+		i18n.Spanish:      "Imagen no encontrada",
+	}},
+	CodeImageLinkInvalid: {http.StatusForbidden, i18n.Text{
+		i18n.PortugueseBR: "Link da imagem inválido ou expirado",
+		i18n.English:      "Invalid or expired image link",
+		i18n.Spanish:      "Enlace de la imagen inválido o expirado",
+	}},
+
+	CodeSOSNoContacts: {http.StatusUnprocessableEntity, i18n.Text{
+		i18n.PortugueseBR: "Nenhum contato de emergência aceitou receber alertas",
+		i18n.English:      "No emergency contact has agreed to receive alerts",
+		i18n.Spanish:      "Ningún contacto de emergencia aceptó recibir alertas",
+	}},
+	CodeSOSAlertNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Alerta não encontrado",
+		i18n.English:      "Alert not found",
+		i18n.Spanish:      "Alerta no encontrada",
+	}},
+	CodeSOSAlertClosed: {http.StatusConflict, i18n.Text{
+		i18n.PortugueseBR: "O alerta já foi cancelado",
+		i18n.English:      "The alert has already been cancelled",
+		i18n.Spanish:      "La alerta ya fue cancelada",
+	}},
+
+	CodeResourceNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Recurso de crise não encontrado",
+		i18n.English:      "Crisis resource not found",
+		i18n.Spanish:      "Recurso de crisis no encontrado",
+	}},
+
+	CodeSafetyPlanNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Plano de segurança não encontrado",
+		i18n.English:      "Safety plan not found",
+		i18n.Spanish:      "Plan de seguridad no encontrado",
+	}},
+	CodeSafetyPlanShareNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Link de compartilhamento inválido ou expirado",
+		i18n.English:      "Invalid or expired sharing link",
+		i18n.Spanish:      "Enlace para compartir inválido o expirado",
+	}},
+	CodeContactNotAccepted: {http.StatusConflict, i18n.Text{
+		i18n.PortugueseBR: "O contato ainda não aceitou ser contato de emergência",
+		i18n.English:      "The contact has not accepted being an emergency contact",
+		i18n.Spanish:      "El contacto aún no aceptó ser contacto de emergencia",
+	}},
+
+	CodeConsentRequired: {http.StatusForbidden, i18n.Text{
+		i18n.PortugueseBR: "É necessário aceitar a versão vigente dos termos",
+		i18n.English:      "You must accept the current version of the terms",
+		i18n.Spanish:      "Es necesario aceptar la versión vigente de los términos",
+	}},
+	CodeConsentVersionOutdated: {http.StatusBadRequest, i18n.Text{
+		i18n.PortugueseBR: "Versão do documento não é a vigente",
+		i18n.English:      "Document version is not the current one",
+		i18n.Spanish:      "La versión del documento no es la vigente",
+	}},
+	CodeConsentNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Consentimento ativo não encontrado",
+		i18n.English:      "Active consent not found",
+		i18n.Spanish:      "Consentimiento activo no encontrado",
+	}},
+
+	CodeExportNotFound: {http.StatusNotFound, i18n.Text{
+		i18n.PortugueseBR: "Exportação não encontrada",
+		i18n.English:      "Export not found",
+		i18n.Spanish:      "Exportación no encontrada",
+	}},
+	CodeExportLinkInvalid: {http.StatusForbidden, i18n.Text{
+		i18n.PortugueseBR: "Link de download inválido ou expirado",
+		i18n.English:      "Invalid or expired download link",
+		i18n.Spanish:      "Enlace de descarga inválido o expirado",
+	}},
+	CodeExportExpired: {http.StatusGone, i18n.Text{
+		i18n.PortugueseBR: "Exportação não está mais disponível",
+		i18n.English:      "Export is no longer available",
+		i18n.Spanish:      "La exportación ya no está disponible",
+	}},
+}
+
+// Codes retorna todos os códigos do catálogo
+func Codes() []Code {
+	codes := make([]Code, 0, len(catalogue))
+	for code := range catalogue {
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// Status retorna o status HTTP associado ao código
+func (c Code) Status() int {
+	if def, ok := catalogue[c]; ok {
+		return def.status
+	}
+	return http.StatusInternalServerError
+}
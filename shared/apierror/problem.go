@@ -0,0 +1,182 @@
+// Package apierror padroniza as respostas de erro da API no formato
+// application/problem+json (RFC 7807), com um código estável por tipo de erro.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/shared/i18n"
+)
+
+// ContentType é o media type das respostas de erro
+const ContentType = "application/problem+json"
+
+// typePrefix forma o URI do campo type a partir do código
+const typePrefix = "urn:meuapoio:problem:"
+
+// FieldError descreve um campo rejeitado pela validação
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+
+	// translate gera Message no idioma da resposta
+	translate func(lang string) string
+}
+
+// Problem é o corpo de erro retornado por gateway e serviços. Campos extras
+// específicos de um código (como os documentos pendentes em CONSENT_REQUIRED)
+// vão em Extensions e são serializados no mesmo nível dos demais.
+type Problem struct {
+	Type       string         `json:"type"`
+	Title      string         `json:"title"`
+	Status     int            `json:"status"`
+	Detail     string         `json:"detail,omitempty"`
+	Instance   string         `json:"instance,omitempty"`
+	Code       Code           `json:"code"`
+	RequestID  string         `json:"request_id,omitempty"`
+	Errors     []FieldError   `json:"errors,omitempty"`
+	Extensions map[string]any `json:"-"`
+
+	title, detail i18n.Text
+}
+
+// New cria o Problem do código com o status e o título do catálogo. Os textos
+// são traduzidos para o idioma da requisição ao responder.
+func New(code Code) *Problem {
+	def, ok := catalogue[code]
+	if !ok {
+		def = catalogue[CodeInternal]
+	}
+	p := &Problem{
+		Type:   typePrefix + strings.ToLower(strings.ReplaceAll(string(code), "_", "-")),
+		Status: def.status,
+		Code:   code,
+		title:  def.title,
+	}
+	p.localize(i18n.Default)
+	return p
+}
+
+// WithDetail acrescenta uma explicação específica desta ocorrência
+func (p *Problem) WithDetail(detail i18n.Text) *Problem {
+	p.detail = detail
+	p.Detail = detail.In(i18n.Default)
+	return p
+}
+
+// WithStatus substitui o status padrão do código, para os poucos casos em que o
+// mesmo código aparece em contextos diferentes
+func (p *Problem) WithStatus(status int) *Problem {
+	p.Status = status
+	return p
+}
+
+// With acrescenta um campo extra ao corpo
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]any{}
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return string(p.Code) + ": " + p.Detail
+	}
+	return string(p.Code) + ": " + p.Title
+}
+
+// MarshalJSON serializa os campos padrão junto com as extensões
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	base, err := json.Marshal((*problem)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return base, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(base, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range p.Extensions {
+		if _, reserved := fields[key]; reserved {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = raw
+	}
+	return json.Marshal(fields)
+}
+
+// localize traduz título, detalhe e mensagens dos campos
+func (p *Problem) localize(lang string) {
+	p.Title = p.title.In(lang)
+	if p.detail != nil {
+		p.Detail = p.detail.In(lang)
+	}
+	for i := range p.Errors {
+		if p.Errors[i].translate != nil {
+			p.Errors[i].Message = p.Errors[i].translate(lang)
+		}
+	}
+}
+
+// Write envia o Problem em um http.ResponseWriter, para código fora do gin
+// (como o ErrorHandler do proxy reverso do gateway). O idioma vem do Accept-Language.
+func (p *Problem) Write(w http.ResponseWriter, r *http.Request) {
+	lang := i18n.Default
+	if r != nil {
+		lang = i18n.Negotiate(r.Header.Get("Accept-Language"))
+		if p.Instance == "" {
+			p.Instance = r.URL.Path
+		}
+		if p.RequestID == "" {
+			p.RequestID = r.Header.Get("X-Request-ID")
+		}
+	}
+	p.localize(lang)
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		body = []byte(`{"code":"INTERNAL_ERROR","status":500}`)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// AbortWith interrompe a cadeia de handlers respondendo com o Problem no idioma
+// da requisição
+func AbortWith(c *gin.Context, p *Problem) {
+	p.localize(i18n.Language(c))
+	p.Instance = c.Request.URL.Path
+	// Definido pelo middleware RequestID
+	p.RequestID = c.GetString("request_id")
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(p.Status, ContentType, body)
+	c.Abort()
+}
+
+// Abort responde com o status e o título do catálogo para o código
+func Abort(c *gin.Context, code Code) {
+	AbortWith(c, New(code))
+}
+
+// NoRoute responde ROUTE_NOT_FOUND; usado em gin.Engine.NoRoute
+func NoRoute(c *gin.Context) {
+	Abort(c, CodeRouteNotFound)
+}
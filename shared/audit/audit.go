@@ -0,0 +1,390 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/shared/database"
+)
+
+// Ações registradas no log de auditoria
+const (
+	ActionRegister       = "auth.register"
+	ActionLogin          = "auth.login"
+	ActionLoginFailed    = "auth.login_failed"
+	ActionProfileUpdated = "user.profile_updated"
+	ActionContactCreated = "contact.created"
+	ActionContactUpdated = "contact.updated"
+	ActionContactDeleted = "contact.deleted"
+	// Exportação da lista de contatos em vCard ou CSV; a importação registra um
+	// contact.created por contato criado
+	ActionContactsExported = "contact.exported"
+
+	ActionContactInvited            = "contact.invited"
+	ActionContactInvitationAccepted = "contact.invitation_accepted"
+	ActionContactInvitationDeclined = "contact.invitation_declined"
+
+	ActionSOSTriggered    = "sos.triggered"
+	ActionSOSCancelled    = "sos.cancelled"
+	ActionSOSAcknowledged = "sos.acknowledged"
+
+	ActionResourceCreated = "resource.created"
+	ActionResourceUpdated = "resource.updated"
+	ActionResourceDeleted = "resource.deleted"
+
+	ActionSafetyPlanUpdated      = "safety_plan.updated"
+	ActionSafetyPlanDeleted      = "safety_plan.deleted"
+	ActionSafetyPlanShared       = "safety_plan.shared"
+	ActionSafetyPlanShareRevoked = "safety_plan.share_revoked"
+
+	ActionAccountDeletionRequested = "user.account_deletion_requested"
+	ActionAccountDeletionCancelled = "user.account_deletion_cancelled"
+	ActionAccountErased            = "user.account_erased"
+
+	ActionConsentGranted   = "consent.granted"
+	ActionConsentWithdrawn = "consent.withdrawn"
+
+	ActionDataExportRequested  = "user.data_export_requested"
+	ActionDataExportDownloaded = "user.data_export_downloaded"
+)
+
+// Tipos de alvo dos eventos
+const (
+	TargetUser       = "user"
+	TargetContact    = "contact"
+	TargetDataExport = "data_export"
+	TargetConsent    = "consent"
+	TargetSOSAlert   = "sos_alert"
+	TargetResource   = "crisis_resource"
+	TargetSafetyPlan = "safety_plan"
+)
+
+// chainLockKey identifica o advisory lock que serializa a escrita da cadeia de hashes
+const chainLockKey = 7262001
+
+// Event representa um evento de segurança registrado de forma append-only
+type Event struct {
+	ID         int64             `json:"id"`
+	ActorID    *string           `json:"actor_id"`
+	Action     string            `json:"action"`
+	TargetType *string           `json:"target_type"`
+	TargetID   *string           `json:"target_id"`
+	IPAddress  string            `json:"ip_address"`
+	UserAgent  string            `json:"user_agent"`
+	RequestID  string            `json:"request_id"`
+	Changes    map[string]Change `json:"changes,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	// AnonymizedAt é preenchido quando a exclusão da conta apaga IP, user
+	// agent e diff do evento
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"`
+	PrevHash     *string    `json:"-"`
+	Hash         *string    `json:"-"`
+}
+
+// FromContext cria um evento preenchido com os dados da requisição atual
+// (usuário autenticado, IP, user agent e request ID)
+func FromContext(c *gin.Context, action string) *Event {
+	e := &Event{
+		Action:    action,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetString("request_id"),
+	}
+	if userID := c.GetString("user_id"); userID != "" {
+		e.ActorID = &userID
+	}
+	return e
+}
+
+// SetActor define o autor do evento (ex.: login, onde ainda não há usuário no contexto)
+func (e *Event) SetActor(actorID string) *Event {
+	e.ActorID = &actorID
+	return e
+}
+
+// SetTarget define o recurso afetado pelo evento
+func (e *Event) SetTarget(targetType, targetID string) *Event {
+	e.TargetType = &targetType
+	if targetID != "" {
+		e.TargetID = &targetID
+	}
+	return e
+}
+
+// Logger grava eventos na tabela audit_events
+type Logger struct {
+	db      *sql.DB
+	chained bool
+}
+
+// NewLogger cria um Logger. Com chained=true cada evento carrega o hash do anterior,
+// tornando detectável qualquer alteração ou remoção de registros.
+func NewLogger(db *sql.DB, chained bool) *Logger {
+	return &Logger{db: db, chained: chained}
+}
+
+// Log registra o evento sem interromper o fluxo da requisição em caso de falha
+func (l *Logger) Log(e *Event) {
+	if l == nil {
+		return
+	}
+	if err := l.Record(e); err != nil {
+		log.Printf("Erro ao registrar evento de auditoria %s: %v", e.Action, err)
+	}
+}
+
+// Record grava o evento e retorna o erro, se houver
+func (l *Logger) Record(e *Event) error {
+	e.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	changes, err := marshalChanges(e.Changes)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar alterações: %w", err)
+	}
+
+	if !l.chained {
+		return l.insert(l.db, e, changes)
+	}
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, chainLockKey); err != nil {
+		return err
+	}
+
+	var prev sql.NullString
+	err = tx.QueryRow(`SELECT hash FROM audit_events WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1`).Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if prev.Valid {
+		e.PrevHash = &prev.String
+	}
+
+	hash := computeHash(e, changes)
+	e.Hash = &hash
+
+	if err := l.insert(tx, e, changes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (l *Logger) insert(db rowQuerier, e *Event, changes []byte) error {
+	query := `
+		INSERT INTO audit_events (actor_id, action, target_type, target_id, ip_address,
+		                          user_agent, request_id, changes, prev_hash, hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`
+
+	return db.QueryRow(
+		query, e.ActorID, e.Action, e.TargetType, e.TargetID, e.IPAddress,
+		e.UserAgent, e.RequestID, nullableJSON(changes), e.PrevHash, e.Hash, e.CreatedAt,
+	).Scan(&e.ID)
+}
+
+// ListByActor retorna os eventos mais recentes realizados pelo usuário. Eventos
+// sobre a conta (login falho, exclusão) também são gravados com o titular como
+// autor. Com limit <= 0 todos os eventos são retornados. Um Logger nil não tem
+// eventos.
+func (l *Logger) ListByActor(ctx context.Context, actorID string, limit int) ([]*Event, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	query := `
+		SELECT id, actor_id, action, target_type, target_id, ip_address,
+		       user_agent, request_id, changes, prev_hash, hash, created_at, anonymized_at
+		FROM audit_events
+		WHERE actor_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+
+	var max any
+	if limit > 0 {
+		max = limit
+	}
+
+	rows, err := l.db.QueryContext(ctx, query, actorID, max)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		e, _, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// anonymizeSetting é a flag de sessão que o trigger de audit_events exige
+// para aceitar a anonimização
+const anonymizeSetting = "meuapoio.audit_anonymize"
+
+// Anonymize apaga IP, user agent e diff dos eventos em que userID é o autor ou
+// o usuário alvo, marcando anonymized_at. exec deve ser a transação que exclui
+// a conta: a flag vale só dentro dela e é desligada ao terminar.
+func Anonymize(ctx context.Context, exec database.Executor, userID string, now time.Time) error {
+	if _, err := exec.ExecContext(ctx, `SELECT set_config($1, 'on', true)`, anonymizeSetting); err != nil {
+		return err
+	}
+
+	_, err := exec.ExecContext(ctx, `
+		UPDATE audit_events
+		SET ip_address = NULL, user_agent = NULL, changes = NULL, anonymized_at = $1
+		WHERE anonymized_at IS NULL
+		  AND (actor_id = $2 OR (target_type = $3 AND target_id = $4))
+	`, now, userID, TargetUser, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.ExecContext(ctx, `SELECT set_config($1, 'off', true)`, anonymizeSetting)
+	return err
+}
+
+// Verify percorre a cadeia de hashes e retorna o ID do primeiro evento adulterado.
+// Retorna 0 quando a cadeia está íntegra.
+func (l *Logger) Verify(ctx context.Context) (int64, error) {
+	query := `
+		SELECT id, actor_id, action, target_type, target_id, ip_address,
+		       user_agent, request_id, changes, prev_hash, hash, created_at, anonymized_at
+		FROM audit_events
+		WHERE hash IS NOT NULL
+		ORDER BY id
+	`
+
+	rows, err := l.db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var last *string
+	for rows.Next() {
+		e, changes, err := scanEvent(rows)
+		if err != nil {
+			return 0, err
+		}
+
+		if !validLink(e, changes, last) {
+			return e.ID, nil
+		}
+		last = e.Hash
+	}
+
+	return 0, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEvent(row scanner) (*Event, []byte, error) {
+	e := &Event{}
+	var ip, userAgent, requestID sql.NullString
+	var changes []byte
+
+	err := row.Scan(
+		&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID, &ip,
+		&userAgent, &requestID, &changes, &e.PrevHash, &e.Hash, &e.CreatedAt, &e.AnonymizedAt,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e.IPAddress = ip.String
+	e.UserAgent = userAgent.String
+	e.RequestID = requestID.String
+	e.CreatedAt = e.CreatedAt.UTC()
+
+	if len(changes) > 0 {
+		if err := json.Unmarshal(changes, &e.Changes); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return e, changes, nil
+}
+
+// validLink confere se o evento aponta para o hash do anterior (prev) e se o
+// próprio hash corresponde ao conteúdo gravado. Num evento anonimizado o
+// conteúdo já não é o do hash: só o encadeamento é conferido.
+func validLink(e *Event, changes []byte, prev *string) bool {
+	if !sameHash(e.PrevHash, prev) || e.Hash == nil {
+		return false
+	}
+	return e.AnonymizedAt != nil || computeHash(e, changes) == *e.Hash
+}
+
+// computeHash encadeia o hash do evento anterior com o conteúdo canônico do evento
+func computeHash(e *Event, changes []byte) string {
+	fields := []string{
+		deref(e.PrevHash),
+		deref(e.ActorID),
+		e.Action,
+		deref(e.TargetType),
+		deref(e.TargetID),
+		e.IPAddress,
+		e.UserAgent,
+		e.RequestID,
+		string(changes),
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+func marshalChanges(changes map[string]Change) ([]byte, error) {
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(changes)
+}
+
+func nullableJSON(b []byte) any {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
+
+func sameHash(a, b *string) bool {
+	return deref(a) == deref(b)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/meuapoio/services/user/models"
+)
+
+func TestDiffMasksSensitiveFields(t *testing.T) {
+	type profile struct {
+		Name      string  `json:"name"`
+		Phone     *string `json:"phone"`
+		City      string  `json:"city"`
+		UpdatedAt string  `json:"updated_at"`
+		Version   int     `json:"version"`
+	}
+	phone := "+5511988887777"
+	before := profile{Name: "Maria", City: "Recife", UpdatedAt: "ontem", Version: 1}
+	after := profile{Name: "Maria Silva", Phone: &phone, City: "Olinda", UpdatedAt: "hoje", Version: 2}
+
+	changes := Diff(before, after)
+	if len(changes) != 3 {
+		t.Fatalf("esperados 3 campos alterados, obtido %v", changes)
+	}
+	if got := changes["name"]; got.Before != Mask || got.After != Mask {
+		t.Errorf("name não mascarado: %+v", got)
+	}
+	// Ausência continua visível: o telefone foi adicionado
+	if got := changes["phone"]; got.Before != nil || got.After != Mask {
+		t.Errorf("phone = %+v, esperado nil -> %s", got, Mask)
+	}
+	if got := changes["city"]; got.Before != "Recife" || got.After != "Olinda" {
+		t.Errorf("city = %+v", got)
+	}
+
+	if changes := Diff(before, before); changes != nil {
+		t.Errorf("sem alterações deveria retornar nil, obtido %v", changes)
+	}
+
+	created := Diff(nil, map[string]any{"email": "maria@exemplo.com", "relationship": "friend"})
+	if created["email"].Before != nil || created["email"].After != Mask {
+		t.Errorf("email na criação = %+v", created["email"])
+	}
+	if created["relationship"].After != "friend" {
+		t.Errorf("relationship na criação = %+v", created["relationship"])
+	}
+}
+
+// TestDiffMasksUserProfile usa o modelo gravado por UpdateProfile: nome e
+// username nunca chegam em texto puro a audit_events, que não aceita UPDATE
+func TestDiffMasksUserProfile(t *testing.T) {
+	before, after := "Maria Silva", "Maria Souza"
+	language := "en"
+	changes := Diff(
+		&models.User{ID: "u1", Username: "maria", FullName: &before, Version: 1},
+		&models.User{ID: "u1", Username: "maria.souza", FullName: &after, PreferredLanguage: &language, Version: 2},
+	)
+	for _, field := range []string{"full_name", "username"} {
+		if got, ok := changes[field]; !ok || got.Before != Mask || got.After != Mask {
+			t.Errorf("%s = %+v, esperado mascarado", field, got)
+		}
+	}
+	if got := changes["preferred_language"]; got.Before != nil || got.After != language {
+		t.Errorf("preferred_language = %+v", got)
+	}
+}
+
+// chain monta eventos encadeados como o Record faria
+func chain(n int) ([]*Event, [][]byte) {
+	events := make([]*Event, n)
+	changes := make([][]byte, n)
+	var prev *string
+	for i := range events {
+		actor := "user-1"
+		e := (&Event{
+			ID:        int64(i + 1),
+			ActorID:   &actor,
+			Action:    ActionProfileUpdated,
+			IPAddress: "10.0.0.1",
+			RequestID: "req",
+			PrevHash:  prev,
+			CreatedAt: time.Date(2024, 3, 1, 12, 0, i, 123000, time.UTC),
+		}).SetTarget(TargetUser, actor)
+		changes[i], _ = marshalChanges(map[string]Change{"city": {Before: "Recife", After: "Olinda"}})
+		hash := computeHash(e, changes[i])
+		e.Hash = &hash
+		events[i], prev = e, e.Hash
+	}
+	return events, changes
+}
+
+func TestChainVerification(t *testing.T) {
+	events, changes := chain(3)
+	var prev *string
+	for i, e := range events {
+		if !validLink(e, changes[i], prev) {
+			t.Fatalf("evento %d íntegro rejeitado", e.ID)
+		}
+		prev = e.Hash
+	}
+
+	// O hash cobre todos os campos gravados
+	tampered := *events[1]
+	tampered.IPAddress = "10.0.0.2"
+	if validLink(&tampered, changes[1], events[0].Hash) {
+		t.Error("alteração do IP não detectada")
+	}
+	if validLink(events[1], []byte(`{"city":{"before":"Recife","after":"Natal"}}`), events[0].Hash) {
+		t.Error("alteração do diff não detectada")
+	}
+	tampered = *events[1]
+	tampered.CreatedAt = tampered.CreatedAt.Add(time.Microsecond)
+	if validLink(&tampered, changes[1], events[0].Hash) {
+		t.Error("alteração da data não detectada")
+	}
+
+	// Remover um evento quebra o encadeamento do seguinte
+	if validLink(events[2], changes[2], events[0].Hash) {
+		t.Error("remoção de evento não detectada")
+	}
+	if validLink(events[1], changes[1], nil) {
+		t.Error("remoção do primeiro evento não detectada")
+	}
+
+	// A exclusão da conta apaga IP, user agent e diff: o evento anonimizado
+	// continua no encadeamento, mas não pode sair da posição
+	anonymized := *events[1]
+	anonymized.IPAddress, anonymized.UserAgent, anonymized.Changes = "", "", nil
+	erasedAt := anonymized.CreatedAt.Add(time.Hour)
+	anonymized.AnonymizedAt = &erasedAt
+	if !validLink(&anonymized, nil, events[0].Hash) || !validLink(events[2], changes[2], anonymized.Hash) {
+		t.Error("evento anonimizado rejeitado")
+	}
+	if validLink(&anonymized, nil, nil) {
+		t.Error("evento anonimizado fora de posição não detectado")
+	}
+
+	// Recalcular o hash do evento alterado não basta: o seguinte aponta para o original
+	tampered = *events[1]
+	tampered.Action = ActionLogin
+	rehashed := computeHash(&tampered, changes[1])
+	tampered.Hash = &rehashed
+	if !validLink(&tampered, changes[1], events[0].Hash) || validLink(events[2], changes[2], tampered.Hash) {
+		t.Error("hash recalculado deveria quebrar o elo seguinte")
+	}
+}
+
+func TestComputeHashIsStable(t *testing.T) {
+	events, changes := chain(1)
+	e := events[0]
+	if computeHash(e, changes[0]) != *e.Hash {
+		t.Fatal("hash deveria ser determinístico")
+	}
+	// O fuso de created_at não altera o hash: o valor é normalizado em UTC
+	local := *e
+	local.CreatedAt = e.CreatedAt.In(time.FixedZone("BRT", -3*3600))
+	if computeHash(&local, changes[0]) != *e.Hash {
+		t.Error("hash mudou com o fuso de created_at")
+	}
+	// Campos vazios são separados: mover texto entre campos muda o hash
+	moved := *e
+	moved.IPAddress, moved.UserAgent = "", e.IPAddress
+	if computeHash(&moved, changes[0]) == *e.Hash {
+		t.Error("hash não distingue campos adjacentes")
+	}
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Mask substitui o valor de campos sensíveis no diff
+const Mask = "***"
+
+// SensitiveFields lista os campos (pelo nome JSON) cujo conteúdo nunca é gravado no log
+var SensitiveFields = map[string]bool{
+	"username":      true,
+	"full_name":     true,
+	"password":      true,
+	"password_hash": true,
+	"token":         true,
+	"phone":         true,
+	"phone_display": true,
+	"email":         true,
+	"birth_date":    true,
+	"name":          true,
+}
+
+// ignoredFields são campos que mudam a cada escrita e só poluiriam o diff
+var ignoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"version":    true,
+}
+
+// Change representa o valor de um campo antes e depois da operação
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Diff compara duas representações (structs ou maps serializáveis em JSON) e retorna
+// apenas os campos alterados, mascarando os sensíveis. Use nil em before para criações
+// e nil em after para remoções.
+func Diff(before, after any) map[string]Change {
+	b := toMap(before)
+	a := toMap(after)
+
+	changes := make(map[string]Change)
+	for key := range union(b, a) {
+		if ignoredFields[key] {
+			continue
+		}
+
+		oldValue, newValue := b[key], a[key]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		if SensitiveFields[key] {
+			oldValue, newValue = mask(oldValue), mask(newValue)
+		}
+		changes[key] = Change{Before: oldValue, After: newValue}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func toMap(v any) map[string]any {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return map[string]any{}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return map[string]any{}
+	}
+
+	m := map[string]any{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return map[string]any{}
+	}
+	return m
+}
+
+func union(a, b map[string]any) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// mask preserva a informação de presença/ausência sem expor o valor
+func mask(v any) any {
+	if v == nil {
+		return nil
+	}
+	return Mask
+}
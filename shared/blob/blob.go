@@ -0,0 +1,54 @@
+// Package blob guarda arquivos (objetos) identificados por uma chave, no disco
+// local ou em um serviço compatível com S3 como o MinIO. Os serviços dependem
+// apenas de Store; a implementação é escolhida na inicialização de cada serviço.
+package blob
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound indica que não há objeto com a chave
+var ErrNotFound = errors.New("objeto não encontrado")
+
+// maxKeyLength é o tamanho máximo de uma chave
+const maxKeyLength = 200
+
+// Store guarda objetos pequenos, lidos e gravados inteiros na memória. As chaves
+// seguem ValidKey; as implementações recusam as demais.
+type Store interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get retorna ErrNotFound se a chave não existir
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete remove o objeto; chave inexistente não é erro
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix remove todos os objetos cuja chave começa com prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+	// URL gera um link assinado para baixar o objeto sem autenticação, válido
+	// até expiresAt
+	URL(key string, expiresAt time.Time) (string, error)
+}
+
+// ValidKey informa se key pode ser usada como chave: até 200 letras ASCII,
+// dígitos, '.', '-' e '_', sem começar com ponto. Chaves assim cabem em um
+// segmento de URL e em um nome de arquivo sem escape.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > maxKeyLength || key[0] == '.' {
+		return false
+	}
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// errInvalidKey é o erro das operações com chaves fora de ValidKey
+func errInvalidKey(key string) error {
+	return errors.New("chave de objeto inválida: " + key)
+}
@@ -0,0 +1,114 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meuapoio/shared/utils"
+)
+
+// FileStore guarda os objetos como arquivos em um diretório. Os links de URL
+// apontam para uma rota do próprio serviço, que confere a assinatura com Verify
+// e entrega o arquivo; o tipo do conteúdo é deduzido da extensão da chave.
+type FileStore struct {
+	dir       string
+	urlPrefix string
+	secret    string
+}
+
+// NewFileStore cria o diretório dir, se preciso. Os links são
+// urlPrefix/<chave>?expires=<unix>&signature=<hmac>, assinados com secret.
+func NewFileStore(dir, urlPrefix, secret string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, urlPrefix: strings.TrimSuffix(urlPrefix, "/"), secret: secret}, nil
+}
+
+// Put grava em um arquivo temporário e renomeia, para que uma leitura
+// concorrente nunca veja o objeto pela metade
+func (s *FileStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	if !ValidKey(key) {
+		return errInvalidKey(key)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, key))
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if !ValidKey(key) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return errInvalidKey(key)
+	}
+	if err := os.Remove(filepath.Join(s.dir, key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) DeletePrefix(ctx context.Context, prefix string) error {
+	if prefix == "" || !ValidKey(prefix) {
+		return errInvalidKey(prefix)
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasPrefix(entry.Name(), prefix) {
+			if err := s.Delete(ctx, entry.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) URL(key string, expiresAt time.Time) (string, error) {
+	if !ValidKey(key) {
+		return "", errInvalidKey(key)
+	}
+	signature := utils.SignExpiring(s.secret, s.resource(key), expiresAt)
+	return s.urlPrefix + "/" + key + "?expires=" + strconv.FormatInt(expiresAt.Unix(), 10) + "&signature=" + signature, nil
+}
+
+// Verify confere a assinatura e a validade de um link gerado por URL
+func (s *FileStore) Verify(key, expires, signature string) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !ValidKey(key) {
+		return false
+	}
+	return utils.VerifyExpiring(s.secret, s.resource(key), expiresAt, signature)
+}
+
+// resource separa as assinaturas dos objetos das de outros links com o mesmo segredo
+func (s *FileStore) resource(key string) string {
+	return "blob:" + key
+}
@@ -0,0 +1,323 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload dispensa o hash do corpo na assinatura dos links pré-assinados
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config são o endereço e as credenciais de um serviço compatível com S3.
+// Endpoint é host[:porta], sem esquema; UseSSL escolhe https.
+type S3Config struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+}
+
+// S3Store guarda os objetos em um bucket S3 ou MinIO, com URLs no estilo de
+// caminho (endpoint/bucket/chave) e requisições assinadas com AWS Signature
+// Version 4. Os links de URL são pré-assinados e vão direto ao serviço.
+type S3Store struct {
+	cfg    S3Config
+	scheme string
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3Store(cfg S3Config) *S3Store {
+	scheme := "http"
+	if cfg.UseSSL {
+		scheme = "https"
+	}
+	return &S3Store{
+		cfg:    cfg,
+		scheme: scheme,
+		client: &http.Client{Timeout: 30 * time.Second},
+		now:    time.Now,
+	}
+}
+
+// EnsureBucket cria o bucket se ele ainda não existir
+func (s *S3Store) EnsureBucket(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, "", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode != http.StatusNotFound:
+		return fmt.Errorf("S3 HEAD %s: %s", s.cfg.Bucket, resp.Status)
+	}
+
+	resp, err = s.do(ctx, http.MethodPut, "", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	return s.check(resp, "criar bucket", s.cfg.Bucket)
+}
+
+func (s *S3Store) Put(ctx context.Context, key, contentType string, data []byte) error {
+	if !ValidKey(key) {
+		return errInvalidKey(key)
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, nil, data, contentType)
+	if err != nil {
+		return err
+	}
+	return s.check(resp, http.MethodPut, key)
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	if !ValidKey(key) {
+		return nil, ErrNotFound
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.check(resp, http.MethodGet, key)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Delete remove o objeto. O S3 responde 204 também para chaves inexistentes.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return errInvalidKey(key)
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil
+	}
+	return s.check(resp, http.MethodDelete, key)
+}
+
+// listResult é a parte usada da resposta do ListObjectsV2
+type listResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// DeletePrefix lista as chaves com o prefixo, página a página, e remove uma a uma
+func (s *S3Store) DeletePrefix(ctx context.Context, prefix string) error {
+	if prefix == "" || !ValidKey(prefix) {
+		return errInvalidKey(prefix)
+	}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, "")
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return s.check(resp, "listar", prefix)
+		}
+		var page listResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("S3 listar %s: %w", prefix, err)
+		}
+
+		for _, object := range page.Contents {
+			if err := s.Delete(ctx, object.Key); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// URL pré-assina um GET do objeto. O S3 aceita no máximo sete dias de validade.
+func (s *S3Store) URL(key string, expiresAt time.Time) (string, error) {
+	if !ValidKey(key) {
+		return "", errInvalidKey(key)
+	}
+	now := s.now()
+	ttl := expiresAt.Sub(now).Round(time.Second)
+	if ttl <= 0 || ttl > 7*24*time.Hour {
+		return "", fmt.Errorf("validade do link fora do intervalo aceito pelo S3: %s", ttl)
+	}
+	return s.presign(http.MethodGet, s.objectURL(key, nil), now, ttl), nil
+}
+
+// objectURL é o endereço do objeto key, ou do bucket quando key é vazia
+func (s *S3Store) objectURL(key string, query url.Values) *url.URL {
+	path := "/" + s.cfg.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	return &url.URL{Scheme: s.scheme, Host: s.cfg.Endpoint, Path: path, RawQuery: canonicalQuery(query)}
+}
+
+// do envia a requisição assinada no cabeçalho Authorization
+func (s *S3Store) do(ctx context.Context, method, key string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body, s.now())
+	return s.client.Do(req)
+}
+
+// check fecha a resposta e a converte em erro quando não é 2xx
+func (s *S3Store) check(resp *http.Response, operation, key string) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("S3 %s %s: %s: %s", operation, key, resp.Status, strings.TrimSpace(string(detail)))
+}
+
+// sign assina host, x-amz-content-sha256 e x-amz-date com o hash do corpo
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	headers := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	scope := s.scope(now)
+	signature := s.signature(now, canonicalRequest(req.Method, req.URL, headers, signedHeaders, payloadHash), scope)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// presign adiciona a u os parâmetros de um link pré-assinado válido por ttl
+func (s *S3Store) presign(method string, u *url.URL, now time.Time, ttl time.Duration) string {
+	scope := s.scope(now)
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", now.UTC().Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(ttl/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	signed := *u
+	signed.RawQuery = canonicalQuery(query)
+	signature := s.signature(now, canonicalRequest(method, &signed, "host:"+u.Host+"\n", "host", unsignedPayload), scope)
+	signed.RawQuery += "&X-Amz-Signature=" + signature
+	return signed.String()
+}
+
+// scope é o escopo da credencial: data/região/s3/aws4_request
+func (s *S3Store) scope(now time.Time) string {
+	return now.UTC().Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+// signature assina a requisição canônica com a chave derivada do dia e da região
+func (s *S3Store) signature(now time.Time, canonical, scope string) string {
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.UTC().Format("20060102T150405Z") + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), now.UTC().Format("20060102"))
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func canonicalRequest(method string, u *url.URL, headers, signedHeaders, payloadHash string) string {
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	return method + "\n" + uriEncode(path, false) + "\n" + u.RawQuery + "\n" + headers + "\n" + signedHeaders + "\n" + payloadHash
+}
+
+// canonicalQuery ordena os parâmetros e os codifica como o SigV4 exige, que
+// difere de url.Values.Encode no espaço e no til
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode mantém só os caracteres não reservados da RFC 3986; a barra é
+// mantida nos caminhos e codificada nos parâmetros
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
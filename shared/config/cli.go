@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Usage descreve os subcomandos aceitos por Run
+const Usage = "config print [--redacted] | validate"
+
+const redactedValue = "***"
+
+// Run executa o subcomando de configuração indicado em args (sem o "config"),
+// escrevendo o resultado em out. Compartilhado pelos binários dos serviços.
+func Run(cfg *Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: %s", Usage)
+	}
+
+	switch args[0] {
+	case "print":
+		redacted := false
+		for _, arg := range args[1:] {
+			if arg != "--redacted" {
+				return fmt.Errorf("opção desconhecida %q; uso: %s", arg, Usage)
+			}
+			redacted = true
+		}
+		return cfg.Print(out, redacted)
+
+	case "validate":
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "configuração válida")
+		return nil
+
+	default:
+		return fmt.Errorf("subcomando desconhecido %q; uso: %s", args[0], Usage)
+	}
+}
+
+// Print escreve a configuração efetiva no formato aceito por CONFIG_FILE. Com
+// redacted, os campos secretos preenchidos aparecem como "***".
+func (c *Config) Print(out io.Writer, redacted bool) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := formatField(v.Field(i))
+		if redacted && field.Tag.Get("secret") == "true" && value != "" {
+			value = redactedValue
+		}
+
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: strings.ToLower(field.Tag.Get("env"))},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: value},
+		)
+	}
+
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func formatField(field reflect.Value) string {
+	switch {
+	case field.Type() == durationType:
+		return time.Duration(field.Int()).String()
+	case field.Kind() == reflect.Int:
+		return strconv.FormatInt(field.Int(), 10)
+	case field.Kind() == reflect.Bool:
+		return strconv.FormatBool(field.Bool())
+	default:
+		return field.String()
+	}
+}
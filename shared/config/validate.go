@@ -0,0 +1,208 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/meuapoio/shared/phone"
+)
+
+// minJWTSecretLength é o tamanho mínimo do JWT_SECRET em produção (256 bits para HS256)
+const minJWTSecretLength = 32
+
+// maxPresignTTL é a maior validade aceita pelo S3 para um link pré-assinado. Os
+// links das fotos de perfil valem até duas vezes PROFILE_IMAGE_URL_TTL.
+const maxPresignTTL = 7 * 24 * time.Hour
+
+// blobBackends são os valores aceitos em BLOB_BACKEND
+var blobBackends = map[string]bool{"local": true, "minio": true}
+
+// sosChannels são os canais aceitos em SOS_CHANNELS
+var sosChannels = map[string]bool{"sms": true, "whatsapp": true, "email": true}
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true,
+	"require": true, "verify-ca": true, "verify-full": true,
+}
+
+// productionSSLModes são os valores de sslmode aceitos em produção: os demais
+// permitem conexão sem TLS
+var productionSSLModes = map[string]bool{"require": true, "verify-ca": true, "verify-full": true}
+
+// IsProduction indica se o serviço roda com ENVIRONMENT=production
+func (c *Config) IsProduction() bool {
+	return c.Environment == "production"
+}
+
+// SOSChannelList retorna os canais de SOS_CHANNELS, sem espaços e sem repetição
+func (c *Config) SOSChannelList() []string {
+	var channels []string
+	seen := map[string]bool{}
+	for _, channel := range strings.Split(c.SOSChannels, ",") {
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if channel != "" && !seen[channel] {
+			seen[channel] = true
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// Validate confere a consistência da configuração. Em produção também recusa as
+// credenciais e chaves padrão de desenvolvimento e um JWT_SECRET curto.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.JWTSecret == "" {
+		fail("JWT_SECRET não pode ser vazio")
+	}
+	if !sslModes[c.DBSSLMode] {
+		fail("DB_SSLMODE inválido: %q", c.DBSSLMode)
+	}
+	if c.DBMaxOpenConns < 1 {
+		fail("DB_MAX_OPEN_CONNS deve ser maior que zero")
+	}
+	if c.DBMaxIdleConns < 0 || c.DBMaxIdleConns > c.DBMaxOpenConns {
+		fail("DB_MAX_IDLE_CONNS deve estar entre 0 e DB_MAX_OPEN_CONNS")
+	}
+	if c.DBConnectTimeout <= 0 {
+		fail("DB_CONNECT_TIMEOUT deve ser positivo")
+	}
+	if c.ExportLinkTTL <= 0 {
+		fail("EXPORT_LINK_TTL deve ser positivo")
+	}
+	if !blobBackends[c.BlobBackend] {
+		fail("BLOB_BACKEND inválido: %q (disponíveis: local, minio)", c.BlobBackend)
+	}
+	if c.BlobBackend == "minio" && (c.MinIOEndpoint == "" || c.MinIOBucket == "" || c.MinIORegion == "") {
+		fail("MINIO_ENDPOINT, MINIO_BUCKET e MINIO_REGION são obrigatórios com BLOB_BACKEND=minio")
+	}
+	if c.BlobBackend == "local" && c.BlobDir == "" {
+		fail("BLOB_DIR não pode ser vazio com BLOB_BACKEND=local")
+	}
+	if c.ProfileImageMaxSize <= 0 {
+		fail("PROFILE_IMAGE_MAX_SIZE deve ser positivo")
+	}
+	if c.ProfileImageURLTTL <= 0 || 2*c.ProfileImageURLTTL > maxPresignTTL {
+		fail("PROFILE_IMAGE_URL_TTL deve ser positivo e no máximo %s", maxPresignTTL/2)
+	}
+	if c.AccountDeletionGracePeriod <= 0 {
+		fail("ACCOUNT_DELETION_GRACE_PERIOD deve ser positivo")
+	}
+	if c.IdempotencyTTL <= 0 {
+		fail("IDEMPOTENCY_TTL deve ser positivo")
+	}
+	if c.IdempotencyMaxBody <= c.ProfileImageMaxSize {
+		fail("IDEMPOTENCY_MAX_BODY deve ser maior que PROFILE_IMAGE_MAX_SIZE, para aceitar o envio da foto de perfil")
+	}
+	if !phone.ValidRegion(c.PhoneDefaultRegion) {
+		fail("PHONE_DEFAULT_REGION inválida: %q", c.PhoneDefaultRegion)
+	}
+	if c.MaxContactsPerUser < 1 {
+		fail("MAX_CONTACTS_PER_USER deve ser maior que zero")
+	}
+	if u, err := url.Parse(c.ContactInvitationURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("CONTACT_INVITATION_URL deve ser uma URL http(s) absoluta: %q", c.ContactInvitationURL)
+	}
+	if c.ContactInvitationTTL <= 0 {
+		fail("CONTACT_INVITATION_TTL deve ser positivo")
+	}
+	if c.SOSEscalationTimeout <= 0 {
+		fail("SOS_ESCALATION_TIMEOUT deve ser positivo")
+	}
+	if c.SOSMaxPerHour < 1 {
+		fail("SOS_MAX_PER_HOUR deve ser maior que zero")
+	}
+	if channels := c.SOSChannelList(); len(channels) == 0 {
+		fail("SOS_CHANNELS deve ter pelo menos um canal")
+	} else {
+		for _, channel := range channels {
+			if !sosChannels[channel] {
+				fail("SOS_CHANNELS tem canal desconhecido: %q (disponíveis: sms, whatsapp, email)", channel)
+			}
+		}
+	}
+	if u, err := url.Parse(c.SOSAcknowledgeURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("SOS_ACKNOWLEDGE_URL deve ser uma URL http(s) absoluta: %q", c.SOSAcknowledgeURL)
+	}
+	if u, err := url.Parse(c.SafetyPlanShareURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("SAFETY_PLAN_SHARE_URL deve ser uma URL http(s) absoluta: %q", c.SafetyPlanShareURL)
+	}
+	if c.SafetyPlanShareTTL <= 0 {
+		fail("SAFETY_PLAN_SHARE_TTL deve ser positivo")
+	}
+
+	if c.IsProduction() {
+		if c.DBURL == "" && c.DBPassword == defaultOf("DBPassword") {
+			fail("DB_PASSWORD usa a senha padrão de desenvolvimento")
+		}
+		if c.JWTSecret == defaultOf("JWTSecret") {
+			fail("JWT_SECRET usa o valor padrão de desenvolvimento")
+		} else if len(c.JWTSecret) < minJWTSecretLength {
+			fail("JWT_SECRET deve ter pelo menos %d caracteres em produção", minJWTSecretLength)
+		}
+		if c.EncryptionKeys == defaultOf("EncryptionKeys") {
+			fail("ENCRYPTION_KEYS usa a chave padrão de desenvolvimento")
+		}
+		if c.BlindIndexKey == defaultOf("BlindIndexKey") {
+			fail("BLIND_INDEX_KEY usa a chave padrão de desenvolvimento")
+		}
+		if c.BlobBackend == "minio" && c.MinIOSecretKey == defaultOf("MinIOSecretKey") {
+			fail("MINIO_SECRET_KEY usa a senha padrão de desenvolvimento")
+		}
+		if usesDefaultPassword(c.MongoURI, defaultOf("MongoURI")) {
+			fail("MONGO_URI usa a senha padrão de desenvolvimento")
+		}
+		if usesDefaultPassword(c.RabbitMQURL, defaultOf("RabbitMQURL")) {
+			fail("RABBITMQ_URL usa a senha padrão de desenvolvimento")
+		}
+
+		// DB_SSLMODE só vale para as conexões montadas a partir de DB_HOST e
+		// DB_REPLICA_HOST; nas URLs vale o sslmode de cada uma
+		builtDSN := c.DBURL == "" || (c.DBReplicaURL == "" && c.DBReplicaHost != "")
+		if builtDSN && !productionSSLModes[c.DBSSLMode] {
+			fail("DB_SSLMODE deve ser require, verify-ca ou verify-full em produção: %q", c.DBSSLMode)
+		}
+		for name, dsn := range map[string]string{"DATABASE_URL": c.DBURL, "DATABASE_REPLICA_URL": c.DBReplicaURL} {
+			if mode := dsnSSLMode(dsn); mode != "" && !productionSSLModes[mode] {
+				fail("%s deve usar sslmode require, verify-ca ou verify-full em produção: %q", name, mode)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// usesDefaultPassword indica se a URL usa a senha da URL padrão, mesmo
+// apontando para outro host
+func usesDefaultPassword(rawURL, defaultURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return false
+	}
+	d, _ := url.Parse(defaultURL)
+	password, _ := u.User.Password()
+	defaultPassword, _ := d.User.Password()
+	return password == defaultPassword
+}
+
+// dsnSSLMode retorna o sslmode de uma conexão do PostgreSQL em URL ou no
+// formato chave=valor; vazio quando não informado, caso em que o lib/pq usa
+// require
+func dsnSSLMode(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		return u.Query().Get("sslmode")
+	}
+	for _, field := range strings.Fields(dsn) {
+		if value, ok := strings.CutPrefix(field, "sslmode="); ok {
+			return strings.Trim(value, "'")
+		}
+	}
+	return ""
+}
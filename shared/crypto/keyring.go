@@ -0,0 +1,253 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// prefix identifica valores cifrados; valores sem o prefixo são tratados como
+// texto puro legado (ainda não migrado)
+const prefix = "enc:v1:"
+
+const keySize = 32
+
+var (
+	ErrUnknownKey       = errors.New("chave de criptografia desconhecida")
+	ErrMalformedPayload = errors.New("valor cifrado malformado")
+)
+
+// Keyring implementa envelope encryption: cada valor é cifrado com uma data key
+// AES-256-GCM aleatória, que por sua vez é cifrada (wrapped) pela master key ativa.
+// O ID da master key fica junto do valor cifrado, permitindo rotacionar a chave
+// ativa sem perder a leitura dos dados antigos.
+//
+// Formato: enc:v1:<key id>:<data key cifrada em base64>:<nonce+ciphertext em base64>
+type Keyring struct {
+	masterKeys map[string][]byte
+	activeID   string
+	indexKey   []byte
+}
+
+// NewKeyring cria um Keyring. activeID indica a master key usada para novas
+// escritas; indexKey é a chave HMAC dos blind indexes.
+func NewKeyring(masterKeys map[string][]byte, activeID string, indexKey []byte) (*Keyring, error) {
+	for id, key := range masterKeys {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("master key %q deve ter %d bytes", id, keySize)
+		}
+	}
+	if _, ok := masterKeys[activeID]; !ok {
+		return nil, fmt.Errorf("master key ativa %q não configurada", activeID)
+	}
+	if len(indexKey) < keySize {
+		return nil, fmt.Errorf("chave do blind index deve ter ao menos %d bytes", keySize)
+	}
+
+	return &Keyring{masterKeys: masterKeys, activeID: activeID, indexKey: indexKey}, nil
+}
+
+// LoadKeyring monta o Keyring a partir dos valores de configuração: a lista de
+// master keys ("id:base64,..."), o ID da chave ativa e a chave do blind index em base64
+func LoadKeyring(keysSpec, activeID, indexKeyB64 string) (*Keyring, error) {
+	masterKeys, err := ParseKeys(keysSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	indexKey, err := base64.StdEncoding.DecodeString(indexKeyB64)
+	if err != nil {
+		return nil, fmt.Errorf("chave do blind index não está em base64: %w", err)
+	}
+
+	return NewKeyring(masterKeys, activeID, indexKey)
+}
+
+// ParseKeys interpreta a lista "id1:base64,id2:base64" usada na configuração
+func ParseKeys(spec string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("entrada de chave inválida: esperado id:base64")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q não está em base64: %w", id, err)
+		}
+		keys[id] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("nenhuma master key configurada")
+	}
+	return keys, nil
+}
+
+// ActiveKeyID retorna o ID da master key usada nas novas escritas
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
+
+// Encrypt cifra o texto com uma nova data key envelopada pela master key ativa
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(k.masterKeys[k.activeID], dataKey, []byte(k.activeID))
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := seal(dataKey, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.activeID + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(payload), nil
+}
+
+// Decrypt decifra um valor produzido por Encrypt. Valores sem o prefixo são
+// retornados sem alteração (dados legados em texto puro).
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformedPayload
+	}
+
+	masterKey, ok := k.masterKeys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformedPayload
+	}
+	payload, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformedPayload
+	}
+
+	dataKey, err := open(masterKey, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(dataKey, payload, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// EncryptPtr cifra valores opcionais, preservando nil
+func (k *Keyring) EncryptPtr(plaintext *string) (*string, error) {
+	if plaintext == nil {
+		return nil, nil
+	}
+	value, err := k.Encrypt(*plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// DecryptPtr decifra valores opcionais, preservando nil
+func (k *Keyring) DecryptPtr(value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	plaintext, err := k.Decrypt(*value)
+	if err != nil {
+		return nil, err
+	}
+	return &plaintext, nil
+}
+
+// NeedsRotation indica se o valor está em texto puro ou cifrado com uma master
+// key diferente da ativa
+func (k *Keyring) NeedsRotation(value string) bool {
+	id, ok := KeyID(value)
+	return !ok || id != k.activeID
+}
+
+// BlindIndex gera um HMAC determinístico do valor, permitindo buscas por igualdade
+// sem armazenar o texto puro. O valor deve ser normalizado pelo chamador.
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted indica se o valor foi produzido por Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID retorna o ID da master key usada no valor cifrado
+func KeyID(value string) (string, bool) {
+	if !IsEncrypted(value) {
+		return "", false
+	}
+	id, _, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return id, ok
+}
+
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformedPayload
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,240 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func testKeyring(t *testing.T, masterKeys map[string][]byte, activeID string) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(masterKeys, activeID, testKey(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	k := testKeyring(t, map[string][]byte{"k1": testKey(1)}, "k1")
+
+	for _, plaintext := range []string{"", "+5511988887777", "Maria José da Silva", strings.Repeat("x", 4096)} {
+		value, err := k.Encrypt(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(value) || (plaintext != "" && strings.Contains(value, plaintext)) {
+			t.Errorf("valor cifrado inesperado: %s", value)
+		}
+		if id, ok := KeyID(value); !ok || id != "k1" {
+			t.Errorf("KeyID = %q, %v", id, ok)
+		}
+		got, err := k.Decrypt(value)
+		if err != nil || got != plaintext {
+			t.Errorf("Decrypt = %q, %v, esperado %q", got, err, plaintext)
+		}
+	}
+
+	// Data key e nonce aleatórios: o mesmo texto nunca gera o mesmo valor
+	a, _ := k.Encrypt("igual")
+	b, _ := k.Encrypt("igual")
+	if a == b {
+		t.Error("dois valores cifrados iguais para o mesmo texto")
+	}
+
+	// Texto puro legado passa sem alteração
+	if got, err := k.Decrypt("legado"); err != nil || got != "legado" {
+		t.Errorf("Decrypt(legado) = %q, %v", got, err)
+	}
+
+	if got, err := k.EncryptPtr(nil); got != nil || err != nil {
+		t.Errorf("EncryptPtr(nil) = %v, %v", got, err)
+	}
+	if got, err := k.DecryptPtr(nil); got != nil || err != nil {
+		t.Errorf("DecryptPtr(nil) = %v, %v", got, err)
+	}
+}
+
+// part substitui o campo i (0 = key id, 1 = data key, 2 = payload) do valor cifrado
+func part(value string, i int, replace func(string) string) string {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	parts[i] = replace(parts[i])
+	return prefix + strings.Join(parts, ":")
+}
+
+func flipByte(encoded string) string {
+	raw, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		panic(err)
+	}
+	raw[len(raw)-1] ^= 0x01
+	return base64.RawStdEncoding.EncodeToString(raw)
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	k := testKeyring(t, map[string][]byte{"k1": testKey(1)}, "k1")
+	value, err := k.Encrypt("+5511988887777")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := k.Encrypt("+5511900001111")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"payload alterado":       part(value, 2, flipByte),
+		"data key alterada":      part(value, 1, flipByte),
+		"payload truncado":       part(value, 2, func(s string) string { return s[:8] }),
+		"payload de outro valor": part(value, 2, func(string) string { return strings.Split(other, ":")[4] }),
+		"base64 inválido":        part(value, 2, func(s string) string { return s + "!" }),
+		"campos a menos":         prefix + "k1:abc",
+		"campos a mais":          value + ":extra",
+	}
+	for name, tampered := range tests {
+		if got, err := k.Decrypt(tampered); err == nil {
+			t.Errorf("%s: Decrypt = %q, esperado erro", name, got)
+		}
+	}
+	if _, err := k.Decrypt(prefix + "k1:abc"); !errors.Is(err, ErrMalformedPayload) {
+		t.Errorf("campos a menos: erro = %v, esperado ErrMalformedPayload", err)
+	}
+}
+
+func TestDecryptBindsKeyIDAsAAD(t *testing.T) {
+	// Duas IDs com a mesma master key: só o AAD impede trocar a ID do valor
+	k := testKeyring(t, map[string][]byte{"k1": testKey(1), "k2": testKey(1)}, "k1")
+	value, err := k.Encrypt("segredo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relabeled := part(value, 0, func(string) string { return "k2" })
+	if got, err := k.Decrypt(relabeled); err == nil {
+		t.Errorf("Decrypt com a ID trocada = %q, esperado erro", got)
+	}
+
+	sealed, err := seal(testKey(1), []byte("dado"), []byte("k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := open(testKey(1), sealed, []byte("k2")); err == nil {
+		t.Error("open com AAD diferente deveria falhar")
+	}
+	if got, err := open(testKey(1), sealed, []byte("k1")); err != nil || string(got) != "dado" {
+		t.Errorf("open = %q, %v", got, err)
+	}
+}
+
+func TestDecryptUnknownKey(t *testing.T) {
+	old := testKeyring(t, map[string][]byte{"k1": testKey(1)}, "k1")
+	value, err := old.Encrypt("segredo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Chave antiga removida antes de recifrar os dados
+	k := testKeyring(t, map[string][]byte{"k2": testKey(2)}, "k2")
+	if _, err := k.Decrypt(value); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("erro = %v, esperado ErrUnknownKey", err)
+	}
+
+	// Mesma ID com outra chave: a data key não abre
+	wrong := testKeyring(t, map[string][]byte{"k1": testKey(3)}, "k1")
+	if _, err := wrong.Decrypt(value); err == nil {
+		t.Error("Decrypt com a master key errada deveria falhar")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	k2 := base64.StdEncoding.EncodeToString(testKey(2))
+
+	keys, err := ParseKeys(" k1:" + k1 + " , k2:" + k2 + ",")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || !bytes.Equal(keys["k1"], testKey(1)) || !bytes.Equal(keys["k2"], testKey(2)) {
+		t.Errorf("chaves inesperadas: %v", keys)
+	}
+
+	for _, spec := range []string{
+		"",
+		" , ",
+		k1,              // sem id
+		":" + k1,        // id vazio
+		"k1:não-base64", // base64 inválido
+		"k1:" + k1 + ",k2",
+	} {
+		if _, err := ParseKeys(spec); err == nil {
+			t.Errorf("ParseKeys(%q) deveria falhar", spec)
+		}
+	}
+}
+
+func TestNewKeyringValidation(t *testing.T) {
+	if _, err := NewKeyring(map[string][]byte{"k1": testKey(1)[:16]}, "k1", testKey(9)); err == nil {
+		t.Error("master key com 16 bytes deveria ser recusada")
+	}
+	if _, err := NewKeyring(map[string][]byte{"k1": testKey(1)}, "k2", testKey(9)); err == nil {
+		t.Error("chave ativa ausente deveria ser recusada")
+	}
+	if _, err := NewKeyring(map[string][]byte{"k1": testKey(1)}, "k1", testKey(9)[:16]); err == nil {
+		t.Error("chave do blind index curta deveria ser recusada")
+	}
+
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	if _, err := LoadKeyring("k1:"+k1, "k1", "não-base64"); err == nil {
+		t.Error("chave do blind index fora de base64 deveria ser recusada")
+	}
+	if _, err := LoadKeyring("k1:"+k1, "k1", base64.StdEncoding.EncodeToString(testKey(9))); err != nil {
+		t.Errorf("LoadKeyring: %v", err)
+	}
+}
+
+func TestNeedsRotation(t *testing.T) {
+	old := testKeyring(t, map[string][]byte{"k1": testKey(1)}, "k1")
+	oldValue, _ := old.Encrypt("segredo")
+
+	k := testKeyring(t, map[string][]byte{"k1": testKey(1), "k2": testKey(2)}, "k2")
+	newValue, _ := k.Encrypt("segredo")
+
+	if !k.NeedsRotation(oldValue) {
+		t.Error("valor da chave antiga deveria precisar de rotação")
+	}
+	if k.NeedsRotation(newValue) {
+		t.Error("valor da chave ativa não precisa de rotação")
+	}
+	if !k.NeedsRotation("texto puro") {
+		t.Error("texto puro legado deveria precisar de rotação")
+	}
+
+	// A chave antiga continua lendo os dados até a recifragem
+	if got, err := k.Decrypt(oldValue); err != nil || got != "segredo" {
+		t.Errorf("Decrypt do valor antigo = %q, %v", got, err)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	k := testKeyring(t, map[string][]byte{"k1": testKey(1)}, "k1")
+	if k.BlindIndex("5511988887777") != k.BlindIndex("5511988887777") {
+		t.Error("blind index deveria ser determinístico")
+	}
+	if k.BlindIndex("5511988887777") == k.BlindIndex("5511988887778") {
+		t.Error("valores diferentes com o mesmo blind index")
+	}
+
+	other, err := NewKeyring(map[string][]byte{"k1": testKey(1)}, "k1", testKey(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.BlindIndex("5511988887777") == k.BlindIndex("5511988887777") {
+		t.Error("o blind index deveria depender da chave")
+	}
+}
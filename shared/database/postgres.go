@@ -1,30 +1,109 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/meuapoio/shared/config"
 )
 
-func ConnectPostgres(cfg *config.Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+const (
+	pingTimeout    = 5 * time.Second
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+)
+
+// ConnectPostgres abre o pool do primário e, se configurada, o da réplica de
+// leitura. Enquanto o banco não responder, novas tentativas são feitas com backoff
+// exponencial até DB_CONNECT_TIMEOUT.
+func ConnectPostgres(cfg *config.Config) (*DB, error) {
+	primaryDSN := cfg.DBURL
+	if primaryDSN == "" {
+		primaryDSN = buildDSN(cfg, cfg.DBHost, cfg.DBPort)
+	}
+
+	primary, err := connect(cfg, primaryDSN, "primário")
+	if err != nil {
+		return nil, err
+	}
+	db := New(primary, cfg.DBQueryTimeout)
 
+	replicaDSN := cfg.DBReplicaURL
+	if replicaDSN == "" && cfg.DBReplicaHost != "" {
+		replicaDSN = buildDSN(cfg, cfg.DBReplicaHost, cfg.DBReplicaPort)
+	}
+	if replicaDSN != "" {
+		replica, err := connect(cfg, replicaDSN, "réplica")
+		if err != nil {
+			primary.Close()
+			return nil, err
+		}
+		db.replica = replica
+	}
+
+	return db, nil
+}
+
+func connect(cfg *config.Config, dsn, name string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao abrir conexão: %w", err)
+		return nil, fmt.Errorf("erro ao abrir conexão (%s): %w", name, err)
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("erro ao conectar ao banco: %w", err)
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+
+	deadline := time.Now().Add(cfg.DBConnectTimeout)
+	backoff := initialBackoff
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err = db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return db, nil
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			db.Close()
+			return nil, fmt.Errorf("erro ao conectar ao banco (%s): %w", name, err)
+		}
+
+		log.Printf("Banco (%s) indisponível: %v; nova tentativa em %s", name, err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
 	}
+}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+// buildDSN monta o DSN no formato chave=valor do lib/pq
+func buildDSN(cfg *config.Config, host, port string) string {
+	params := []string{
+		"host=" + dsnValue(host),
+		"port=" + dsnValue(port),
+		"user=" + dsnValue(cfg.DBUser),
+		"password=" + dsnValue(cfg.DBPassword),
+		"dbname=" + dsnValue(cfg.DBName),
+		"sslmode=" + dsnValue(cfg.DBSSLMode),
+		fmt.Sprintf("connect_timeout=%d", int(pingTimeout.Seconds())),
+	}
+	if cfg.DBSSLRootCert != "" {
+		params = append(params, "sslrootcert="+dsnValue(cfg.DBSSLRootCert))
+	}
+	return strings.Join(params, " ")
+}
 
-	return db, nil
+// dsnValue escapa o valor conforme as regras do formato chave=valor: valores
+// vazios ou com espaços vão entre aspas simples, com \ e ' escapados
+func dsnValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + escaped + "'"
 }
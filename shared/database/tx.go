@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Executor é satisfeito tanto pelo pool (*sql.DB) quanto por uma transação (*sql.Tx)
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Transactor executa uma unidade de trabalho atômica. As operações feitas com o
+// contexto recebido por fn participam da mesma transação.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// DB envolve o pool de conexões, aplicando o timeout padrão das consultas e
+// usando a transação em andamento no contexto, quando houver.
+type DB struct {
+	*sql.DB
+	// replica é o pool opcional da réplica de leitura, usado por Reader
+	replica      *sql.DB
+	queryTimeout time.Duration
+}
+
+// New cria um DB. Com queryTimeout <= 0 as consultas só respeitam o contexto recebido.
+func New(db *sql.DB, queryTimeout time.Duration) *DB {
+	return &DB{DB: db, queryTimeout: queryTimeout}
+}
+
+type txKey struct{}
+
+// Executor retorna a transação aberta por WithTx no contexto ou, fora dela, o pool
+func (db *DB) Executor(ctx context.Context) Executor {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db.DB
+}
+
+// Reader retorna o executor para consultas somente leitura: a transação do
+// contexto, se houver, senão a réplica de leitura ou, sem réplica, o pool primário.
+// Como a réplica pode estar atrasada, leituras logo após uma escrita que precisam
+// vê-la devem usar Executor.
+func (db *DB) Reader(ctx context.Context) Executor {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	if db.replica != nil {
+		return db.replica
+	}
+	return db.DB
+}
+
+// Close fecha o pool primário e o da réplica
+func (db *DB) Close() error {
+	if db.replica != nil {
+		db.replica.Close()
+	}
+	return db.DB.Close()
+}
+
+// Timeout limita a duração de uma consulta. O cancel só deve ser chamado depois
+// de lido todo o resultado (Scan ou rows.Close).
+func (db *DB) Timeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// WithTx executa fn em uma transação, com commit se fn retornar nil e rollback
+// caso contrário. Chamadas aninhadas participam da transação já aberta.
+func (db *DB) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,43 @@
+// Package email define o envio de emails em texto puro. Como em shared/sms, os
+// serviços dependem apenas de Sender e o provedor é escolhido na inicialização.
+package email
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// Sender envia um email em texto puro
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// LogSender escreve os emails no log em vez de enviá-los. Serve para
+// desenvolvimento e testes; como o corpo vai para o log, não deve ser usado em
+// produção.
+type LogSender struct {
+	logger *log.Logger
+}
+
+// NewLogSender cria o LogSender. Com logger nil usa o logger padrão.
+func NewLogSender(logger *log.Logger) *LogSender {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &LogSender{logger: logger}
+}
+
+func (s *LogSender) Send(ctx context.Context, to, subject, body string) error {
+	s.logger.Printf("Email para %s (%s): %s", Mask(to), subject, body)
+	return nil
+}
+
+// Mask mantém apenas a primeira letra do usuário e o domínio do endereço
+func Mask(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 1 {
+		return strings.Repeat("*", len(address))
+	}
+	return address[:1] + strings.Repeat("*", at-1) + address[at:]
+}
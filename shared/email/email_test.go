@@ -0,0 +1,34 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	tests := map[string]string{
+		"maria@meuapoio.com": "m****@meuapoio.com",
+		"a@b.com":            "a@b.com",
+		"invalido":           "********",
+	}
+	for address, want := range tests {
+		if got := Mask(address); got != want {
+			t.Errorf("Mask(%q) = %q, esperado %q", address, got, want)
+		}
+	}
+}
+
+func TestLogSenderMasksAddress(t *testing.T) {
+	var buf bytes.Buffer
+	sender := NewLogSender(log.New(&buf, "", 0))
+
+	if err := sender.Send(context.Background(), "maria@meuapoio.com", "Assunto", "olá"); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Contains(out, "maria@") || !strings.Contains(out, "(Assunto): olá") {
+		t.Errorf("log = %q", out)
+	}
+}
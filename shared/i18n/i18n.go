@@ -0,0 +1,100 @@
+// Package i18n negocia o idioma das respostas da API e guarda os textos
+// traduzidos. Os catálogos ficam junto de quem os usa (códigos de erro em
+// shared/apierror, mensagens de sucesso nos handlers de cada serviço).
+package i18n
+
+import (
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// Idiomas suportados, como tags BCP 47
+const (
+	PortugueseBR = "pt-BR"
+	English      = "en"
+	Spanish      = "es"
+
+	// Default é usado sem Accept-Language ou quando nenhum idioma pedido é suportado
+	Default = PortugueseBR
+)
+
+// Supported lista os idiomas suportados; o primeiro é o padrão
+var Supported = []string{PortugueseBR, English, Spanish}
+
+// contextKey guarda o idioma escolhido no gin.Context
+const contextKey = "language"
+
+var matcher = language.NewMatcher([]language.Tag{
+	language.BrazilianPortuguese,
+	language.English,
+	language.Spanish,
+})
+
+// Negotiate escolhe o idioma suportado mais adequado ao header Accept-Language
+// ("pt-PT" resulta em pt-BR, "es-AR" em es)
+func Negotiate(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return Supported[index]
+}
+
+// IsSupported indica se lang é exatamente uma das tags de Supported
+func IsSupported(lang string) bool {
+	for _, supported := range Supported {
+		if lang == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware define o idioma da requisição a partir do Accept-Language
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		SetLanguage(c, Negotiate(c.GetHeader("Accept-Language")))
+		c.Next()
+	}
+}
+
+// SetLanguage troca o idioma da requisição e informa o cliente em Content-Language
+func SetLanguage(c *gin.Context, lang string) {
+	c.Set(contextKey, lang)
+	c.Header("Content-Language", lang)
+}
+
+// Language retorna o idioma da requisição (Default se o Middleware não rodou)
+func Language(c *gin.Context) string {
+	if lang := c.GetString(contextKey); lang != "" {
+		return lang
+	}
+	return Default
+}
+
+// Text é um texto nas várias línguas, indexado pela tag do idioma
+type Text map[string]string
+
+// In retorna o texto no idioma pedido, ou no padrão se não houver tradução
+func (t Text) In(lang string) string {
+	if s, ok := t[lang]; ok {
+		return s
+	}
+	return t[Default]
+}
+
+// Catalog agrupa textos por chave
+type Catalog map[string]Text
+
+// Get retorna o texto da chave no idioma pedido; chaves ausentes retornam a própria chave
+func (c Catalog) Get(lang, key string) string {
+	text, ok := c[key]
+	if !ok {
+		return key
+	}
+	return text.In(lang)
+}
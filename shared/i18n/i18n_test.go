@@ -0,0 +1,31 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                          PortugueseBR,
+		"pt-BR":                     PortugueseBR,
+		"pt-PT,pt;q=0.9":            PortugueseBR,
+		"en-US,en;q=0.9":            English,
+		"es-AR":                     Spanish,
+		"fr-FR,en;q=0.5":            English,
+		"de-DE":                     PortugueseBR,
+		"cabeçalho inválido;;q=xyz": PortugueseBR,
+	}
+	for header, want := range tests {
+		if got := Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %s, esperado %s", header, got, want)
+		}
+	}
+}
+
+func TestTextFallsBackToDefault(t *testing.T) {
+	text := Text{PortugueseBR: "olá", English: "hello"}
+	if text.In(Spanish) != "olá" || text.In(English) != "hello" {
+		t.Fatalf("tradução inesperada")
+	}
+	if (Catalog{}).Get(English, "chave.ausente") != "chave.ausente" {
+		t.Fatalf("chave ausente deveria retornar a própria chave")
+	}
+}
@@ -0,0 +1,31 @@
+package i18n
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreferenceStore informa o idioma escolhido pelo usuário no perfil ("" se nenhum)
+type PreferenceStore interface {
+	PreferredLanguage(ctx context.Context, userID string) (string, error)
+}
+
+// UserPreferenceMiddleware aplica o idioma do perfil do usuário autenticado, que
+// tem precedência sobre o Accept-Language. Deve ser registrado depois do
+// AuthMiddleware. Uma falha na consulta não bloqueia a requisição: o idioma
+// negociado pelo header continua valendo.
+func UserPreferenceMiddleware(store PreferenceStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		if userID == "" {
+			c.Next()
+			return
+		}
+
+		if lang, err := store.PreferredLanguage(c.Request.Context(), userID); err == nil && IsSupported(lang) {
+			SetLanguage(c, lang)
+		}
+		c.Next()
+	}
+}
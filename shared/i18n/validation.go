@@ -0,0 +1,51 @@
+package i18n
+
+import (
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/pt_BR"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	pt_br_translations "github.com/go-playground/validator/v10/translations/pt_BR"
+)
+
+var translators = map[string]ut.Translator{}
+
+// RegisterValidator registra as mensagens traduzidas das regras padrão do
+// validator. Deve ser chamado uma vez, antes de qualquer validação, com a
+// instância usada pelo gin (binding.Validator.Engine()).
+func RegisterValidator(v *validator.Validate) error {
+	uni := ut.New(pt_BR.New(), pt_BR.New(), en.New(), es.New())
+
+	registrations := map[string]struct {
+		locale   string
+		register func(*validator.Validate, ut.Translator) error
+	}{
+		PortugueseBR: {"pt_BR", pt_br_translations.RegisterDefaultTranslations},
+		English:      {"en", en_translations.RegisterDefaultTranslations},
+		Spanish:      {"es", es_translations.RegisterDefaultTranslations},
+	}
+
+	for lang, r := range registrations {
+		trans, _ := uni.GetTranslator(r.locale)
+		if err := r.register(v, trans); err != nil {
+			return err
+		}
+		translators[lang] = trans
+	}
+	return nil
+}
+
+// ValidationMessage traduz o erro de validação de um campo
+func ValidationMessage(lang string, fe validator.FieldError) string {
+	trans, ok := translators[lang]
+	if !ok {
+		trans, ok = translators[Default]
+	}
+	if !ok {
+		return fe.Error()
+	}
+	return fe.Translate(trans)
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/shared/apierror"
+)
+
+// AccountChecker informa se a conta existe e não está desativada
+type AccountChecker interface {
+	IsActive(ctx context.Context, userID string) (bool, error)
+}
+
+// ActiveAccountMiddleware rejeita tokens de contas desativadas. O JWT continua
+// válido depois do pedido de exclusão; só um novo login, que cancela a
+// exclusão, devolve o acesso. Deve ser registrado depois do AuthMiddleware.
+func ActiveAccountMiddleware(checker AccountChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		active, err := checker.IsActive(c.Request.Context(), c.GetString("user_id"))
+		if err != nil {
+			apierror.Abort(c, apierror.CodeInternal)
+			return
+		}
+		if !active {
+			apierror.Abort(c, apierror.CodeAccountInactive)
+			return
+		}
+
+		c.Next()
+	}
+}
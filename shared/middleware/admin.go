@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/shared/apierror"
+)
+
+// AdminChecker informa se o usuário tem o papel de administrador
+type AdminChecker interface {
+	IsAdmin(ctx context.Context, userID string) (bool, error)
+}
+
+// AdminMiddleware restringe a rota a administradores. Deve ser registrado depois
+// do AuthMiddleware; o papel é consultado a cada requisição, e não gravado no
+// token, para que a revogação valha imediatamente.
+func AdminMiddleware(checker AdminChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin, err := checker.IsAdmin(c.Request.Context(), c.GetString("user_id"))
+		if err != nil {
+			apierror.Abort(c, apierror.CodeInternal)
+			return
+		}
+		if !admin {
+			apierror.Abort(c, apierror.CodeAdminRequired)
+			return
+		}
+
+		c.Next()
+	}
+}
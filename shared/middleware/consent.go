@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/shared/apierror"
+)
+
+// ConsentChecker informa quais documentos obrigatórios o usuário ainda precisa aceitar
+type ConsentChecker interface {
+	PendingMandatoryTypes(ctx context.Context, userID string) ([]string, error)
+}
+
+// ConsentMiddleware bloqueia rotas protegidas enquanto houver uma versão obrigatória
+// de termos de uso ou política de privacidade sem aceite. Deve ser registrado
+// depois do AuthMiddleware.
+func ConsentMiddleware(checker ConsentChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		if userID == "" {
+			c.Next()
+			return
+		}
+
+		pending, err := checker.PendingMandatoryTypes(c.Request.Context(), userID)
+		if err != nil {
+			apierror.Abort(c, apierror.CodeInternal)
+			return
+		}
+
+		if len(pending) > 0 {
+			// O cliente deve exibir e coletar o aceite das novas versões antes de continuar
+			apierror.AbortWith(c, apierror.New(apierror.CodeConsentRequired).With("pending", pending))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// OptInChecker informa se o usuário tem ativo um consentimento opcional
+type OptInChecker interface {
+	HasActiveConsent(ctx context.Context, userID, consentType string) (bool, error)
+}
+
+// RequireConsent bloqueia a rota enquanto o usuário não tiver ativos os
+// opt-ins informados, com CONSENT_REQUIRED e os tipos faltantes em pending.
+// Deve ser registrado depois do AuthMiddleware.
+func RequireConsent(checker OptInChecker, consentTypes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		if userID == "" {
+			c.Next()
+			return
+		}
+
+		pending := []string{}
+		for _, consentType := range consentTypes {
+			active, err := checker.HasActiveConsent(c.Request.Context(), userID, consentType)
+			if err != nil {
+				apierror.Abort(c, apierror.CodeInternal)
+				return
+			}
+			if !active {
+				pending = append(pending, consentType)
+			}
+		}
+
+		if len(pending) > 0 {
+			apierror.AbortWith(c, apierror.New(apierror.CodeConsentRequired).With("pending", pending))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/i18n"
+)
+
+const (
+	// IdempotencyKeyHeader é o header em que o cliente envia a chave
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marca respostas devolvidas da gravação
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyLease é o tempo que uma chave fica reservada enquanto a primeira
+	// requisição executa; passado esse prazo (queda do processo no meio da
+	// requisição), a chave pode ser reservada de novo
+	idempotencyLease = 2 * time.Minute
+)
+
+// replayedHeaders são os headers da resposta gravados junto com o corpo
+var replayedHeaders = []string{"Content-Type", "Content-Language", "Location"}
+
+// IdempotencyKey identifica a chave no escopo de um usuário ("" em rotas
+// públicas) e de uma rota ("POST /api/v1/contacts")
+type IdempotencyKey struct {
+	UserID string
+	Route  string
+	Key    string
+}
+
+// IdempotentResponse é o registro de uma chave: a impressão digital da
+// requisição e, quando concluída, a resposta a repetir
+type IdempotentResponse struct {
+	Fingerprint string
+	Completed   bool
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+}
+
+// IdempotencyStore guarda as chaves e respostas. Reserve deve ser atômico: entre
+// requisições concorrentes com a mesma chave, apenas uma obtém a reserva.
+type IdempotencyStore interface {
+	// Reserve grava a chave como em andamento até expiresAt e retorna nil. Se já
+	// existir um registro não expirado, não altera nada e o retorna.
+	Reserve(ctx context.Context, key IdempotencyKey, fingerprint string, expiresAt time.Time) (*IdempotentResponse, error)
+	// Complete grava a resposta da chave reservada, válida até expiresAt
+	Complete(ctx context.Context, key IdempotencyKey, response *IdempotentResponse, expiresAt time.Time) error
+	// Release libera a chave reservada, para que a requisição possa ser repetida
+	Release(ctx context.Context, key IdempotencyKey) error
+}
+
+// IdempotencyMiddleware repete a primeira resposta de requisições POST, PUT,
+// PATCH e DELETE enviadas com o header Idempotency-Key, para que retentativas de
+// clientes com conexão instável não criem registros duplicados. A mesma chave
+// com outro corpo resulta em 422 e, enquanto a primeira requisição executa, em 409.
+// Respostas 5xx não são gravadas. O corpo é lido inteiro para a comparação, antes
+// dos limites de cada handler, e por isso limitado a maxBody bytes (413 acima
+// disso). Em rotas autenticadas, deve ser registrado depois do AuthMiddleware.
+func IdempotencyMiddleware(store IdempotencyStore, ttl time.Duration, maxBody int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader(IdempotencyKeyHeader)
+		if header == "" || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(header) {
+			apierror.AbortWith(c, apierror.Field(IdempotencyKeyHeader, "max", invalidIdempotencyKey))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxBody)))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.AbortWith(c, apierror.New(apierror.CodeRequestTooLarge).With("max_bytes", maxBody))
+			return
+		}
+		if err != nil {
+			apierror.Abort(c, apierror.CodeMalformedRequest)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := IdempotencyKey{
+			UserID: c.GetString("user_id"),
+			Route:  c.Request.Method + " " + c.FullPath(),
+			Key:    header,
+		}
+		fingerprint := requestFingerprint(c.Request, body)
+
+		existing, err := store.Reserve(c.Request.Context(), key, fingerprint, time.Now().Add(idempotencyLease))
+		if err != nil {
+			apierror.Abort(c, apierror.CodeInternal)
+			return
+		}
+		if existing != nil {
+			replay(c, existing, fingerprint)
+			return
+		}
+
+		// Gravação e liberação não dependem do cliente continuar conectado: uma
+		// retentativa logo em seguida precisa encontrar a resposta
+		ctx := context.WithoutCancel(c.Request.Context())
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		completed := false
+		defer func() {
+			// Panic ou resposta 5xx: a chave volta a ficar livre
+			if !completed {
+				store.Release(ctx, key)
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		response := &IdempotentResponse{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			StatusCode:  status,
+			Header:      http.Header{},
+			Body:        recorder.body.Bytes(),
+		}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				response.Header.Set(name, value)
+			}
+		}
+		if err := store.Complete(ctx, key, response, time.Now().Add(ttl)); err == nil {
+			completed = true
+		}
+	}
+}
+
+func replay(c *gin.Context, existing *IdempotentResponse, fingerprint string) {
+	switch {
+	case existing.Fingerprint != fingerprint:
+		apierror.Abort(c, apierror.CodeIdempotencyKeyReused)
+	case !existing.Completed:
+		apierror.Abort(c, apierror.CodeIdempotencyKeyInUse)
+	default:
+		for name, values := range existing.Header {
+			for _, value := range values {
+				c.Writer.Header().Add(name, value)
+			}
+		}
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Status(existing.StatusCode)
+		c.Writer.Write(existing.Body)
+		c.Abort()
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// validIdempotencyKey aceita até 255 caracteres ASCII visíveis
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+var invalidIdempotencyKey = i18n.Text{
+	i18n.PortugueseBR: "deve ter até 255 caracteres ASCII, sem espaços",
+	i18n.English:      "must be up to 255 ASCII characters, without spaces",
+	i18n.Spanish:      "debe tener hasta 255 caracteres ASCII, sin espacios",
+}
+
+// requestFingerprint resume o que identifica a operação além da rota: o caminho
+// concreto (IDs), a query e o corpo. Uma resposta só é repetida para a mesma
+// requisição, então reenviar a chave de outra pessoa não revela nada que o
+// cliente já não tenha enviado.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.RawQuery))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder copia o corpo escrito pelo handler
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
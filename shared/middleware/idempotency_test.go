@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mapStore é um IdempotencyStore mínimo para os testes do middleware
+type mapStore struct {
+	mu      sync.Mutex
+	entries map[IdempotencyKey]IdempotentResponse
+}
+
+func (s *mapStore) Reserve(ctx context.Context, key IdempotencyKey, fingerprint string, expiresAt time.Time) (*IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.entries[key]; ok {
+		return &existing, nil
+	}
+	s.entries[key] = IdempotentResponse{Fingerprint: fingerprint}
+	return nil, nil
+}
+
+func (s *mapStore) Complete(ctx context.Context, key IdempotencyKey, response *IdempotentResponse, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = *response
+	return nil
+}
+
+func (s *mapStore) Release(ctx context.Context, key IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+const testMaxBody = 1 << 10
+
+func newIdempotentRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(IdempotencyMiddleware(&mapStore{entries: map[IdempotencyKey]IdempotentResponse{}}, time.Hour, testMaxBody))
+	r.POST("/recurso", handler)
+	return r
+}
+
+func sendWithKey(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/recurso", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyInFlightConflict(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := newIdempotentRouter(func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- sendWithKey(r, "chave", `{}`) }()
+	<-started
+
+	if w := sendWithKey(r, "chave", `{}`); w.Code != http.StatusConflict {
+		t.Errorf("requisição concorrente: status = %d, esperado 409", w.Code)
+	}
+
+	close(release)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Fatalf("primeira requisição: status = %d", w.Code)
+	}
+	if w := sendWithKey(r, "chave", `{}`); w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retentativa: status = %d, replayed = %q", w.Code, w.Header().Get(IdempotentReplayedHeader))
+	}
+}
+
+func TestIdempotencyReleasesOnServerError(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(func(c *gin.Context) {
+		calls++
+		body, _ := io.ReadAll(c.Request.Body)
+		if calls == 1 {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusCreated, string(body))
+	})
+
+	sendWithKey(r, "chave", `{"a":1}`)
+	w := sendWithKey(r, "chave", `{"a":1}`)
+	if calls != 2 || w.Code != http.StatusCreated || w.Body.String() != `{"a":1}` {
+		t.Fatalf("após 5xx a requisição deveria executar de novo: chamadas = %d, status = %d, corpo = %q", calls, w.Code, w.Body.String())
+	}
+}
+
+func TestIdempotencyRejectsInvalidKey(t *testing.T) {
+	r := newIdempotentRouter(func(c *gin.Context) { c.Status(http.StatusCreated) })
+	if w := sendWithKey(r, "chave com espaço", `{}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, esperado 400", w.Code)
+	}
+}
+
+func TestIdempotencyLimitsBody(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(func(c *gin.Context) {
+		calls++
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusCreated, strconv.Itoa(len(body)))
+	})
+
+	w := sendWithKey(r, "grande", strings.Repeat("x", testMaxBody+1))
+	if w.Code != http.StatusRequestEntityTooLarge || calls != 0 {
+		t.Fatalf("status = %d, chamadas = %d, esperado 413 sem executar o handler", w.Code, calls)
+	}
+	var problem struct {
+		Code     string `json:"code"`
+		MaxBytes int    `json:"max_bytes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Code != "REQUEST_TOO_LARGE" || problem.MaxBytes != testMaxBody {
+		t.Errorf("problema inesperado: %s", w.Body.String())
+	}
+
+	// A chave não fica reservada: a retentativa com um corpo menor executa
+	if w := sendWithKey(r, "grande", strings.Repeat("x", testMaxBody)); w.Code != http.StatusCreated || w.Body.String() != strconv.Itoa(testMaxBody) {
+		t.Errorf("corpo no limite: status = %d, corpo = %q", w.Code, w.Body.String())
+	}
+
+	// Sem a chave o middleware não lê o corpo; o limite fica com o handler
+	req := httptest.NewRequest(http.MethodPost, "/recurso", strings.NewReader(strings.Repeat("x", 2*testMaxBody)))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || w.Body.String() != strconv.Itoa(2*testMaxBody) {
+		t.Errorf("sem chave: status = %d, corpo = %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader é o header usado para propagar o ID da requisição entre gateway e serviços
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID garante que toda requisição tenha um identificador único.
+// Reaproveita o header recebido (ex.: gerado pelo gateway) ou gera um novo.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+			// Atualizar o header para que o proxy repasse o mesmo ID aos serviços
+			c.Request.Header.Set(RequestIDHeader, requestID)
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,376 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileRegexp reconhece arquivos no formato 0001_descricao.up.sql / 0001_descricao.down.sql
+var fileRegexp = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration é uma alteração de schema numerada com seus scripts de ida e volta
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status descreve a situação de uma migração no banco
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Migrator aplica as migrações de um serviço. Cada serviço tem seu próprio
+// conjunto de versões em schema_migrations, identificado pelo nome do serviço.
+type Migrator struct {
+	db         *sql.DB
+	service    string
+	migrations []*Migration
+}
+
+// New carrega e valida as migrações do sistema de arquivos (normalmente um embed.FS)
+func New(db *sql.DB, service string, fsys fs.FS) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, service: service, migrations: migrations}, nil
+}
+
+func load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler migrações: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		// Um .sql fora do padrão seria ignorado sem aviso e nunca aplicado
+		match := fileRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			if strings.HasSuffix(entry.Name(), ".sql") {
+				return nil, fmt.Errorf("nome de migração inválido: %s (esperado NNNN_nome.up.sql ou NNNN_nome.down.sql)", entry.Name())
+			}
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("versão inválida em %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("versão %d usada por duas migrações: %s e %s", version, m.Name, match[2])
+		}
+
+		script := &m.Up
+		if match[3] == "down" {
+			script = &m.Down
+		}
+		if *script != "" {
+			return nil, fmt.Errorf("versão %d com mais de um arquivo .%s.sql", version, match[3])
+		}
+		*script = string(content)
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migração %04d_%s sem arquivo .up.sql", m.Version, m.Name)
+		}
+		sum := sha256.Sum256([]byte(m.Up))
+		m.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type appliedMigration struct {
+	version   int64
+	checksum  string
+	appliedAt time.Time
+}
+
+// withLock executa fn em uma conexão dedicada segurando um advisory lock do
+// serviço, impedindo que duas instâncias migrem o banco ao mesmo tempo
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	key := m.lockKey()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+		return fmt.Errorf("erro ao obter lock de migração: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key)
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		    service VARCHAR(50) NOT NULL,
+		    version BIGINT NOT NULL,
+		    name VARCHAR(255) NOT NULL,
+		    checksum CHAR(64) NOT NULL,
+		    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		    PRIMARY KEY (service, version)
+		)
+	`); err != nil {
+		return fmt.Errorf("erro ao criar schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("schema_migrations:" + m.service))
+	return int64(h.Sum64())
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) ([]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT version, checksum, applied_at
+		FROM schema_migrations
+		WHERE service = $1
+		ORDER BY version
+	`, m.service)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []appliedMigration
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+
+	return applied, rows.Err()
+}
+
+// verify garante que toda migração aplicada ainda existe e não foi editada
+func (m *Migrator) verify(applied []appliedMigration) error {
+	for _, a := range applied {
+		migration := m.find(a.version)
+		if migration == nil {
+			return fmt.Errorf("migração %04d aplicada no banco não existe no binário", a.version)
+		}
+		if migration.Checksum != a.checksum {
+			return fmt.Errorf("checksum da migração %04d_%s difere do aplicado; migrações já aplicadas não devem ser editadas",
+				migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
+
+// Up aplica todas as migrações pendentes e retorna quantas foram aplicadas
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	var done []*Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		isApplied := make(map[int64]bool, len(applied))
+		for _, a := range applied {
+			isApplied[a.version] = true
+		}
+
+		for _, migration := range m.migrations {
+			if isApplied[migration.Version] {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverte as últimas `steps` migrações aplicadas
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	var done []*Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.find(applied[i].version)
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Redo reverte e reaplica a última migração
+func (m *Migrator) Redo(ctx context.Context) (*Migration, error) {
+	var redone *Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			return errors.New("nenhuma migração aplicada")
+		}
+
+		migration := m.find(applied[len(applied)-1].version)
+		if err := m.revert(ctx, conn, migration); err != nil {
+			return err
+		}
+		if err := m.apply(ctx, conn, migration); err != nil {
+			return err
+		}
+		redone = migration
+		return nil
+	})
+
+	return redone, err
+}
+
+// Status lista todas as migrações conhecidas e se já foram aplicadas
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		appliedAt := make(map[int64]time.Time, len(applied))
+		for _, a := range applied {
+			appliedAt[a.version] = a.appliedAt
+		}
+
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if at, ok := appliedAt[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+
+	return statuses, err
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration *Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+		return fmt.Errorf("erro ao aplicar %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO schema_migrations (service, version, name, checksum)
+		VALUES ($1, $2, $3, $4)
+	`, m.service, migration.Version, migration.Name, migration.Checksum)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration *Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("migração %04d_%s não possui arquivo .down.sql", migration.Version, migration.Name)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+		return fmt.Errorf("erro ao reverter %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE service = $1 AND version = $2`,
+		m.service, migration.Version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,150 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_contacts.up.sql":   file("CREATE TABLE contacts ();"),
+		"0002_contacts.down.sql": file("DROP TABLE contacts;"),
+		"0001_baseline.up.sql":   file("CREATE TABLE users ();"),
+		"0010_no_down.up.sql":    file("ALTER TABLE users ADD x INT;"),
+		"embed.go":               file("package migrations"),
+		"README.md":              file("# migrações"),
+		"sub/0003_nested.up.sql": file("SELECT 1;"),
+	}
+
+	migrations, err := load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 3 {
+		t.Fatalf("esperadas 3 migrações, obtidas %d", len(migrations))
+	}
+	want := []struct {
+		version int64
+		name    string
+	}{{1, "baseline"}, {2, "contacts"}, {10, "no_down"}}
+	for i, w := range want {
+		if migrations[i].Version != w.version || migrations[i].Name != w.name {
+			t.Errorf("migração %d = %04d_%s, esperado %04d_%s", i, migrations[i].Version, migrations[i].Name, w.version, w.name)
+		}
+	}
+	if migrations[1].Up != "CREATE TABLE contacts ();" || migrations[1].Down != "DROP TABLE contacts;" {
+		t.Errorf("scripts inesperados: %+v", migrations[1])
+	}
+	if migrations[2].Down != "" {
+		t.Errorf("migração sem .down.sql com Down = %q", migrations[2].Down)
+	}
+	if len(migrations[0].Checksum) != 64 || migrations[0].Checksum == migrations[1].Checksum {
+		t.Errorf("checksums inesperados: %s, %s", migrations[0].Checksum, migrations[1].Checksum)
+	}
+}
+
+func TestLoadRejectsInvalidSets(t *testing.T) {
+	tests := map[string]struct {
+		fsys fstest.MapFS
+		err  string
+	}{
+		"versão duplicada": {fstest.MapFS{
+			"0001_users.up.sql":    file("SELECT 1;"),
+			"0001_contacts.up.sql": file("SELECT 2;"),
+		}, "usada por duas migrações"},
+		"mesma versão com outro número de zeros": {fstest.MapFS{
+			"0001_users.up.sql": file("SELECT 1;"),
+			"01_users.up.sql":   file("SELECT 2;"),
+		}, "mais de um arquivo .up.sql"},
+		"sem .up.sql": {fstest.MapFS{
+			"0001_users.up.sql":  file("SELECT 1;"),
+			"0002_drop.down.sql": file("SELECT 2;"),
+		}, "0002_drop sem arquivo .up.sql"},
+		"maiúsculas no nome": {fstest.MapFS{
+			"0001_Users.up.sql": file("SELECT 1;"),
+		}, "nome de migração inválido: 0001_Users.up.sql"},
+		"sem versão": {fstest.MapFS{
+			"users.up.sql": file("SELECT 1;"),
+		}, "nome de migração inválido"},
+		"direção desconhecida": {fstest.MapFS{
+			"0001_users.sql": file("SELECT 1;"),
+		}, "nome de migração inválido"},
+		"versão fora do intervalo": {fstest.MapFS{
+			"99999999999999999999_users.up.sql": file("SELECT 1;"),
+		}, "versão inválida"},
+	}
+
+	for name, tt := range tests {
+		_, err := load(tt.fsys)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: erro = %v, esperado %q", name, err, tt.err)
+		}
+	}
+}
+
+func TestVerifyDetectsEditedMigrations(t *testing.T) {
+	original, err := load(fstest.MapFS{
+		"0001_users.up.sql":    file("CREATE TABLE users ();"),
+		"0002_contacts.up.sql": file("CREATE TABLE contacts ();"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := []appliedMigration{
+		{version: 1, checksum: original[0].Checksum},
+		{version: 2, checksum: original[1].Checksum},
+	}
+
+	m := &Migrator{service: "user", migrations: original}
+	if err := m.verify(applied); err != nil {
+		t.Fatalf("migrações intactas rejeitadas: %v", err)
+	}
+
+	// Só o .up.sql entra no checksum: corrigir o .down.sql é permitido
+	withDown, err := load(fstest.MapFS{
+		"0001_users.up.sql":      file("CREATE TABLE users ();"),
+		"0001_users.down.sql":    file("DROP TABLE users;"),
+		"0002_contacts.up.sql":   file("CREATE TABLE contacts ();"),
+		"0002_contacts.down.sql": file("DROP TABLE contacts;"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Migrator{migrations: withDown}).verify(applied); err != nil {
+		t.Errorf("alteração no .down.sql rejeitada: %v", err)
+	}
+
+	edited, err := load(fstest.MapFS{
+		"0001_users.up.sql":    file("CREATE TABLE users (id INT);"),
+		"0002_contacts.up.sql": file("CREATE TABLE contacts ();"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = (&Migrator{migrations: edited}).verify(applied)
+	if err == nil || !strings.Contains(err.Error(), "checksum da migração 0001_users") {
+		t.Errorf("edição não detectada: %v", err)
+	}
+
+	// Binário mais antigo que o banco
+	err = (&Migrator{migrations: original[:1]}).verify(applied)
+	if err == nil || !strings.Contains(err.Error(), "0002 aplicada no banco não existe") {
+		t.Errorf("migração ausente no binário não detectada: %v", err)
+	}
+}
+
+func TestLockKeyIsPerService(t *testing.T) {
+	user := &Migrator{service: "user"}
+	if user.lockKey() != (&Migrator{service: "user"}).lockKey() {
+		t.Error("a chave do lock deveria ser estável entre instâncias")
+	}
+	if user.lockKey() == (&Migrator{service: "notification"}).lockKey() {
+		t.Error("serviços diferentes não devem disputar o mesmo lock")
+	}
+}
@@ -0,0 +1,383 @@
+// Package pagination padroniza as listagens da API: paginação por cursor
+// (keyset), limite de itens por página, ordenação e filtros lidos da query
+// string, sempre restritos ao que cada endpoint declara em Options.
+//
+// O cursor é opaco para o cliente e guarda a chave de ordenação do último item
+// entregue. A próxima página começa logo depois dele, o que mantém as páginas
+// estáveis mesmo com inserções e remoções entre uma requisição e outra, ao
+// contrário de OFFSET.
+package pagination
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meuapoio/shared/apierror"
+	"github.com/meuapoio/shared/i18n"
+)
+
+// Parâmetros de query reconhecidos em todas as listagens
+const (
+	LimitParam  = "limit"
+	CursorParam = "cursor"
+	SortParam   = "sort"
+)
+
+// maxFilterLength limita filtros de texto livre
+const maxFilterLength = 100
+
+// Options descreve o que uma listagem aceita
+type Options struct {
+	DefaultLimit int
+	MaxLimit     int
+	// Sorts são as ordenações aceitas em sort; a primeira é a padrão
+	Sorts []Sort
+	// Filters são os filtros aceitos, cada um com o nome do parâmetro na query
+	Filters []Filter
+}
+
+// Sort é uma ordenação aceita. Em ?sort= o nome puro usa a direção padrão e o
+// nome com "-" na frente inverte a direção.
+type Sort struct {
+	Name string
+	Desc bool
+	// Key são os tipos dos campos da chave de ordenação, na ordem das colunas.
+	// O cursor só é aceito com o mesmo número de campos, cada um válido no
+	// tipo declarado.
+	Key []KeyType
+}
+
+// KeyType é o tipo de um campo da chave de ordenação. O cursor vem do
+// cliente: cada campo é conferido antes de chegar à consulta SQL.
+type KeyType int
+
+const (
+	// KeyText aceita qualquer texto
+	KeyText KeyType = iota
+	// KeyTime aceita instantes no formato de Time
+	KeyTime
+	// KeyBool aceita os valores de Bool
+	KeyBool
+	// KeyUUID aceita UUIDs em minúsculas, como o PostgreSQL os devolve
+	KeyUUID
+)
+
+const timeLayout = "2006-01-02 15:04:05.000000"
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+func (t KeyType) valid(value string) bool {
+	switch t {
+	case KeyTime:
+		_, err := time.Parse(timeLayout, value)
+		return err == nil
+	case KeyBool:
+		return value == "true" || value == "false"
+	case KeyUUID:
+		return uuidRegexp.MatchString(value)
+	}
+	return true
+}
+
+// ErrInvalidCursor indica um cursor cuja chave não corresponde às colunas da
+// ordenação. Quem lista responde com InvalidCursor.
+var ErrInvalidCursor = errors.New("cursor incompatível com a ordenação")
+
+// Filter é um filtro de igualdade aceito na query string
+type Filter struct {
+	Name string
+	// Values lista os valores aceitos; vazio aceita qualquer texto de até 100
+	// caracteres
+	Values []string
+}
+
+// Request é a listagem pedida, já validada
+type Request struct {
+	Limit int
+	Sort  string
+	Desc  bool
+	// Filters traz só os filtros informados
+	Filters map[string]string
+	// After é a chave de ordenação do último item da página anterior; nil na
+	// primeira página
+	After []string
+}
+
+// Page é o envelope padrão das listagens. NextCursor é nulo na última página.
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// cursor é o conteúdo do cursor opaco. A ordenação e os filtros vão junto
+// para que o cursor não seja usado com outra consulta.
+type cursor struct {
+	Sort    string   `json:"s"`
+	Desc    bool     `json:"d,omitempty"`
+	Filters string   `json:"f,omitempty"`
+	Key     []string `json:"k"`
+}
+
+var (
+	cursorInvalid = i18n.Text{
+		i18n.PortugueseBR: "cursor inválido; use o next_cursor da página anterior com os mesmos sort e filtros",
+		i18n.English:      "invalid cursor; use the previous page's next_cursor with the same sort and filters",
+		i18n.Spanish:      "cursor inválido; use el next_cursor de la página anterior con los mismos sort y filtros",
+	}
+	sortInvalid = i18n.Text{
+		i18n.PortugueseBR: "ordenação não suportada",
+		i18n.English:      "unsupported sort",
+		i18n.Spanish:      "ordenamiento no soportado",
+	}
+	filterTooLong = i18n.Text{
+		i18n.PortugueseBR: "deve ter no máximo 100 caracteres",
+		i18n.English:      "must be at most 100 characters long",
+		i18n.Spanish:      "debe tener como máximo 100 caracteres",
+	}
+)
+
+// Parse lê limit, cursor, sort e os filtros de query. Valores fora do que opts
+// aceita resultam em VALIDATION_FAILED, com um item por parâmetro.
+func Parse(query url.Values, opts Options) (Request, *apierror.Problem) {
+	p := apierror.New(apierror.CodeValidationFailed)
+	req := Request{Limit: opts.DefaultLimit, Filters: map[string]string{}}
+
+	if raw := query.Get(LimitParam); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > opts.MaxLimit {
+			p.WithField(LimitParam, "range", limitRange(opts.MaxLimit))
+		} else {
+			req.Limit = limit
+		}
+	}
+
+	var key []KeyType
+	if len(opts.Sorts) > 0 {
+		req.Sort, req.Desc, key = opts.Sorts[0].Name, opts.Sorts[0].Desc, opts.Sorts[0].Key
+	}
+	if raw := query.Get(SortParam); raw != "" {
+		name := strings.TrimPrefix(raw, "-")
+		found := false
+		for _, s := range opts.Sorts {
+			if s.Name == name {
+				req.Sort, req.Desc, key, found = s.Name, s.Desc != strings.HasPrefix(raw, "-"), s.Key, true
+			}
+		}
+		if !found {
+			p.WithField(SortParam, "oneof", sortInvalid)
+		}
+	}
+
+	for _, f := range opts.Filters {
+		value := query.Get(f.Name)
+		if value == "" {
+			continue
+		}
+		switch {
+		case len(f.Values) > 0 && !contains(f.Values, value):
+			p.WithField(f.Name, "oneof", oneOf(f.Values))
+		case len(f.Values) == 0 && len([]rune(value)) > maxFilterLength:
+			p.WithField(f.Name, "max", filterTooLong)
+		default:
+			req.Filters[f.Name] = value
+		}
+	}
+
+	if raw := query.Get(CursorParam); raw != "" && len(p.Errors) == 0 {
+		c, ok := decodeCursor(raw)
+		if !ok || c.Sort != req.Sort || c.Desc != req.Desc || c.Filters != req.filtersHash() || !validKey(c.Key, key) {
+			p.WithField(CursorParam, "cursor", cursorInvalid)
+		} else {
+			req.After = c.Key
+		}
+	}
+
+	if len(p.Errors) > 0 {
+		return Request{}, p
+	}
+	return req, nil
+}
+
+// InvalidCursor é o erro de validação de um cursor recusado
+func InvalidCursor() *apierror.Problem {
+	return apierror.Field(CursorParam, "cursor", cursorInvalid)
+}
+
+// validKey confere a chave do cursor com os tipos declarados na ordenação
+func validKey(values []string, types []KeyType) bool {
+	if len(values) == 0 || len(values) != len(types) {
+		return false
+	}
+	for i, t := range types {
+		if !t.valid(values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func limitRange(max int) i18n.Text {
+	return i18n.Text{
+		i18n.PortugueseBR: fmt.Sprintf("deve estar entre 1 e %d", max),
+		i18n.English:      fmt.Sprintf("must be between 1 and %d", max),
+		i18n.Spanish:      fmt.Sprintf("debe estar entre 1 y %d", max),
+	}
+}
+
+func oneOf(values []string) i18n.Text {
+	list := strings.Join(values, ", ")
+	return i18n.Text{
+		i18n.PortugueseBR: "deve ser um de: " + list,
+		i18n.English:      "must be one of: " + list,
+		i18n.Spanish:      "debe ser uno de: " + list,
+	}
+}
+
+// filtersHash resume os filtros em ordem estável, para conferir o cursor
+func (r Request) filtersHash() string {
+	if len(r.Filters) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(r.Filters))
+	for name := range r.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%s\n", name, r.Filters[name])
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func decodeCursor(raw string) (cursor, bool) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return c, false
+	}
+	return c, json.Unmarshal(data, &c) == nil
+}
+
+// Cursor codifica a posição depois do item com a chave informada
+func (r Request) Cursor(key []string) string {
+	data, _ := json.Marshal(cursor{Sort: r.Sort, Desc: r.Desc, Filters: r.filtersHash(), Key: key})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// NewPage monta o envelope a partir de até Limit+1 itens lidos do repositório:
+// o item a mais só indica que há uma próxima página. key retorna a chave de
+// ordenação de um item, na ordem das colunas da ordenação pedida.
+func NewPage[T any](r Request, items []T, key func(T) []string) Page[T] {
+	page := Page[T]{Items: items}
+	if len(items) > r.Limit {
+		page.Items = items[:r.Limit]
+		next := r.Cursor(key(page.Items[r.Limit-1]))
+		page.NextCursor = &next
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page
+}
+
+// Keyset monta a condição e a ordenação SQL da página. columns são as colunas
+// da ordenação, terminando numa coluna única (como id), na mesma ordem da
+// chave; arg é o número do primeiro placeholder livre. Sem cursor, a condição
+// é TRUE; com um cursor de outro número de campos, retorna ErrInvalidCursor.
+// A consulta deve usar LIMIT Limit+1 para NewPage.
+func (r Request) Keyset(columns []string, arg int) (condition, orderBy string, args []any, err error) {
+	if err := r.CheckKey(len(columns)); err != nil {
+		return "", "", nil, err
+	}
+
+	direction := "ASC"
+	operator := ">"
+	if r.Desc {
+		direction, operator = "DESC", "<"
+	}
+
+	order := make([]string, len(columns))
+	for i, column := range columns {
+		order[i] = column + " " + direction
+	}
+	orderBy = strings.Join(order, ", ")
+
+	if r.After == nil {
+		return "TRUE", orderBy, nil, nil
+	}
+	placeholders := make([]string, len(columns))
+	for i, value := range r.After {
+		placeholders[i] = "$" + strconv.Itoa(arg+i)
+		args = append(args, value)
+	}
+	condition = fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), operator, strings.Join(placeholders, ", "))
+	return condition, orderBy, args, nil
+}
+
+// CheckKey retorna ErrInvalidCursor se há cursor e a chave dele não tem size
+// campos. Parse já recusa esses cursores quando a ordenação declara Key.
+func (r Request) CheckKey(size int) error {
+	if r.After != nil && len(r.After) != size {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// Less compara duas chaves na ordem pedida, para listagens feitas em memória.
+// As chaves são comparadas como texto, campo a campo; por isso Time e Bool
+// geram textos que ordenam como os valores.
+func (r Request) Less(a, b []string) bool {
+	c := compare(a, b)
+	if r.Desc {
+		return c > 0
+	}
+	return c < 0
+}
+
+// Includes informa se o item com a chave vem depois do cursor, para listagens
+// feitas em memória
+func (r Request) Includes(key []string) bool {
+	return r.After == nil || r.Less(r.After, key)
+}
+
+func compare(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// Time formata um instante para a chave de ordenação: UTC, com microssegundos
+// (a precisão do PostgreSQL) e largura fixa, para que a ordem do texto seja a
+// do tempo. Sem fuso no texto, vale para colunas TIMESTAMP.
+func Time(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// Bool formata um booleano para a chave de ordenação; "false" vem antes de
+// "true", como no PostgreSQL
+func Bool(b bool) string {
+	return strconv.FormatBool(b)
+}
@@ -0,0 +1,221 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testOptions = Options{
+	DefaultLimit: 2,
+	MaxLimit:     10,
+	Sorts: []Sort{
+		{Name: "created_at", Desc: true, Key: []KeyType{KeyTime, KeyText}},
+		{Name: "name", Key: []KeyType{KeyText, KeyText}},
+	},
+	Filters: []Filter{{Name: "status", Values: []string{"pending", "accepted"}}, {Name: "q"}},
+}
+
+func parse(t *testing.T, query string) Request {
+	t.Helper()
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, problem := Parse(values, testOptions)
+	if problem != nil {
+		t.Fatalf("Parse(%q): %+v", query, problem.Errors)
+	}
+	return req
+}
+
+func TestParse(t *testing.T) {
+	req := parse(t, "")
+	if req.Limit != 2 || req.Sort != "created_at" || !req.Desc || req.After != nil || len(req.Filters) != 0 {
+		t.Errorf("padrões inesperados: %+v", req)
+	}
+
+	req = parse(t, "limit=10&sort=-name&status=accepted&q=ana&outro=x")
+	if req.Limit != 10 || req.Sort != "name" || !req.Desc {
+		t.Errorf("limit e sort: %+v", req)
+	}
+	if !reflect.DeepEqual(req.Filters, map[string]string{"status": "accepted", "q": "ana"}) {
+		t.Errorf("filtros: %+v", req.Filters)
+	}
+	if req = parse(t, "sort=-created_at"); req.Desc {
+		t.Error("-created_at deveria inverter a direção padrão")
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"limit=0":          "limit",
+		"limit=11":         "limit",
+		"limit=abc":        "limit",
+		"sort=password":    "sort",
+		"status=deleted":   "status",
+		"cursor=!!!":       "cursor",
+		"cursor=bm9wZQ":    "cursor",
+		"q=" + long(101):   "q",
+		"limit=0&sort=foo": "limit",
+	}
+	for query, field := range tests {
+		values, _ := url.ParseQuery(query)
+		_, problem := Parse(values, testOptions)
+		if problem == nil || len(problem.Errors) == 0 || problem.Errors[0].Field != field {
+			t.Errorf("%s: esperado erro em %s, veio %+v", query, field, problem)
+		}
+	}
+}
+
+func long(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = 'a'
+	}
+	return string(b)
+}
+
+func TestCursorIsBoundToQuery(t *testing.T) {
+	req := parse(t, "status=pending")
+	next := req.Cursor([]string{"2026-01-02 03:04:05.000000", "id-1"})
+
+	if got := parse(t, "status=pending&cursor="+next); !reflect.DeepEqual(got.After, []string{"2026-01-02 03:04:05.000000", "id-1"}) {
+		t.Errorf("After = %v", got.After)
+	}
+	for _, query := range []string{"cursor=" + next, "status=accepted&cursor=" + next, "status=pending&sort=name&cursor=" + next} {
+		values, _ := url.ParseQuery(query)
+		if _, problem := Parse(values, testOptions); problem == nil {
+			t.Errorf("%s: cursor de outra consulta deveria ser rejeitado", query)
+		}
+	}
+}
+
+// TestParseRejectsTamperedKeys confere que campos alterados no cursor são
+// recusados em Parse, antes de chegar ao SQL
+func TestParseRejectsTamperedKeys(t *testing.T) {
+	opts := Options{
+		DefaultLimit: 2,
+		MaxLimit:     10,
+		Sorts:        []Sort{{Name: "primary", Desc: true, Key: []KeyType{KeyBool, KeyTime, KeyUUID}}},
+	}
+	req := Request{Sort: "primary", Desc: true, Filters: map[string]string{}}
+	const (
+		when = "2026-01-02 03:04:05.000000"
+		id   = "0b6f3a52-6c1e-4f4e-9d2a-3f1c2b7e8a90"
+	)
+
+	values := url.Values{CursorParam: {req.Cursor([]string{"true", when, id})}}
+	if got, problem := Parse(values, opts); problem != nil || len(got.After) != 3 {
+		t.Fatalf("cursor válido recusado: %+v", problem)
+	}
+
+	tests := map[string][]string{
+		"campo a menos":   {when, id},
+		"campo a mais":    {"true", when, id, id},
+		"sem campos":      {},
+		"booleano":        {"sim", when, id},
+		"instante":        {"true", "2026-01-02T03:04:05Z", id},
+		"id fora de uuid": {"true", when, "1 OR 1=1"},
+		"uuid maiúsculo":  {"true", when, "0B6F3A52-6C1E-4F4E-9D2A-3F1C2B7E8A90"},
+	}
+	for name, key := range tests {
+		values := url.Values{CursorParam: {req.Cursor(key)}}
+		_, problem := Parse(values, opts)
+		if problem == nil || len(problem.Errors) == 0 || problem.Errors[0].Field != CursorParam {
+			t.Errorf("%s: esperado erro em cursor, veio %+v", name, problem)
+		}
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	req := parse(t, "")
+	condition, orderBy, args, err := req.Keyset([]string{"created_at", "id"}, 2)
+	if err != nil || condition != "TRUE" || orderBy != "created_at DESC, id DESC" || args != nil {
+		t.Errorf("primeira página: %q %q %v %v", condition, orderBy, args, err)
+	}
+
+	req.After = []string{"2026-01-02 03:04:05.000000", "id-1"}
+	condition, _, args, err = req.Keyset([]string{"created_at", "id"}, 2)
+	if err != nil || condition != "(created_at, id) < ($2, $3)" || len(args) != 2 {
+		t.Errorf("com cursor: %q %v %v", condition, args, err)
+	}
+
+	req.Desc = false
+	if condition, orderBy, _, _ = req.Keyset([]string{"created_at", "id"}, 2); condition != "(created_at, id) > ($2, $3)" || orderBy != "created_at ASC, id ASC" {
+		t.Errorf("ascendente: %q %q", condition, orderBy)
+	}
+
+	// Cursor com outro número de campos não recomeça da primeira página
+	if _, _, _, err = req.Keyset([]string{"is_primary", "created_at", "id"}, 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("chave incompatível: erro = %v, esperado ErrInvalidCursor", err)
+	}
+}
+
+// TestPagesInMemory percorre uma lista página a página como um repositório em
+// memória faria, e confere que nenhum item se repete ou some
+func TestPagesInMemory(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	type item struct {
+		id      string
+		created time.Time
+	}
+	var items []item
+	for i := 0; i < 7; i++ {
+		// Dois itens por instante: o id desempata
+		items = append(items, item{id: "id-" + strconv.Itoa(i), created: base.Add(time.Duration(i/2) * 1500 * time.Microsecond)})
+	}
+	key := func(it item) []string { return []string{Time(it.created), it.id} }
+
+	var seen []string
+	query := "limit=3"
+	for pages := 0; pages < 10; pages++ {
+		req := parse(t, query)
+		var selected []item
+		for _, it := range items {
+			if req.Includes(key(it)) {
+				selected = append(selected, it)
+			}
+		}
+		sort.Slice(selected, func(i, j int) bool { return req.Less(key(selected[i]), key(selected[j])) })
+		if len(selected) > req.Limit+1 {
+			selected = selected[:req.Limit+1]
+		}
+
+		page := NewPage(req, selected, key)
+		for _, it := range page.Items {
+			seen = append(seen, it.id)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		query = "limit=3&cursor=" + *page.NextCursor
+	}
+
+	want := []string{"id-6", "id-5", "id-4", "id-3", "id-2", "id-1", "id-0"}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("itens = %v, esperado %v", seen, want)
+	}
+}
+
+func TestNewPageEmpty(t *testing.T) {
+	page := NewPage(parse(t, ""), []string(nil), func(s string) []string { return []string{s} })
+	if page.Items == nil || page.NextCursor != nil {
+		t.Errorf("página vazia: %+v", page)
+	}
+}
+
+func TestTimeOrdersAsText(t *testing.T) {
+	a := time.Date(2026, 1, 1, 0, 0, 5, 100_000_000, time.UTC)
+	b := time.Date(2026, 1, 1, 0, 0, 5, 120_000_000, time.FixedZone("BRT", -3*3600))
+	if !(Time(a) < Time(b)) {
+		t.Errorf("%s deveria vir antes de %s", Time(a), Time(b))
+	}
+	if Time(a) != "2026-01-01 00:00:05.100000" {
+		t.Errorf("Time = %s", Time(a))
+	}
+}
@@ -0,0 +1,255 @@
+// Package pdf gera documentos PDF simples de texto (títulos, parágrafos e
+// itens de lista) em páginas A4, com as fontes padrão Helvetica. Não embute
+// fontes nem imagens: o objetivo são documentos para impressão gerados sem
+// dependências externas.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Dimensões da página A4 e margens, em pontos
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	margin       = 56
+	contentWidth = pageWidth - 2*margin
+)
+
+type font struct {
+	name string
+	// Fator sobre as larguras da Helvetica normal; o negrito é um pouco mais largo
+	scale float64
+}
+
+var (
+	regular = font{name: "F1", scale: 1}
+	bold    = font{name: "F2", scale: 1.08}
+)
+
+// Document acumula o conteúdo das páginas já completas e o da página atual,
+// com a posição vertical da próxima linha
+type Document struct {
+	pages [][]byte
+	page  *bytes.Buffer
+	y     float64
+}
+
+// New cria um documento vazio
+func New() *Document {
+	d := &Document{}
+	d.newPage()
+	return d
+}
+
+// Title escreve o título do documento
+func (d *Document) Title(text string) {
+	d.write(text, bold, 18, 0, 10)
+}
+
+// Heading escreve o título de uma seção
+func (d *Document) Heading(text string) {
+	d.space(8)
+	d.write(text, bold, 13, 0, 4)
+}
+
+// Paragraph escreve um parágrafo, quebrado na largura da página
+func (d *Document) Paragraph(text string) {
+	d.write(text, regular, 11, 0, 4)
+}
+
+// Note escreve um texto menor, para datas e observações
+func (d *Document) Note(text string) {
+	d.write(text, regular, 9, 0, 4)
+}
+
+// Bullet escreve um item de lista com marcador
+func (d *Document) Bullet(text string) {
+	const indent = 14
+	d.ensure(11 * 1.3)
+	fmt.Fprintf(d.page, "BT /%s 11 Tf %d %.2f Td (%s) Tj ET\n", regular.name, margin, d.y-11, escape("•"))
+	d.write(text, regular, 11, indent, 2)
+}
+
+// WriteTo grava o PDF em w
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	pages := append(append([][]byte{}, d.pages...), d.page.Bytes())
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1: catálogo, 2: árvore de páginas, 3 e 4: fontes; depois página e conteúdo
+	// de cada página
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(content); err != nil {
+			return 0, err
+		}
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.WriteTo(w)
+}
+
+// write quebra o texto na largura disponível e escreve as linhas, mudando de
+// página quando necessário
+func (d *Document) write(text string, f font, size, indent, after float64) {
+	leading := size * 1.3
+	for _, paragraph := range strings.Split(text, "\n") {
+		for _, line := range wrap(paragraph, f, size, contentWidth-indent) {
+			d.ensure(leading)
+			d.y -= leading
+			fmt.Fprintf(d.page, "BT /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", f.name, size, margin+indent, d.y+leading-size, escape(line))
+		}
+	}
+	d.space(after)
+}
+
+func (d *Document) space(height float64) {
+	d.y -= height
+}
+
+// ensure abre uma nova página se não couber uma linha da altura informada
+func (d *Document) ensure(height float64) {
+	if d.y-height < margin {
+		d.newPage()
+	}
+}
+
+func (d *Document) newPage() {
+	if d.page != nil {
+		d.pages = append(d.pages, d.page.Bytes())
+	}
+	d.page = &bytes.Buffer{}
+	d.y = pageHeight - margin
+}
+
+// wrap quebra o texto em linhas que cabem em width pontos. Palavras maiores que
+// a linha são cortadas.
+func wrap(text string, f font, size, width float64) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{""}
+	}
+
+	var lines []string
+	var line string
+	for _, word := range words {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if textWidth(candidate, f, size) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		for textWidth(word, f, size) > width {
+			cut := 1
+			for cut < len([]rune(word)) && textWidth(string([]rune(word)[:cut+1]), f, size) <= width {
+				cut++
+			}
+			lines = append(lines, string([]rune(word)[:cut]))
+			word = string([]rune(word)[cut:])
+		}
+		line = word
+	}
+	return append(lines, line)
+}
+
+// textWidth estima a largura do texto pelas métricas da Helvetica; letras
+// acentuadas têm a largura da letra base
+func textWidth(text string, f font, size float64) float64 {
+	var units int
+	for _, r := range base(text) {
+		if r >= ' ' && r <= '~' {
+			units += widths[r-' ']
+		} else {
+			units += 556
+		}
+	}
+	return float64(units) * size * f.scale / 1000
+}
+
+// base remove os acentos, para consultar a tabela de larguras
+func base(text string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)))
+	result, _, err := transform.String(t, text)
+	if err != nil {
+		return text
+	}
+	return result
+}
+
+// escape converte o texto para WinAnsiEncoding e escapa os caracteres especiais
+// das strings do PDF. Caracteres fora da codificação viram "?".
+func escape(text string) string {
+	encoder := charmap.Windows1252.NewEncoder()
+	var b strings.Builder
+	for _, r := range text {
+		if r == '\t' {
+			r = ' '
+		}
+		encoded, err := encoder.String(string(r))
+		if err != nil || unicode.IsControl(r) {
+			encoded = "?"
+		}
+		switch encoded {
+		case "(", ")", "\\":
+			b.WriteString("\\")
+		}
+		b.WriteString(encoded)
+	}
+	return b.String()
+}
+
+// widths são as larguras da Helvetica (AFM) dos caracteres de ' ' a '~', em
+// milésimos do tamanho da fonte
+var widths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
@@ -0,0 +1,78 @@
+package pdf
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	d := New()
+	d.Title("Plano de segurança")
+	d.Heading("Sinais de alerta")
+	for i := 0; i < 80; i++ {
+		d.Bullet("Não consigo dormir (há dias) e me isolo dos amigos \\ família")
+	}
+
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("cabeçalho ou final inválidos")
+	}
+	// 80 itens não cabem numa página A4
+	if count := regexp.MustCompile(`/Count (\d+)`).FindStringSubmatch(out); count == nil || count[1] == "1" {
+		t.Errorf("esperadas várias páginas, obtido %v", count)
+	}
+
+	// startxref aponta para a tabela, e cada entrada para o início do objeto
+	start := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(out)
+	offset, _ := strconv.Atoi(start[1])
+	if !strings.HasPrefix(out[offset:], "xref\n") {
+		t.Fatalf("startxref %d não aponta para a tabela xref", offset)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(out[offset:], -1)
+	for i, entry := range entries {
+		pos, _ := strconv.Atoi(entry[1])
+		if want := strconv.Itoa(i+1) + " 0 obj"; !strings.HasPrefix(out[pos:], want) {
+			t.Errorf("entrada %d aponta para %q", i+1, out[pos:pos+10])
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	text := strings.Repeat("palavra ", 40)
+	lines := wrap(text, regular, 11, contentWidth)
+	if len(lines) < 2 {
+		t.Fatalf("texto longo deveria quebrar: %q", lines)
+	}
+	for _, line := range lines {
+		if textWidth(line, regular, 11) > contentWidth {
+			t.Errorf("linha maior que a página: %q", line)
+		}
+	}
+
+	long := strings.Repeat("a", 200)
+	if lines := wrap(long, regular, 11, contentWidth); len(lines) < 2 || strings.Join(lines, "") != long {
+		t.Errorf("palavra maior que a linha deveria ser cortada sem perder caracteres: %q", lines)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		"ação":      "a\xe7\xe3o",
+		"(a) \\ b":  `\(a\) \\ b`,
+		"emoji 🙂":   "emoji ?",
+		"tab\there": "tab here",
+	}
+	for in, want := range tests {
+		if got := escape(in); got != want {
+			t.Errorf("escape(%q) = %q, esperado %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package phone normaliza números de telefone para o formato E.164
+// (+<código do país><número nacional>), aceitando as formas usuais de digitar
+// números brasileiros e internacionais. Números sem código do país são
+// interpretados na região padrão informada.
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalid indica um texto que não forma um número de telefone válido
+var ErrInvalid = errors.New("número de telefone inválido")
+
+// regions mapeia as regiões aceitas como padrão para o código do país
+var regions = map[string]string{
+	"BR": "55",
+	"US": "1",
+	"PT": "351",
+	"ES": "34",
+}
+
+// twoDigitCodes são os códigos de país de dois dígitos; 1 e 7 têm um dígito e os
+// demais, três (recomendação E.164 da UIT)
+var twoDigitCodes = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true, "34": true,
+	"36": true, "39": true, "40": true, "41": true, "43": true, "44": true, "45": true,
+	"46": true, "47": true, "48": true, "49": true, "51": true, "52": true, "53": true,
+	"54": true, "55": true, "56": true, "57": true, "58": true, "60": true, "61": true,
+	"62": true, "63": true, "64": true, "65": true, "66": true, "81": true, "82": true,
+	"84": true, "86": true, "90": true, "91": true, "92": true, "93": true, "94": true,
+	"95": true, "98": true,
+}
+
+// Number é um telefone já validado
+type Number struct {
+	CountryCode string
+	National    string
+}
+
+// ValidRegion informa se a região pode ser usada como padrão em Parse
+func ValidRegion(region string) bool {
+	_, ok := regions[region]
+	return ok
+}
+
+// Parse interpreta o número digitado. Aceita dígitos separados por espaços,
+// hífens, pontos e parênteses; "+" ou "00" indicam o código do país, e sem eles
+// o número é da região padrão. Números brasileiros podem vir com o 0 de longa
+// distância e o código da operadora ("0 15 11 98888-7777").
+func Parse(raw, region string) (Number, error) {
+	raw = strings.TrimSpace(raw)
+	international := strings.HasPrefix(raw, "+")
+	if international {
+		raw = raw[1:]
+	}
+
+	var digits strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return Number{}, ErrInvalid
+		}
+	}
+
+	number := digits.String()
+	if !international && strings.HasPrefix(number, "00") {
+		international, number = true, number[2:]
+	}
+	if international {
+		return parseInternational(number)
+	}
+	return parseNational(number, region)
+}
+
+func parseInternational(digits string) (Number, error) {
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return Number{}, ErrInvalid
+	}
+	n := Number{CountryCode: countryCode(digits)}
+	n.National = digits[len(n.CountryCode):]
+	return n, n.validate()
+}
+
+func parseNational(digits, region string) (Number, error) {
+	code, ok := regions[region]
+	if !ok {
+		return Number{}, ErrInvalid
+	}
+
+	switch code {
+	case "55":
+		// 13 ou 12 dígitos começando por 55 já trazem o código do país, pois um
+		// número nacional (mesmo do DDD 55) tem no máximo 11
+		if strings.HasPrefix(digits, "55") && (len(digits) == 12 || len(digits) == 13) {
+			digits = digits[2:]
+			break
+		}
+		if strings.HasPrefix(digits, "0") {
+			digits = digits[1:]
+			if len(digits) == 12 || len(digits) == 13 {
+				digits = digits[2:] // código da operadora
+			}
+		}
+	case "1":
+		if len(digits) == 11 && digits[0] == '1' {
+			digits = digits[1:]
+		}
+	}
+
+	n := Number{CountryCode: code, National: digits}
+	return n, n.validate()
+}
+
+func (n Number) validate() error {
+	var ok bool
+	switch n.CountryCode {
+	case "55":
+		ok = validBrazilian(n.National)
+	case "1":
+		ok = len(n.National) == 10 && n.National[0] >= '2' && n.National[3] >= '2'
+	default:
+		total := len(n.CountryCode) + len(n.National)
+		ok = len(n.National) >= 4 && total >= 8 && total <= 15
+	}
+	if !ok || n.National[0] == '0' {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// validBrazilian aceita DDD (dois dígitos de 1 a 9) seguido de celular com nove
+// dígitos começando por 9 ou de fixo com oito dígitos começando por 2 a 5
+func validBrazilian(national string) bool {
+	if len(national) != 10 && len(national) != 11 {
+		return false
+	}
+	if national[0] == '0' || national[1] == '0' {
+		return false
+	}
+	first := national[2]
+	if len(national) == 11 {
+		return first == '9'
+	}
+	return first >= '2' && first <= '5'
+}
+
+// countryCode separa o código do país do início de um número internacional
+func countryCode(digits string) string {
+	switch {
+	case digits[0] == '1' || digits[0] == '7':
+		return digits[:1]
+	case twoDigitCodes[digits[:2]]:
+		return digits[:2]
+	default:
+		return digits[:3]
+	}
+}
+
+// E164 é a forma canônica, usada para gravar e comparar números
+func (n Number) E164() string {
+	return "+" + n.CountryCode + n.National
+}
+
+// Display é a forma para exibição: "+55 11 98888-7777", "+1 212-555-0100" ou,
+// para os demais países, o código separado do número nacional
+func (n Number) Display() string {
+	national := n.National
+	switch n.CountryCode {
+	case "55":
+		national = national[:2] + " " + national[2:len(national)-4] + "-" + national[len(national)-4:]
+	case "1":
+		national = national[:3] + "-" + national[3:6] + "-" + national[6:]
+	}
+	return "+" + n.CountryCode + " " + national
+}
+
+// Display formata um número gravado em E.164; valores que não forem E.164
+// válidos são retornados como estão
+func Display(e164 string) string {
+	if !strings.HasPrefix(e164, "+") {
+		return e164
+	}
+	n, err := parseInternational(e164[1:])
+	if err != nil {
+		return e164
+	}
+	return n.Display()
+}
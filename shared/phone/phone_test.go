@@ -0,0 +1,60 @@
+package phone
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw, region, e164, display string
+	}{
+		{"(11) 98888-7777", "BR", "+5511988887777", "+55 11 98888-7777"},
+		{"11988887777", "BR", "+5511988887777", "+55 11 98888-7777"},
+		{"+55 11 98888-7777", "BR", "+5511988887777", "+55 11 98888-7777"},
+		{"5511988887777", "BR", "+5511988887777", "+55 11 98888-7777"},
+		{"0055 11 98888 7777", "BR", "+5511988887777", "+55 11 98888-7777"},
+		{"011 98888-7777", "BR", "+5511988887777", "+55 11 98888-7777"},
+		{"0 15 11 98888-7777", "BR", "+5511988887777", "+55 11 98888-7777"},
+		{"(21) 3333-4444", "BR", "+552133334444", "+55 21 3333-4444"},
+		{"(55) 99999-0000", "BR", "+5555999990000", "+55 55 99999-0000"},
+		{"+1 (212) 555-0100", "BR", "+12125550100", "+1 212-555-0100"},
+		{"1-212-555-0100", "US", "+12125550100", "+1 212-555-0100"},
+		{"+351 912 345 678", "BR", "+351912345678", "+351 912345678"},
+		{"912345678", "PT", "+351912345678", "+351 912345678"},
+		{"+44 20 7946 0958", "BR", "+442079460958", "+44 2079460958"},
+	}
+	for _, tt := range tests {
+		n, err := Parse(tt.raw, tt.region)
+		if err != nil {
+			t.Errorf("Parse(%q, %s): %v", tt.raw, tt.region, err)
+			continue
+		}
+		if n.E164() != tt.e164 || n.Display() != tt.display {
+			t.Errorf("Parse(%q, %s) = %s / %s, esperado %s / %s", tt.raw, tt.region, n.E164(), n.Display(), tt.e164, tt.display)
+		}
+		if Display(n.E164()) != tt.display {
+			t.Errorf("Display(%s) = %s, esperado %s", n.E164(), Display(n.E164()), tt.display)
+		}
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"abc",
+		"11 98888-7777 ramal 2",
+		"8888-7777",          // sem DDD
+		"(11) 88888-7777",    // celular sem o 9
+		"(01) 98888-7777",    // DDD inválido
+		"(11) 6333-4444",     // fixo começando por 6
+		"+55 11 98888-77777", // dígitos demais
+		"+1 012 555 0100",    // código de área NANP inválido
+		"+0 123 456 789",
+		"+12 3",
+	} {
+		if n, err := Parse(raw, "BR"); err == nil {
+			t.Errorf("Parse(%q) = %s, esperado erro", raw, n.E164())
+		}
+	}
+	if _, err := Parse("11988887777", "XX"); err == nil {
+		t.Error("região desconhecida deveria ser rejeitada")
+	}
+}
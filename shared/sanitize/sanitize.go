@@ -0,0 +1,90 @@
+// Package sanitize limpa os textos livres digitados pelos usuários (nomes,
+// parentesco) antes da validação e da gravação. Esses textos voltam em SMS,
+// emails e PDFs, então saem sem caracteres de controle, sem marcação HTML e em
+// Unicode normalizado (NFC), para que o mesmo nome digitado em teclados
+// diferentes seja gravado igual.
+//
+// RegisterValidator instala no gin um validador que chama Sanitize nas
+// requisições que implementam Sanitizer antes de validá-las; assim as regras de
+// binding (required, max...) valem para o texto já limpo.
+package sanitize
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Sanitizer é implementado pelas requisições com textos livres. Sanitize limpa
+// os campos no lugar.
+type Sanitizer interface {
+	Sanitize()
+}
+
+// validator chama Sanitize antes da validação padrão do gin. Elementos de
+// listas e structs aninhadas não passam por aqui: quem os tiver limpa os itens
+// no próprio Sanitize.
+type validator struct {
+	binding.StructValidator
+}
+
+func (v validator) ValidateStruct(obj any) error {
+	if s, ok := obj.(Sanitizer); ok {
+		s.Sanitize()
+	}
+	return v.StructValidator.ValidateStruct(obj)
+}
+
+// RegisterValidator instala o validador no gin, envolvendo o atual. Deve ser
+// chamado na montagem do router, antes de atender requisições; chamadas
+// repetidas não o instalam de novo.
+func RegisterValidator() {
+	if _, ok := binding.Validator.(validator); !ok {
+		binding.Validator = validator{binding.Validator}
+	}
+}
+
+// markup casa tags HTML e comentários
+var markup = regexp.MustCompile(`<[^<>]*>`)
+
+const (
+	zeroWidthNonJoiner = '\u200c'
+	zeroWidthJoiner    = '\u200d'
+)
+
+// Text normaliza s para NFC, decodifica entidades HTML, remove tags, os sinais
+// < e > que sobrarem e os caracteres de formatação invisíveis (como os que
+// invertem a direção do texto), troca caracteres de controle por espaço e junta
+// os espaços repetidos. Os joiners de largura zero ficam, porque compõem
+// emojis e a escrita de várias línguas.
+func Text(s string) string {
+	s = norm.NFC.String(html.UnescapeString(s))
+	s = markup.ReplaceAllString(s, " ")
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case r == '<' || r == '>':
+		case unicode.IsControl(r):
+			b.WriteRune(' ')
+		case unicode.Is(unicode.Cf, r) && r != zeroWidthJoiner && r != zeroWidthNonJoiner:
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Ptr aplica Text a um campo opcional
+func Ptr(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	clean := Text(*s)
+	return &clean
+}
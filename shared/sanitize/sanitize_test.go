@@ -0,0 +1,64 @@
+package sanitize
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestText(t *testing.T) {
+	tests := map[string]string{
+		"  Maria   da Silva ":                   "Maria da Silva",
+		"Jose\u0301":                            "Jos\u00e9",
+		"Ana\tPaula\r\nSouza":                   "Ana Paula Souza",
+		"<b>Mãe</b>":                            "Mãe",
+		"<script>alert(1)</script>João":         "alert(1) João",
+		"&lt;img src=x onerror=alert(1)&gt;Bia": "Bia",
+		"Tom &amp; Jerry":                       "Tom & Jerry",
+		"a > b":                                 "a b",
+		"\u202eailatI\u202c":                    "ailatI",
+		"Zero\u200bWidth\ufeff":                 "ZeroWidth",
+		"👩\u200d👧":                              "👩\u200d👧",
+		"Nul\x00Byte":                           "Nul Byte",
+		"<!-- comentário -->":                   "",
+		"Linha\u2028separada":                   "Linha separada",
+	}
+	for in, want := range tests {
+		if got := Text(in); got != want {
+			t.Errorf("Text(%q) = %q, esperado %q", in, got, want)
+		}
+	}
+}
+
+type request struct {
+	Name     string  `binding:"required,max=5"`
+	Nickname *string `binding:"omitempty,max=5"`
+}
+
+func (r *request) Sanitize() {
+	r.Name = Text(r.Name)
+	r.Nickname = Ptr(r.Nickname)
+}
+
+func TestValidatorSanitizesBeforeValidating(t *testing.T) {
+	RegisterValidator()
+	RegisterValidator()
+	if v, ok := binding.Validator.(validator); !ok {
+		t.Fatal("validador não instalado")
+	} else if _, twice := v.StructValidator.(validator); twice {
+		t.Error("validador instalado duas vezes")
+	}
+
+	nickname := " <i>Bia</i> "
+	req := &request{Name: "<b>Ana</b>", Nickname: &nickname}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		t.Fatalf("texto limpo deveria passar em max=5: %v", err)
+	}
+	if req.Name != "Ana" || *req.Nickname != "Bia" {
+		t.Errorf("campos não foram limpos: %q %q", req.Name, *req.Nickname)
+	}
+
+	if err := binding.Validator.ValidateStruct(&request{Name: "<p></p>"}); err == nil {
+		t.Error("nome só com marcação deveria falhar em required")
+	}
+}
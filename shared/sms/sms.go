@@ -0,0 +1,45 @@
+// Package sms define o envio de mensagens de texto para um telefone, por SMS ou
+// por aplicativos de mensagem como o WhatsApp. Os serviços dependem apenas de
+// Sender; o provedor real é escolhido na inicialização de cada serviço.
+package sms
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// Sender envia uma mensagem de texto para um telefone em E.164
+type Sender interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+// LogSender escreve as mensagens no log em vez de enviá-las. Serve para
+// desenvolvimento e testes enquanto nenhum provedor está configurado; como o
+// corpo vai para o log, não deve ser usado em produção.
+type LogSender struct {
+	logger  *log.Logger
+	channel string
+}
+
+// NewLogSender cria o LogSender. channel identifica o meio nas linhas do log
+// ("SMS", "WhatsApp"); com logger nil usa o logger padrão.
+func NewLogSender(logger *log.Logger, channel string) *LogSender {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &LogSender{logger: logger, channel: channel}
+}
+
+func (s *LogSender) Send(ctx context.Context, to, body string) error {
+	s.logger.Printf("%s para %s: %s", s.channel, Mask(to), body)
+	return nil
+}
+
+// Mask oculta o telefone exceto pelos quatro últimos dígitos
+func Mask(number string) string {
+	if len(number) <= 4 {
+		return strings.Repeat("*", len(number))
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
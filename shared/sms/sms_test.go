@@ -0,0 +1,34 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	tests := map[string]string{
+		"+5511988887777": "**********7777",
+		"123":            "***",
+		"":               "",
+	}
+	for number, want := range tests {
+		if got := Mask(number); got != want {
+			t.Errorf("Mask(%q) = %q, esperado %q", number, got, want)
+		}
+	}
+}
+
+func TestLogSenderMasksNumber(t *testing.T) {
+	var buf bytes.Buffer
+	sender := NewLogSender(log.New(&buf, "", 0), "SMS")
+
+	if err := sender.Send(context.Background(), "+5511988887777", "olá"); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Contains(out, "98888") || !strings.Contains(out, "SMS para **********7777: olá") {
+		t.Errorf("log = %q", out)
+	}
+}
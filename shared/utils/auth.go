@@ -21,6 +21,9 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
+// ErrTokenExpired é retornado (via errors.Is) por ValidateJWT quando o token expirou
+var ErrTokenExpired = jwt.ErrTokenExpired
+
 // Claims representa os claims do JWT
 type Claims struct {
 	UserID string `json:"user_id"`
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// SignExpiring gera uma assinatura HMAC para um recurso válida até expiresAt.
+// Usada em links temporários que não exigem o token JWT (ex.: download de exportações).
+func SignExpiring(secret, resource string, expiresAt time.Time) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(resource + "|" + strconv.FormatInt(expiresAt.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyExpiring valida a assinatura e se o link ainda não expirou
+func VerifyExpiring(secret, resource string, expiresAt int64, signature string) bool {
+	if time.Now().Unix() > expiresAt {
+		return false
+	}
+
+	expected := SignExpiring(secret, resource, time.Unix(expiresAt, 0))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
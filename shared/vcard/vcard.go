@@ -0,0 +1,370 @@
+// Package vcard lê e escreve arquivos vCard 3.0 e 4.0 (RFC 2426 e RFC 6350).
+// Trata dobra de linhas, grupos, parâmetros e escapes de texto; a interpretação
+// das propriedades fica com quem usa o pacote.
+package vcard
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineLength limita uma linha já desdobrada; fotos embutidas costumam ser as
+// maiores
+const maxLineLength = 1 << 20
+
+// foldLength é o tamanho máximo das linhas escritas, em octetos, sem o CRLF
+const foldLength = 75
+
+// SyntaxError indica um arquivo que não segue o formato vCard
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("vcard: linha %d: %s", e.Line, e.Msg)
+}
+
+// Property é uma linha de conteúdo do cartão. Name vem em maiúsculas, sem o
+// grupo; os nomes dos parâmetros também.
+type Property struct {
+	Name   string
+	Params map[string][]string
+	// Value é o valor como está no arquivo, ainda com os escapes de texto
+	Value string
+}
+
+// Text cria uma propriedade de texto, escapando o valor
+func Text(name, value string) Property {
+	return Property{Name: strings.ToUpper(name), Value: escape(value)}
+}
+
+// Text retorna o valor sem os escapes, para propriedades de texto
+func (p Property) Text() string {
+	return unescape(p.Value)
+}
+
+// Components separa os componentes de um valor estruturado (como N e ADR),
+// sem os escapes
+func (p Property) Components() []string {
+	var components []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range p.Value {
+		switch {
+		case escaped:
+			current.WriteRune('\\')
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			components = append(components, unescape(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(components, unescape(current.String()))
+}
+
+// HasType informa se o parâmetro TYPE contém t, sem diferenciar maiúsculas
+func (p Property) HasType(t string) bool {
+	for _, value := range p.Params["TYPE"] {
+		if strings.EqualFold(value, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Card é um contato do arquivo
+type Card struct {
+	// Line é a linha do BEGIN:VCARD, para mensagens de erro
+	Line       int
+	Version    string
+	Properties []Property
+}
+
+// Get retorna a primeira propriedade com o nome informado, ou nil
+func (c *Card) Get(name string) *Property {
+	name = strings.ToUpper(name)
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// All retorna todas as propriedades com o nome informado, na ordem do arquivo
+func (c *Card) All(name string) []Property {
+	name = strings.ToUpper(name)
+	var properties []Property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			properties = append(properties, p)
+		}
+	}
+	return properties
+}
+
+// Decode lê todos os cartões de r. Só aceita as versões 3.0 e 4.0.
+func Decode(r io.Reader) ([]*Card, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []*Card
+	var card *Card
+	for _, l := range lines {
+		if strings.TrimSpace(l.text) == "" {
+			continue
+		}
+		p, err := parseLine(l.text)
+		if err != nil {
+			return nil, &SyntaxError{Line: l.number, Msg: err.Error()}
+		}
+
+		switch {
+		case p.Name == "BEGIN" && strings.EqualFold(p.Value, "VCARD"):
+			if card != nil {
+				return nil, &SyntaxError{Line: l.number, Msg: "BEGIN:VCARD dentro de outro cartão"}
+			}
+			card = &Card{Line: l.number}
+		case card == nil:
+			return nil, &SyntaxError{Line: l.number, Msg: "conteúdo fora de BEGIN:VCARD"}
+		case p.Name == "END" && strings.EqualFold(p.Value, "VCARD"):
+			if card.Version != "3.0" && card.Version != "4.0" {
+				return nil, &SyntaxError{Line: card.Line, Msg: fmt.Sprintf("versão %q não suportada (use 3.0 ou 4.0)", card.Version)}
+			}
+			cards = append(cards, card)
+			card = nil
+		case p.Name == "VERSION":
+			card.Version = strings.TrimSpace(p.Value)
+		default:
+			card.Properties = append(card.Properties, p)
+		}
+	}
+	if card != nil {
+		return nil, &SyntaxError{Line: card.Line, Msg: "cartão sem END:VCARD"}
+	}
+	return cards, nil
+}
+
+type line struct {
+	number int
+	text   string
+}
+
+// unfold junta as linhas de continuação (iniciadas por espaço ou tab) à linha
+// anterior
+func unfold(r io.Reader) ([]line, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	var lines []line
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if number == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if !utf8.ValidString(text) {
+			return nil, &SyntaxError{Line: number, Msg: "texto fora de UTF-8"}
+		}
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			last := &lines[len(lines)-1]
+			if len(last.text)+len(text) > maxLineLength {
+				return nil, &SyntaxError{Line: number, Msg: "linha longa demais"}
+			}
+			last.text += text[1:]
+			continue
+		}
+		lines = append(lines, line{number: number, text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return nil, &SyntaxError{Line: number + 1, Msg: "linha longa demais"}
+		}
+		return nil, err
+	}
+	return lines, nil
+}
+
+// parseLine interpreta "grupo.NOME;PARAM=a,b;PARAM2=\"c:d\":valor"
+func parseLine(text string) (Property, error) {
+	// O primeiro ":" fora de aspas separa o valor
+	colon := -1
+	quoted := false
+	for i, r := range text {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return Property{}, fmt.Errorf("linha sem \":\"")
+	}
+
+	head, value := text[:colon], text[colon+1:]
+	parts := splitParams(head)
+	name := parts[0]
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" {
+		return Property{}, fmt.Errorf("propriedade sem nome")
+	}
+
+	p := Property{Name: strings.ToUpper(name), Value: value}
+	for _, param := range parts[1:] {
+		if p.Params == nil {
+			p.Params = map[string][]string{}
+		}
+		key, values, found := strings.Cut(param, "=")
+		if !found {
+			// Parâmetro sem nome, como em "TEL;CELL:...", é um TYPE
+			key, values = "TYPE", param
+		}
+		key = strings.ToUpper(key)
+		for _, v := range splitQuoted(values, ',') {
+			v = strings.Trim(v, `"`)
+			// Alguns programas põem a lista de tipos entre aspas: TYPE="cell,voice"
+			if key == "TYPE" {
+				p.Params[key] = append(p.Params[key], strings.Split(v, ",")...)
+			} else {
+				p.Params[key] = append(p.Params[key], v)
+			}
+		}
+	}
+	return p, nil
+}
+
+// splitParams separa o nome e os parâmetros por ";" fora de aspas
+func splitParams(head string) []string {
+	return splitQuoted(head, ';')
+}
+
+func splitQuoted(s string, sep rune) []string {
+	var parts []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case r == sep && !quoted:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(parts, current.String())
+}
+
+func unescape(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		if !escaped {
+			if r == '\\' {
+				escaped = true
+			} else {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		escaped = false
+		switch r {
+		case 'n', 'N':
+			b.WriteRune('\n')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", "", ",", `\,`, ";", `\;`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+// Encode escreve os cartões com CRLF e linhas dobradas em 75 octetos. Cartões
+// sem Version saem como 4.0.
+func Encode(w io.Writer, cards []*Card) error {
+	bw := bufio.NewWriter(w)
+	for _, card := range cards {
+		version := card.Version
+		if version == "" {
+			version = "4.0"
+		}
+		writeFolded(bw, "BEGIN:VCARD")
+		writeFolded(bw, "VERSION:"+version)
+		for _, p := range card.Properties {
+			writeFolded(bw, formatProperty(p))
+		}
+		writeFolded(bw, "END:VCARD")
+	}
+	return bw.Flush()
+}
+
+func formatProperty(p Property) string {
+	var b bytes.Buffer
+	b.WriteString(p.Name)
+	for _, key := range sortedKeys(p.Params) {
+		b.WriteString(";" + key + "=")
+		for i, v := range p.Params[key] {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			if strings.ContainsAny(v, ";:,") {
+				v = `"` + strings.ReplaceAll(v, `"`, "") + `"`
+			}
+			b.WriteString(v)
+		}
+	}
+	b.WriteString(":" + p.Value)
+	return b.String()
+}
+
+func sortedKeys(params map[string][]string) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeFolded quebra a linha sem partir caracteres UTF-8; as continuações
+// começam com um espaço, que conta no limite
+func writeFolded(w *bufio.Writer, text string) {
+	limit := foldLength
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		w.WriteString(text[:cut] + "\r\n ")
+		text = text[cut:]
+		limit = foldLength - 1
+	}
+	w.WriteString(text + "\r\n")
+}
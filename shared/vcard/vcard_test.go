@@ -0,0 +1,133 @@
+package vcard
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	input := "\ufeffBEGIN:VCARD\r\n" +
+		"VERSION:3.0\r\n" +
+		"N:Souza;Maria;Clara;;\r\n" +
+		"FN:Maria Clara\r\n" +
+		" Souza\r\n" +
+		"item1.TEL;TYPE=\"cell,voice\":+55 11 98888-7777\r\n" +
+		"TEL;HOME:(11) 3333-4444\r\n" +
+		"NOTE:linha 1\\nlinha 2\\, com vírgula\\; e ponto e vírgula\r\n" +
+		"END:VCARD\r\n" +
+		"\r\n" +
+		"begin:vcard\n" +
+		"version:4.0\n" +
+		"fn:João\n" +
+		"tel;value=uri;type=\"voice,cell\";pref=1:tel:+55-21-99999-0000\n" +
+		"end:vcard\n"
+
+	cards, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("esperados 2 cartões, veio %d", len(cards))
+	}
+
+	first := cards[0]
+	if first.Line != 1 || first.Version != "3.0" {
+		t.Errorf("cartão 1: linha %d, versão %s", first.Line, first.Version)
+	}
+	if fn := first.Get("fn").Text(); fn != "Maria ClaraSouza" {
+		t.Errorf("FN = %q", fn)
+	}
+	if n := first.Get("N").Components(); len(n) != 5 || n[0] != "Souza" || n[1] != "Maria" || n[2] != "Clara" {
+		t.Errorf("N = %q", n)
+	}
+	tels := first.All("TEL")
+	if len(tels) != 2 || !tels[0].HasType("CELL") || !tels[0].HasType("voice") || !tels[1].HasType("home") {
+		t.Errorf("TEL = %+v", tels)
+	}
+	if tels[0].Value != "+55 11 98888-7777" {
+		t.Errorf("TEL agrupado = %q", tels[0].Value)
+	}
+	if note := first.Get("NOTE").Text(); note != "linha 1\nlinha 2, com vírgula; e ponto e vírgula" {
+		t.Errorf("NOTE = %q", note)
+	}
+
+	second := cards[1]
+	if second.Line != 11 || second.Version != "4.0" {
+		t.Errorf("cartão 2: linha %d, versão %s", second.Line, second.Version)
+	}
+	tel := second.Get("TEL")
+	if tel == nil || tel.Value != "tel:+55-21-99999-0000" || !tel.HasType("cell") || tel.Params["PREF"][0] != "1" {
+		t.Errorf("TEL = %+v", tel)
+	}
+	if second.Get("EMAIL") != nil {
+		t.Error("EMAIL ausente deveria ser nil")
+	}
+}
+
+func TestDecodeRejectsInvalid(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		line  int
+	}{
+		"versão 2.1":            {"BEGIN:VCARD\nVERSION:2.1\nFN:Ana\nEND:VCARD\n", 1},
+		"sem versão":            {"BEGIN:VCARD\nFN:Ana\nEND:VCARD\n", 1},
+		"sem END":               {"BEGIN:VCARD\nVERSION:3.0\nFN:Ana\n", 1},
+		"BEGIN aninhado":        {"BEGIN:VCARD\nVERSION:3.0\nBEGIN:VCARD\n", 3},
+		"conteúdo fora":         {"FN:Ana\n", 1},
+		"linha sem dois pontos": {"BEGIN:VCARD\nVERSION:3.0\nFN Ana\nEND:VCARD\n", 3},
+		"não UTF-8":             {"BEGIN:VCARD\nVERSION:3.0\nFN:\xe9\nEND:VCARD\n", 3},
+	}
+	for name, tt := range tests {
+		_, err := Decode(strings.NewReader(tt.input))
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: esperado SyntaxError, veio %v", name, err)
+			continue
+		}
+		if syntaxErr.Line != tt.line {
+			t.Errorf("%s: linha %d, esperada %d", name, syntaxErr.Line, tt.line)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	long := strings.Repeat("ação ", 30)
+	card := &Card{Properties: []Property{
+		Text("FN", "Silva; Ana, a \"Aninha\"\nda escola"),
+		{Name: "TEL", Params: map[string][]string{"VALUE": {"uri"}, "TYPE": {"cell"}}, Value: "tel:+5511988887777"},
+		Text("NOTE", long),
+	}}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, []*Card{card}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "BEGIN:VCARD\r\nVERSION:4.0\r\n") || !strings.HasSuffix(out, "END:VCARD\r\n") {
+		t.Errorf("saída sem BEGIN/VERSION/END: %q", out)
+	}
+	if !strings.Contains(out, "TEL;TYPE=cell;VALUE=uri:tel:+5511988887777\r\n") {
+		t.Errorf("TEL com parâmetros fora de ordem: %q", out)
+	}
+	for _, l := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(l) > foldLength {
+			t.Errorf("linha com %d octetos: %q", len(l), l)
+		}
+	}
+
+	cards, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(cards) != 1 {
+		t.Fatalf("esperado 1 cartão, veio %d", len(cards))
+	}
+	if fn := cards[0].Get("FN").Text(); fn != "Silva; Ana, a \"Aninha\"\nda escola" {
+		t.Errorf("FN = %q", fn)
+	}
+	if note := cards[0].Get("NOTE").Text(); note != long {
+		t.Errorf("NOTE dobrada = %q", note)
+	}
+}
@@ -22,7 +22,11 @@ REGISTER_RESPONSE=$(curl -s -X POST "$API_BASE/auth/register" \
     "username": "teste_user",
     "email": "teste@meuapoio.com",
     "password": "123456",
-    "full_name": "Usuário de Teste"
+    "full_name": "Usuário de Teste",
+    "consents": [
+      {"type": "terms_of_use", "version": "1.0"},
+      {"type": "privacy_policy", "version": "1.0"}
+    ]
   }')
 
 echo "$REGISTER_RESPONSE" | jq '.'